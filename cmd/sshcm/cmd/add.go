@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"slices"
 
 	"github.com/cannable/sshcm/pkg/cdb"
 	"github.com/spf13/cobra"
@@ -16,12 +17,36 @@ var addCmd = &cobra.Command{
 Add a new connection.
 
 All connection settings are expected to be passed via flags. Most are optional,
-but a nickname and host are required. The nickname must be unique.`,
+but a nickname and host are required. The nickname must be unique.
+
+The host, user, identity, args and command can use placeholders (ex. ${USER} or
+{{.Nickname}}), expanded by connect. See "sshcm connect --help".
+
+To reach the connection through jump hosts (a la ssh -J), pass --jump with a
+comma-separated list of the jump host connections' ids or nicknames, in the
+order they are connected through. Jump hosts may have jump hosts of their own.
+
+Local commands can be run by connect before connecting (--pre-hook) and after
+the session ends (--post-hook). Pass --record to record the terminal output of
+every session. See "sshcm connect --help".
+
+To add a numbered set of hosts (ex. web01 to web40) as one pattern connection,
+put {n} in the nickname and pass the numbers it stands for with --range. {n}
+in the host, user, description, args, identity and command is replaced by the
+number, or by the number zero-padded to W digits with {n:0W} (ex. {n:02}). The
+connections of a pattern are used by nickname (ex. sshcm c web17), and can't be
+changed or removed on their own.`,
 	Example: `
-sshcm add --nickname something --user me --host 127.0.0.1`,
+sshcm add --nickname something --user me --host 127.0.0.1
+sshcm add --nickname internal --host 10.0.0.1 --jump bastion
+sshcm add --nickname prod --host 10.1.0.1 --pre-hook 'ssh-add ~/.ssh/prod'
+sshcm add --nickname web --host '{{.Nickname}}.example.com' --user '${USER}'
+sshcm add --nickname 'web{n}' --host 'web{n:02}.example.com' --range 1-40`,
 	Aliases: []string{"a"},
 	Run: func(cmd *cobra.Command, args []string) {
-		db = openDb()
+		ctx := cmd.Context()
+
+		db = openDb(ctx)
 
 		cmd.Flags().Visit(accSetCnFlags)
 
@@ -33,7 +58,7 @@ sshcm add --nickname something --user me --host 127.0.0.1`,
 		}
 
 		// Nicknames must be unique. See if this one exists.
-		exists, err := db.ExistsByProperty("nickname", cmdCnNickname)
+		exists, err := db.ExistsByPropertyContext(ctx, "nickname", cmdCnNickname)
 
 		if err != nil {
 			bail(err)
@@ -53,6 +78,18 @@ sshcm add --nickname something --user me --host 127.0.0.1`,
 		c.Args = cmdCnArgs
 		c.Identity = cmdCnIdentity
 		c.Command = cmdCnCommand
+		c.PreHook = cmdCnPreHook
+		c.PostHook = cmdCnPostHook
+		c.Record = cmdCnRecord
+		c.Range = cmdCnRange
+
+		if slices.Contains(cmdCnSetFlags, "jump") {
+			c.Jump, err = jumpNicknames(ctx, cmdCnJump)
+
+			if err != nil {
+				bail(err)
+			}
+		}
 
 		if debugMode {
 			fmt.Println("Adding connection:")
@@ -68,7 +105,7 @@ sshcm add --nickname something --user me --host 127.0.0.1`,
 		}
 
 		// Add connection
-		id, err := db.Add(&c)
+		id, err := db.AddContext(ctx, &c)
 
 		if err != nil {
 			bail(err)
@@ -76,6 +113,8 @@ sshcm add --nickname something --user me --host 127.0.0.1`,
 
 		fmt.Printf("Added new connection with id %d.\n", id)
 
+		syncSshConfig(ctx)
+
 		db.Close()
 	},
 }
@@ -91,6 +130,11 @@ func init() {
 	addCmd.PersistentFlags().StringVarP(&cmdCnArgs, "args", "a", "", "Arguments to pass to SSH command")
 	addCmd.PersistentFlags().StringVar(&cmdCnIdentity, "identity", "", "SSH identity to use for connection (a la '-i')")
 	addCmd.PersistentFlags().StringVarP(&cmdCnCommand, "command", "c", "", "SSH command to run")
+	addCmd.PersistentFlags().StringVarP(&cmdCnJump, "jump", "j", "", "Comma-separated jump host connections (a la '-J')")
+	addCmd.PersistentFlags().StringVar(&cmdCnPreHook, "pre-hook", "", "Local command to run before connecting")
+	addCmd.PersistentFlags().StringVar(&cmdCnPostHook, "post-hook", "", "Local command to run after the session ends")
+	addCmd.PersistentFlags().BoolVar(&cmdCnRecord, "record", false, "Record the terminal output of every session")
+	addCmd.PersistentFlags().StringVar(&cmdCnRange, "range", "", "Numbers a pattern connection stands for (ex. 1-40)")
 
 	addCmd.MarkPersistentFlagRequired("nickname")
 	addCmd.MarkPersistentFlagRequired("host")
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/cannable/sshcm/pkg/ansible"
+	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/spf13/cobra"
+)
+
+// ansibleInventoryCmd represents the ansible-inventory command
+var (
+	ansibleList bool
+	ansibleHost string
+
+	ansibleInventoryCmd = &cobra.Command{
+		Use:   "ansible-inventory { --list | --host nickname }",
+		Short: "Act as an Ansible dynamic inventory script",
+		Long: `
+Act as an Ansible dynamic inventory script.
+
+With --list, all connections are printed as inventory hosts in JSON format, as
+expected by Ansible's dynamic inventory script protocol. Connection settings
+(and program defaults) are mapped to ansible_host, ansible_user, ansible_port,
+ansible_ssh_private_key_file and ansible_ssh_common_args. With --host, only the
+variables for the passed connection are printed.
+
+To use sshcm as an inventory, point Ansible at an executable wrapper script:
+
+  #!/bin/sh
+  exec sshcm ansible-inventory "$@"`,
+		Example: `
+sshcm ansible-inventory --list
+sshcm ansible-inventory --host something`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.NoArgs(cmd, args); err != nil {
+				return err
+			}
+
+			if ansibleList == (len(ansibleHost) > 0) {
+				return ErrAnsibleListOrHost
+			}
+
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := cmd.Context()
+
+			db = openDb(ctx)
+
+			var out any
+
+			if ansibleList {
+				cns, err := db.GetAllContext(ctx)
+
+				if err != nil {
+					bail(err)
+				}
+
+				inv, err := buildInventory(ctx, cns)
+
+				if err != nil {
+					bail(err)
+				}
+
+				out = inv.List()
+			} else {
+				// Unknown hosts get an empty set of variables
+				out = map[string]string{}
+
+				// Pattern connection instances are hosts too
+				c, err := db.GetByIdOrNicknameContext(ctx, ansibleHost)
+
+				if err == nil {
+					inv, err := buildInventory(ctx, []*cdb.Connection{&c})
+
+					if err != nil {
+						bail(err)
+					}
+
+					out = inv.HostVars(c.Nickname)
+				} else if err != cdb.ErrConnectionNotFound {
+					bail(err)
+				}
+			}
+
+			db.Close()
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+
+			err := enc.Encode(out)
+
+			if err != nil {
+				bail(err)
+			}
+		},
+	}
+)
+
+// buildInventory returns an Ansible inventory containing the passed
+// connections, with program defaults applied and placeholders expanded.
+// Connections are listed by nickname, and pattern connections are replaced by
+// their instances.
+func buildInventory(ctx context.Context, cns []*cdb.Connection) (*ansible.Inventory, error) {
+	inv := ansible.New()
+
+	for _, c := range expandPatterns(cns) {
+		resolved := *c
+
+		if err := resolveConnection(ctx, &resolved); err != nil {
+			return nil, fmt.Errorf("connection %s: %w", c.Nickname, err)
+		}
+
+		vars, err := ansible.HostVars(&resolved)
+
+		if err != nil {
+			return nil, err
+		}
+
+		inv.AddHost(c.Nickname, vars)
+	}
+
+	return inv, nil
+}
+
+func init() {
+	rootCmd.AddCommand(ansibleInventoryCmd)
+
+	// Command flags
+	ansibleInventoryCmd.PersistentFlags().BoolVar(&ansibleList, "list", false, "List all connections as inventory hosts.")
+	ansibleInventoryCmd.PersistentFlags().StringVar(&ansibleHost, "host", "", "Print the variables for a single connection.")
+}
@@ -0,0 +1,320 @@
+package cmd
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/spf13/cobra"
+)
+
+// autoBackupCount is the number of automatic backups kept for each connection
+// DB. Older backups are deleted as new ones are made.
+const autoBackupCount = 5
+
+// autoBackupTimeFormat is the format of the timestamp in automatic backup file
+// names.
+const autoBackupTimeFormat = "20060102T150405.000000000"
+
+// backupCmd represents the backup command
+var (
+	backupPath string
+	noBackup   bool
+
+	backupCmd = &cobra.Command{
+		Use:   "backup",
+		Short: "Back up the connection DB",
+		Long: `
+Back up the entire connection DB to standard output (default) or a file.
+
+Unlike export, a backup includes program defaults, global settings and any
+other data in the connection DB, along with the DB schema version. Backups are
+written in JSON format. If the backup path ends in ".gz", the backup will be
+compressed.
+
+An automatic backup is also made before commands that change or remove
+existing data (ex. remove, import, restore). The last 5 automatic backups are
+kept in a "sshcm-backups" directory next to the connection DB file (in
+~/.config for PostgreSQL DBs). Pass --no-backup to skip the automatic backup.`,
+		Example: `
+sshcm backup -f sshcm-backup.json.gz`,
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := cmd.Context()
+
+			db = openDb(ctx)
+
+			a, err := db.BackupContext(ctx)
+
+			if err != nil {
+				bail(err)
+			}
+
+			db.Close()
+
+			if len(backupPath) < 1 {
+				err = a.Write(os.Stdout)
+
+				if err != nil {
+					bail(err)
+				}
+
+				return
+			}
+
+			if _, err := os.Stat(backupPath); err == nil {
+				// Print warning because the output file exists
+				fmt.Fprintln(os.Stderr,
+					"warning: backup file exists and will be overwritten")
+			}
+
+			err = writeArchive(a, backupPath)
+
+			if err != nil {
+				bail(err)
+			}
+		},
+	}
+
+	restoreCmd = &cobra.Command{
+		Use:   "restore",
+		Short: "Restore the connection DB from a backup",
+		Long: `
+Restore the entire connection DB from standard input (default) or a file.
+
+All connections, program defaults and global settings are replaced with the
+contents of the backup. Backups made with an older DB schema version are
+upgraded as they are restored. If the connection DB does not exist, it will be
+created.
+
+Compressed backups are detected automatically.`,
+		Example: `
+sshcm restore -f sshcm-backup.json.gz`,
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := cmd.Context()
+
+			var r io.Reader = os.Stdin
+
+			if len(backupPath) > 0 {
+				f, err := os.Open(backupPath)
+
+				if err != nil {
+					bail(err)
+				}
+
+				defer f.Close()
+
+				r = f
+			}
+
+			a, err := readArchive(r)
+
+			if err != nil {
+				bail(err)
+			}
+
+			db = openDb(ctx)
+
+			autoBackup(ctx)
+
+			err = db.RestoreContext(ctx, a)
+
+			if err != nil {
+				bail(err)
+			}
+
+			syncSshConfig(ctx)
+
+			db.Close()
+
+			fmt.Println("Restored connection DB from backup made", a.Created.Local().Format(time.DateTime)+".")
+		},
+	}
+)
+
+// readArchive reads a backup archive, decompressing it if needed.
+func readArchive(r io.Reader) (*cdb.Archive, error) {
+	br := bufio.NewReader(r)
+
+	// Look for the gzip magic number
+	magic, _ := br.Peek(2)
+
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", cdb.ErrArchiveInvalid, err)
+		}
+
+		defer gz.Close()
+
+		return cdb.ReadArchive(gz)
+	}
+
+	return cdb.ReadArchive(br)
+}
+
+// writeArchive writes a backup archive to the passed path. If the path ends
+// in ".gz", the archive is compressed.
+func writeArchive(a *cdb.Archive, path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	if !strings.HasSuffix(path, ".gz") {
+		return a.Write(f)
+	}
+
+	gz := gzip.NewWriter(f)
+
+	if err := a.Write(gz); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// autoBackupLocation returns the directory that automatic backups of the
+// connection DB at path are kept in, and the prefix of their file names.
+// PostgreSQL DBs have no file to keep backups next to, so their backups are
+// kept in ~/.config/sshcm-backups, named after the DB host and name.
+func autoBackupLocation(path string) (string, string) {
+	if dbDriver(path) != "postgres" {
+		return filepath.Join(filepath.Dir(path), "sshcm-backups"), filepath.Base(path) + "."
+	}
+
+	dir := "sshcm-backups"
+
+	if home, err := os.UserHomeDir(); err == nil {
+		dir = filepath.Join(home, ".config", "sshcm-backups")
+	}
+
+	name := path
+
+	if u, err := url.Parse(path); err == nil {
+		name = u.Host + u.Path
+	}
+
+	return dir, safeFileName(name) + "."
+}
+
+// safeFileName returns s with anything but letters, digits, dots, dashes and
+// underscores replaced by underscores, for use in a file name.
+func safeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(".-_", r) {
+			return r
+		}
+
+		return '_'
+	}, s)
+}
+
+// autoBackup backs up the open connection DB before a destructive command
+// runs, then deletes the oldest automatic backups so that only
+// autoBackupCount are kept. Backups are written to a "sshcm-backups"
+// directory next to the connection DB file.
+//
+// Passing --no-backup skips the backup.
+func autoBackup(ctx context.Context) {
+	path, err := writeAutoBackup(ctx)
+
+	if err != nil {
+		bail(err)
+	}
+
+	if debugMode && len(path) > 0 {
+		fmt.Println("Backed up connection DB to", path)
+	}
+}
+
+// writeAutoBackup does the work of autoBackup, returning the path of the
+// backup written (empty if backups are turned off) and any error instead of
+// printing or bailing.
+func writeAutoBackup(ctx context.Context) (string, error) {
+	if noBackup {
+		return "", nil
+	}
+
+	dir, prefix := autoBackupLocation(getDbPath())
+
+	a, err := db.BackupContext(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(dir, 0700)
+
+	if err != nil {
+		return "", err
+	}
+
+	name := prefix + time.Now().UTC().Format(autoBackupTimeFormat) + ".json.gz"
+
+	path := filepath.Join(dir, name)
+
+	err = writeArchive(a, path)
+
+	if err != nil {
+		return "", err
+	}
+
+	// Rotate old backups. The timestamped names sort oldest first.
+	matches, err := filepath.Glob(filepath.Join(dir, prefix+"*.json.gz"))
+
+	if err != nil {
+		return "", err
+	}
+
+	// The glob also matches the backups of other DBs whose names start with
+	// this one's (ex. a.db.old for a.db), so check that only a timestamp
+	// follows the prefix
+	var backups []string
+
+	for _, m := range matches {
+		ts := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(m), prefix), ".json.gz")
+
+		if _, err := time.Parse(autoBackupTimeFormat, ts); err == nil {
+			backups = append(backups, m)
+		}
+	}
+
+	slices.Sort(backups)
+
+	for len(backups) > autoBackupCount {
+		err = os.Remove(backups[0])
+
+		if err != nil {
+			return "", err
+		}
+
+		backups = backups[1:]
+	}
+
+	return path, nil
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+
+	// Command flags
+	backupCmd.PersistentFlags().StringVarP(&backupPath, "path", "f", "", "Backup destination path.")
+	restoreCmd.PersistentFlags().StringVarP(&backupPath, "path", "f", "", "Backup source path.")
+
+	rootCmd.PersistentFlags().BoolVar(&noBackup, "no-backup", false, "Skip the automatic backup before destructive commands.")
+}
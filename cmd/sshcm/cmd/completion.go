@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/spf13/cobra"
+)
+
+// completionTimeout bounds the connection DB lookups done for shell
+// completion, which runs on every tab press.
+const completionTimeout = 2 * time.Second
+
+// defaultDescriptions describes the program default settings, for shell
+// completion.
+var defaultDescriptions = map[string]string{
+	"args":         "SSH arguments for connections that don't set their own",
+	"command":      "SSH command for connections that don't set their own",
+	"identity":     "SSH identity for connections that don't set their own",
+	"user":         "User name for connections that don't set their own",
+	"pre_hook":     "Local command run before every connection",
+	"post_hook":    "Local command run after every session",
+	"hook_timeout": "How long a hook may run (ex. 30s, or 0 for no limit)",
+	"hook_abort":   "Whether a failed hook stops the connection",
+	"record_dir":   "Directory session recordings are written to",
+}
+
+// openCompletionDb opens the connection DB for shell completion. Unlike
+// openDb, it never creates or upgrades the connection DB, and never prints
+// anything: if the connection DB can't be used as it is, false is returned.
+func openCompletionDb(ctx context.Context) (cdb.ConnectionDB, bool) {
+	path := getDbPath()
+	driver := dbDriver(path)
+
+	if driver != "postgres" {
+		file, _, _ := strings.Cut(path, "?")
+
+		if _, err := os.Stat(file); err != nil {
+			return cdb.ConnectionDB{}, false
+		}
+	}
+
+	conndb, err := cdb.Connect(driver, path)
+
+	if err != nil {
+		return conndb, false
+	}
+
+	if err := conndb.CheckDbHealthContext(ctx); err != nil {
+		conndb.Close()
+		return conndb, false
+	}
+
+	return conndb, true
+}
+
+// completeConnections returns a ValidArgsFunction that completes connection
+// nicknames, described by their description (or host). The first maxArgs
+// positional arguments are completed, or every one if maxArgs is 0, and
+// nicknames that were already passed are left out.
+//
+// If instances is true, pattern connections are completed as their instances
+// (ex. web1 to web40 for web{n}), which is what connect takes.
+func completeConnections(maxArgs int, instances bool) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		if maxArgs > 0 && len(args) >= maxArgs {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), completionTimeout)
+		defer cancel()
+
+		conndb, ok := openCompletionDb(ctx)
+
+		if !ok {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		defer conndb.Close()
+
+		var completions []cobra.Completion
+
+		add := func(c cdb.Connection) {
+			if !strings.HasPrefix(c.Nickname, toComplete) || slices.Contains(args, c.Nickname) {
+				return
+			}
+
+			desc := c.Description
+
+			if len(desc) < 1 {
+				desc = c.Host
+			}
+
+			completions = append(completions, cobra.CompletionWithDesc(c.Nickname, desc))
+		}
+
+		err := conndb.ForEachContext(ctx, func(c *cdb.Connection) error {
+			if !instances {
+				add(*c)
+				return nil
+			}
+
+			for _, i := range c.Instances() {
+				add(i)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+
+		return completions, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeDefaults completes the names of program default settings, as the
+// first positional argument.
+func completeDefaults(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var completions []cobra.Completion
+
+	for _, def := range cdb.ValidDefaults {
+		completions = append(completions, cobra.CompletionWithDesc(def, defaultDescriptions[def]))
+	}
+
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeValues returns a flag completion function that completes the passed
+// values.
+func completeValues(values ...string) cobra.CompletionFunc {
+	return cobra.FixedCompletions(values, cobra.ShellCompDirectiveNoFileComp)
+}
@@ -1,17 +1,36 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"runtime"
 	"slices"
+	"strconv"
 	"syscall"
 
 	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/cannable/sshcm/pkg/hooks"
+	"github.com/cannable/sshcm/pkg/sshargs"
 	"github.com/spf13/cobra"
 )
 
+var (
+	// connectNoHooks skips the connection's hooks, and the global ones.
+	connectNoHooks bool
+
+	// connectSupervise runs ssh as a child process and records the session.
+	connectSupervise bool
+
+	// connectReconnect restarts supervised sessions that drop.
+	connectReconnect bool
+
+	// connectMaxReconnects limits how many times in a row a supervised
+	// session is restarted. Zero means no limit.
+	connectMaxReconnects int
+)
+
 // connectCmd represents the connect command
 var connectCmd = &cobra.Command{
 	Use:   "connect { id | nickname }",
@@ -20,12 +39,79 @@ var connectCmd = &cobra.Command{
 Start a connection.
 
 A connection ID or nickname must be specified as the only positional argument.
-
-Some connection settings (ex. command) can be overridden at runtime by passing flags.`,
+The connections of a pattern connection are passed by nickname (ex. web17 for
+pattern web{n} with range 1-40).
+
+Some connection settings (ex. command) can be overridden at runtime by passing flags.
+
+The host, user, identity, args and command settings, and their program
+defaults, can use placeholders, which are expanded when connecting:
+
+  ${NAME}, ${env:NAME}  Environment variable NAME (ex. ${USER})
+  {{.Field}}            The connection's Id, Nickname, Description, Host or User
+  %h, %r, %n            Host, (remote) user or nickname
+  %u, %d                Local user name or home directory
+  %%                    A literal %
+
+Percent tokens are only expanded in the host, user and identity; ssh expands
+them itself in ssh options. Other percent tokens (ex. %C, or the zone in
+fe80::1%eth0) are left as they are. The host can't refer to the host or user,
+and the user can't refer to itself. Unset environment variables are an error, except
+USER, which is the local user name if it isn't set. See "sshcm get --resolved".
+
+If the connection has jump hosts, ssh connects through them with -J. If any
+jump host needs more than a user, host and port to be reached (ex. an
+identity), a ProxyCommand that runs ssh -W through each jump host, with its own
+settings, is used instead.
+
+Hooks are local commands run before connecting (pre_hook) and after the session
+ends (post_hook). The global hooks (see "sshcm def") run for every connection,
+the pre_hook before the connection's own and the post_hook after it. Hooks are
+run with the system shell and are passed the connection, with program defaults
+applied, as environment variables:
+
+  SSHCM_HOOK         pre or post
+  SSHCM_ID           SSHCM_NICKNAME     SSHCM_HOST        SSHCM_USER
+  SSHCM_DESCRIPTION  SSHCM_ARGS         SSHCM_IDENTITY    SSHCM_COMMAND
+  SSHCM_JUMP
+  SSHCM_EXIT_STATUS  ssh's exit status (post hooks only)
+
+Hooks that run longer than the hook_timeout default (30s if it isn't set) are
+killed, along with the commands they started. A failed hook is reported and the
+connection goes ahead, unless the hook_abort default is true. Pass --no-hooks
+to skip hooks.
+
+When there are post hooks, ssh is run as a child process that sshcm waits for,
+rather than replacing sshcm.
+
+Pass --supervise to run ssh as a child process and record each session (when it
+started and ended, and ssh's exit status) in the connection DB. Signals sent to
+sshcm (ex. SIGTERM, or SIGHUP when the terminal is closed) are passed on to ssh.
+See "sshcm sessions" for the recorded sessions.
+
+Pass --reconnect to restart ssh when the connection drops (ssh exits with
+status 255), waiting 1s before the first attempt and twice as long before each
+one after it, up to 1m. Sessions that stay up for a minute or more start the
+wait over. Use --max-reconnects to give up after a number of attempts in a row.
+This suits long-lived tunnels (ex. --args "-N -L 8080:localhost:80").
+
+Pass --record to record the session's terminal output (implies --supervise).
+ssh is run under a pty, and what it writes to the terminal is saved, with
+timing, as an asciicast v2 file named by nickname and time (ex.
+web-20260501-120000.cast). Connections added or set with --record are always
+recorded, unless --record=false is passed. Recordings are kept in the
+record_dir default (see "sshcm def"), or $XDG_STATE_HOME/sshcm/recordings (if
+XDG_STATE_HOME is set) or sshcm/recordings in the user config directory (ex.
+~/.config/sshcm/recordings), and can be played back with "sshcm replay". Their
+paths are noted in the session records.`,
 	Example: `
 sshcm connect something
 sshcm c 22
 sshcm c something --user=someone
+sshcm c internal --jump=""
+sshcm c prod --no-hooks
+sshcm c tunnel --reconnect --max-reconnects 10
+sshcm c prod --record
 `,
 	Aliases: []string{"c"},
 	Args: func(cmd *cobra.Command, args []string) error {
@@ -39,13 +125,16 @@ sshcm c something --user=someone
 
 		return nil
 	},
+	ValidArgsFunction: completeConnections(1, true),
 	Run: func(cmd *cobra.Command, args []string) {
-		db = openDb()
+		ctx := cmd.Context()
+
+		db = openDb(ctx)
 
 		cmd.Flags().Visit(accSetCnFlags)
 
 		// Look up connection
-		c, err := db.GetByIdOrNickname(args[0])
+		c, err := db.GetByIdOrNicknameContext(ctx, args[0])
 
 		if err != nil {
 			bail(err)
@@ -68,21 +157,30 @@ sshcm c something --user=someone
 			c.Command = cmdCnCommand
 		}
 
-		if debugMode {
-			fmt.Println("Connecting to ", c)
-		}
-
-		// Get effective SSH command
-		sshCmd := c.Command
-		if len(sshCmd) < 1 {
-			sshCmd, err = db.GetDefault("command")
+		if slices.Contains(cmdCnSetFlags, "jump") {
+			c.Jump, err = jumpNicknames(ctx, cmdCnJump)
 
 			if err != nil {
 				bail(err)
 			}
 		}
 
-		// If the program default is empty, use 'ssh'
+		if slices.Contains(cmdCnSetFlags, "record") {
+			c.Record = cmdCnRecord
+		}
+
+		// Fill in program defaults and expand placeholders
+		if err := resolveConnection(ctx, &c); err != nil {
+			bail(err)
+		}
+
+		if debugMode {
+			fmt.Println("Connecting to ", c)
+		}
+
+		// Get effective SSH command. If there's none, use 'ssh'
+		sshCmd := c.Command
+
 		if len(sshCmd) < 1 {
 			sshCmd = "ssh"
 		}
@@ -97,53 +195,31 @@ sshcm c something --user=someone
 		// Append arguments
 		var execArgs = []string{execBin}
 
-		sshArgs := c.Args
-		if len(sshArgs) < 1 {
-			sshArgs, err = db.GetDefault("args")
-
-			if err != nil {
-				panic(err)
-			}
-		}
-
-		if len(sshArgs) > 0 {
+		if len(c.Args) > 0 {
 			// TODO: This is probably really mangled and won't work.
 			// Figure out a way to reconstitute flat arguments from the DB.
-			execArgs = append(execArgs, sshArgs)
+			execArgs = append(execArgs, c.Args)
 		}
 
 		// Append identity
-		identity := c.Identity
-
-		if len(identity) < 1 {
-			identity, err = db.GetDefault("identity")
-
-			if err != nil {
-				panic(err)
-			}
-		}
-
-		if len(identity) > 0 {
-			execArgs = append(execArgs, "-i", identity)
+		if len(c.Identity) > 0 {
+			execArgs = append(execArgs, "-i", c.Identity)
 		}
 
-		// Host
-		host := c.Host
+		// Jump hosts
+		jumpOpts, err := jumpOptions(ctx, c)
 
-		// User
-		user := c.User
-
-		if len(user) < 1 {
-			user, err = db.GetDefault("user")
-			if err != nil {
-				panic(err)
-			}
+		if err != nil {
+			bail(err)
 		}
 
-		if len(user) > 0 {
-			execArgs = append(execArgs, user+"@"+host)
+		execArgs = append(execArgs, sshargs.Flatten(jumpOpts)...)
+
+		// User and host
+		if len(c.User) > 0 {
+			execArgs = append(execArgs, c.User+"@"+c.Host)
 		} else {
-			execArgs = append(execArgs, host)
+			execArgs = append(execArgs, c.Host)
 		}
 
 		if debugMode {
@@ -152,30 +228,68 @@ sshcm c something --user=someone
 			fmt.Printf("arguments:'%s'\n", execArgs)
 		}
 
+		// Hooks are passed the connection as it will be connected to
+		var h connectHooks
+
+		if !connectNoHooks {
+			h, err = loadHooks(ctx, c)
+
+			if err != nil {
+				bail(err)
+			}
+		}
+
 		// We want to pass our environment to the new process
 		execEnv := os.Environ()
 
-		// Now's a good time to close the connection DB, since we're not going to
+		// Recorded sessions are supervised, so that the recording can be
+		// found from the session record
+		var dir string
+
+		if c.Record {
+			dir, err = recordDir(ctx)
+
+			if err != nil {
+				bail(err)
+			}
+		}
+
+		// Supervised sessions are recorded in the connection DB once they end.
+		// Otherwise, now's a good time to close it, since we're not going to
 		// need it anymore
-		db.Close()
-
-		// Run the SSH command differently based on the OS on which we're running
-		switch runtime.GOOS {
-		case "windows":
-			// On Windows, use os/exec to run the process
-			exe := exec.Cmd{
-				Path:   execBin,
-				Args:   execArgs,
-				Env:    execEnv,
-				Stdin:  os.Stdin,
-				Stdout: os.Stdout,
-				Stderr: os.Stderr,
+		supervise := connectSupervise || connectReconnect || c.Record
+
+		if !supervise {
+			db.Close()
+		}
+
+		if err := h.run(ctx, hooks.Pre); err != nil {
+			bail(err)
+		}
+
+		// Run the SSH command differently based on the OS on which we're
+		// running, and whether anything needs to happen after it exits
+		switch {
+		case supervise || runtime.GOOS == "windows" || len(h.post) > 0:
+			// Use os/exec to run the process, and wait for it
+			var status int
+
+			if supervise {
+				status = superviseSsh(ctx, c, execBin, execArgs, execEnv, dir)
+				db.Close()
+			} else {
+				status, _ = runSsh(execBin, execArgs, execEnv, nil)
 			}
 
-			err = exe.Run()
+			// Post hooks run even if the session was interrupted
+			err := h.run(context.WithoutCancel(ctx), hooks.Post, "SSHCM_EXIT_STATUS="+strconv.Itoa(status))
 
 			if err != nil {
-				fmt.Println("Error: ", err)
+				bail(err)
+			}
+
+			if status != 0 {
+				os.Exit(status)
 			}
 
 		default:
@@ -197,4 +311,10 @@ func init() {
 	connectCmd.PersistentFlags().StringVarP(&cmdCnArgs, "args", "a", "", "Arguments to pass to SSH command")
 	connectCmd.PersistentFlags().StringVar(&cmdCnIdentity, "identity", "", "SSH identity to use for connection (a la '-i')")
 	connectCmd.PersistentFlags().StringVarP(&cmdCnCommand, "command", "c", "", "SSH command to run")
+	connectCmd.PersistentFlags().StringVarP(&cmdCnJump, "jump", "j", "", "Comma-separated jump host connections (a la '-J')")
+	connectCmd.PersistentFlags().BoolVar(&connectNoHooks, "no-hooks", false, "Don't run pre or post hooks")
+	connectCmd.PersistentFlags().BoolVar(&cmdCnRecord, "record", false, "Record the session's terminal output (implies --supervise)")
+	connectCmd.PersistentFlags().BoolVar(&connectSupervise, "supervise", false, "Run ssh as a child process and record the session")
+	connectCmd.PersistentFlags().BoolVar(&connectReconnect, "reconnect", false, "Reconnect when the connection drops (implies --supervise)")
+	connectCmd.PersistentFlags().IntVar(&connectMaxReconnects, "max-reconnects", 0, "Give up after this many reconnects in a row (0 for no limit)")
 }
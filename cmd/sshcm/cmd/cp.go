@@ -0,0 +1,354 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/cannable/sshcm/pkg/sshargs"
+	"github.com/spf13/cobra"
+)
+
+// copyTools contains the file transfer programs cp knows how to drive.
+var copyTools = []string{"scp", "sftp", "rsync"}
+
+// A copyPath is a cp source or destination argument. Remote paths reference
+// a connection by id or nickname, local paths don't.
+type copyPath struct {
+	conn string // connection id or nickname (empty for local paths)
+	path string // file path, local or on the remote host
+}
+
+// cpCmd represents the cp command
+var (
+	cpTool      string
+	cpRecursive bool
+
+	cpCmd = &cobra.Command{
+		Use:   "cp source... destination",
+		Short: "Copy files to or from a connection",
+		Long: `
+Copy files to or from a connection.
+
+Remote paths are written as "id:path" or "nickname:path". Either all sources or
+the destination must be remote, and all remote paths must reference the same
+connection. To copy a local file with a colon in its name, prefix it with "./".
+
+The user, host, identity, args and jump host settings of the connection (or
+the program defaults) are used to build the copy command. SSH arguments are
+translated for the selected tool (ex. "-p 2222" becomes "-P 2222" for scp and sftp).
+
+sftp transfers run in batch mode, which requires non-interactive authentication
+(ex. a key or ssh-agent).`,
+		Example: `
+sshcm cp notes.txt something:/tmp/
+sshcm cp something:/var/log/syslog something:/var/log/auth.log .
+sshcm cp -r --tool rsync ./site 42:/srv/www`,
+		Aliases: []string{"copy"},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.MinimumNArgs(2)(cmd, args); err != nil {
+				return err
+			}
+
+			if !slices.Contains(copyTools, cpTool) {
+				return ErrCopyInvalidTool
+			}
+
+			_, _, err := parseCopyPaths(args)
+
+			return err
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := cmd.Context()
+
+			sources, dest, _ := parseCopyPaths(args)
+
+			// Work out which connection we're copying to or from
+			conn := dest.conn
+			upload := conn != ""
+
+			if !upload {
+				conn = sources[0].conn
+			}
+
+			db = openDb(ctx)
+
+			c, err := db.GetByIdOrNicknameContext(ctx, conn)
+
+			if err != nil {
+				bail(err)
+			}
+
+			err = resolveConnection(ctx, &c)
+
+			if err != nil {
+				bail(err)
+			}
+
+			jumpOpts, err := jumpOptions(ctx, c)
+
+			if err != nil {
+				bail(err)
+			}
+
+			db.Close()
+
+			// Translate connection args for the copy tool
+			split, err := sshargs.Split(c.Args)
+
+			if err != nil {
+				bail(err)
+			}
+
+			opts, _, err := sshargs.Parse(split)
+
+			if err != nil {
+				bail(err)
+			}
+
+			if len(c.Identity) > 0 {
+				opts = append(opts, sshargs.Option{Flag: 'i', Value: c.Identity})
+			}
+
+			opts = append(opts, jumpOpts...)
+
+			var execArgs []string
+			var batch string
+
+			switch cpTool {
+			case "scp":
+				execArgs = scpArgs(&c, opts, sources, dest)
+			case "sftp":
+				execArgs, batch = sftpArgs(&c, opts, sources, dest, upload)
+			case "rsync":
+				execArgs = rsyncArgs(&c, opts, sources, dest)
+			}
+
+			execBin, err := exec.LookPath(cpTool)
+
+			if err != nil {
+				panic(err)
+			}
+
+			if debugMode {
+				fmt.Println("copy details:")
+				fmt.Printf("command:   '%s'\n", execBin)
+				fmt.Printf("arguments:'%s'\n", execArgs)
+
+				if len(batch) > 0 {
+					fmt.Printf("batch:\n%s", batch)
+				}
+			}
+
+			exe := exec.Cmd{
+				Path:   execBin,
+				Args:   execArgs,
+				Env:    os.Environ(),
+				Stdin:  os.Stdin,
+				Stdout: os.Stdout,
+				Stderr: os.Stderr,
+			}
+
+			if len(batch) > 0 {
+				exe.Stdin = strings.NewReader(batch)
+			}
+
+			err = exe.Run()
+
+			var exitErr *exec.ExitError
+
+			if errors.As(err, &exitErr) {
+				os.Exit(exitErr.ExitCode())
+			} else if err != nil {
+				panic(err)
+			}
+		},
+	}
+)
+
+// parseCopyPath determines whether the passed cp argument is a local or a
+// remote path.
+//
+// An argument is remote if the text before the first colon is a valid id or
+// nickname. Single-letter prefixes are treated as drive letters on Windows.
+func parseCopyPath(arg string) copyPath {
+	conn, path, found := strings.Cut(arg, ":")
+
+	if !found || strings.ContainsAny(conn, `/\`) || !cdb.IsValidIdOrNickname(conn) {
+		return copyPath{path: arg}
+	}
+
+	if runtime.GOOS == "windows" && len(conn) == 1 {
+		return copyPath{path: arg}
+	}
+
+	return copyPath{conn: conn, path: path}
+}
+
+// parseCopyPaths splits cp arguments into sources and a destination, then
+// checks that they describe a copy sshcm can perform.
+func parseCopyPaths(args []string) ([]copyPath, copyPath, error) {
+	var sources []copyPath
+
+	for _, arg := range args[:len(args)-1] {
+		sources = append(sources, parseCopyPath(arg))
+	}
+
+	dest := parseCopyPath(args[len(args)-1])
+
+	// Collect the remote connection references
+	var conns []string
+
+	for _, p := range append([]copyPath{dest}, sources...) {
+		if p.conn != "" && !slices.Contains(conns, p.conn) {
+			conns = append(conns, p.conn)
+		}
+	}
+
+	if len(conns) == 0 {
+		return sources, dest, ErrCopyNoRemote
+	}
+
+	if len(conns) > 1 {
+		return sources, dest, ErrCopyMultipleConnections
+	}
+
+	// Sources must be all remote (download) or all local (upload)
+	for _, s := range sources {
+		if (dest.conn == "") == (s.conn == "") {
+			return sources, dest, ErrCopyRemoteBothSides
+		}
+	}
+
+	return sources, dest, nil
+}
+
+// remoteTarget returns the "[user@]host" string used to reach the passed
+// connection. IPv6 addresses are wrapped in brackets so that they can be
+// followed by ":path".
+func remoteTarget(c *cdb.Connection) string {
+	host := c.Host
+
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	if len(c.User) > 0 {
+		return c.User + "@" + host
+	}
+
+	return host
+}
+
+// remotePaths returns cp paths formatted for scp or rsync, where remote
+// paths are written as "[user@]host:path".
+func remotePaths(c *cdb.Connection, paths []copyPath) []string {
+	var s []string
+
+	for _, p := range paths {
+		if p.conn == "" {
+			s = append(s, p.path)
+		} else {
+			s = append(s, remoteTarget(c)+":"+p.path)
+		}
+	}
+
+	return s
+}
+
+// warnDroppedArgs prints a warning about ssh options the copy tool can't use.
+func warnDroppedArgs(dropped []sshargs.Option) {
+	for _, o := range dropped {
+		fmt.Fprintf(os.Stderr, "warning: ignoring ssh argument '%s' not supported by %s\n",
+			sshargs.Join(o.Args()), cpTool)
+	}
+}
+
+// scpArgs assembles the scp command line for a copy.
+func scpArgs(c *cdb.Connection, opts []sshargs.Option, sources []copyPath, dest copyPath) []string {
+	args, dropped := sshargs.ForCopy(opts)
+	warnDroppedArgs(dropped)
+
+	execArgs := append([]string{"scp"}, args...)
+
+	if cpRecursive {
+		execArgs = append(execArgs, "-r")
+	}
+
+	execArgs = append(execArgs, "--")
+	execArgs = append(execArgs, remotePaths(c, sources)...)
+
+	return append(execArgs, remotePaths(c, []copyPath{dest})...)
+}
+
+// sftpQuote quotes a path for use in an sftp batch file.
+func sftpQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// sftpArgs assembles the sftp command line for a copy. As sftp can't upload
+// files from its command line, transfers are done with a batch of put or get
+// commands that is returned alongside the arguments, to be fed to stdin.
+func sftpArgs(c *cdb.Connection, opts []sshargs.Option, sources []copyPath, dest copyPath, upload bool) ([]string, string) {
+	args, dropped := sshargs.ForCopy(opts)
+	warnDroppedArgs(dropped)
+
+	execArgs := append([]string{"sftp"}, args...)
+	execArgs = append(execArgs, "-b", "-", remoteTarget(c))
+
+	op := "get"
+
+	if upload {
+		op = "put"
+	}
+
+	if cpRecursive {
+		op += " -R"
+	}
+
+	destPath := dest.path
+
+	if len(destPath) < 1 {
+		destPath = "."
+	}
+
+	var b strings.Builder
+
+	for _, s := range sources {
+		fmt.Fprintf(&b, "%s %s %s\n", op, sftpQuote(s.path), sftpQuote(destPath))
+	}
+
+	return execArgs, b.String()
+}
+
+// rsyncArgs assembles the rsync command line for a copy. The connection's ssh
+// arguments are passed through untranslated via rsync's -e option.
+func rsyncArgs(c *cdb.Connection, opts []sshargs.Option, sources []copyPath, dest copyPath) []string {
+	execArgs := []string{"rsync"}
+
+	if cpRecursive {
+		execArgs = append(execArgs, "-r")
+	}
+
+	rsh := append([]string{"ssh"}, sshargs.Flatten(opts)...)
+
+	execArgs = append(execArgs, "-e", sshargs.Join(rsh), "--")
+	execArgs = append(execArgs, remotePaths(c, sources)...)
+
+	return append(execArgs, remotePaths(c, []copyPath{dest})...)
+}
+
+func init() {
+	rootCmd.AddCommand(cpCmd)
+
+	// Command flags
+	cpCmd.PersistentFlags().StringVarP(&cpTool, "tool", "t", "scp", "Copy tool to use. Valid tools: scp, sftp or rsync.")
+	cpCmd.PersistentFlags().BoolVarP(&cpRecursive, "recursive", "r", false, "Copy directories recursively.")
+}
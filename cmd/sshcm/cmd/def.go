@@ -12,9 +12,24 @@ var defCmd = &cobra.Command{
 	Use:   "def setting value",
 	Short: "Set program default settings",
 	Long: `
-Set program default settings.`,
+Set program default settings.
+
+Besides the defaults used for connections that don't set their own (args,
+command, identity and user), these control the hooks run by connect:
+
+  pre_hook      Local command run before every connection
+  post_hook     Local command run after every session
+  hook_timeout  How long a hook may run (ex. 30s, or 0 for no limit)
+  hook_abort    Whether a failed hook stops the connection (true or false)
+
+and this controls where session recordings are kept:
+
+  record_dir    Directory recordings are written to (defaults to
+                $XDG_STATE_HOME/sshcm/recordings, or sshcm/recordings in the
+                user config directory, ex. ~/.config/sshcm/recordings)`,
 	Example: `
 sshcm def user asdf
+sshcm def hook_timeout 2m
 `,
 	Aliases: []string{},
 	Args: func(cmd *cobra.Command, args []string) error {
@@ -26,23 +41,28 @@ sshcm def user asdf
 			return ErrInvalidDefault
 		}
 
-		return nil
+		return validateDefault(args[0], args[1])
 	},
+	ValidArgsFunction: completeDefaults,
 	Run: func(cmd *cobra.Command, args []string) {
-		db = openDb()
+		ctx := cmd.Context()
+
+		db = openDb(ctx)
 
 		setting := args[0]
 		value := args[1]
 
-		err := db.SetDefault(setting, value)
+		err := db.SetDefaultContext(ctx, setting, value)
 
 		if err != nil {
 			panic(err)
 		}
 
+		syncSshConfig(ctx)
+
 		fmt.Printf("Updated '%s' default setting to '%s'.\n", setting, value)
 
-		err = listDefaults()
+		err = listDefaults(ctx)
 
 		if err != nil {
 			panic(err)
@@ -1,25 +1,39 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"os"
 
 	"github.com/cannable/sshcm/pkg/cdb"
 	"github.com/spf13/cobra"
 )
 
-func listDefaults() error {
-	fmt.Println("Program default settings:")
+// listDefaults prints all program default settings to stdout in the selected
+// output format.
+func listDefaults(ctx context.Context) error {
+	var defs []defaultRecord
 
 	for i := range cdb.ValidDefaults {
 		def := cdb.ValidDefaults[i]
 
-		val, err := db.GetDefault(def)
+		val, err := db.GetDefaultContext(ctx, def)
 
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("%-10s: %s\n", def, val)
+		defs = append(defs, defaultRecord{Setting: def, Value: val})
+	}
+
+	if outputFmt != "table" {
+		return writeDefaults(os.Stdout, defs)
+	}
+
+	fmt.Println("Program default settings:")
+
+	for _, d := range defs {
+		fmt.Printf("%-12s: %s\n", d.Setting, d.Value)
 	}
 
 	return nil
@@ -34,9 +48,11 @@ List program defaults.`,
 	Example: `
 sshcm defaults`,
 	Run: func(cmd *cobra.Command, args []string) {
-		db = openDb()
+		ctx := cmd.Context()
+
+		db = openDb(ctx)
 
-		err := listDefaults()
+		err := listDefaults(ctx)
 
 		if err != nil {
 			panic(err)
@@ -2,8 +2,31 @@ package cmd
 
 import "errors"
 
+var ErrAnsibleListOrHost = errors.New("pass exactly one of --list or --host")
+var ErrCopyInvalidTool = errors.New("invalid copy tool")
+var ErrCopyMultipleConnections = errors.New("copy paths reference more than one connection")
+var ErrCopyNoRemote = errors.New("no remote path specified (ex. nickname:/path)")
+var ErrCopyRemoteBothSides = errors.New("remote paths must be either all sources or the destination")
+var ErrExportNeedsDirectory = errors.New("this export format requires a directory --path")
 var ErrImportCSVInvalidColumn = errors.New("spurious column in import file")
 var ErrImportFileNotFound = errors.New("import file does not exist")
+var ErrImportInvalid = errors.New("invalid import data")
+var ErrImportInvalidDocument = errors.New("unexpected document structure")
+var ErrImportInvalidKey = errors.New("unknown key")
+var ErrImportMapFormat = errors.New("--map can only be used with --format json")
+var ErrImportPreviewNoMap = errors.New("--preview requires --map")
+var ErrInvalidColorMode = errors.New("invalid color mode")
+var ErrInvalidColumn = errors.New("invalid column")
 var ErrInvalidDefault = errors.New("invalid default")
+var ErrInvalidExportFormat = errors.New("invalid export format")
+var ErrInvalidHookAbort = errors.New("invalid hook_abort default (true or false)")
+var ErrInvalidHookTimeout = errors.New("invalid hook_timeout default (ex. 30s, or 0 for none)")
+var ErrInvalidImportFormat = errors.New("invalid import format")
+var ErrInvalidOutputFormat = errors.New("invalid output format")
+var ErrInvalidReplayOptions = errors.New("--speed must be more than 0 and --idle-limit can't be negative")
+var ErrInvalidResolveFormat = errors.New("invalid resolve format")
 var ErrNicknameExists = errors.New("nickname already exists")
 var ErrNoIdOrNickname = errors.New("no id or nickname specified")
+var ErrNoPostgresDriver = errors.New("this build of sshcm does not include a PostgreSQL driver")
+var ErrPatternConnection = errors.New("pattern connections can't be used directly; pass the nickname of one of their connections")
+var ErrSshConfigDisable = errors.New("--disable can't be used with --include or --path")
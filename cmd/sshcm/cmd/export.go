@@ -1,18 +1,34 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
-
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/cannable/sshcm/pkg/exchange"
+	"github.com/cannable/sshcm/pkg/sessions"
+	"github.com/cannable/sshcm/pkg/sshargs"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
+// exportFormats contains the valid values for the export --format flag.
+var exportFormats = []string{"csv", "json", "ndjson", "yaml", "toml", "ansible-ini", "ansible-yaml", "putty", "remmina"}
+
 // exportCmd represents the export command
 var (
-	exportFmt  string
-	exportPath string
+	exportFmt    string
+	exportPath   string
+	exportSchema bool
+	exportExpand bool
 
 	exportCmd = &cobra.Command{
 		Use:   "export",
@@ -21,8 +37,57 @@ var (
 
 The export process will update existing connections and append new ones.
 
-The default format is CSV. To use json, pass --format json.`,
+The default format is CSV. To use another format, pass --format json, ndjson,
+yaml or toml. The json, yaml and toml formats are versioned documents holding
+format_version, schema_version, program default settings (defaults) and
+connections. Pass --schema to print the JSON Schema for the json format. The
+csv, json and ndjson formats are streamed, so they are best suited to exporting
+large connection DBs. ndjson writes one JSON connection object per line (ex.
+for jq).
+
+To write an Ansible inventory, pass --format ansible-ini or ansible-yaml.
+Connections are listed by nickname, with ansible_host, ansible_user,
+ansible_port, ansible_ssh_private_key_file and ansible_ssh_common_args set from
+the connection and program defaults. See also the ansible-inventory command.
+
+To export sessions for desktop ssh clients, pass --format putty (a registry
+file to import with regedit) or remmina. Remmina keeps one connection per file,
+so the remmina format requires --path, which is used as a directory. The port,
+user, identity and proxy settings (-J, or a ProxyCommand) are taken from the
+connection, any other ssh args are dropped with a warning.
+
+Pattern connections (see add) are exported as they are stored, with their
+range. Pass --expand to export each of their connections instead (ex. web1 to
+web40 for web{n} with range 1-40). The ansible, putty and remmina formats always
+export pattern connections expanded.`,
 		Run: func(cmd *cobra.Command, args []string) {
+			if exportSchema {
+				out, err := exchange.MarshalSchema()
+
+				if err != nil {
+					bail(err)
+				}
+
+				os.Stdout.Write(out)
+
+				return
+			}
+
+			if exportFmt == "remmina" {
+				// Remmina keeps one connection per file
+				if len(exportPath) < 1 {
+					bail(ErrExportNeedsDirectory)
+				}
+
+				err := exportRemmina(cmd.Context(), exportPath)
+
+				if err != nil {
+					bail(err)
+				}
+
+				return
+			}
+
 			if len(exportPath) > 0 {
 				// Write to file
 
@@ -40,78 +105,290 @@ The default format is CSV. To use json, pass --format json.`,
 				}
 
 				// Export
-				err = exportConnections(f)
+				err = exportConnections(cmd.Context(), f)
 
 				if err != nil {
+					f.Close()
 					bail(err)
 				}
 
-				defer f.Close()
+				err = f.Close()
+
+				if err != nil {
+					bail(err)
+				}
 			} else {
-				// Write to stdout
-				err := exportConnections(os.Stdout)
+				// Write to stdout, buffered as output may be large
+				w := bufio.NewWriter(os.Stdout)
+
+				err := exportConnections(cmd.Context(), w)
+
+				if err == nil {
+					err = w.Flush()
+				}
 
 				if err != nil {
 					bail(err)
 				}
 			}
-
 		},
 	}
 )
 
 // exportConnections writes connection properties to the passed Writer, in
-// either CSV or json format.
+// CSV, json, ndjson, yaml, toml, Ansible inventory or PuTTY registry format.
+// The yaml and toml formats also include program default settings.
+//
+// The csv, json and ndjson formats are streamed: each connection is written
+// as it is read from the connection DB. Any error reading the connection DB or
+// writing the output is returned.
 //
 // If the export path is a file and it exists, a warning will be printed to
 // stderr that the file will be clobbered, but an error will not be returned.
-func exportConnections(f *os.File) error {
-	db = openDb()
+func exportConnections(ctx context.Context, w io.Writer) error {
+	db = openDb(ctx)
+	defer db.Close()
+
+	switch exportFmt {
+	case "csv":
+		return exportCSV(ctx, w)
+	case "json":
+		return exportJSON(ctx, w)
+	case "ndjson":
+		return exportNDJSON(ctx, w)
+	}
 
-	// Get all connections
-	cns, err := db.GetAll()
+	// The remaining formats need every connection up front
+	cns, _, err := db.List(ctx, cdb.ListOptions{})
 
 	if err != nil {
-		bail(err)
+		return err
+	}
+
+	// Desktop clients don't know about pattern connections
+	if exportExpand || exportFmt == "putty" {
+		cns = expandPatterns(cns)
 	}
 
 	switch exportFmt {
+	case "yaml", "toml":
+		doc := exchange.NewDocument()
 
-	case "csv":
-		w := csv.NewWriter(f)
-
-		// Write CSV file header
-		header := []string{
-			"id",
-			"nickname",
-			"user",
-			"host",
-			"description",
-			"args",
-			"identity",
-			"command",
+		doc.Defaults, err = exportDefaults(ctx)
+
+		if err != nil {
+			return err
 		}
 
-		err := w.Write(header)
+		for _, c := range cns {
+			doc.Connections = append(doc.Connections, exchange.FromConnection(c))
+		}
+
+		if exportFmt == "yaml" {
+			enc := yaml.NewEncoder(w)
+			enc.SetIndent(2)
+
+			err = enc.Encode(doc)
+
+			if err == nil {
+				err = enc.Close()
+			}
+
+			return err
+		}
+
+		return toml.NewEncoder(w).Encode(doc)
+	case "ansible-ini", "ansible-yaml":
+		inv, err := buildInventory(ctx, cns)
 
 		if err != nil {
 			return err
 		}
 
-		// Write output
+		if exportFmt == "ansible-ini" {
+			return inv.WriteINI(w)
+		}
+
+		return inv.WriteYAML(w)
+	case "putty":
+		var sess []sessions.Session
+
 		for _, c := range cns {
-			err = c.WriteCSV(w)
+			s, err := sessionFromConnection(ctx, c)
 
 			if err != nil {
 				return err
 			}
+
+			sess = append(sess, s)
 		}
 
-		w.Flush()
-	case "json":
-		out, err := json.Marshal(cns)
+		return sessions.WritePuTTY(w, sess)
+	}
+
+	return ErrInvalidExportFormat
+}
+
+// exportCSV streams connections in CSV format, with a header row.
+func exportCSV(ctx context.Context, w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	// Write CSV file header
+	err := cw.Write(connectionRecordHeader)
+
+	if err != nil {
+		return err
+	}
+
+	// Write output
+	err = forEachExported(ctx, func(c *cdb.Connection) error {
+		return c.WriteCSV(cw)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
 
-		f.Write(out)
+// exportDefaults returns the program default settings, by name.
+func exportDefaults(ctx context.Context) (map[string]string, error) {
+	defs := make(map[string]string)
+
+	for _, def := range cdb.ValidDefaults {
+		val, err := db.GetDefaultContext(ctx, def)
+
+		if err != nil {
+			return nil, err
+		}
+
+		defs[def] = val
+	}
+
+	return defs, nil
+}
+
+// exportJSON streams program defaults and connections as a JSON document.
+func exportJSON(ctx context.Context, w io.Writer) error {
+	defs, err := exportDefaults(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	enc, err := exchange.NewEncoder(w, defs)
+
+	if err != nil {
+		return err
+	}
+
+	err = forEachExported(ctx, func(c *cdb.Connection) error {
+		return enc.Encode(exchange.FromConnection(c))
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+// exportNDJSON streams connections as newline-delimited JSON, one connection
+// record per line.
+func exportNDJSON(ctx context.Context, w io.Writer) error {
+	enc := json.NewEncoder(w)
+
+	return forEachExported(ctx, func(c *cdb.Connection) error {
+		return enc.Encode(exchange.FromConnection(c))
+	})
+}
+
+// forEachExported calls fn for each connection in the connection DB, as
+// db.ForEachContext does. With --expand, pattern connections are replaced by
+// their instances.
+func forEachExported(ctx context.Context, fn func(*cdb.Connection) error) error {
+	return db.ForEachContext(ctx, func(c *cdb.Connection) error {
+		if !exportExpand {
+			return fn(c)
+		}
+
+		for _, i := range c.Instances() {
+			if err := fn(&i); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// sessionFromConnection returns a desktop client session for a connection,
+// with program defaults applied and placeholders expanded. A warning is printed
+// for each ssh argument the session can't hold.
+func sessionFromConnection(ctx context.Context, c *cdb.Connection) (sessions.Session, error) {
+	resolved := *c
+
+	if err := resolveConnection(ctx, &resolved); err != nil {
+		return sessions.Session{}, fmt.Errorf("connection %s: %w", c.Nickname, err)
+	}
+
+	s, dropped, err := sessions.FromConnection(&resolved)
+
+	if err != nil {
+		return s, fmt.Errorf("connection %s: %w", c.Nickname, err)
+	}
+
+	for _, o := range dropped {
+		fmt.Fprintf(os.Stderr, "warning: connection '%s': ignoring ssh argument '%s' not supported by %s\n",
+			c.Nickname, sshargs.Join(o.Args()), exportFmt)
+	}
+
+	return s, nil
+}
+
+// exportRemmina writes each connection to its own Remmina connection file in
+// the passed directory, which is created if needed. Files are named after the
+// connection nickname.
+func exportRemmina(ctx context.Context, dir string) error {
+	db = openDb(ctx)
+
+	cns, _, err := db.List(ctx, cdb.ListOptions{})
+
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dir, 0750)
+
+	if err != nil {
+		return err
+	}
+
+	for _, c := range expandPatterns(cns) {
+		s, err := sessionFromConnection(ctx, c)
+
+		if err != nil {
+			return err
+		}
+
+		name := strings.NewReplacer("/", "_", string(os.PathSeparator), "_").Replace(c.Nickname)
+		path := filepath.Join(dir, name+".remmina")
+
+		if _, err := os.Stat(path); err == nil {
+			fmt.Fprintf(os.Stderr, "warning: %s exists and will be overwritten\n", path)
+		}
+
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+
+		if err != nil {
+			return err
+		}
+
+		err = sessions.WriteRemmina(f, s)
+		f.Close()
 
 		if err != nil {
 			return err
@@ -127,7 +404,11 @@ func init() {
 	rootCmd.AddCommand(exportCmd)
 
 	// Command flags
-	exportCmd.PersistentFlags().StringVar(&exportFmt, "format", "csv", "Export format. Valid formats: csv or json.")
+	exportCmd.PersistentFlags().StringVar(&exportFmt, "format", "csv", "Export format. Valid formats: csv, json, ndjson, yaml, toml, ansible-ini, ansible-yaml, putty or remmina.")
+	exportCmd.PersistentFlags().BoolVar(&exportSchema, "schema", false, "Print the JSON Schema for the json export format, then exit.")
 	exportCmd.PersistentFlags().StringVarP(&exportPath, "path", "f", "", "Export destination path.")
+	exportCmd.PersistentFlags().BoolVar(&exportExpand, "expand", false, "Export each connection of pattern connections, instead of the patterns.")
+
+	exportCmd.RegisterFlagCompletionFunc("format", completeValues(exportFormats...))
 
 }
@@ -2,11 +2,15 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/cannable/sshcm/pkg/cdb"
 	"github.com/spf13/cobra"
 )
 
+// getResolved prints the connection as connect would use it.
+var getResolved bool
+
 // getCmd represents the get command
 var getCmd = &cobra.Command{
 	Use:   "get { id | nickname }",
@@ -14,10 +18,19 @@ var getCmd = &cobra.Command{
 	Long: `
 Print connection settings.
 
-A valid connection ID or nickname must be specified.`,
+A valid connection ID or nickname must be specified. Passing the nickname of
+one of the connections of a pattern connection (ex. web17 for web{n}) prints
+that connection.
+
+Pass --resolved to print the connection as connect would use it, with program
+defaults filled in and placeholders (ex. ${USER} or {{.Nickname}}) expanded.
+
+Pass --output to print the connection in a machine-readable format.`,
 	Example: `
 sshcm get asdf
 sshcm g 42
+sshcm get asdf --output json
+sshcm get asdf --resolved
 `,
 	Aliases: []string{"g"},
 	Args: func(cmd *cobra.Command, args []string) error {
@@ -31,19 +44,36 @@ sshcm g 42
 
 		return nil
 	},
+	ValidArgsFunction: completeConnections(1, false),
 	Run: func(cmd *cobra.Command, args []string) {
-		db = openDb()
+		ctx := cmd.Context()
+
+		db = openDb(ctx)
 
 		// Look up connection
-		c, err := db.GetByIdOrNickname(args[0])
+		c, err := db.GetByIdOrNicknameContext(ctx, args[0])
 
 		if err != nil {
 			bail(err)
 		}
 
+		if getResolved {
+			if err := resolveConnection(ctx, &c); err != nil {
+				bail(err)
+			}
+		}
+
 		// Show user the connection settings
-		printConnection(&c, false)
-		fmt.Println("")
+		if outputFmt == "table" {
+			printConnection(&c, false)
+			fmt.Println("")
+		} else {
+			err = writeConnection(os.Stdout, &c)
+
+			if err != nil {
+				bail(err)
+			}
+		}
 
 		db.Close()
 	},
@@ -53,4 +83,5 @@ func init() {
 	rootCmd.AddCommand(getCmd)
 
 	// Command flags
+	getCmd.Flags().BoolVar(&getResolved, "resolved", false, "Print the connection with program defaults and placeholders expanded")
 }
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/cannable/sshcm/pkg/hooks"
+)
+
+// defaultHookTimeout is how long a hook may run when the hook_timeout program
+// default isn't set.
+const defaultHookTimeout = 30 * time.Second
+
+// connectHooks holds the hooks connect runs for a connection, and how they are
+// run.
+type connectHooks struct {
+	pre     []string // run before connecting, global hook first
+	post    []string // run after the session ends, global hook last
+	env     []string // SSHCM_* variables for the connection
+	timeout time.Duration
+	abort   bool // fail, rather than warn, when a hook fails
+}
+
+// parseHookTimeout parses the hook_timeout program default. An empty value is
+// defaultHookTimeout, and zero means hooks can run for as long as they like.
+func parseHookTimeout(value string) (time.Duration, error) {
+	if len(value) < 1 {
+		return defaultHookTimeout, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+
+	if err != nil || timeout < 0 {
+		return 0, ErrInvalidHookTimeout
+	}
+
+	return timeout, nil
+}
+
+// parseHookAbort parses the hook_abort program default. An empty value is
+// false.
+func parseHookAbort(value string) (bool, error) {
+	if len(value) < 1 {
+		return false, nil
+	}
+
+	abort, err := strconv.ParseBool(value)
+
+	if err != nil {
+		return false, ErrInvalidHookAbort
+	}
+
+	return abort, nil
+}
+
+// validateDefault checks the value of a program default that has a format of
+// its own. Connection setting defaults may only use known placeholders.
+func validateDefault(name string, value string) error {
+	var err error
+
+	switch name {
+	case "hook_timeout":
+		_, err = parseHookTimeout(value)
+	case "hook_abort":
+		_, err = parseHookAbort(value)
+	case "user":
+		err = cdb.Connection{User: value}.ValidatePlaceholders()
+	case "args":
+		err = cdb.Connection{Args: value}.ValidatePlaceholders()
+	case "identity":
+		err = cdb.Connection{Identity: value}.ValidatePlaceholders()
+	case "command":
+		err = cdb.Connection{Command: value}.ValidatePlaceholders()
+	}
+
+	return err
+}
+
+// loadHooks returns the hooks to run for the passed connection, which should
+// have been resolved (see resolveConnection). Global hooks (the pre_hook and
+// post_hook program defaults) are run along with the connection's own.
+func loadHooks(ctx context.Context, c cdb.Connection) (connectHooks, error) {
+	defs := make(map[string]string)
+
+	for _, name := range []string{"pre_hook", "post_hook", "hook_timeout", "hook_abort"} {
+		value, err := db.GetDefaultContext(ctx, name)
+
+		if err != nil {
+			return connectHooks{}, err
+		}
+
+		defs[name] = value
+	}
+
+	h := connectHooks{env: hooks.Env(c)}
+
+	for _, command := range []string{defs["pre_hook"], c.PreHook} {
+		if len(command) > 0 {
+			h.pre = append(h.pre, command)
+		}
+	}
+
+	for _, command := range []string{c.PostHook, defs["post_hook"]} {
+		if len(command) > 0 {
+			h.post = append(h.post, command)
+		}
+	}
+
+	var err error
+
+	if h.timeout, err = parseHookTimeout(defs["hook_timeout"]); err != nil {
+		return connectHooks{}, err
+	}
+
+	if h.abort, err = parseHookAbort(defs["hook_abort"]); err != nil {
+		return connectHooks{}, err
+	}
+
+	return h, nil
+}
+
+// run runs the hooks for a phase (hooks.Pre or hooks.Post) in order, with
+// extra added to their environment. A failed hook is reported as a warning,
+// unless hook_abort is set, in which case the remaining hooks are skipped and
+// the error is returned.
+func (h connectHooks) run(ctx context.Context, phase string, extra ...string) error {
+	commands := h.pre
+
+	if phase == hooks.Post {
+		commands = h.post
+	}
+
+	env := append(append([]string{"SSHCM_HOOK=" + phase}, h.env...), extra...)
+
+	for _, command := range commands {
+		if debugMode {
+			fmt.Printf("Running %s hook: '%s'\n", phase, command)
+		}
+
+		err := hooks.Run(ctx, command, env, h.timeout)
+
+		if err == nil {
+			continue
+		}
+
+		if h.abort {
+			return err
+		}
+
+		fmt.Fprintln(os.Stderr, "Warning:", err)
+	}
+
+	return nil
+}
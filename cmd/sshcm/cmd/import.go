@@ -1,19 +1,47 @@
 package cmd
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cannable/sshcm/pkg/ansible"
 	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/cannable/sshcm/pkg/exchange"
+	"github.com/cannable/sshcm/pkg/jsonpath"
+	"github.com/cannable/sshcm/pkg/sessions"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
+// importFormats contains the valid values for the import --format flag.
+var importFormats = []string{"csv", "json", "ndjson", "yaml", "toml", "ansible", "putty", "remmina"}
+
 // importCmd represents the import command
 var (
-	importFmt  string
-	importPath string
+	importFmt     string
+	importMap     string
+	importPath    string
+	importPreview bool
+	importRoot    string
+
+	// importJumps holds the jump hosts of imported connections, by nickname.
+	// They are set once every connection has been imported (see
+	// cdb.Tx.SetJumpHosts), as a jump host may come after the connections
+	// using it in the import file.
+	importJumps map[string]string
 
 	importCmd = &cobra.Command{
 		Use:   "import",
@@ -21,9 +49,42 @@ var (
 		Long: `Import connections from standard input (default) or a file.
 
 The import process will update existing connections and append new ones.
-
-The default format is CSV. To use json, pass --format json.`,
+Imports are all or nothing: if any connection fails to import, the connection
+DB is left unchanged.
+
+The default format is CSV. To use another format, pass --format json, ndjson,
+yaml or toml. The json, yaml and toml formats are versioned documents, as
+written by export, and also include program default settings. Documents with a
+newer format_version than this version of sshcm supports are rejected. The
+bare array of connections written by older versions of sshcm is still accepted
+for json. ndjson files hold one JSON connection object per line.
+
+To import hosts from an Ansible inventory file (INI or YAML), pass --format
+ansible. Each host becomes a connection, nicknamed after the host (host names
+that aren't valid nicknames, such as IP addresses, are prefixed with "host-").
+ansible_host, ansible_user and ansible_ssh_private_key_file are mapped onto the
+matching connection settings, and ansible_port, ansible_ssh_common_args and
+ansible_ssh_extra_args become ssh args. Group variables apply to their member
+hosts. The groups a host belongs to (including parent groups) are listed in the
+connection's description.
+
+To import sessions from desktop ssh clients, pass --format putty (a registry
+file exported with regedit) or remmina. A Remmina import path may be a single
+.remmina file or a directory of them. The host name, port, user name, private
+key path and proxy settings are mapped onto the connection. Sessions are
+nicknamed after their name, prefixed with "host-" if it isn't a valid nickname
+(ex. an IP address). Sessions that don't use ssh are skipped.
+
+JSON documents of any shape (ex. Terraform output or cloud CLI dumps) can be
+imported by passing --map, a comma-separated list of property=JSONPath pairs.
+Paths are relative to each item in the document's top-level array. To import
+items from somewhere else in the document, pass --root, a JSONPath that selects
+them (ex. $.resources[*].instances[*]). Pass --preview to print the mapped
+connections without importing them.`,
+		Example: `
+sshcm import --format json --map 'nickname=$.name,host=$.public_ip,user=$.tags.ssh_user' -f hosts.json --preview`,
 		Run: func(cmd *cobra.Command, args []string) {
+			ctx := cmd.Context()
 
 			if len(importPath) > 0 {
 				// Read from file
@@ -40,7 +101,7 @@ The default format is CSV. To use json, pass --format json.`,
 				}
 
 				// Export
-				err = importConnections(f)
+				err = importConnections(ctx, f)
 
 				if err != nil {
 					bail(err)
@@ -49,7 +110,7 @@ The default format is CSV. To use json, pass --format json.`,
 				defer f.Close()
 			} else {
 				// Read from stdin
-				err := importConnections(os.Stdin)
+				err := importConnections(ctx, os.Stdin)
 
 				if err != nil {
 					bail(err)
@@ -60,6 +121,20 @@ The default format is CSV. To use json, pass --format json.`,
 	}
 )
 
+// tomlConnectionsHeader matches the table array header that starts each
+// connection in a toml import file.
+var tomlConnectionsHeader = regexp.MustCompile(`^\s*\[\[\s*connections\s*\]\]`)
+
+// importError wraps an error caused by bad input with ErrImportInvalid and,
+// if it is known, the line number of the offending input.
+func importError(line int, err error) error {
+	if line > 0 {
+		return fmt.Errorf("%w: line %d: %w", ErrImportInvalid, line, err)
+	}
+
+	return fmt.Errorf("%w: %w", ErrImportInvalid, err)
+}
+
 // getCSVColumnMappings returns a map of CSV column names vs. offset, determined
 // from the passed row slice of strings. This allows importConnections() to
 // re-assemble connection properties from CSV columns in any positional order,
@@ -71,6 +146,7 @@ The default format is CSV. To use json, pass --format json.`,
 //	  int == positional index of column within row string slice/CSV file
 //
 // If a heading is encountered that is not valid, an error will be returned.
+// The id column written by export is accepted, but ignored on import.
 func getCSVColumnMappings(row []string) (map[string]int, error) {
 	cols := make(map[string]int)
 
@@ -79,7 +155,7 @@ func getCSVColumnMappings(row []string) (map[string]int, error) {
 	}
 
 	for col, _ := range cols {
-		if !cdb.IsValidProperty(col) {
+		if !slices.Contains(connectionRecordHeader, col) {
 			return cols, ErrImportCSVInvalidColumn
 		}
 	}
@@ -87,211 +163,749 @@ func getCSVColumnMappings(row []string) (map[string]int, error) {
 	return cols, nil
 }
 
+// importConnection adds the passed connection to the connection DB or, if a
+// connection with the same nickname exists, updates it. Ids are not imported,
+// as they are specific to a connection DB.
+func importConnection(tx *cdb.Tx, c cdb.Connection) error {
+	c.Id = 0
+
+	importJumps[c.Nickname] = c.Jump
+	c.Jump = ""
+
+	// See if the nickname exists. If it does, we'll update the existing
+	// connection.
+	exists, err := tx.ExistsByProperty("nickname", c.Nickname)
+
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		fmt.Printf("Importing new connection '%s'...\n", c.Nickname)
+
+		// Run smoke test on connection properties. As this is a new connection,
+		// the only error we should get is that the connection ID is zero.
+		err = c.Validate()
+
+		if err != cdb.ErrConnIdZero {
+			return err
+		}
+
+		// Add connection
+		id, err := tx.Add(&c)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Added new connection '%s' (%d).\n", c.Nickname, id)
+
+		return nil
+	}
+
+	existing, err := tx.GetByProperty("nickname", c.Nickname)
+
+	// If we found the connection by nickname but couldn't actually retrieve it,
+	// something is really wrong
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Updating existing connection '%s' (%d)...\n", existing.Nickname, existing.Id)
+
+	existing.CopySettings(c)
+
+	// Run smoke test on connection properties
+	err = existing.Validate()
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Update(existing)
+}
+
+// importDefaults updates program default settings from an import file.
+// Settings are checked before any are written, so that an invalid setting
+// doesn't result in a partial update.
+func importDefaults(tx *cdb.Tx, defs map[string]string) error {
+	for name := range defs {
+		if !cdb.IsValidDefault(name) {
+			return fmt.Errorf("%w: %s", cdb.ErrInvalidDefault, name)
+		}
+	}
+
+	for name, value := range defs {
+		fmt.Printf("Setting default '%s' to '%s'.\n", name, value)
+
+		if err := tx.SetDefault(name, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // importConnections imports connections from the passed Reader.
 //
 // nil will be returned if the entire import operation succeeds.
 //
-// This func supports csv and json format. The format used is determed by the
-// global variable inputFmt. As this is managed by cobra/pflag, the value is
-// not validated.
+// This func supports csv, json, ndjson, yaml, toml, Ansible inventory, PuTTY
+// registry and Remmina format. The format used is determined by the global
+// variable importFmt. JSON documents of any shape can be imported by passing a
+// field mapping (see importJSONMapped).
 //
-// Various errors may be returned at any point during the import and are
-// likely caused by an I/O failure.
+// Errors caused by bad input are wrapped in ErrImportInvalid and include a
+// line number where possible. Other errors are likely caused by an I/O
+// failure.
 //
-// An error during import will likely stop the process between connections
-// (the previous one that succeeded and the current one that failed). As such,
-// it's worth being aware that a partial import is a likely failure mode.
-func importConnections(f *os.File) error {
-	db = openDb()
+// The import runs in a single transaction. If any connection or default fails
+// to import, none of the changes are kept.
+func importConnections(ctx context.Context, f *os.File) error {
+	var err error
 
-	cols := make(map[string]int)
+	if len(importMap) > 0 && importFmt != "json" {
+		return ErrImportMapFormat
+	}
 
-	switch importFmt {
+	if importPreview {
+		return previewMappedJSON(f)
+	}
 
-	case "csv":
-		r := csv.NewReader(f)
+	db = openDb(ctx)
 
-		// Read all records from CSV file
-		records, err := r.ReadAll()
+	autoBackup(ctx)
 
-		if err != nil {
+	// Import everything in a single transaction, so that a failed import
+	// leaves the connection DB unchanged
+	err = db.WithTxContext(ctx, func(tx *cdb.Tx) error {
+		importJumps = make(map[string]string)
+
+		if err := importFormat(tx, f); err != nil {
 			return err
 		}
 
-		// Loop through each record and import
-		for i, row := range records {
+		err := tx.SetJumpHosts(importJumps)
 
-			if i == 0 {
-				// Header row - determine column order
-				cols, err = getCSVColumnMappings(row)
-			}
+		// Jump hosts that don't exist or loop are bad input; other errors
+		// (ex. a busy connection DB) are not
+		if errors.Is(err, cdb.ErrJumpNotFound) || errors.Is(err, cdb.ErrJumpLoop) {
+			return importError(0, err)
+		}
 
-			c := cdb.NewConnection()
+		return err
+	})
 
-			importNickname := row[cols["nickname"]]
+	if err != nil {
+		err = fmt.Errorf("%w; no changes were made", err)
+	} else {
+		syncSshConfig(ctx)
+	}
 
-			// See if the nickname exists. If it does, we'll start with the existing
-			// connection and update it
-			update := false
+	db.Close()
 
-			exists, err := db.ExistsByProperty("nickname", importNickname)
+	return err
+}
 
-			if err != nil {
-				return err
-			}
+// importCSV imports connections from a CSV file with a header row.
+func importCSV(tx *cdb.Tx, f io.Reader) error {
+	r := csv.NewReader(f)
 
-			if exists {
-				update = true
-				c, err = db.GetByProperty("nickname", importNickname)
+	// Read the header row to determine column order
+	header, err := r.Read()
 
-				// If we found the connection by nickname but couldn't actually retrieve
-				// it, something is really wrong
-				if err != nil {
-					return err
-				}
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return importError(0, err)
+	}
 
-				fmt.Printf("Updating existing connection '%s' (%d)...\n", importNickname, c.Id)
-			} else {
-				fmt.Printf("Importing new connection '%s'...\n", importNickname)
-			}
+	cols, err := getCSVColumnMappings(header)
 
-			// Populate or update properties in new Connection
-			c.Nickname = row[cols["nickname"]]
-			c.Host = row[cols["host"]]
-			c.User = row[cols["user"]]
-			c.Description = row[cols["description"]]
-			c.Args = row[cols["args"]]
-			c.Identity = row[cols["identity"]]
-			c.Command = row[cols["command"]]
+	if err != nil {
+		return importError(1, err)
+	}
 
-			if update {
-				// Run smoke test on connection properties
-				err = c.Validate()
+	// Missing columns are treated as empty
+	field := func(row []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(row) {
+			return row[i]
+		}
 
-				if err != nil {
-					return err
-				}
+		return ""
+	}
 
-				// Update connection
-				err = c.Update()
+	// Loop through each record and import
+	for {
+		row, err := r.Read()
 
-				if err != nil {
-					return err
-				}
-			} else {
-				// Run smoke test on connection properties
-				err = c.Validate()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return importError(0, err)
+		}
 
-				if err != nil {
-					return err
-				}
+		line, _ := r.FieldPos(0)
 
-				// Add connection
-				id, err := db.Add(&c)
+		c := cdb.NewConnection()
 
-				if err != nil {
-					return err
-				}
+		c.Nickname = field(row, "nickname")
+		c.Host = field(row, "host")
+		c.User = field(row, "user")
+		c.Description = field(row, "description")
+		c.Args = field(row, "args")
+		c.Identity = field(row, "identity")
+		c.Command = field(row, "command")
+		c.Jump = field(row, "jump")
+		c.PreHook = field(row, "pre_hook")
+		c.PostHook = field(row, "post_hook")
+		c.Range = field(row, "range")
+
+		if v := field(row, "record"); len(v) > 0 {
+			c.Record, err = strconv.ParseBool(v)
 
-				fmt.Printf("Added new connection '%s' (%d).\n", importNickname, id)
+			if err != nil {
+				return importError(line, fmt.Errorf("invalid record value: %s", v))
 			}
 		}
+
+		if err := importConnection(tx, c); err != nil {
+			return importError(line, err)
+		}
+	}
+}
+
+// importFormat imports connections from the passed file, in the format
+// selected by importFmt.
+func importFormat(tx *cdb.Tx, f *os.File) error {
+	switch importFmt {
+	case "csv":
+		return importCSV(tx, f)
 	case "json":
-		d := json.NewDecoder(f)
+		if len(importMap) > 0 {
+			return importJSONMapped(tx, f)
+		}
 
-		// Read the opening bracket (because we should have an array)
-		_, err := d.Token()
+		return importJSON(tx, f)
+	case "ndjson":
+		return importNDJSON(tx, f)
+	case "yaml":
+		return importYAML(tx, f)
+	case "toml":
+		return importTOML(tx, f)
+	case "ansible":
+		return importAnsible(tx, f)
+	case "putty":
+		return importPuTTY(tx, f)
+	case "remmina":
+		return importRemmina(tx, f)
+	}
 
-		if err != nil {
-			return err
+	return ErrInvalidImportFormat
+}
+
+// importJSON imports program defaults and connections from a JSON document.
+// Both the current document envelope and the bare array of connections written
+// by older versions of sshcm are accepted.
+func importJSON(tx *cdb.Tx, f io.Reader) error {
+	err := exchange.Decode(f, exchange.Handler{
+		Defaults: func(defs map[string]string) error {
+			return importDefaults(tx, defs)
+		},
+		Connection: func(c exchange.Connection) error {
+			return importConnection(tx, c.ToConnection())
+		},
+	})
+
+	if err != nil {
+		return importError(0, err)
+	}
+
+	return nil
+}
+
+// maxNDJSONLine is the longest line importNDJSON accepts.
+const maxNDJSONLine = 1024 * 1024
+
+// importNDJSON imports connections from newline-delimited JSON, with one
+// connection record per line. Blank lines are skipped. Unknown properties are
+// rejected, so that typos don't go unnoticed.
+func importNDJSON(tx *cdb.Tx, f io.Reader) error {
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 0, 64*1024), maxNDJSONLine)
+
+	line := 0
+
+	for s.Scan() {
+		line++
+
+		text := bytes.TrimSpace(s.Bytes())
+
+		if len(text) == 0 {
+			continue
 		}
 
-		// Read the stream of data, decoding Connection JSON payloads as we go
-		for d.More() {
-			c := cdb.NewConnection()
+		var r exchange.Connection
 
-			err := d.Decode(&c)
+		d := json.NewDecoder(bytes.NewReader(text))
+		d.DisallowUnknownFields()
 
-			if err != nil {
-				return err
+		if err := d.Decode(&r); err != nil {
+			return importError(line, err)
+		}
+
+		if d.More() {
+			return importError(line, ErrImportInvalidDocument)
+		}
+
+		if err := importConnection(tx, r.ToConnection()); err != nil {
+			return importError(line, err)
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return importError(line+1, err)
+	}
+
+	return nil
+}
+
+// importYAML imports program defaults and connections from a YAML document.
+// The document is either a mapping with the document envelope keys (as
+// written by export) or a bare list of connections.
+func importYAML(tx *cdb.Tx, f io.Reader) error {
+	var doc yaml.Node
+
+	err := yaml.NewDecoder(f).Decode(&doc)
+
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return importError(0, err)
+	}
+
+	root := doc.Content[0]
+
+	if root.Kind == yaml.SequenceNode {
+		return importYAMLConnections(tx, root)
+	}
+
+	if root.Kind != yaml.MappingNode {
+		return importError(root.Line, ErrImportInvalidDocument)
+	}
+
+	// Import defaults before connections, regardless of document order
+	var connections *yaml.Node
+
+	for i := 0; i < len(root.Content); i += 2 {
+		key := root.Content[i]
+		value := root.Content[i+1]
+
+		switch key.Value {
+		case "format_version":
+			var version int
+
+			if err := value.Decode(&version); err != nil {
+				return importError(value.Line, err)
 			}
 
-			// See if the nickname exists. If it does, we'll do an update.
-			exists, err := db.ExistsByProperty("nickname", c.Nickname)
+			if err := exchange.CheckVersion(version); err != nil {
+				return importError(value.Line, err)
+			}
+		case "schema_version":
+		case "defaults":
+			defs := make(map[string]string)
 
-			if err != nil {
-				return err
+			if err := value.Decode(&defs); err != nil {
+				return importError(value.Line, err)
 			}
 
-			if exists {
-				newCn := c
+			if err := importDefaults(tx, defs); err != nil {
+				return importError(value.Line, err)
+			}
+		case "connections":
+			connections = value
+		default:
+			return importError(key.Line, fmt.Errorf("%w: %s", ErrImportInvalidKey, key.Value))
+		}
+	}
 
-				c, err = db.GetByProperty("nickname", c.Nickname)
+	if connections == nil {
+		return nil
+	}
 
-				// If we found the connection by nickname but couldn't actually retrieve
-				// it, something is really wrong
-				if err != nil {
-					return err
-				}
+	return importYAMLConnections(tx, connections)
+}
 
-				// Update existing connection properties with those from the decoded
-				// json object.
-				c.Nickname = newCn.Nickname
-				c.Host = newCn.Host
-				c.User = newCn.User
-				c.Description = newCn.Description
-				c.Args = newCn.Args
-				c.Identity = newCn.Identity
-				c.Command = newCn.Command
+// importYAMLConnections imports connections from a YAML sequence node.
+func importYAMLConnections(tx *cdb.Tx, node *yaml.Node) error {
+	if node.Kind != yaml.SequenceNode {
+		return importError(node.Line, ErrImportInvalidDocument)
+	}
 
-				fmt.Printf("Updating existing connection '%s' (%d)...\n", c.Nickname, c.Id)
+	for _, item := range node.Content {
+		if item.Kind != yaml.MappingNode {
+			return importError(item.Line, ErrImportInvalidDocument)
+		}
 
-				// Run smoke test on connection properties
-				err = c.Validate()
+		// Reject unknown properties, so that typos don't go unnoticed
+		for i := 0; i < len(item.Content); i += 2 {
+			if !slices.Contains(connectionRecordHeader, item.Content[i].Value) {
+				return importError(item.Content[i].Line,
+					fmt.Errorf("%w: %s", ErrImportInvalidKey, item.Content[i].Value))
+			}
+		}
 
-				if err != nil {
-					return err
-				}
+		var r exchange.Connection
 
-				// Update connection
+		if err := item.Decode(&r); err != nil {
+			return importError(item.Line, err)
+		}
 
-				err = c.Update()
+		if err := importConnection(tx, r.ToConnection()); err != nil {
+			return importError(item.Line, err)
+		}
+	}
 
-				if err != nil {
-					return err
-				}
-			} else {
-				fmt.Printf("Importing new connection '%s'...\n", c.Nickname)
+	return nil
+}
 
-				// Run smoke test on connection properties
-				err = c.Validate()
+// importTOML imports program defaults and connections from a TOML document,
+// with a defaults table and a connections table array (as written by export).
+func importTOML(tx *cdb.Tx, f io.Reader) error {
+	data, err := io.ReadAll(f)
 
-				if err != nil {
-					return err
-				}
+	if err != nil {
+		return err
+	}
 
-				// Add connection
-				id, err := db.Add(&c)
+	var doc exchange.Document
 
-				if err != nil {
-					return err
-				}
+	md, err := toml.Decode(string(data), &doc)
 
-				fmt.Printf("Added new connection '%s' (%d).\n", c.Nickname, id)
-			}
+	if err != nil {
+		var pe toml.ParseError
+
+		if errors.As(err, &pe) {
+			return importError(pe.Position.Line, err)
 		}
+
+		return importError(0, err)
 	}
 
-	db.Close()
+	// Documents written before the format version was added don't have one
+	if md.IsDefined("format_version") {
+		if err := exchange.CheckVersion(doc.FormatVersion); err != nil {
+			return importError(tomlKeyLine(data, toml.Key{"format_version"}), err)
+		}
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return importError(tomlKeyLine(data, undecoded[0]),
+			fmt.Errorf("%w: %s", ErrImportInvalidKey, undecoded[0]))
+	}
+
+	// The toml decoder doesn't report positions, so find the line each
+	// connection starts on by looking for its table array header.
+	var lines []int
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+
+	for line := 1; s.Scan(); line++ {
+		if tomlConnectionsHeader.MatchString(s.Text()) {
+			lines = append(lines, line)
+		}
+	}
+
+	if err := importDefaults(tx, doc.Defaults); err != nil {
+		return importError(tomlKeyLine(data, toml.Key{"defaults"}), err)
+	}
+
+	for i, r := range doc.Connections {
+		line := 0
+
+		if i < len(lines) {
+			line = lines[i]
+		}
+
+		if err := importConnection(tx, r.ToConnection()); err != nil {
+			return importError(line, err)
+		}
+	}
 
 	return nil
 }
 
+// tomlKeyLine returns the line of a toml document on which key is first set, or
+// is the header of a table, or 0 if it can't be found. The toml decoder
+// doesn't report positions, so only the last part of the key is looked for
+// when it is set.
+func tomlKeyLine(data []byte, key toml.Key) int {
+	if len(key) < 1 {
+		return 0
+	}
+
+	name := regexp.QuoteMeta(key[len(key)-1])
+	re := regexp.MustCompile(`^\s*(["']?` + name + `["']?\s*=|\[+\s*` + regexp.QuoteMeta(key.String()) + `\s*\])`)
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+
+	for line := 1; s.Scan(); line++ {
+		if re.MatchString(s.Text()) {
+			return line
+		}
+	}
+
+	return 0
+}
+
 func init() {
 	rootCmd.AddCommand(importCmd)
 
 	// Command flags
-	importCmd.PersistentFlags().StringVar(&importFmt, "format", "csv", "Export format. Valid formats: csv or json.")
-	importCmd.PersistentFlags().StringVarP(&importPath, "path", "f", "", "Export destination path.")
+	importCmd.PersistentFlags().StringVar(&importFmt, "format", "csv", "Import format. Valid formats: csv, json, ndjson, yaml, toml, ansible, putty or remmina.")
+	importCmd.PersistentFlags().StringVarP(&importPath, "path", "f", "", "Import source path.")
+	importCmd.PersistentFlags().StringVar(&importMap, "map", "", "Map JSON fields to connection properties (ex. nickname=$.name,host=$.ip).")
+	importCmd.PersistentFlags().StringVar(&importRoot, "root", "$", "JSONPath selecting the items to import with --map.")
+	importCmd.PersistentFlags().BoolVar(&importPreview, "preview", false, "Print the connections mapped with --map without importing them.")
+
+	importCmd.RegisterFlagCompletionFunc("format", completeValues(importFormats...))
+}
+
+// importAnsible imports hosts from an Ansible inventory file in INI or YAML
+// format. Group membership is recorded in the connection description.
+func importAnsible(tx *cdb.Tx, f io.Reader) error {
+	inv, err := ansible.Read(f)
+
+	if err != nil {
+		return importError(0, err)
+	}
+
+	for _, h := range inv.Hosts() {
+		c := ansible.ToConnection(h, inv.EffectiveVars(h))
+
+		if groups := inv.HostGroups(h); len(groups) > 0 {
+			c.Description = "Ansible groups: " + strings.Join(groups, ", ")
+		}
+
+		if err := importConnection(tx, c); err != nil {
+			return importError(0, fmt.Errorf("host %s: %w", h, err))
+		}
+	}
+
+	return nil
+}
+
+// importPuTTY imports saved sessions from a PuTTY registry export.
+func importPuTTY(tx *cdb.Tx, f io.Reader) error {
+	sess, err := sessions.ReadPuTTY(f)
+
+	if err != nil {
+		return importError(0, err)
+	}
+
+	for _, s := range sess {
+		if err := importConnection(tx, s.ToConnection()); err != nil {
+			return importError(0, fmt.Errorf("session %s: %w", s.Name, err))
+		}
+	}
+
+	return nil
+}
+
+// importRemmina imports a Remmina connection file or, if f is a directory,
+// every .remmina file in it. The Remmina group is recorded in the connection
+// description.
+func importRemmina(tx *cdb.Tx, f *os.File) error {
+	info, err := f.Stat()
+
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return importRemminaFile(tx, f, f.Name())
+	}
+
+	paths, err := filepath.Glob(filepath.Join(f.Name(), "*.remmina"))
+
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		rf, err := os.Open(path)
+
+		if err != nil {
+			return err
+		}
+
+		err = importRemminaFile(tx, rf, path)
+		rf.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importRemminaFile imports a single Remmina connection file. Connections
+// without a name are nicknamed after their file.
+func importRemminaFile(tx *cdb.Tx, r io.Reader, path string) error {
+	s, ok, err := sessions.ReadRemmina(r)
+
+	if err != nil {
+		return importError(0, fmt.Errorf("%s: %w", path, err))
+	}
+
+	if !ok {
+		fmt.Fprintf(os.Stderr, "warning: skipping %s, which is not an ssh connection\n", path)
+		return nil
+	}
+
+	if len(s.Name) < 1 {
+		s.Name = strings.TrimSuffix(filepath.Base(path), ".remmina")
+	}
+
+	c := s.ToConnection()
+
+	if len(s.Group) > 0 {
+		c.Description = "Remmina group: " + s.Group
+	}
+
+	if err := importConnection(tx, c); err != nil {
+		return importError(0, fmt.Errorf("%s: %w", path, err))
+	}
+
+	return nil
+}
+
+// mapJSON reads a JSON document and maps its items to connections, using the
+// field mapping passed with --map. Items are selected with the --root path:
+// if it selects a single array, its elements are the items, otherwise each
+// selected value is an item. Properties that aren't mapped, or whose path
+// doesn't match anything, are left empty.
+func mapJSON(f io.Reader) ([]*cdb.Connection, error) {
+	fields, err := jsonpath.ParseMapping(importMap)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range fields {
+		if !cdb.IsValidProperty(field.Name) {
+			return nil, fmt.Errorf("%w: %s", cdb.ErrInvalidConnectionProperty, field.Name)
+		}
+	}
+
+	root, err := jsonpath.Parse(importRoot)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d := json.NewDecoder(f)
+	d.UseNumber()
 
+	var doc any
+
+	if err := d.Decode(&doc); err != nil {
+		return nil, importError(0, err)
+	}
+
+	items := root.Get(doc)
+
+	if len(items) == 1 {
+		if a, ok := items[0].([]any); ok {
+			items = a
+		}
+	}
+
+	var cns []*cdb.Connection
+
+	for _, item := range items {
+		c := cdb.NewConnection()
+
+		for _, field := range fields {
+			value := ""
+
+			if v := field.Path.Get(item); len(v) > 0 {
+				value = jsonpath.Format(v[0])
+			}
+
+			switch field.Name {
+			case "nickname":
+				c.Nickname = value
+			case "host":
+				c.Host = value
+			case "user":
+				c.User = value
+			case "description":
+				c.Description = value
+			case "args":
+				c.Args = value
+			case "identity":
+				c.Identity = value
+			case "command":
+				c.Command = value
+			case "jump":
+				c.Jump = value
+			case "pre_hook":
+				c.PreHook = value
+			case "post_hook":
+				c.PostHook = value
+			case "range":
+				c.Range = value
+			}
+		}
+
+		cns = append(cns, &c)
+	}
+
+	return cns, nil
+}
+
+// importJSONMapped imports connections from a JSON document of any shape,
+// using the field mapping passed with --map.
+func importJSONMapped(tx *cdb.Tx, f io.Reader) error {
+	cns, err := mapJSON(f)
+
+	if err != nil {
+		return err
+	}
+
+	for i, c := range cns {
+		if err := importConnection(tx, *c); err != nil {
+			return importError(0, fmt.Errorf("item %d: %w", i+1, err))
+		}
+	}
+
+	return nil
+}
+
+// previewMappedJSON prints the connections mapped from a JSON document with
+// --map, without importing them. The connection DB isn't touched.
+func previewMappedJSON(f io.Reader) error {
+	if len(importMap) < 1 {
+		return ErrImportPreviewNoMap
+	}
+
+	cns, err := mapJSON(f)
+
+	if err != nil {
+		return err
+	}
+
+	// Mapped connections don't have an id yet
+	if len(listColumns) < 1 {
+		listColumns = slices.DeleteFunc(slices.Clone(connectionRecordHeader), func(col string) bool {
+			return col == "id"
+		})
+	}
+
+	listConnections(cns, true)
+
+	return nil
 }
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/cannable/sshcm/pkg/sshargs"
+)
+
+// jumpNicknames resolves the comma-separated ids and nicknames passed with
+// --jump to the nicknames of the jump host connections, as stored in the
+// connection DB.
+func jumpNicknames(ctx context.Context, value string) (string, error) {
+	var nicknames []string
+
+	for _, arg := range strings.Split(value, ",") {
+		arg = strings.TrimSpace(arg)
+
+		if len(arg) < 1 {
+			continue
+		}
+
+		c, err := db.GetByIdOrNicknameContext(ctx, arg)
+
+		if err != nil {
+			return "", err
+		}
+
+		nicknames = append(nicknames, c.Nickname)
+	}
+
+	return cdb.JoinJumpHosts(nicknames), nil
+}
+
+// jumpOptions returns the ssh options that reach the passed connection through
+// its chain of jump hosts (see sshargs.JumpOptions). Each jump host is
+// resolved (see resolveConnection).
+func jumpOptions(ctx context.Context, c cdb.Connection) ([]sshargs.Option, error) {
+	chain, err := db.JumpChainContext(ctx, c)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var hops []sshargs.Hop
+
+	for _, hop := range chain {
+		if err := resolveConnection(ctx, &hop); err != nil {
+			return nil, err
+		}
+
+		split, err := sshargs.Split(hop.Args)
+
+		if err != nil {
+			return nil, err
+		}
+
+		opts, _, err := sshargs.Parse(split)
+
+		if err != nil {
+			return nil, err
+		}
+
+		hops = append(hops, sshargs.Hop{
+			Host:     hop.Host,
+			User:     hop.User,
+			Identity: hop.Identity,
+			Options:  opts,
+		})
+	}
+
+	return sshargs.JumpOptions(hops), nil
+}
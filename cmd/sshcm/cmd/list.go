@@ -1,41 +1,172 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/cannable/sshcm/pkg/exchange"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
+// defaultListColumns contains the columns shown in list output, unless wide
+// output or specific columns were requested.
+var defaultListColumns = []string{"id", "nickname", "user", "host", "description"}
+
+// colorModes contains the valid values for the --color flag.
+var colorModes = []string{"auto", "always", "never"}
+
 // listCmd represents the list command
 var (
-	listAll bool
+	listAll     bool
+	listColumns []string
+	listSort    string
+	listColor   string
+	listLimit   int
+	listOffset  int
 
 	listCmd = &cobra.Command{
 		Use:   "list",
 		Short: "List all connections",
 		Long: `
-List all connections.`,
+List all connections.
+
+Columns are sized to fit their content and the terminal. Pass --columns to pick
+which columns are shown (and in what order) and --sort to sort by a column.
+Prefix the sort column with "-" to sort in descending order.
+
+Pass --limit and --offset to page through a large connection DB. When output is
+paged, the total number of connections is printed to stderr.`,
 		Example: `
-sshcm list`,
+sshcm list
+sshcm list --columns nickname,host --sort host
+sshcm list --sort -id
+sshcm list --sort nickname --limit 20 --offset 40`,
 		Aliases: []string{"l"},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return validateListFlags()
+		},
 		Run: func(cmd *cobra.Command, args []string) {
-			db = openDb()
+			ctx := cmd.Context()
+
+			db = openDb(ctx)
 
 			// Get all connections
-			cns, err := db.GetAll()
+			cns, total, err := db.List(ctx, listOptions(""))
 
 			if err != nil {
 				panic(err)
 			}
 
 			listConnections(cns, listAll)
+			listTotal(len(cns), total)
 
 			db.Close()
 		},
 	}
 )
 
+// columnHeading returns the table heading for the passed column name.
+func columnHeading(col string) string {
+	if col == "id" {
+		return "ID"
+	}
+
+	return strings.ToUpper(col[:1]) + strings.ReplaceAll(col[1:], "_", " ")
+}
+
+// connectionField returns the value of the passed column for a connection.
+func connectionField(c *cdb.Connection, col string) string {
+	i := slices.Index(connectionRecordHeader, col)
+
+	return connectionRow(exchange.FromConnection(c))[i]
+}
+
+// listOptions returns the connection DB list options for the passed search
+// filter and the --sort, --limit and --offset flags shared by the list and
+// search commands.
+func listOptions(filter string) cdb.ListOptions {
+	return cdb.ListOptions{
+		Filter: filter,
+		SortBy: strings.TrimPrefix(listSort, "-"),
+		Desc:   strings.HasPrefix(listSort, "-"),
+		Limit:  listLimit,
+		Offset: listOffset,
+	}
+}
+
+// listTotal prints the number of connections shown out of the total to
+// stderr, if the output was paged with --limit or --offset.
+func listTotal(shown int, total int) {
+	if listLimit < 1 && listOffset < 1 {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Showing %d of %d connections.\n", shown, total)
+}
+
+// useColor returns true if table output should be colourised. In auto mode,
+// colour is used when stdout is a terminal and NO_COLOR is not set.
+func useColor() bool {
+	switch listColor {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// validateListFlags checks the --columns, --sort, --color, --limit and
+// --offset flags shared by the list and search commands.
+func validateListFlags() error {
+	for _, col := range listColumns {
+		if !slices.Contains(connectionRecordHeader, col) {
+			return ErrInvalidColumn
+		}
+	}
+
+	// Connections can be sorted by id or any text property
+	sortBy := strings.TrimPrefix(listSort, "-")
+
+	if len(sortBy) > 0 && sortBy != "id" && !cdb.IsValidProperty(sortBy) {
+		return ErrInvalidColumn
+	}
+
+	if !slices.Contains(colorModes, listColor) {
+		return ErrInvalidColorMode
+	}
+
+	if listLimit < 0 || listOffset < 0 {
+		return cdb.ErrInvalidListRange
+	}
+
+	return nil
+}
+
+// addListFlags registers the flags shared by the list and search commands.
+func addListFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().BoolVarP(&listAll, "all", "a", false, "List all connection details (wide output).")
+	cmd.PersistentFlags().StringSliceVar(&listColumns, "columns", nil, "Comma-separated list of columns to show. Valid columns: "+strings.Join(connectionRecordHeader, ", ")+".")
+	cmd.PersistentFlags().StringVar(&listSort, "sort", "", "Column to sort by. Prefix with '-' for descending order.")
+	cmd.PersistentFlags().StringVar(&listColor, "color", "auto", "Colorize table output. Valid modes: auto, always or never.")
+	cmd.PersistentFlags().IntVar(&listLimit, "limit", 0, "Maximum number of connections to show (0 for no limit).")
+	cmd.PersistentFlags().IntVar(&listOffset, "offset", 0, "Number of connections to skip.")
+
+	cmd.RegisterFlagCompletionFunc("color", completeValues(colorModes...))
+}
+
 func init() {
 	rootCmd.AddCommand(listCmd)
 
 	// Command flags
-	listCmd.PersistentFlags().BoolVarP(&listAll, "all", "a", false, "List all connection details (wide output).")
+	addListFlags(listCmd)
 }
@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/cannable/sshcm/pkg/exchange"
+	"gopkg.in/yaml.v3"
+)
+
+// outputFormats contains the valid values for the --output flag. The template
+// format is passed as "template=<Go template>".
+var outputFormats = []string{"table", "json", "yaml", "csv", "tsv", "template"}
+
+var (
+	outputFlag     string
+	outputFmt      string
+	outputTemplate *template.Template
+)
+
+// connectionRecordHeader is the header row for connections in csv and tsv
+// output.
+var connectionRecordHeader = []string{
+	"id",
+	"nickname",
+	"user",
+	"host",
+	"description",
+	"args",
+	"identity",
+	"command",
+	"jump",
+	"pre_hook",
+	"post_hook",
+	"record",
+	"range",
+}
+
+// A defaultRecord is the machine-readable representation of a program default
+// setting. Templates are executed against it, so its fields are available as
+// {{.Setting}} and {{.Value}}.
+type defaultRecord struct {
+	Setting string
+	Value   string
+}
+
+// sessionRecordHeader is the header row for sessions in csv and tsv output.
+var sessionRecordHeader = []string{
+	"id",
+	"connection_id",
+	"nickname",
+	"started",
+	"ended",
+	"duration",
+	"exit_status",
+	"recording",
+}
+
+// A sessionRecord is the machine-readable representation of a recorded
+// session. Times are in RFC 3339 format and the duration is in seconds.
+type sessionRecord struct {
+	Id           int64   `json:"id" yaml:"id"`
+	ConnectionId int64   `json:"connection_id" yaml:"connection_id"`
+	Nickname     string  `json:"nickname" yaml:"nickname"`
+	Started      string  `json:"started" yaml:"started"`
+	Ended        string  `json:"ended" yaml:"ended"`
+	Duration     float64 `json:"duration" yaml:"duration"`
+	ExitStatus   int     `json:"exit_status" yaml:"exit_status"`
+	Recording    string  `json:"recording" yaml:"recording"`
+}
+
+// connectionRow returns a connection as a csv/tsv row, in
+// connectionRecordHeader order.
+func connectionRow(r exchange.Connection) []string {
+	return []string{
+		fmt.Sprintf("%d", r.Id),
+		r.Nickname,
+		r.User,
+		r.Host,
+		r.Description,
+		r.Args,
+		r.Identity,
+		r.Command,
+		r.Jump,
+		r.PreHook,
+		r.PostHook,
+		strconv.FormatBool(r.Record),
+		r.Range,
+	}
+}
+
+// parseOutputFlag validates the --output flag and stores the selected format
+// in outputFmt. If a template was passed, it is parsed into outputTemplate.
+func parseOutputFlag() error {
+	format, text, hasText := strings.Cut(outputFlag, "=")
+
+	if !slices.Contains(outputFormats, format) {
+		return ErrInvalidOutputFormat
+	}
+
+	// Only the template format takes (and requires) a value
+	if (format == "template") != hasText {
+		return ErrInvalidOutputFormat
+	}
+
+	if format == "template" {
+		tmpl, err := template.New("output").Parse(text)
+
+		if err != nil {
+			return err
+		}
+
+		outputTemplate = tmpl
+	}
+
+	outputFmt = format
+
+	return nil
+}
+
+// writeOutput writes data in the selected structured output format.
+//
+// data is encoded as-is for json and yaml output. header and rows are used for
+// csv and tsv output. For template output, the template is executed once for
+// each item, followed by a newline.
+//
+// The table format is not handled here, as each command has its own layout.
+func writeOutput(w io.Writer, data any, header []string, rows [][]string, items []any) error {
+	switch outputFmt {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(data)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+
+		if err := enc.Encode(data); err != nil {
+			return err
+		}
+
+		return enc.Close()
+	case "csv", "tsv":
+		cw := csv.NewWriter(w)
+
+		if outputFmt == "tsv" {
+			cw.Comma = '\t'
+		}
+
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+
+		return cw.Error()
+	case "template":
+		for _, item := range items {
+			if err := outputTemplate.Execute(w, item); err != nil {
+				return err
+			}
+
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeConnections writes the passed connections in the selected structured
+// output format. Lists are always written as a list, even if they're empty.
+//
+// Connections are written in their exported form (exchange.Connection), so
+// templates can use its fields as {{.Nickname}}, {{.Host}}, etc.
+func writeConnections(w io.Writer, cns []*cdb.Connection) error {
+	records := []exchange.Connection{}
+	var rows [][]string
+	var items []any
+
+	for _, c := range cns {
+		r := exchange.FromConnection(c)
+
+		records = append(records, r)
+		rows = append(rows, connectionRow(r))
+		items = append(items, r)
+	}
+
+	return writeOutput(w, records, connectionRecordHeader, rows, items)
+}
+
+// writeConnection writes a single connection in the selected structured output
+// format. Unlike writeConnections, json and yaml output is a single object.
+func writeConnection(w io.Writer, c *cdb.Connection) error {
+	r := exchange.FromConnection(c)
+
+	return writeOutput(w, r, connectionRecordHeader, [][]string{connectionRow(r)}, []any{r})
+}
+
+// writeDefaults writes the passed program default settings in the selected
+// structured output format. json and yaml output is an object keyed by
+// setting name.
+func writeDefaults(w io.Writer, defs []defaultRecord) error {
+	data := make(map[string]string)
+	var rows [][]string
+	var items []any
+
+	for _, d := range defs {
+		data[d.Setting] = d.Value
+		rows = append(rows, []string{d.Setting, d.Value})
+		items = append(items, d)
+	}
+
+	return writeOutput(w, data, []string{"setting", "value"}, rows, items)
+}
+
+// writeSessions writes the passed sessions in the selected structured output
+// format. Lists are always written as a list, even if they're empty.
+func writeSessions(w io.Writer, sessions []cdb.Session) error {
+	records := []sessionRecord{}
+	var rows [][]string
+	var items []any
+
+	for _, s := range sessions {
+		r := sessionRecord{
+			Id:           s.Id,
+			ConnectionId: s.ConnectionId,
+			Nickname:     s.Nickname,
+			Started:      s.Started.Format(time.RFC3339),
+			Ended:        s.Ended.Format(time.RFC3339),
+			Duration:     s.Duration().Seconds(),
+			ExitStatus:   s.ExitStatus,
+			Recording:    s.Recording,
+		}
+
+		records = append(records, r)
+		items = append(items, r)
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", r.Id),
+			fmt.Sprintf("%d", r.ConnectionId),
+			r.Nickname,
+			r.Started,
+			r.Ended,
+			fmt.Sprintf("%.0f", r.Duration),
+			fmt.Sprintf("%d", r.ExitStatus),
+			r.Recording,
+		})
+	}
+
+	return writeOutput(w, records, sessionRecordHeader, rows, items)
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cannable/sshcm/pkg/asciicast"
+	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/creack/pty"
+	"golang.org/x/term"
+)
+
+// recordingTimeFormat is the timestamp in recording file names.
+const recordingTimeFormat = "20060102-150405"
+
+// stdinChunks carries what's typed at the terminal to the recorded session's
+// pty. Stdin is read by a single goroutine for the life of the process, so
+// that a session doesn't lose input to a reader left over from the last one.
+var (
+	stdinChunks  chan []byte
+	stdinPumping sync.Once
+)
+
+// pumpStdin starts reading stdin into stdinChunks, if it hasn't been started
+// already.
+func pumpStdin() {
+	stdinPumping.Do(func() {
+		stdinChunks = make(chan []byte)
+
+		go func() {
+			for {
+				buf := make([]byte, 1024)
+				n, err := os.Stdin.Read(buf)
+
+				if n > 0 {
+					stdinChunks <- buf[:n]
+				}
+
+				if err != nil {
+					close(stdinChunks)
+					return
+				}
+			}
+		}()
+	})
+}
+
+// recordDir returns the directory recordings are written to: the record_dir
+// program default, or a per-user sshcm/recordings directory in
+// $XDG_STATE_HOME or, if it isn't set, the user config directory (ex.
+// ~/.config/sshcm/recordings). Recordings can hold secrets, so they aren't
+// kept next to the connection DB, which may be shared (ex. in a dotfiles
+// repo).
+func recordDir(ctx context.Context) (string, error) {
+	dir, err := db.GetDefaultContext(ctx, "record_dir")
+
+	if err != nil || len(dir) > 0 {
+		return dir, err
+	}
+
+	if state := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(state) {
+		return filepath.Join(state, "sshcm", "recordings"), nil
+	}
+
+	config, err := os.UserConfigDir()
+
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(config, "sshcm", "recordings"), nil
+}
+
+// createRecording creates the recording file for a session of the passed
+// connection starting now, named by nickname and timestamp (ex.
+// web-20260501-120000.cast), and writes its header. Recordings can hold
+// secrets typed at the terminal, so only the current user can read them.
+func createRecording(dir string, c cdb.Connection, started time.Time) (*os.File, *asciicast.Writer, error) {
+	// The path is kept in the session record, so it shouldn't depend on where
+	// sshcm was run from
+	dir, err := filepath.Abs(dir)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, nil, err
+	}
+
+	name := fmt.Sprintf("%s-%s.cast", safeFileName(c.Nickname), started.Format(recordingTimeFormat))
+
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	width, height := terminalSize()
+
+	rec, err := asciicast.NewWriter(f, asciicast.Header{
+		Width:     width,
+		Height:    height,
+		Timestamp: started.Unix(),
+		Title:     c.Nickname,
+		Env: map[string]string{
+			"SHELL": os.Getenv("SHELL"),
+			"TERM":  os.Getenv("TERM"),
+		},
+	})
+
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	return f, rec, nil
+}
+
+// terminalSize returns the size of the terminal sshcm is running in, or 80x24
+// if it isn't running in one.
+func terminalSize() (int, int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+
+	if err != nil || width < 1 || height < 1 {
+		return 80, 24
+	}
+
+	return width, height
+}
+
+// startRecorded starts ssh under a pty, passing the terminal through to it
+// and recording its output with rec. The returned function waits for ssh to
+// exit and for its output to be written, then puts the terminal back the way
+// it was.
+//
+// The terminal is put in raw mode while ssh runs, so keys like Ctrl-C are sent
+// to ssh rather than handled by the local terminal.
+func startRecorded(exe *exec.Cmd, rec *asciicast.Writer) (func() error, error) {
+	width, height := terminalSize()
+
+	ptmx, err := pty.StartWithSize(exe, &pty.Winsize{Cols: uint16(width), Rows: uint16(height)})
+
+	if err != nil {
+		return nil, err
+	}
+
+	restore := func() {}
+
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		if state, err := term.MakeRaw(fd); err == nil {
+			restore = func() { term.Restore(fd, state) }
+		}
+	}
+
+	// Follow the terminal's size
+	stopResize := watchResize(func() {
+		width, height := terminalSize()
+
+		pty.Setsize(ptmx, &pty.Winsize{Cols: uint16(width), Rows: uint16(height)})
+		rec.Resize(width, height)
+	})
+
+	// Input goes to ssh until it exits
+	exited := make(chan struct{})
+
+	pumpStdin()
+
+	go func() {
+		for {
+			select {
+			case b, ok := <-stdinChunks:
+				if !ok {
+					return
+				}
+
+				ptmx.Write(b)
+			case <-exited:
+				return
+			}
+		}
+	}()
+
+	// Output is copied until ssh (and anything it left running) closes the
+	// pty, which shows up as an error reading it
+	copied := make(chan struct{})
+
+	go func() {
+		io.Copy(io.MultiWriter(os.Stdout, rec), ptmx)
+		close(copied)
+	}()
+
+	return func() error {
+		err := exe.Wait()
+
+		close(exited)
+		stopResize()
+
+		// Don't wait forever on processes that keep the pty open
+		select {
+		case <-copied:
+		case <-time.After(time.Second):
+		}
+
+		ptmx.Close()
+		restore()
+
+		return err
+	}, nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package cmd
+
+// watchResize does nothing, as terminal resizes aren't signalled on this
+// platform.
+func watchResize(fn func()) func() {
+	return func() {}
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"github.com/cannable/sshcm/pkg/asciicast"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// replaySpeed multiplies the playback speed.
+	replaySpeed float64
+
+	// replayIdleLimit caps the pauses between output.
+	replayIdleLimit time.Duration
+)
+
+// replayCmd represents the replay command
+var replayCmd = &cobra.Command{
+	Use:   "replay file",
+	Short: "Play back a session recording",
+	Long: `
+Play back a session recorded by connect --record.
+
+The recording's output is written to the terminal with the timing it was
+recorded with. Pass --speed to play it faster (or slower), and --idle-limit to
+cut long pauses short. Recordings are asciicast v2 files, so they can also be
+played with asciinema.
+
+The sessions command lists the recording of each recorded session.`,
+	Example: `
+sshcm replay ~/.config/sshcm/recordings/prod-20260501-120000.cast
+sshcm replay prod-20260501-120000.cast --speed 2 --idle-limit 1s`,
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if replaySpeed <= 0 || replayIdleLimit < 0 {
+			return ErrInvalidReplayOptions
+		}
+
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		f, err := os.Open(args[0])
+
+		if err != nil {
+			bail(err)
+		}
+
+		defer f.Close()
+
+		r, err := asciicast.NewReader(f)
+
+		if err != nil {
+			bail(err)
+		}
+
+		err = asciicast.Play(cmd.Context(), os.Stdout, r, asciicast.PlayOptions{
+			Speed:     replaySpeed,
+			IdleLimit: replayIdleLimit,
+		})
+
+		if err != nil {
+			bail(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	// Command flags
+	replayCmd.Flags().Float64Var(&replaySpeed, "speed", 1, "Playback speed (ex. 2 for twice as fast)")
+	replayCmd.Flags().DurationVar(&replayIdleLimit, "idle-limit", 0, "Longest pause between output (ex. 1s, or 0 for no limit)")
+}
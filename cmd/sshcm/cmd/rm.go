@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 
 	"github.com/cannable/sshcm/pkg/cdb"
 	"github.com/spf13/cobra"
@@ -11,68 +12,88 @@ import (
 
 // removeCmd represents the remove command
 var removeCmd = &cobra.Command{
-	Use:   "remove { id | nickname }",
-	Short: "Remove a connection",
+	Use:   "remove { id | nickname }...",
+	Short: "Remove connections",
 	Long: `
-Remove a connection.
+Remove one or more connections.
 
-A valid connection ID or nickname must be specified.`,
+Valid connection IDs or nicknames must be specified. The connections are
+removed together: if any of them can't be removed, none are.
+
+A jump host can't be removed while other connections use it, unless they are
+removed along with it.`,
 	Example: `
 sshcm rm asdf
-sshcm delete 42`,
+sshcm delete 42
+sshcm rm web1 web2 web3`,
 	Aliases: []string{"rm", "delete", "del"},
 	Args: func(cmd *cobra.Command, args []string) error {
-		if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+		if err := cobra.MinimumNArgs(1)(cmd, args); err != nil {
 			return err
 		}
 
-		if !cdb.IsValidIdOrNickname(args[0]) {
-			return ErrNoIdOrNickname
+		for _, arg := range args {
+			if !cdb.IsValidIdOrNickname(arg) {
+				return ErrNoIdOrNickname
+			}
 		}
 
 		return nil
 	},
+	ValidArgsFunction: completeConnections(0, false),
 	Run: func(cmd *cobra.Command, args []string) {
-		db = openDb()
+		ctx := cmd.Context()
 
-		c, err := db.GetByIdOrNickname(args[0])
+		db = openDb(ctx)
 
-		if err != nil {
-			if errors.Is(err, cdb.ErrConnNoId) {
-				fmt.Fprintln(os.Stderr, "ID does not exist.")
-				os.Exit(1)
-			} else if errors.Is(err, cdb.ErrConnNoNickname) {
-				fmt.Fprintln(os.Stderr, "Nickname does not exist.")
-				os.Exit(1)
-			} else if errors.Is(err, cdb.ErrConnectionNotFound) {
-				fmt.Fprintln(os.Stderr, "Connection not found.")
-				os.Exit(1)
+		var cns []cdb.Connection
+
+		for _, arg := range args {
+			c, err := db.GetByIdOrNicknameContext(ctx, arg)
+
+			if err != nil {
+				if errors.Is(err, cdb.ErrConnNoId) {
+					fmt.Fprintln(os.Stderr, "ID does not exist:", arg)
+					os.Exit(1)
+				} else if errors.Is(err, cdb.ErrConnNoNickname) {
+					fmt.Fprintln(os.Stderr, "Nickname does not exist:", arg)
+					os.Exit(1)
+				} else if errors.Is(err, cdb.ErrConnectionNotFound) {
+					fmt.Fprintln(os.Stderr, "Connection not found:", arg)
+					os.Exit(1)
+				}
+				panic(err)
 			}
-			panic(err)
-		}
 
-		if debugMode {
-			fmt.Println("Deleting connection", c)
+			// Skip connections that were passed more than once
+			if !slices.ContainsFunc(cns, func(r cdb.Connection) bool { return r.Id == c.Id }) {
+				cns = append(cns, c)
+			}
 		}
 
-		// Delete connection
-		err = c.Delete()
+		autoBackup(ctx)
 
-		if err != nil {
-			panic(err)
-		}
+		// Delete connections
+		err := db.WithTxContext(ctx, func(tx *cdb.Tx) error {
+			for _, c := range cdb.RemovalOrder(cns) {
+				if debugMode {
+					fmt.Println("Deleting connection", c)
+				}
 
-		if err != nil {
-			if errors.Is(err, cdb.ErrConnNoId) {
-				fmt.Fprintln(os.Stderr, "ID does not exist.")
-				os.Exit(1)
-			} else if errors.Is(err, cdb.ErrConnNoNickname) {
-				fmt.Fprintln(os.Stderr, "Nickname does not exist.")
-				os.Exit(1)
+				if err := tx.Delete(c); err != nil {
+					return err
+				}
 			}
-			panic(err)
+
+			return nil
+		})
+
+		if err != nil {
+			bail(err)
 		}
 
+		syncSshConfig(ctx)
+
 		db.Close()
 	},
 }
@@ -1,16 +1,26 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"slices"
 	"strings"
+	"syscall"
 
+	"github.com/cannable/sshcm/pkg/asciicast"
 	"github.com/cannable/sshcm/pkg/cdb"
-	"github.com/cannable/sshcm/pkg/misc"
+	"github.com/cannable/sshcm/pkg/hooks"
+	"github.com/cannable/sshcm/pkg/jsonpath"
+	"github.com/cannable/sshcm/pkg/table"
+	"github.com/cannable/sshcm/pkg/tui"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"golang.org/x/term"
 )
 
 var (
@@ -24,6 +34,11 @@ var (
 	cmdCnArgs        string
 	cmdCnIdentity    string
 	cmdCnCommand     string
+	cmdCnJump        string
+	cmdCnPreHook     string
+	cmdCnPostHook    string
+	cmdCnRecord      bool
+	cmdCnRange       string
 	cmdCnSetFlags    []string
 
 	// rootCmd represents the base command when called without any subcommands
@@ -31,23 +46,97 @@ var (
 		Use:   "sshcm",
 		Short: "An SSH connection manager written in Go",
 		Long:  `A simple SSH manager, written in Go, that uses a Sqlite DB.`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return parseOutputFlag()
+		},
 	}
 )
 
 // accSetCnFlags accumulates passed pflag names and stores them in the global
-// slice cmdCnSetFlags.
+// slice cmdCnSetFlags. Flags are named like the connection properties they set,
+// with dashes in place of underscores (ex. --pre-hook sets pre_hook).
 func accSetCnFlags(f *pflag.Flag) {
-	if cdb.IsValidProperty(f.Name) {
-		cmdCnSetFlags = append(cmdCnSetFlags, f.Name)
+	name := strings.ReplaceAll(f.Name, "-", "_")
+
+	if slices.Contains(connectionRecordHeader, name) {
+		cmdCnSetFlags = append(cmdCnSetFlags, name)
 	}
 }
 
+// resolveConnection fills in any empty user, args, identity or command
+// settings in the passed connection with the program defaults from the
+// connection DB, then expands its placeholders (see cdb.Connection.Expand), so
+// that it's what connect would connect to. Pattern connections can't be
+// resolved, only their instances.
+func resolveConnection(ctx context.Context, c *cdb.Connection) error {
+	if c.IsPattern() {
+		first, _, _ := cdb.ParseRange(c.Range)
+
+		return fmt.Errorf("%w (ex. %s)", ErrPatternConnection, c.Instance(first).Nickname)
+	}
+
+	settings := map[string]*string{
+		"user":     &c.User,
+		"args":     &c.Args,
+		"identity": &c.Identity,
+		"command":  &c.Command,
+	}
+
+	for name, value := range settings {
+		if len(*value) > 0 {
+			continue
+		}
+
+		def, err := db.GetDefaultContext(ctx, name)
+
+		if err != nil {
+			return err
+		}
+
+		*value = def
+	}
+
+	expanded, err := c.Expand()
+
+	if err != nil {
+		return err
+	}
+
+	*c = expanded
+
+	return nil
+}
+
+// expandPatterns returns the passed connections with each pattern connection
+// replaced by its instances (see cdb.Connection.Instances).
+func expandPatterns(cns []*cdb.Connection) []*cdb.Connection {
+	var expanded []*cdb.Connection
+
+	for _, c := range cns {
+		for _, i := range c.Instances() {
+			expanded = append(expanded, &i)
+		}
+	}
+
+	return expanded
+}
+
 // bail reports somewhat-expected errors to the user in a "friendly" way.
 // If the passed error is known and originates from the cdb module, this
 // function will print the error to stderr and exit(1).
 // If the error was not known, the program will panic.
 func bail(err error) {
 	minorErrors := []error{
+		context.Canceled,
+		os.ErrNotExist,
+		asciicast.ErrInvalidEvent,
+		asciicast.ErrInvalidHeader,
+		asciicast.ErrUnsupportedVersion,
+		cdb.ErrArchiveFormatTooNew,
+		cdb.ErrArchiveInvalid,
+		cdb.ErrArchiveUnknownColumn,
+		cdb.ErrArchiveUnknownTable,
+		cdb.ErrBusy,
 		cdb.ErrConnNoDb,
 		cdb.ErrConnNoId,
 		cdb.ErrConnNoNickname,
@@ -57,12 +146,45 @@ func bail(err error) {
 		cdb.ErrInvalidConnectionProperty,
 		cdb.ErrInvalidDefault,
 		cdb.ErrInvalidId,
+		cdb.ErrInvalidListRange,
+		cdb.ErrInvalidPattern,
+		cdb.ErrInvalidPlaceholder,
+		cdb.ErrInvalidRange,
+		cdb.ErrInvalidSetting,
+		cdb.ErrJumpInUse,
+		cdb.ErrJumpLoop,
+		cdb.ErrJumpNotFound,
 		cdb.ErrNicknameLetter,
+		cdb.ErrPatternInstance,
+		cdb.ErrPlaceholderUnset,
 		cdb.ErrPropertyInvalid,
+		cdb.ErrSchemaTooNew,
 		cdb.ErrSchemaVerInvalid,
+		tui.ErrNotTerminal,
+		ErrExportNeedsDirectory,
+		ErrInvalidHookAbort,
+		ErrInvalidHookTimeout,
+		ErrImportInvalid,
+		ErrImportMapFormat,
+		ErrImportPreviewNoMap,
+		ErrInvalidExportFormat,
+		ErrInvalidImportFormat,
+		ErrInvalidReplayOptions,
+		ErrInvalidResolveFormat,
+		ErrNoPostgresDriver,
+		ErrPatternConnection,
+		ErrSshConfigDisable,
+		hooks.ErrHookFailed,
+		hooks.ErrHookTimeout,
+		jsonpath.ErrInvalidMapping,
+		jsonpath.ErrInvalidPath,
 	}
 
-	if slices.Contains(minorErrors, err) && !debugMode {
+	isMinor := slices.ContainsFunc(minorErrors, func(e error) bool {
+		return errors.Is(err, e)
+	})
+
+	if isMinor && !debugMode {
 		fmt.Fprintln(os.Stderr, "Error:", err)
 		os.Exit(1)
 	}
@@ -106,109 +228,169 @@ func getDbPath() string {
 	return filepath.Join(homePath, "/.config/"+dbFileName)
 }
 
-// listConnections prints the passed connections in list format to stdout.
+// listConnections prints the passed connections in list format to stdout, in
+// the order they were passed.
 //
-// wide controls whether all connection property columns are printed or a subset.
+// Table output shows the --columns columns, or a default set (all columns if
+// wide is true). The structured output formats always include every property.
 func listConnections(cns []*cdb.Connection, wide bool) {
-
-	// Print header
-	if wide {
-		// Long header
-		_, err := fmt.Fprintf(os.Stdout, "%s %s %s %s %s %s %s %s\n",
-			misc.StringTrimmer("ID", cdb.ListViewColumnWidths["id"]),
-			misc.StringTrimmer("Nickname", cdb.ListViewColumnWidths["nickname"]),
-			misc.StringTrimmer("User", cdb.ListViewColumnWidths["user"]),
-			misc.StringTrimmer("Host", cdb.ListViewColumnWidths["host"]),
-			misc.StringTrimmer("Description", cdb.ListViewColumnWidths["description"]),
-			misc.StringTrimmer("Args", cdb.ListViewColumnWidths["args"]),
-			misc.StringTrimmer("Identity", cdb.ListViewColumnWidths["identity"]),
-			misc.StringTrimmer("Command", cdb.ListViewColumnWidths["command"]),
-		)
+	if outputFmt != "table" {
+		err := writeConnections(os.Stdout, cns)
 
 		if err != nil {
 			bail(err)
 		}
-	} else {
-		// Short header
-		_, err := fmt.Fprintf(os.Stdout, "%s %s %s %s %s\n",
-			misc.StringTrimmer("ID", cdb.ListViewColumnWidths["id"]),
-			misc.StringTrimmer("Nickname", cdb.ListViewColumnWidths["nickname"]),
-			misc.StringTrimmer("User", cdb.ListViewColumnWidths["user"]),
-			misc.StringTrimmer("Host", cdb.ListViewColumnWidths["host"]),
-			misc.StringTrimmer("Description", cdb.ListViewColumnWidths["description"]),
-		)
 
-		if err != nil {
-			bail(err)
-		}
+		return
 	}
 
-	for _, c := range cns {
+	columns := listColumns
+
+	if len(columns) < 1 {
+		columns = defaultListColumns
+
+		// Pattern connections are listed once, with their range
+		if slices.ContainsFunc(cns, func(c *cdb.Connection) bool { return c.IsPattern() }) {
+			columns = append(slices.Clip(columns), "range")
+		}
+
 		if wide {
-			err := c.WriteLineLong(os.Stderr)
+			columns = connectionRecordHeader
+		}
+	}
 
-			if err != nil {
-				bail(err)
-			}
-		} else {
-			err := c.WriteLineShort(os.Stderr)
+	var header []string
 
-			if err != nil {
-				bail(err)
-			}
+	for _, col := range columns {
+		header = append(header, columnHeading(col))
+	}
+
+	t := table.New(header...)
+	t.Color = useColor()
 
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+			t.MaxWidth = width
 		}
+	}
+
+	for _, c := range cns {
+		var row []string
+
+		for _, col := range columns {
+			row = append(row, connectionField(c, col))
+		}
+
+		t.Append(row...)
+	}
+
+	err := t.Render(os.Stdout)
+
+	if err != nil {
+		bail(err)
+	}
+}
+
+// dbDriver returns the cdb driver for the passed connection DB location:
+// postgres for postgres:// and postgresql:// URLs, file for .json, .yaml and
+// .yml files, and sqlite for anything else.
+func dbDriver(path string) string {
+	if strings.HasPrefix(path, "postgres://") || strings.HasPrefix(path, "postgresql://") {
+		return "postgres"
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+		return "file"
+	}
+
+	return "sqlite"
+}
+
+// displayDbPath returns the connection DB location in a form that is safe to
+// show the user, with any password in a PostgreSQL URL masked.
+func displayDbPath(path string) string {
+	if dbDriver(path) != "postgres" {
+		return path
+	}
 
+	u, err := url.Parse(path)
+
+	if err != nil {
+		return path
 	}
 
+	return u.Redacted()
 }
 
-// openDb provides a simple wrapper around cdb.Open(). It calls getDbPath, then
-// checks whether the path exists or not. If the connection DB file does not
-// exist, it will print a message to stdout informing the user that one will
-// be created. It then calls cdb.Open().
-func openDb() cdb.ConnectionDB {
+// openDb provides a simple wrapper around cdb.Connect(). It calls getDbPath,
+// then picks a storage backend for the path (see dbDriver). If the connection
+// DB has not been set up yet, it will print a message to stderr informing the
+// user that one will be created.
+//
+// If another sshcm process is changing the connection DB, openDb (and later
+// changes) wait for it. If it doesn't finish in time, the command fails with
+// cdb.ErrBusy.
+//
+// PostgreSQL connection DBs need a database/sql driver, which is left out of
+// builds with the nopostgres tag (see cdb.PostgresDriver).
+func openDb(ctx context.Context) cdb.ConnectionDB {
 	path := getDbPath()
+	driver := dbDriver(path)
 
 	if debugMode {
-		fmt.Printf("Connection file path: '%s'\n", path)
+		fmt.Printf("Connection file path: '%s'\n", displayDbPath(path))
 	}
 
-	// See if calling Open will create a new DB file
-	create := false
-	if _, err := os.Stat(path); err != nil {
-		fmt.Printf("Connection file '%s' does not exist and will be created.\n", path)
-		create = true
+	if driver == "postgres" && len(cdb.PostgresDriver()) < 1 {
+		bail(ErrNoPostgresDriver)
 	}
 
-	db, err := cdb.Connect("sqlite", path)
+	db, err := cdb.Connect(driver, path)
 
 	if err != nil {
-		panic(err)
+		bail(err)
+	}
+
+	// See if the DB needs to be created
+	initialized, err := db.InitializedContext(ctx)
+
+	if err != nil {
+		bail(err)
+	}
+
+	create := !initialized
+
+	if create {
+		fmt.Fprintf(os.Stderr, "Connection file '%s' does not exist and will be created.\n", displayDbPath(path))
 	}
 
 	// Create tables, if we need to. If not, see if upgrade is needed
 	if create {
-		err = db.InitializeDb(cdb.SchemaVersion)
+		err = db.InitializeDbContext(ctx, cdb.SchemaVersion)
 
-		if err != nil {
-			panic(err)
+		// Another sshcm process may have just created it
+		if err != nil && !errors.Is(err, cdb.ErrStoreInitialized) {
+			bail(err)
 		}
 	} else {
 		// Can we use the DB?
-		err = db.CheckDbHealth()
+		err = db.CheckDbHealthContext(ctx)
 
 		if err != nil {
 			switch err {
 			case cdb.ErrSchemaUpgradeNeeded:
-				// TODO: Do schema upgrade, if needed
+				if err := db.UpgradeDbContext(ctx); err != nil {
+					bail(err)
+				}
+
+				fmt.Fprintf(os.Stderr, "Connection file '%s' was upgraded to schema %s.\n", displayDbPath(path), cdb.SchemaVersion)
 
-			case cdb.ErrSchemaTooNew:
-				// The schema version being too new for the tool is not a catastrophic
-				// error.
-				bail(err)
 			default:
-				panic(err)
+				// bail reports errors that aren't catastrophic (ex. the schema
+				// version being too new for the tool, or the DB being busy) and
+				// panics on the rest
+				bail(err)
 			}
 		}
 	}
@@ -238,14 +420,26 @@ func printConnection(c *cdb.Connection, printHeader bool) {
 // Execute adds all child commands to the root command and sets flags
 // appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+//
+// Commands are run with a context that is cancelled on SIGINT or SIGTERM, so
+// that interrupting a long-running command (ex. importing or exporting a large
+// connection DB) stops its connection DB queries.
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&connDbFilePath, "db", "", "Path to connection DB file (ssh-cm.connections).")
+	rootCmd.PersistentFlags().StringVar(&connDbFilePath, "db", "", "Path to connection DB file (ssh-cm.connections), or a PostgreSQL URL.")
 	rootCmd.PersistentFlags().BoolVarP(&debugMode, "verbose", "v", false, "Verbose output")
+	rootCmd.PersistentFlags().StringVarP(&outputFlag, "output", "o", "table", "Output format for read commands. Valid formats: table, json, yaml, csv, tsv or template=<Go template>.")
+
+	// Go templates can't be completed
+	rootCmd.RegisterFlagCompletionFunc("output", completeValues("table", "json", "yaml", "csv", "tsv"))
 }
@@ -20,22 +20,28 @@ Search references the following properties:
 - description`,
 	Aliases: []string{"f"},
 	Args:    cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateListFlags()
+	},
 	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
 		if debugMode {
 			fmt.Println("Searching for '", args[0]+"'")
 
 		}
 
-		db = openDb()
+		db = openDb(ctx)
 
 		// Get all connections
-		cns, err := db.Search(args[0])
+		cns, total, err := db.List(ctx, listOptions(args[0]))
 
 		if err != nil {
 			panic(err)
 		}
 
 		listConnections(cns, listAll)
+		listTotal(len(cns), total)
 
 		db.Close()
 	},
@@ -45,5 +51,5 @@ func init() {
 	rootCmd.AddCommand(searchCmd)
 
 	// Command flags
-	searchCmd.PersistentFlags().BoolVarP(&listAll, "all", "a", false, "List all connection details (wide output).")
+	addListFlags(searchCmd)
 }
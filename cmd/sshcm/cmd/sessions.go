@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/cannable/sshcm/pkg/table"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// sessionsLimit is the most sessions the sessions command prints.
+var sessionsLimit int
+
+// listSessions prints the passed sessions to stdout as a table.
+func listSessions(sessions []cdb.Session) {
+	if len(sessions) < 1 {
+		fmt.Println("No sessions have been recorded.")
+		return
+	}
+
+	t := table.New("ID", "Nickname", "Started", "Duration", "Exit status", "Recording")
+
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+			t.MaxWidth = width
+		}
+	}
+
+	for _, s := range sessions {
+		t.Append(
+			fmt.Sprintf("%d", s.Id),
+			s.Nickname,
+			s.Started.Local().Format(time.DateTime),
+			s.Duration().Round(time.Second).String(),
+			fmt.Sprintf("%d", s.ExitStatus),
+			s.Recording,
+		)
+	}
+
+	err := t.Render(os.Stdout)
+
+	if err != nil {
+		bail(err)
+	}
+}
+
+// sessionsCmd represents the sessions command
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions [ id | nickname ]",
+	Short: "List recorded sessions",
+	Long: `
+List sessions recorded by connect --supervise (or --reconnect), newest first.
+
+Pass a connection ID or nickname to only list that connection's sessions. An
+exit status of 255 means ssh couldn't connect, or the connection dropped.
+Sessions recorded with connect --record show where their recording was saved
+(see "sshcm replay").
+
+Pass --output to print the sessions in a machine-readable format.`,
+	Example: `
+sshcm sessions
+sshcm sessions tunnel --limit 5
+sshcm sessions --limit 0 --output csv`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.MaximumNArgs(1)(cmd, args); err != nil {
+			return err
+		}
+
+		if len(args) > 0 && !cdb.IsValidIdOrNickname(args[0]) {
+			return ErrNoIdOrNickname
+		}
+
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		db = openDb(ctx)
+
+		var id int64
+
+		if len(args) > 0 {
+			c, err := db.GetByIdOrNicknameContext(ctx, args[0])
+
+			if err != nil {
+				bail(err)
+			}
+
+			id = c.Id
+		}
+
+		sessions, err := db.SessionsContext(ctx, id, sessionsLimit)
+
+		if err != nil {
+			bail(err)
+		}
+
+		if outputFmt == "table" {
+			listSessions(sessions)
+		} else {
+			err = writeSessions(os.Stdout, sessions)
+
+			if err != nil {
+				bail(err)
+			}
+		}
+
+		db.Close()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sessionsCmd)
+
+	// Command flags
+	sessionsCmd.Flags().IntVar(&sessionsLimit, "limit", 20, "Most sessions to list (0 for all)")
+}
@@ -16,11 +16,32 @@ var setCmd = &cobra.Command{
 	Long: `Change connection settings.
 A valid ID or nickname must be specified.
 
-A connection can be renamed by passing --nickname="new_nickname".
+A connection can be renamed by passing --nickname="new_nickname". Connections
+that use it as a jump host are updated to match.
+
+Jump hosts are set with --jump, a comma-separated list of connection ids or
+nicknames. Pass --jump="" to connect directly.
+
+Hooks are set with --pre-hook and --post-hook. Pass an empty value to remove
+one.
+
+The host, user, identity, args and command can use placeholders (ex. ${USER} or
+{{.Nickname}}), expanded by connect. See "sshcm connect --help".
+
+Pass --record to record the terminal output of every session, and
+--record=false to stop.
+
+The numbers a pattern connection stands for are set with --range. The
+connections of a pattern can't be changed on their own; change the pattern
+instead. See "sshcm add --help".
 `,
 	Example: `
 sshcm set 42 --user="blarg"
-sshcm s asdf --nickname fdsa`,
+sshcm s asdf --nickname fdsa
+sshcm set internal --jump bastion,inner-bastion
+sshcm set prod --record
+sshcm set web --identity '~/.ssh/%r@%h'
+sshcm set 'web{n}' --range 1-60`,
 	Aliases: []string{"s"},
 	Args: func(cmd *cobra.Command, args []string) error {
 		if err := cobra.ExactArgs(1)(cmd, args); err != nil {
@@ -33,15 +54,18 @@ sshcm s asdf --nickname fdsa`,
 
 		return nil
 	},
+	ValidArgsFunction: completeConnections(1, false),
 	Run: func(cmd *cobra.Command, args []string) {
-		db = openDb()
+		ctx := cmd.Context()
+
+		db = openDb(ctx)
 
 		oldNickname := args[0]
 
 		cmd.Flags().Visit(accSetCnFlags)
 
 		// Look up connection
-		c, err := db.GetByIdOrNickname(oldNickname)
+		c, err := db.GetByIdOrNicknameContext(ctx, oldNickname)
 
 		if err != nil {
 			bail(err)
@@ -64,7 +88,7 @@ sshcm s asdf --nickname fdsa`,
 			}
 
 			// See if the new nickname exists already.
-			exists, err := db.ExistsByProperty("nickname", cmdCnNickname)
+			exists, err := db.ExistsByPropertyContext(ctx, "nickname", cmdCnNickname)
 
 			if err != nil {
 				bail(err)
@@ -110,6 +134,34 @@ sshcm s asdf --nickname fdsa`,
 			c.Command = cmdCnCommand
 		}
 
+		// Update hooks, if they were passed
+		if slices.Contains(cmdCnSetFlags, "pre_hook") {
+			c.PreHook = cmdCnPreHook
+		}
+
+		if slices.Contains(cmdCnSetFlags, "post_hook") {
+			c.PostHook = cmdCnPostHook
+		}
+
+		// Update the record flag, if it was passed
+		if slices.Contains(cmdCnSetFlags, "record") {
+			c.Record = cmdCnRecord
+		}
+
+		// Update the pattern range, if it was passed
+		if slices.Contains(cmdCnSetFlags, "range") {
+			c.Range = cmdCnRange
+		}
+
+		// Update jump hosts, if they were passed
+		if slices.Contains(cmdCnSetFlags, "jump") {
+			c.Jump, err = jumpNicknames(ctx, cmdCnJump)
+
+			if err != nil {
+				bail(err)
+			}
+		}
+
 		// Run smoke test on connection properties
 		err = c.Validate()
 
@@ -118,12 +170,14 @@ sshcm s asdf --nickname fdsa`,
 		}
 
 		// Update the connection
-		err = c.Update()
+		err = c.UpdateContext(ctx)
 
 		if err != nil {
-			panic(err)
+			bail(err)
 		}
 
+		syncSshConfig(ctx)
+
 		// Show user the updated connection settings
 		fmt.Println("New connection settings:")
 		printConnection(&c, false)
@@ -144,4 +198,9 @@ func init() {
 	setCmd.PersistentFlags().StringVarP(&cmdCnArgs, "args", "a", "", "Arguments to pass to SSH command")
 	setCmd.PersistentFlags().StringVar(&cmdCnIdentity, "identity", "", "SSH identity to use for connection (a la '-i')")
 	setCmd.PersistentFlags().StringVarP(&cmdCnCommand, "command", "c", "", "SSH command to run")
+	setCmd.PersistentFlags().StringVarP(&cmdCnJump, "jump", "j", "", "Comma-separated jump host connections (a la '-J')")
+	setCmd.PersistentFlags().StringVar(&cmdCnPreHook, "pre-hook", "", "Local command to run before connecting")
+	setCmd.PersistentFlags().StringVar(&cmdCnPostHook, "post-hook", "", "Local command to run after the session ends")
+	setCmd.PersistentFlags().BoolVar(&cmdCnRecord, "record", false, "Record the terminal output of every session")
+	setCmd.PersistentFlags().StringVar(&cmdCnRange, "range", "", "Numbers a pattern connection stands for (ex. 1-40)")
 }
@@ -0,0 +1,442 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/cannable/sshcm/pkg/sshargs"
+	"github.com/cannable/sshcm/pkg/sshconfig"
+	"github.com/spf13/cobra"
+)
+
+// sshConfigIncludeSetting is the global setting that holds the path of the
+// ssh_config file kept in sync with the connection DB.
+const sshConfigIncludeSetting = "ssh_config_include"
+
+var (
+	sshConfigPath    string
+	sshConfigInclude bool
+	sshConfigDisable bool
+	resolveFormat    string
+	resolveQuiet     bool
+	resolveProxy     bool
+
+	// sshConfigGenCmd represents the ssh-config-gen command
+	sshConfigGenCmd = &cobra.Command{
+		Use:   "ssh-config-gen",
+		Short: "Generate an ssh_config file from the connection DB",
+		Long: `
+Generate an OpenSSH client config file from the connection DB, so that plain
+ssh (and tools that use it, like scp, rsync and git) can connect to sshcm
+connections by nickname.
+
+Each connection becomes a Host block named after its nickname. Connection
+settings (and program defaults) are mapped to HostName, User, IdentityFile and
+the ssh_config equivalents of the connection's args. ssh arguments that have no
+ssh_config equivalent are left out, with a warning.
+
+The config is printed to standard output, or written to --path.
+
+With --include, the config is written to ~/.ssh/sshcm_config (or --path) and
+kept in sync: sshcm rewrites it whenever connections or program defaults
+change. To use it, add an Include line to the top of ~/.ssh/config:
+
+  Include ~/.ssh/sshcm_config
+
+Pass --disable to stop keeping the file in sync. The file is left in place.`,
+		Example: `
+sshcm ssh-config-gen
+sshcm ssh-config-gen --path sshcm_config
+sshcm ssh-config-gen --include
+sshcm ssh-config-gen --disable`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.NoArgs(cmd, args); err != nil {
+				return err
+			}
+
+			if sshConfigDisable && (sshConfigInclude || len(sshConfigPath) > 0) {
+				return ErrSshConfigDisable
+			}
+
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := cmd.Context()
+
+			db = openDb(ctx)
+
+			if sshConfigDisable {
+				err := db.SetSettingContext(ctx, sshConfigIncludeSetting, "")
+
+				if err != nil {
+					bail(err)
+				}
+
+				db.Close()
+
+				fmt.Println("The ssh_config file will no longer be kept in sync.")
+				return
+			}
+
+			hosts, err := buildSshConfig(ctx, true)
+
+			if err != nil {
+				bail(err)
+			}
+
+			path := sshConfigPath
+
+			if sshConfigInclude && len(path) < 1 {
+				path, err = defaultSshConfigPath()
+
+				if err != nil {
+					bail(err)
+				}
+			}
+
+			if len(path) < 1 {
+				db.Close()
+
+				err = sshconfig.Write(os.Stdout, hosts)
+
+				if err != nil {
+					bail(err)
+				}
+
+				return
+			}
+
+			// The setting is used by sshcm processes run from any directory
+			path, err = filepath.Abs(path)
+
+			if err != nil {
+				bail(err)
+			}
+
+			err = writeSshConfig(path, hosts)
+
+			if err != nil {
+				bail(err)
+			}
+
+			if sshConfigInclude {
+				err = db.SetSettingContext(ctx, sshConfigIncludeSetting, path)
+
+				if err != nil {
+					bail(err)
+				}
+
+				fmt.Printf("Wrote '%s'. It will be kept in sync with the connection DB.\n", path)
+				fmt.Println("To use it, add this line to the top of ~/.ssh/config:")
+				fmt.Println("")
+				fmt.Println("  Include", path)
+			}
+
+			db.Close()
+		},
+	}
+
+	// resolveCmd represents the resolve command
+	resolveCmd = &cobra.Command{
+		Use:   "resolve { id | nickname }",
+		Short: "Print the ssh settings for a connection",
+		Long: `
+Print the effective ssh settings for a connection, with program defaults
+applied, for use by ssh and other tools.
+
+--format selects the output format:
+
+  ssh-G   one lowercase "keyword value" pair per line, like ssh -G (default)
+  config  an ssh_config Host block
+
+With --quiet, nothing is printed. The exit status is 0 if the connection
+exists and 1 if it doesn't, for use with ssh_config's Match exec.
+
+With --proxy, sshcm acts as an ssh ProxyCommand: it connects to the
+connection's host and port and relays the connection over standard input and
+output. Jump hosts and proxies in the connection's args aren't followed.
+
+For example, to let plain ssh reach any sshcm connection by nickname without
+generating a config file (see ssh-config-gen for one that also sets the user
+and identity), add this to ~/.ssh/config:
+
+  Match exec "sshcm resolve --quiet %n"
+      ProxyCommand sshcm resolve --proxy %n`,
+		Example: `
+sshcm resolve something
+sshcm resolve 42 --format config
+sshcm resolve --quiet something && echo "sshcm knows something"`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+
+			if !cdb.IsValidIdOrNickname(args[0]) {
+				return ErrNoIdOrNickname
+			}
+
+			if resolveFormat != "ssh-G" && resolveFormat != "config" {
+				return ErrInvalidResolveFormat
+			}
+
+			return nil
+		},
+		ValidArgsFunction: completeConnections(1, true),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := cmd.Context()
+
+			db = openDb(ctx)
+
+			c, err := db.GetByIdOrNicknameContext(ctx, args[0])
+
+			if resolveQuiet {
+				db.Close()
+
+				if err != nil {
+					os.Exit(1)
+				}
+
+				return
+			}
+
+			if err != nil {
+				bail(err)
+			}
+
+			err = resolveConnection(ctx, &c)
+
+			if err != nil {
+				bail(err)
+			}
+
+			db.Close()
+
+			h, dropped, err := sshconfig.FromConnection(&c)
+
+			if err != nil {
+				bail(err)
+			}
+
+			if resolveProxy {
+				err = proxy(ctx, h)
+
+				if err != nil {
+					bail(err)
+				}
+
+				return
+			}
+
+			warnDroppedConfig(c.Nickname, dropped)
+
+			if resolveFormat == "config" {
+				err = h.WriteConfig(os.Stdout)
+			} else {
+				err = h.WriteG(os.Stdout)
+			}
+
+			if err != nil {
+				bail(err)
+			}
+		},
+	}
+)
+
+// buildSshConfig returns a Host block for each connection in the connection
+// DB, with program defaults applied and placeholders expanded. If warn is true,
+// ssh arguments that were left out are reported on stderr.
+func buildSshConfig(ctx context.Context, warn bool) ([]sshconfig.Host, error) {
+	cns, err := db.GetAllContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []sshconfig.Host
+
+	for _, c := range expandPatterns(cns) {
+		resolved := *c
+
+		if err := resolveConnection(ctx, &resolved); err != nil {
+			return nil, fmt.Errorf("connection %s: %w", c.Nickname, err)
+		}
+
+		h, dropped, err := sshconfig.FromConnection(&resolved)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if warn {
+			warnDroppedConfig(c.Nickname, dropped)
+		}
+
+		hosts = append(hosts, h)
+	}
+
+	return hosts, nil
+}
+
+// defaultSshConfigPath returns the default location of the ssh_config file
+// kept in sync by ssh-config-gen --include.
+func defaultSshConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".ssh", "sshcm_config"), nil
+}
+
+// proxy connects to the host's HostName and Port, then copies stdin to the
+// connection and the connection to stdout until both sides are done.
+func proxy(ctx context.Context, h sshconfig.Host) error {
+	host, _ := h.Lookup("HostName")
+	port, ok := h.Lookup("Port")
+
+	if !ok {
+		port = sshconfig.DefaultPort
+	}
+
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	go func() {
+		io.Copy(conn, os.Stdin)
+
+		// Let the server know ssh is done sending
+		if tc, ok := conn.(*net.TCPConn); ok {
+			tc.CloseWrite()
+		}
+	}()
+
+	_, err = io.Copy(os.Stdout, conn)
+
+	return err
+}
+
+// syncSshConfig rewrites the ssh_config file set up by ssh-config-gen
+// --include, if there is one, so that it matches the connection DB. It should
+// be called after a command changes connections or program defaults.
+//
+// Failures are reported on stderr, but don't fail the command, since the
+// connection DB change itself has already been made.
+func syncSshConfig(ctx context.Context) {
+	path, err := updateSshConfig(ctx)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		return
+	}
+
+	if debugMode && len(path) > 0 {
+		fmt.Println("Updated ssh_config file", path)
+	}
+}
+
+// updateSshConfig does the work of syncSshConfig, returning the path of the
+// ssh_config file written (empty if there is none) and any error instead of
+// printing them.
+func updateSshConfig(ctx context.Context) (string, error) {
+	path, err := db.GetSettingContext(ctx, sshConfigIncludeSetting)
+
+	if err == nil && len(path) < 1 {
+		return "", nil
+	}
+
+	if err == nil {
+		var hosts []sshconfig.Host
+
+		hosts, err = buildSshConfig(ctx, false)
+
+		if err == nil {
+			err = writeSshConfig(path, hosts)
+		}
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("could not update ssh_config file '%s': %w", path, err)
+	}
+
+	return path, nil
+}
+
+// warnDroppedConfig prints a warning about a connection's ssh arguments that
+// have no ssh_config equivalent.
+func warnDroppedConfig(nickname string, dropped []sshargs.Option) {
+	for _, o := range dropped {
+		fmt.Fprintf(os.Stderr, "warning: %s: ignoring ssh argument '%s' with no ssh_config equivalent\n",
+			nickname, sshargs.Join(o.Args()))
+	}
+}
+
+// writeSshConfig writes an ssh_config file to path. The file is written to a
+// temporary file and renamed into place, so that ssh never reads a partly
+// written config.
+func writeSshConfig(path string, hosts []sshconfig.Host) error {
+	dir := filepath.Dir(path)
+
+	err := os.MkdirAll(dir, 0700)
+
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(f.Name())
+
+	err = sshconfig.Write(f, hosts)
+
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Close()
+
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(f.Name(), 0600)
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+func init() {
+	rootCmd.AddCommand(sshConfigGenCmd)
+	rootCmd.AddCommand(resolveCmd)
+
+	// Command flags
+	sshConfigGenCmd.PersistentFlags().StringVarP(&sshConfigPath, "path", "f", "", "Write the config to this path.")
+	sshConfigGenCmd.PersistentFlags().BoolVar(&sshConfigInclude, "include", false, "Keep the config file in sync with the connection DB.")
+	sshConfigGenCmd.PersistentFlags().BoolVar(&sshConfigDisable, "disable", false, "Stop keeping the config file in sync.")
+
+	resolveCmd.PersistentFlags().StringVar(&resolveFormat, "format", "ssh-G", "Output format. Valid formats: ssh-G or config.")
+	resolveCmd.PersistentFlags().BoolVarP(&resolveQuiet, "quiet", "q", false, "Print nothing; exit with status 1 if the connection doesn't exist.")
+	resolveCmd.PersistentFlags().BoolVar(&resolveProxy, "proxy", false, "Relay standard input and output to the connection's host and port.")
+
+	resolveCmd.RegisterFlagCompletionFunc("format", completeValues("ssh-G", "config"))
+}
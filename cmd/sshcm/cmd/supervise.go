@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cannable/sshcm/pkg/asciicast"
+	"github.com/cannable/sshcm/pkg/cdb"
+)
+
+const (
+	// reconnectDelayMin is how long --reconnect waits before the first
+	// attempt. The wait doubles after each attempt, up to reconnectDelayMax.
+	reconnectDelayMin = time.Second
+
+	// reconnectDelayMax is the longest --reconnect waits between attempts.
+	// Sessions that stay up at least this long start the wait over.
+	reconnectDelayMax = time.Minute
+
+	// sshConnectionError is the exit status ssh uses when it can't connect,
+	// or the connection drops.
+	sshConnectionError = 255
+)
+
+// forwardedSignals are passed on to ssh when it runs as a child process.
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+
+// runSsh runs ssh as a child process attached to the terminal, waits for it
+// to exit and returns its exit status. If ssh can't be run, or is killed by a
+// signal, the status is 255, as ssh uses for its own errors.
+//
+// If rec isn't nil, ssh is run under a pty and its output is recorded (see
+// startRecorded).
+//
+// Signals sent to sshcm (see forwardedSignals) are passed on to ssh, and
+// signalled is true if there were any.
+func runSsh(execBin string, execArgs []string, execEnv []string, rec *asciicast.Writer) (status int, signalled bool) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, forwardedSignals...)
+	defer signal.Stop(sigs)
+
+	exe := &exec.Cmd{
+		Path: execBin,
+		Args: execArgs,
+		Env:  execEnv,
+	}
+
+	var wait func() error
+	var err error
+
+	if rec != nil {
+		wait, err = startRecorded(exe, rec)
+	} else {
+		exe.Stdin = os.Stdin
+		exe.Stdout = os.Stdout
+		exe.Stderr = os.Stderr
+
+		wait, err = exe.Wait, exe.Start()
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return sshConnectionError, false
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- wait()
+	}()
+
+	for {
+		select {
+		case sig := <-sigs:
+			// Signals can't be sent on every platform (ex. Windows), in
+			// which case ssh gets the console's signals on its own
+			signalled = true
+			exe.Process.Signal(sig)
+
+		case err := <-done:
+			var exitErr *exec.ExitError
+
+			if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+				return exitErr.ExitCode(), signalled
+			}
+
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				return sshConnectionError, signalled
+			}
+
+			return 0, signalled
+		}
+	}
+}
+
+// superviseSsh runs ssh for the passed connection as a child process (see
+// runSsh) and records each session in the connection DB. If recordDir isn't
+// empty, the terminal output of each session is recorded to a new file in it
+// (see createRecording), which is noted in the session record.
+//
+// With --reconnect, ssh is restarted with exponential backoff whenever it exits
+// with status 255, until it exits with another status, sshcm is signalled or
+// --max-reconnects attempts in a row have failed. The exit status of the last
+// session is returned.
+func superviseSsh(ctx context.Context, c cdb.Connection, execBin string, execArgs []string, execEnv []string, recordDir string) int {
+	delay := reconnectDelayMin
+	attempts := 0
+
+	for {
+		s := cdb.Session{
+			ConnectionId: c.Id,
+			Nickname:     c.Nickname,
+			Started:      time.Now(),
+		}
+
+		var f *os.File
+		var rec *asciicast.Writer
+
+		if len(recordDir) > 0 {
+			var err error
+
+			f, rec, err = createRecording(recordDir, c, s.Started)
+
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error: the session can't be recorded:", err)
+				return sshConnectionError
+			}
+
+			s.Recording = f.Name()
+		}
+
+		status, signalled := runSsh(execBin, execArgs, execEnv, rec)
+
+		if f != nil {
+			f.Close()
+		}
+
+		s.Ended = time.Now()
+		s.ExitStatus = status
+
+		// Sessions are recorded even if sshcm was interrupted
+		if _, err := db.AddSessionContext(context.WithoutCancel(ctx), &s); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: the session could not be saved:", err)
+		}
+
+		if debugMode {
+			fmt.Printf("Session ended after %s with exit status %d\n", s.Duration().Round(time.Second), status)
+		}
+
+		if !connectReconnect || status != sshConnectionError || signalled || ctx.Err() != nil {
+			return status
+		}
+
+		// A session that stayed up for a while isn't a failed attempt
+		if s.Duration() >= reconnectDelayMax {
+			delay = reconnectDelayMin
+			attempts = 0
+		}
+
+		if connectMaxReconnects > 0 && attempts >= connectMaxReconnects {
+			fmt.Fprintf(os.Stderr, "Giving up on %s after %d reconnects.\n", c.Nickname, attempts)
+			return status
+		}
+
+		attempts++
+
+		fmt.Fprintf(os.Stderr, "Connection to %s lost; reconnecting in %s (attempt %d)...\n", c.Nickname, delay, attempts)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return status
+		}
+
+		delay = min(delay*2, reconnectDelayMax)
+	}
+}
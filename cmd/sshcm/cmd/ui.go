@@ -0,0 +1,754 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/cannable/sshcm/pkg/misc"
+	"github.com/cannable/sshcm/pkg/tui"
+	"github.com/spf13/cobra"
+)
+
+// uiFormFields contains the connection properties edited by the ui add and
+// edit forms, in order.
+var uiFormFields = []string{"nickname", "host", "user", "description", "args", "identity", "command", "jump", "pre_hook", "post_hook", "record", "range"}
+
+// uiMode is what the ui is showing, and so what key presses do.
+type uiMode int
+
+const (
+	uiBrowse uiMode = iota
+	uiSearch
+	uiForm
+	uiConfirmDelete
+	uiDefaults
+	uiEditDefault
+)
+
+// uiHelp contains the key bindings shown in the status line of each mode.
+var uiHelp = map[uiMode]string{
+	uiBrowse:        "enter connect  / search  a add  e edit  d delete  D defaults  q quit",
+	uiSearch:        "type to search  enter done  esc clear",
+	uiForm:          "tab/↑/↓ move  space toggle record  ctrl-s save  esc cancel",
+	uiConfirmDelete: "y delete  any other key cancels",
+	uiDefaults:      "enter edit  esc back",
+	uiEditDefault:   "enter save  esc cancel",
+}
+
+// ui holds the state of the ui command.
+type ui struct {
+	ctx  context.Context
+	mode uiMode
+
+	// Connections matching the search filter, by nickname
+	cns      []*cdb.Connection
+	filter   *tui.Input
+	selected int
+	top      int // index of the first connection shown
+
+	// The connection being added or edited, and its form
+	form      cdb.Connection
+	inputs    []*tui.Input
+	formFocus int
+
+	// Program defaults
+	defaults        []string
+	defaultSelected int
+	defaultInput    *tui.Input
+
+	status   string // message shown in place of the key bindings
+	backedUp bool   // an automatic backup was made this session
+	connect  string // the nickname to connect to on leaving
+}
+
+// uiCmd represents the ui command
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Browse and edit connections in a full-screen interface",
+	Long: `
+Browse and edit connections in a full-screen terminal interface.
+
+Connections are listed by nickname, with the settings of the selected
+connection beside them. Type / to search connections by nickname, host, user
+or description, and enter to connect to the selected connection; the interface
+closes, and connect runs as "sshcm connect nickname" would.
+
+Connections are added (a), edited (e) and removed (d, after confirming) with
+the same checks as the add, set and remove commands, and the connection DB is
+backed up before the first change (see --no-backup). Program defaults are
+listed and edited with D.
+
+Keys:
+
+  ↑/↓, k/j, PgUp/PgDn  Move the selection
+  /                    Search
+  enter                Connect
+  a, e, d              Add, edit or remove a connection
+  D                    Edit program defaults
+  q, esc               Quit (esc clears the search first)`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		db = openDb(ctx)
+
+		nickname, err := runUi(ctx)
+
+		db.Close()
+
+		if err != nil {
+			bail(err)
+		}
+
+		if len(nickname) > 0 {
+			connectCmd.SetContext(ctx)
+			connectCmd.Run(connectCmd, []string{nickname})
+		}
+	},
+}
+
+// runUi runs the ui until the user quits, and returns the nickname of the
+// connection to connect to, if one was chosen. The terminal is restored
+// before returning.
+func runUi(ctx context.Context) (string, error) {
+	screen, err := tui.Open(os.Stdin, os.Stdout)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer screen.Close()
+
+	resized := make(chan struct{}, 1)
+
+	stopWatching := watchResize(func() {
+		select {
+		case resized <- struct{}{}:
+		default:
+		}
+	})
+
+	defer stopWatching()
+
+	u := &ui{ctx: ctx, filter: tui.NewInput("")}
+
+	if err := u.reload(""); err != nil {
+		return "", err
+	}
+
+	for {
+		err := screen.Draw(u.frame(screen.Size()))
+
+		if err != nil {
+			return "", err
+		}
+
+		events, err := screen.ReadEvents(resized)
+
+		if err != nil {
+			return "", err
+		}
+
+		for _, ev := range events {
+			if done := u.handle(ev); done {
+				return u.connect, nil
+			}
+		}
+	}
+}
+
+// reload reads the connections matching the search filter from the
+// connection DB, and selects the one with the passed nickname, if any.
+func (u *ui) reload(nickname string) error {
+	cns, _, err := db.List(u.ctx, cdb.ListOptions{
+		Filter: u.filter.Value(),
+		SortBy: "nickname",
+	})
+
+	if err != nil {
+		return err
+	}
+
+	u.cns = cns
+	u.selected = min(u.selected, max(len(cns)-1, 0))
+
+	for i, c := range cns {
+		if c.Nickname == nickname {
+			u.selected = i
+		}
+	}
+
+	return nil
+}
+
+// current returns the selected connection, or nil if no connections are
+// listed.
+func (u *ui) current() *cdb.Connection {
+	if len(u.cns) < 1 {
+		return nil
+	}
+
+	return u.cns[u.selected]
+}
+
+// fail shows err in the status line, and returns true if there was one.
+func (u *ui) fail(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	u.status = "error: " + err.Error()
+
+	return true
+}
+
+// backup makes an automatic backup before the first change of the session
+// (see autoBackup).
+func (u *ui) backup() error {
+	if u.backedUp {
+		return nil
+	}
+
+	path, err := writeAutoBackup(u.ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if debugMode && len(path) > 0 {
+		u.note("Backed up connection DB to " + path)
+	}
+
+	u.backedUp = true
+
+	return nil
+}
+
+// syncSshConfig is the ui's version of syncSshConfig, which reports on the
+// status line instead of stdout and stderr, as those would garble the screen.
+func (u *ui) syncSshConfig() {
+	path, err := updateSshConfig(u.ctx)
+
+	if err != nil {
+		u.note("warning: " + err.Error())
+	} else if debugMode && len(path) > 0 {
+		u.note("Updated ssh_config file " + path)
+	}
+}
+
+// note adds msg to the status line, after anything already shown there.
+func (u *ui) note(msg string) {
+	if len(u.status) > 0 {
+		u.status += " "
+	}
+
+	u.status += msg
+}
+
+// handle acts on a key press, and returns true if the ui should close.
+func (u *ui) handle(ev tui.Event) bool {
+	u.status = ""
+
+	switch u.mode {
+	case uiBrowse:
+		return u.handleBrowse(ev)
+	case uiSearch:
+		u.handleSearch(ev)
+	case uiForm:
+		u.handleForm(ev)
+	case uiConfirmDelete:
+		u.handleConfirmDelete(ev)
+	case uiDefaults, uiEditDefault:
+		u.handleDefaults(ev)
+	}
+
+	return false
+}
+
+// move moves the selection by n connections, staying within the list.
+func (u *ui) move(n int) {
+	u.selected = max(min(u.selected+n, len(u.cns)-1), 0)
+}
+
+func (u *ui) handleBrowse(ev tui.Event) bool {
+	switch {
+	case ev.Key == tui.KeyCtrlC, ev.Key == tui.KeyRune && ev.Rune == 'q':
+		return true
+	case ev.Key == tui.KeyEsc:
+		if len(u.filter.Value()) < 1 {
+			return true
+		}
+
+		u.filter = tui.NewInput("")
+		u.fail(u.reload(""))
+	case ev.Key == tui.KeyUp, ev.Key == tui.KeyRune && ev.Rune == 'k':
+		u.move(-1)
+	case ev.Key == tui.KeyDown, ev.Key == tui.KeyRune && ev.Rune == 'j':
+		u.move(1)
+	case ev.Key == tui.KeyPgUp:
+		u.move(-10)
+	case ev.Key == tui.KeyPgDn:
+		u.move(10)
+	case ev.Key == tui.KeyHome:
+		u.selected = 0
+	case ev.Key == tui.KeyEnd:
+		u.move(len(u.cns))
+	case ev.Key == tui.KeyEnter:
+		c := u.current()
+
+		if c == nil {
+			return false
+		}
+
+		// Catch connections that can't be connected to (ex. patterns, or
+		// unset environment variables) without leaving
+		resolved := *c
+
+		if u.fail(resolveConnection(u.ctx, &resolved)) {
+			return false
+		}
+
+		u.connect = c.Nickname
+
+		return true
+	case ev.Key == tui.KeyRune && ev.Rune == '/':
+		u.mode = uiSearch
+	case ev.Key == tui.KeyRune && ev.Rune == 'a':
+		u.openForm(cdb.NewConnection())
+	case ev.Key == tui.KeyRune && ev.Rune == 'e':
+		if c := u.current(); c != nil {
+			u.openForm(*c)
+		}
+	case ev.Key == tui.KeyRune && ev.Rune == 'd':
+		if u.current() != nil {
+			u.mode = uiConfirmDelete
+		}
+	case ev.Key == tui.KeyRune && ev.Rune == 'D':
+		u.fail(u.openDefaults())
+	}
+
+	return false
+}
+
+func (u *ui) handleSearch(ev tui.Event) {
+	switch ev.Key {
+	case tui.KeyEnter:
+		u.mode = uiBrowse
+	case tui.KeyEsc, tui.KeyCtrlC:
+		u.filter = tui.NewInput("")
+		u.mode = uiBrowse
+	case tui.KeyUp:
+		u.move(-1)
+		return
+	case tui.KeyDown:
+		u.move(1)
+		return
+	default:
+		if !u.filter.Handle(ev) {
+			return
+		}
+	}
+
+	var nickname string
+
+	if c := u.current(); c != nil {
+		nickname = c.Nickname
+	}
+
+	u.fail(u.reload(nickname))
+}
+
+// openForm shows the add (for a connection without an id) or edit form for
+// the passed connection.
+func (u *ui) openForm(c cdb.Connection) {
+	u.form = c
+	u.inputs = nil
+	u.formFocus = 0
+
+	for _, name := range uiFormFields {
+		value := connectionField(&c, name)
+
+		if name == "record" && len(value) < 1 {
+			value = "false"
+		}
+
+		u.inputs = append(u.inputs, tui.NewInput(value))
+	}
+
+	u.mode = uiForm
+}
+
+func (u *ui) handleForm(ev tui.Event) {
+	switch ev.Key {
+	case tui.KeyEsc, tui.KeyCtrlC:
+		u.mode = uiBrowse
+	case tui.KeyTab, tui.KeyDown, tui.KeyEnter:
+		u.formFocus = (u.formFocus + 1) % len(u.inputs)
+	case tui.KeyBacktab, tui.KeyUp:
+		u.formFocus = (u.formFocus + len(u.inputs) - 1) % len(u.inputs)
+	case tui.KeyCtrlS:
+		if !u.fail(u.saveForm()) {
+			u.mode = uiBrowse
+		}
+	default:
+		in := u.inputs[u.formFocus]
+
+		// Record is a toggle
+		if uiFormFields[u.formFocus] == "record" {
+			if ev.Key == tui.KeyRune && ev.Rune == ' ' {
+				u.inputs[u.formFocus] = tui.NewInput(fmt.Sprint(in.Value() != "true"))
+			}
+
+			return
+		}
+
+		in.Handle(ev)
+	}
+}
+
+// saveForm adds or updates the connection in the form, checking it as the add
+// and set commands do.
+func (u *ui) saveForm() error {
+	c := u.form
+
+	for i, name := range uiFormFields {
+		value := u.inputs[i].Value()
+
+		switch name {
+		case "nickname":
+			c.Nickname = value
+		case "host":
+			c.Host = value
+		case "user":
+			c.User = value
+		case "description":
+			c.Description = value
+		case "args":
+			c.Args = value
+		case "identity":
+			c.Identity = value
+		case "command":
+			c.Command = value
+		case "jump":
+			jump, err := jumpNicknames(u.ctx, value)
+
+			if err != nil {
+				return err
+			}
+
+			c.Jump = jump
+		case "pre_hook":
+			c.PreHook = value
+		case "post_hook":
+			c.PostHook = value
+		case "record":
+			c.Record = value == "true"
+		case "range":
+			c.Range = value
+		}
+	}
+
+	// Run smoke test on connection properties. New connections don't have an
+	// id yet.
+	if err := c.Validate(); err != nil && !(c.Id == 0 && err == cdb.ErrConnIdZero) {
+		return err
+	}
+
+	if err := u.backup(); err != nil {
+		return err
+	}
+
+	var err error
+
+	if c.Id == 0 {
+		_, err = db.AddContext(u.ctx, &c)
+	} else {
+		err = c.UpdateContext(u.ctx)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	u.syncSshConfig()
+
+	return u.reload(c.Nickname)
+}
+
+func (u *ui) handleConfirmDelete(ev tui.Event) {
+	u.mode = uiBrowse
+
+	if ev.Key != tui.KeyRune || (ev.Rune != 'y' && ev.Rune != 'Y') {
+		return
+	}
+
+	c := u.current()
+
+	if u.fail(u.backup()) || u.fail(c.DeleteContext(u.ctx)) {
+		return
+	}
+
+	u.note(fmt.Sprintf("Removed '%s'.", c.Nickname))
+	u.syncSshConfig()
+	u.fail(u.reload(""))
+}
+
+// openDefaults reads the program defaults, and shows them.
+func (u *ui) openDefaults() error {
+	u.defaults = nil
+
+	for _, name := range cdb.ValidDefaults {
+		value, err := db.GetDefaultContext(u.ctx, name)
+
+		if err != nil {
+			return err
+		}
+
+		u.defaults = append(u.defaults, value)
+	}
+
+	u.mode = uiDefaults
+
+	return nil
+}
+
+func (u *ui) handleDefaults(ev tui.Event) {
+	if u.mode == uiEditDefault {
+		switch ev.Key {
+		case tui.KeyEsc, tui.KeyCtrlC:
+			u.mode = uiDefaults
+		case tui.KeyEnter:
+			name, value := cdb.ValidDefaults[u.defaultSelected], u.defaultInput.Value()
+
+			if u.fail(validateDefault(name, value)) || u.fail(db.SetDefaultContext(u.ctx, name, value)) {
+				return
+			}
+
+			u.syncSshConfig()
+
+			u.defaults[u.defaultSelected] = value
+			u.mode = uiDefaults
+		default:
+			u.defaultInput.Handle(ev)
+		}
+
+		return
+	}
+
+	switch {
+	case ev.Key == tui.KeyEsc, ev.Key == tui.KeyCtrlC, ev.Key == tui.KeyRune && ev.Rune == 'q':
+		u.mode = uiBrowse
+	case ev.Key == tui.KeyUp, ev.Key == tui.KeyRune && ev.Rune == 'k':
+		u.defaultSelected = max(u.defaultSelected-1, 0)
+	case ev.Key == tui.KeyDown, ev.Key == tui.KeyRune && ev.Rune == 'j':
+		u.defaultSelected = min(u.defaultSelected+1, len(u.defaults)-1)
+	case ev.Key == tui.KeyEnter:
+		u.defaultInput = tui.NewInput(u.defaults[u.defaultSelected])
+		u.mode = uiEditDefault
+	}
+}
+
+// frame draws the ui on a screen of the passed size.
+func (u *ui) frame(width int, height int) tui.Frame {
+	var f tui.Frame
+
+	bodyHeight := max(height-2, 1)
+
+	// Title bar
+	title := fmt.Sprintf(" sshcm  %d connections", len(u.cns))
+
+	if filter := u.filter.Value(); len(filter) > 0 {
+		title += fmt.Sprintf(" matching '%s'", filter)
+	}
+
+	f.Lines = append(f.Lines, tui.Reverse(misc.StringTrimmer(title, width)))
+
+	switch u.mode {
+	case uiForm:
+		f.Lines = append(f.Lines, u.formLines(width, bodyHeight, &f)...)
+	case uiDefaults, uiEditDefault:
+		f.Lines = append(f.Lines, u.defaultLines(width, bodyHeight, &f)...)
+	default:
+		f.Lines = append(f.Lines, u.browseLines(width, bodyHeight)...)
+	}
+
+	// Status line
+	status := tui.Dim(misc.StringTrimmer(uiHelp[u.mode], width))
+
+	switch {
+	case len(u.status) > 0:
+		status = tui.Bold(misc.StringTrimmer(u.status, width))
+	case u.mode == uiConfirmDelete:
+		status = tui.Bold(misc.StringTrimmer(fmt.Sprintf("Remove '%s'? (y/N)", u.current().Nickname), width))
+	case u.mode == uiSearch:
+		view, x := u.filter.View(max(width-1, 1))
+
+		status = "/" + view
+		f.ShowCursor, f.CursorX, f.CursorY = true, x+1, len(f.Lines)
+	}
+
+	f.Lines = append(f.Lines, status)
+
+	return f
+}
+
+// browseLines returns the lines of the connection list, with the settings of
+// the selected connection beside it if there is room.
+func (u *ui) browseLines(width int, height int) []string {
+	listWidth := width
+
+	if width >= 60 {
+		listWidth = width * 2 / 5
+	}
+
+	// Keep the selection in view
+	if u.selected < u.top {
+		u.top = u.selected
+	} else if u.selected >= u.top+height {
+		u.top = u.selected - height + 1
+	}
+
+	var details []string
+
+	if c := u.current(); c != nil && listWidth < width {
+		details = connectionDetails(c)
+	}
+
+	var lines []string
+
+	for i := 0; i < height; i++ {
+		var line string
+
+		if n := u.top + i; n < len(u.cns) {
+			c := u.cns[n]
+			nickWidth := min(listWidth/2, 24)
+
+			line = misc.StringTrimmer(" "+misc.StringTrimmer(c.Nickname, nickWidth)+" "+c.Host, listWidth)
+
+			if n == u.selected {
+				line = tui.Reverse(line)
+			}
+		} else if i == 0 && len(u.cns) == 0 {
+			line = misc.StringTrimmer(" No connections. Press a to add one.", listWidth)
+		} else {
+			line = misc.StringTrimmer("", listWidth)
+		}
+
+		if listWidth < width {
+			detail := ""
+
+			if i < len(details) {
+				detail = details[i]
+			}
+
+			line += tui.Dim("│") + " " + misc.Truncate(detail, width-listWidth-2)
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// connectionDetails returns the settings of a connection, as get prints them.
+// The connections a pattern connection stands for are listed too.
+func connectionDetails(c *cdb.Connection) []string {
+	var b bytes.Buffer
+
+	if err := c.WriteRecordLong(&b); err != nil {
+		return []string{err.Error()}
+	}
+
+	lines := strings.Split(strings.TrimRight(b.String(), "\n"), "\n")
+
+	if instances := c.Instances(); c.IsPattern() && len(instances) > 0 {
+		lines = append(lines, "",
+			fmt.Sprintf("%d connections: %s to %s", len(instances), instances[0].Nickname, instances[len(instances)-1].Nickname))
+	}
+
+	return lines
+}
+
+// formLines returns the lines of the add or edit form, and places the cursor
+// in the focused field.
+func (u *ui) formLines(width int, height int, f *tui.Frame) []string {
+	const labelWidth = 14
+
+	title := " Add connection"
+
+	if u.form.Id != 0 {
+		title = fmt.Sprintf(" Edit '%s'", u.form.Nickname)
+	}
+
+	lines := []string{tui.Bold(misc.StringTrimmer(title, width)), ""}
+
+	for i, name := range uiFormFields {
+		label := misc.StringTrimmer(" "+columnHeading(name), labelWidth)
+		view, x := u.inputs[i].View(max(width-labelWidth, 1))
+
+		if i == u.formFocus {
+			label = tui.Bold(label)
+			f.ShowCursor, f.CursorX, f.CursorY = true, labelWidth+x, len(lines)+1
+		}
+
+		lines = append(lines, label+view)
+	}
+
+	// Describe the focused field with the add flag's usage
+	if flag := addCmd.Flags().Lookup(strings.ReplaceAll(uiFormFields[u.formFocus], "_", "-")); flag != nil {
+		lines = append(lines, "", tui.Dim(misc.Truncate(" "+flag.Usage, width)))
+	}
+
+	return uiFill(lines, height)
+}
+
+// defaultLines returns the lines of the program defaults list, and places the
+// cursor in the default being edited.
+func (u *ui) defaultLines(width int, height int, f *tui.Frame) []string {
+	const nameWidth = 14
+
+	lines := []string{tui.Bold(misc.StringTrimmer(" Program defaults", width)), ""}
+
+	for i, name := range cdb.ValidDefaults {
+		label := misc.StringTrimmer(" "+name, nameWidth)
+		value := misc.StringTrimmer(u.defaults[i], max(width-nameWidth, 0))
+
+		switch {
+		case i == u.defaultSelected && u.mode == uiEditDefault:
+			view, x := u.defaultInput.View(max(width-nameWidth, 1))
+
+			value = view
+			label = tui.Bold(label)
+			f.ShowCursor, f.CursorX, f.CursorY = true, nameWidth+x, len(lines)+1
+		case i == u.defaultSelected:
+			label, value = tui.Reverse(label), tui.Reverse(value)
+		}
+
+		lines = append(lines, label+value)
+	}
+
+	lines = append(lines, "", tui.Dim(misc.Truncate(" "+defaultDescriptions[cdb.ValidDefaults[u.defaultSelected]], width)))
+
+	return uiFill(lines, height)
+}
+
+// uiFill returns lines cut or padded with empty lines to the passed height.
+func uiFill(lines []string, height int) []string {
+	if len(lines) > height {
+		return lines[:height]
+	}
+
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+
+	return lines
+}
+
+func init() {
+	rootCmd.AddCommand(uiCmd)
+}
@@ -8,20 +8,29 @@ Usage:
 
 Available Commands:
 
-	add         Add a connection
-	completion  Generate the autocompletion script for the specified shell
-	connect     Start a connection
-	def         Set program default settings
-	defaults    List program defaults
-	export      Export all connections
-	get         Print existing connection details
-	help        Help about any command
-	import      Import connections
-	list        list all connections
-	remove      Remove connection
-	search      Search for connections
-	set         Alter an existing connection
-	version     Print program version
+	add                Add a connection
+	ansible-inventory  Act as an Ansible dynamic inventory script
+	backup             Back up the connection DB
+	completion         Generate the autocompletion script for the specified shell
+	connect            Start a connection
+	cp                 Copy files to or from a connection
+	def                Set program default settings
+	defaults           List program defaults
+	export             Export all connections
+	get                Print existing connection details
+	help               Help about any command
+	import             Import connections
+	list               list all connections
+	remove             Remove connections
+	resolve            Print the ssh settings for a connection
+	replay             Play back a session recording
+	restore            Restore the connection DB from a backup
+	search             Search for connections
+	sessions           List recorded sessions
+	set                Alter an existing connection
+	ssh-config-gen     Generate an ssh_config file from the connection DB
+	ui                 Browse and edit connections in a full-screen interface
+	version            Print program version
 
 Flags:
 
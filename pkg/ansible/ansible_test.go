@@ -0,0 +1,278 @@
+package ansible
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cannable/sshcm/pkg/cdb"
+)
+
+func TestHostVars(t *testing.T) {
+	tests := []struct {
+		name string
+		conn cdb.Connection
+		want map[string]string
+	}{
+		{
+			name: "plain",
+			conn: cdb.Connection{Host: "somewhere"},
+			want: map[string]string{VarHost: "somewhere"},
+		},
+		{
+			name: "args",
+			conn: cdb.Connection{Host: "somewhere", Args: "-p 2222 -l me -i ~/.ssh/id -t"},
+			want: map[string]string{
+				VarHost:       "somewhere",
+				VarPort:       "2222",
+				VarUser:       "me",
+				VarIdentity:   "~/.ssh/id",
+				VarCommonArgs: "-t",
+			},
+		},
+		{
+			name: "precedence",
+			conn: cdb.Connection{Host: "somewhere", User: "you", Identity: "id2", Args: "-l me -i id1"},
+			want: map[string]string{VarHost: "somewhere", VarUser: "you", VarIdentity: "id2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HostVars(&tt.conn)
+
+			if err != nil {
+				t.Fatalf("HostVars() error = %v", err)
+			}
+
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("HostVars() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// testInventory returns an inventory with one grouped and one ungrouped host.
+func testInventory() *Inventory {
+	inv := New()
+
+	inv.AddHost("something", map[string]string{VarHost: "somewhere"})
+	inv.AddHost("else", map[string]string{VarHost: "elsewhere", VarCommonArgs: "-o A=b"}, "web")
+
+	return inv
+}
+
+func TestInventory_WriteINI(t *testing.T) {
+	var b strings.Builder
+
+	if err := testInventory().WriteINI(&b); err != nil {
+		t.Fatalf("Inventory.WriteINI() error = %v", err)
+	}
+
+	want := `something ansible_host=somewhere
+
+[web]
+else ansible_host=elsewhere ansible_ssh_common_args='-o A=b'
+`
+
+	if b.String() != want {
+		t.Errorf("Inventory.WriteINI() = %q, want %q", b.String(), want)
+	}
+}
+
+func TestInventory_WriteYAML(t *testing.T) {
+	var b strings.Builder
+
+	if err := testInventory().WriteYAML(&b); err != nil {
+		t.Fatalf("Inventory.WriteYAML() error = %v", err)
+	}
+
+	want := `all:
+  hosts:
+    something:
+      ansible_host: somewhere
+  children:
+    web:
+      hosts:
+        else:
+          ansible_host: elsewhere
+          ansible_ssh_common_args: -o A=b
+`
+
+	if b.String() != want {
+		t.Errorf("Inventory.WriteYAML() = %q, want %q", b.String(), want)
+	}
+}
+
+func TestInventory_List(t *testing.T) {
+	list := testInventory().List()
+
+	meta := list["_meta"].(map[string]any)["hostvars"].(map[string]map[string]string)
+
+	if len(meta) != 2 || meta["else"][VarHost] != "elsewhere" {
+		t.Errorf("Inventory.List() hostvars = %v", meta)
+	}
+
+	ungrouped := list[GroupUngrouped].(map[string]any)["hosts"].([]string)
+
+	if len(ungrouped) != 1 || ungrouped[0] != "something" {
+		t.Errorf("Inventory.List() ungrouped hosts = %v, want [something]", ungrouped)
+	}
+
+	web := list["web"].(map[string]any)["hosts"].([]string)
+
+	if len(web) != 1 || web[0] != "else" {
+		t.Errorf("Inventory.List() web hosts = %v, want [else]", web)
+	}
+}
+
+func TestExpandHostPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+		wantErr error
+	}{
+		{"web", []string{"web"}, nil},
+		{"web[01:03].lan", []string{"web01.lan", "web02.lan", "web03.lan"}, nil},
+		{"web[1:5:2]", []string{"web1", "web3", "web5"}, nil},
+		{"db-[a:b][1:2]", []string{"db-a1", "db-a2", "db-b1", "db-b2"}, nil},
+		{"web[3:1]", nil, ErrInvalidHostPattern},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got, err := expandHostPattern(tt.pattern)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expandHostPattern() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expandHostPattern() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name      string
+		inventory string
+	}{
+		{
+			name: "ini",
+			inventory: `# comment
+something ansible_host=somewhere
+
+[web]
+else:2222 ansible_ssh_common_args='-o A=b'
+
+[prod:children]
+web
+
+[prod:vars]
+ansible_user=me
+`,
+		},
+		{
+			name: "yaml",
+			inventory: `all:
+  hosts:
+    something:
+      ansible_host: somewhere
+  children:
+    prod:
+      vars:
+        ansible_user: me
+      children:
+        web:
+          hosts:
+            else:2222:
+              ansible_ssh_common_args: -o A=b
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, err := Read(strings.NewReader(tt.inventory))
+
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+
+			if got := inv.Hosts(); !slices.Equal(got, []string{"something", "else"}) {
+				t.Fatalf("Inventory.Hosts() = %v", got)
+			}
+
+			if got := inv.HostGroups("else"); !slices.Equal(got, []string{"prod", "web"}) {
+				t.Errorf("Inventory.HostGroups() = %v, want [prod web]", got)
+			}
+
+			want := map[string]string{
+				VarPort:       "2222",
+				VarUser:       "me",
+				VarCommonArgs: "-o A=b",
+			}
+
+			if got := inv.EffectiveVars("else"); !maps.Equal(got, want) {
+				t.Errorf("Inventory.EffectiveVars() = %v, want %v", got, want)
+			}
+
+			c := ToConnection("else", inv.EffectiveVars("else"))
+
+			if c.Host != "else" || c.User != "me" || c.Args != "-p 2222 -o A=b" {
+				t.Errorf("ToConnection() = %+v", c)
+			}
+		})
+	}
+}
+
+func TestToConnection(t *testing.T) {
+	tests := []struct {
+		name         string
+		host         string
+		vars         map[string]string
+		wantNickname string
+		wantHost     string
+	}{
+		{"name", "web", nil, "web", "web"},
+		{"ansible_host", "web", map[string]string{VarHost: "10.0.0.5"}, "web", "10.0.0.5"},
+		{"ip", "10.0.0.5", nil, "host-10.0.0.5", "10.0.0.5"},
+		{"ip-ansible_host", "10.0.0.5", map[string]string{VarHost: "10.0.0.6"}, "host-10.0.0.5", "10.0.0.6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := ToConnection(tt.host, tt.vars)
+
+			if c.Nickname != tt.wantNickname || c.Host != tt.wantHost {
+				t.Errorf("ToConnection() = %q, %q, want %q, %q", c.Nickname, c.Host, tt.wantNickname, tt.wantHost)
+			}
+
+			c.Id = 1
+
+			if err := c.Validate(); err != nil {
+				t.Errorf("ToConnection() is invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestInventory_EffectiveVarsPrecedence(t *testing.T) {
+	inv := New()
+
+	inv.Group(GroupAll).Vars[VarUser] = "all"
+	inv.Group("parent").Vars[VarUser] = "parent"
+	inv.Group("parent").Children = []string{"child"}
+	inv.Group("child").Vars[VarUser] = "child"
+	inv.AddHost("something", nil, "child")
+
+	if got := inv.EffectiveVars("something")[VarUser]; got != "child" {
+		t.Errorf("Inventory.EffectiveVars() user = %q, want %q", got, "child")
+	}
+
+	inv.AddHost("something", map[string]string{VarUser: "host"})
+
+	if got := inv.EffectiveVars("something")[VarUser]; got != "host" {
+		t.Errorf("Inventory.EffectiveVars() user = %q, want %q", got, "host")
+	}
+}
@@ -0,0 +1,131 @@
+package ansible
+
+import (
+	"strings"
+
+	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/cannable/sshcm/pkg/sshargs"
+)
+
+// Ansible connection variables that map onto connection properties.
+const (
+	VarHost       = "ansible_host"
+	VarUser       = "ansible_user"
+	VarPort       = "ansible_port"
+	VarIdentity   = "ansible_ssh_private_key_file"
+	VarCommonArgs = "ansible_ssh_common_args"
+	VarExtraArgs  = "ansible_ssh_extra_args"
+)
+
+// varAliases lists older or alternate names for connection variables, which
+// are only used if the preferred name isn't set.
+var varAliases = map[string][]string{
+	VarHost:     {"ansible_ssh_host"},
+	VarUser:     {"ansible_ssh_user"},
+	VarPort:     {"ansible_ssh_port"},
+	VarIdentity: {"ansible_private_key_file"},
+}
+
+// lookupVar returns a connection variable, falling back to its aliases.
+func lookupVar(vars map[string]string, name string) string {
+	if v, ok := vars[name]; ok {
+		return v
+	}
+
+	for _, alias := range varAliases[name] {
+		if v, ok := vars[alias]; ok {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// HostVars returns the Ansible connection variables for a connection. Callers
+// should apply program defaults to the connection first.
+//
+// The port, user and identity are taken from the connection's args if they're
+// set there (ex. "-p 2222"), with the connection's own user and identity
+// taking precedence. Any remaining ssh options are passed through as
+// ansible_ssh_common_args. Empty values are omitted.
+func HostVars(c *cdb.Connection) (map[string]string, error) {
+	split, err := sshargs.Split(c.Args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts, _, err := sshargs.Parse(split)
+
+	if err != nil {
+		return nil, err
+	}
+
+	vars := map[string]string{VarHost: c.Host}
+
+	// Pull out the options Ansible has dedicated variables for
+	var common []sshargs.Option
+
+	for _, o := range opts {
+		switch o.Flag {
+		case 'p':
+			vars[VarPort] = o.Value
+		case 'l':
+			vars[VarUser] = o.Value
+		case 'i':
+			vars[VarIdentity] = o.Value
+		default:
+			common = append(common, o)
+		}
+	}
+
+	if len(c.User) > 0 {
+		vars[VarUser] = c.User
+	}
+
+	if len(c.Identity) > 0 {
+		vars[VarIdentity] = c.Identity
+	}
+
+	if len(common) > 0 {
+		vars[VarCommonArgs] = sshargs.Join(sshargs.Flatten(common))
+	}
+
+	return vars, nil
+}
+
+// ToConnection returns a connection for an inventory host, given its
+// effective variables. It is the inverse of HostVars: the host's name becomes
+// the nickname (prefixed if it isn't a valid nickname, see cdb.NicknameFor,
+// as with hosts named by IP address), ansible_host (or the name, if it isn't
+// set), ansible_user and ansible_ssh_private_key_file map onto the matching
+// properties, and ansible_port, ansible_ssh_common_args and
+// ansible_ssh_extra_args become ssh args.
+func ToConnection(name string, vars map[string]string) cdb.Connection {
+	c := cdb.NewConnection()
+
+	c.Nickname = cdb.NicknameFor(name)
+	c.Host = lookupVar(vars, VarHost)
+	c.User = lookupVar(vars, VarUser)
+	c.Identity = lookupVar(vars, VarIdentity)
+
+	if len(c.Host) < 1 {
+		c.Host = name
+	}
+
+	var args []string
+
+	if port := lookupVar(vars, VarPort); len(port) > 0 {
+		args = append(args, "-p "+sshargs.Quote(port))
+	}
+
+	for _, v := range []string{VarCommonArgs, VarExtraArgs} {
+		if len(vars[v]) > 0 {
+			args = append(args, vars[v])
+		}
+	}
+
+	c.Args = strings.Join(args, " ")
+
+	return c
+}
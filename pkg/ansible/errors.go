@@ -0,0 +1,6 @@
+package ansible
+
+import "errors"
+
+var ErrInvalidHostPattern = errors.New("invalid host range pattern")
+var ErrInvalidInventory = errors.New("invalid inventory")
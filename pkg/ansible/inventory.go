@@ -0,0 +1,399 @@
+// Package ansible converts between sshcm connections and Ansible inventories.
+//
+// An Inventory can be written as an INI or YAML inventory file, or in the
+// JSON format used by Ansible's dynamic inventory script protocol.
+package ansible
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/cannable/sshcm/pkg/sshargs"
+	"gopkg.in/yaml.v3"
+)
+
+// Names of the groups every Ansible inventory implicitly contains.
+const (
+	GroupAll       = "all"
+	GroupUngrouped = "ungrouped"
+)
+
+// A Group is a named set of hosts and child groups, with variables that
+// apply to all of its members.
+type Group struct {
+	Name     string
+	Hosts    []string
+	Children []string
+	Vars     map[string]string
+}
+
+// An Inventory is a set of hosts, their variables and the groups they belong
+// to. Hosts are kept in the order they were added.
+type Inventory struct {
+	hosts    []string
+	hostVars map[string]map[string]string
+	groups   map[string]*Group
+}
+
+// New returns a new, empty Inventory.
+func New() *Inventory {
+	return &Inventory{
+		hostVars: make(map[string]map[string]string),
+		groups:   make(map[string]*Group),
+	}
+}
+
+// AddHost adds a host with the passed variables to the inventory, as a member
+// of the passed groups. Groups are created as needed. If the host already
+// exists, its variables are merged and it is added to any new groups.
+func (inv *Inventory) AddHost(name string, vars map[string]string, groups ...string) {
+	if _, ok := inv.hostVars[name]; !ok {
+		inv.hosts = append(inv.hosts, name)
+		inv.hostVars[name] = make(map[string]string)
+	}
+
+	maps.Copy(inv.hostVars[name], vars)
+
+	for _, g := range groups {
+		group := inv.Group(g)
+
+		if !slices.Contains(group.Hosts, name) {
+			group.Hosts = append(group.Hosts, name)
+		}
+	}
+}
+
+// Group returns the named group, creating it if it doesn't exist.
+func (inv *Inventory) Group(name string) *Group {
+	g, ok := inv.groups[name]
+
+	if !ok {
+		g = &Group{Name: name, Vars: make(map[string]string)}
+		inv.groups[name] = g
+	}
+
+	return g
+}
+
+// Hosts returns the names of all hosts in the inventory.
+func (inv *Inventory) Hosts() []string {
+	return slices.Clone(inv.hosts)
+}
+
+// HostVars returns the variables set directly on a host.
+func (inv *Inventory) HostVars(name string) map[string]string {
+	return inv.hostVars[name]
+}
+
+// Groups returns the names of all groups in the inventory, sorted, excluding
+// the implicit all and ungrouped groups.
+func (inv *Inventory) Groups() []string {
+	var names []string
+
+	for name := range inv.groups {
+		if name != GroupAll && name != GroupUngrouped {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// parents returns the names of the groups that have the passed group as a
+// child, sorted.
+func (inv *Inventory) parents(name string) []string {
+	var names []string
+
+	for _, g := range inv.Groups() {
+		if slices.Contains(inv.groups[g].Children, name) {
+			names = append(names, g)
+		}
+	}
+
+	return names
+}
+
+// depth returns how deeply a group is nested below the all group. Groups with
+// no parents have a depth of 1. Cycles are ignored.
+func (inv *Inventory) depth(name string, seen map[string]bool) int {
+	if seen[name] {
+		return 0
+	}
+
+	seen[name] = true
+	defer delete(seen, name)
+
+	d := 1
+
+	for _, p := range inv.parents(name) {
+		d = max(d, inv.depth(p, seen)+1)
+	}
+
+	return d
+}
+
+// HostGroups returns the names of all groups a host is a member of, directly
+// or through child groups, sorted. The implicit all and ungrouped groups are
+// not included.
+func (inv *Inventory) HostGroups(name string) []string {
+	member := make(map[string]bool)
+
+	var visit func(g string)
+
+	visit = func(g string) {
+		if member[g] {
+			return
+		}
+
+		member[g] = true
+
+		for _, p := range inv.parents(g) {
+			visit(p)
+		}
+	}
+
+	for _, g := range inv.Groups() {
+		if slices.Contains(inv.groups[g].Hosts, name) {
+			visit(g)
+		}
+	}
+
+	return slices.Sorted(maps.Keys(member))
+}
+
+// EffectiveVars returns the variables that apply to a host, merged the way
+// Ansible merges them: variables from the all group, then from each group the
+// host is a member of (parent groups before child groups, then by name), then
+// variables set on the host itself.
+func (inv *Inventory) EffectiveVars(name string) map[string]string {
+	vars := make(map[string]string)
+
+	if g, ok := inv.groups[GroupAll]; ok {
+		maps.Copy(vars, g.Vars)
+	}
+
+	groups := inv.HostGroups(name)
+	depths := make(map[string]int, len(groups))
+
+	for _, g := range groups {
+		depths[g] = inv.depth(g, make(map[string]bool))
+	}
+
+	slices.SortStableFunc(groups, func(a, b string) int {
+		return depths[a] - depths[b]
+	})
+
+	for _, g := range groups {
+		maps.Copy(vars, inv.groups[g].Vars)
+	}
+
+	maps.Copy(vars, inv.hostVars[name])
+
+	return vars
+}
+
+// ungrouped returns the hosts that aren't a member of any group.
+func (inv *Inventory) ungrouped() []string {
+	var hosts []string
+
+	for _, h := range inv.hosts {
+		grouped := false
+
+		for _, g := range inv.Groups() {
+			if slices.Contains(inv.groups[g].Hosts, h) {
+				grouped = true
+				break
+			}
+		}
+
+		if !grouped {
+			hosts = append(hosts, h)
+		}
+	}
+
+	return hosts
+}
+
+// formatINIVars formats variables as space-separated key=value pairs, sorted
+// by key. Values are quoted as needed.
+func formatINIVars(vars map[string]string) string {
+	var pairs []string
+
+	for _, k := range slices.Sorted(maps.Keys(vars)) {
+		pairs = append(pairs, k+"="+sshargs.Quote(vars[k]))
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// WriteINI writes the inventory in Ansible's INI inventory format.
+func (inv *Inventory) WriteINI(w io.Writer) error {
+	var b strings.Builder
+
+	// Hosts that aren't in a group are listed before the first section
+	for _, h := range inv.ungrouped() {
+		fmt.Fprintf(&b, "%s %s\n", h, formatINIVars(inv.hostVars[h]))
+	}
+
+	for _, name := range inv.Groups() {
+		g := inv.groups[name]
+
+		fmt.Fprintf(&b, "\n[%s]\n", name)
+
+		for _, h := range g.Hosts {
+			fmt.Fprintf(&b, "%s %s\n", h, formatINIVars(inv.hostVars[h]))
+		}
+
+		if len(g.Children) > 0 {
+			fmt.Fprintf(&b, "\n[%s:children]\n", name)
+
+			for _, c := range g.Children {
+				fmt.Fprintln(&b, c)
+			}
+		}
+
+		if len(g.Vars) > 0 {
+			fmt.Fprintf(&b, "\n[%s:vars]\n", name)
+
+			for _, k := range slices.Sorted(maps.Keys(g.Vars)) {
+				fmt.Fprintf(&b, "%s=%s\n", k, sshargs.Quote(g.Vars[k]))
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, strings.TrimLeft(b.String(), "\n"))
+
+	return err
+}
+
+// yamlGroup is the layout of a group in a YAML inventory.
+type yamlGroup struct {
+	Hosts    map[string]map[string]string `yaml:"hosts,omitempty"`
+	Vars     map[string]string            `yaml:"vars,omitempty"`
+	Children map[string]*yamlGroup        `yaml:"children,omitempty"`
+}
+
+// WriteYAML writes the inventory in Ansible's YAML inventory format. Host
+// variables are written where the host is first listed.
+func (inv *Inventory) WriteYAML(w io.Writer) error {
+	all := &yamlGroup{}
+	written := make(map[string]bool)
+
+	// hostEntry returns a host's variables the first time it's written, after
+	// that it returns an empty entry
+	hostEntry := func(h string) map[string]string {
+		if written[h] {
+			return nil
+		}
+
+		written[h] = true
+
+		return inv.hostVars[h]
+	}
+
+	if hosts := inv.ungrouped(); len(hosts) > 0 {
+		all.Hosts = make(map[string]map[string]string)
+
+		for _, h := range hosts {
+			all.Hosts[h] = hostEntry(h)
+		}
+	}
+
+	if g, ok := inv.groups[GroupAll]; ok && len(g.Vars) > 0 {
+		all.Vars = g.Vars
+	}
+
+	for _, name := range inv.Groups() {
+		g := inv.groups[name]
+		yg := &yamlGroup{Vars: g.Vars}
+
+		if len(g.Hosts) > 0 {
+			yg.Hosts = make(map[string]map[string]string)
+
+			for _, h := range g.Hosts {
+				yg.Hosts[h] = hostEntry(h)
+			}
+		}
+
+		if len(g.Children) > 0 {
+			yg.Children = make(map[string]*yamlGroup)
+
+			for _, c := range g.Children {
+				yg.Children[c] = &yamlGroup{}
+			}
+		}
+
+		if all.Children == nil {
+			all.Children = make(map[string]*yamlGroup)
+		}
+
+		all.Children[name] = yg
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(map[string]*yamlGroup{GroupAll: all}); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+// List returns the inventory in the layout Ansible expects from a dynamic
+// inventory script called with --list. Host variables are included in the
+// _meta section, so that Ansible doesn't need to call the script with --host
+// for each host.
+func (inv *Inventory) List() map[string]any {
+	list := make(map[string]any)
+	hostVars := make(map[string]map[string]string)
+
+	for _, h := range inv.hosts {
+		hostVars[h] = inv.hostVars[h]
+	}
+
+	list["_meta"] = map[string]any{"hostvars": hostVars}
+
+	children := append(inv.Groups(), GroupUngrouped)
+
+	all := map[string]any{"children": children}
+
+	if g, ok := inv.groups[GroupAll]; ok && len(g.Vars) > 0 {
+		all["vars"] = g.Vars
+	}
+
+	list[GroupAll] = all
+	list[GroupUngrouped] = map[string]any{"hosts": nonNil(inv.ungrouped())}
+
+	for _, name := range inv.Groups() {
+		g := inv.groups[name]
+		entry := map[string]any{"hosts": nonNil(g.Hosts)}
+
+		if len(g.Children) > 0 {
+			entry["children"] = g.Children
+		}
+
+		if len(g.Vars) > 0 {
+			entry["vars"] = g.Vars
+		}
+
+		list[name] = entry
+	}
+
+	return list
+}
+
+// nonNil returns s, or an empty slice if s is nil, so that it is encoded as an
+// empty JSON list rather than null.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+
+	return s
+}
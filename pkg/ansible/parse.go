@@ -0,0 +1,446 @@
+package ansible
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cannable/sshcm/pkg/sshargs"
+	"gopkg.in/yaml.v3"
+)
+
+// hostRange matches a host range pattern (ex. web[01:10] or db-[a:c]), with
+// an optional stride.
+var hostRange = regexp.MustCompile(`\[([0-9a-zA-Z]+):([0-9a-zA-Z]+)(?::([0-9]+))?\]`)
+
+// Read reads an inventory in either Ansible's INI or YAML inventory format.
+// The format is detected from the content: YAML inventories are mappings of
+// group names to groups, which INI inventories never parse as.
+func Read(r io.Reader) (*Inventory, error) {
+	data, err := io.ReadAll(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if isYAMLInventory(data) {
+		return ReadYAML(bytes.NewReader(data))
+	}
+
+	return ReadINI(bytes.NewReader(data))
+}
+
+// isYAMLInventory returns true if data is a YAML mapping of group names to
+// group mappings.
+func isYAMLInventory(data []byte) bool {
+	var doc yaml.Node
+
+	if yaml.Unmarshal(data, &doc) != nil || len(doc.Content) == 0 {
+		return false
+	}
+
+	root := doc.Content[0]
+
+	if root.Kind != yaml.MappingNode || len(root.Content) == 0 {
+		return false
+	}
+
+	for i := 1; i < len(root.Content); i += 2 {
+		value := root.Content[i]
+
+		if value.Kind != yaml.MappingNode && value.Tag != "!!null" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// expandHostPattern expands a host range pattern into a list of host names.
+// Numeric ranges keep the zero-padding of their start value (ex. web[01:03]
+// expands to web01, web02 and web03). Names without a range are returned
+// as-is.
+func expandHostPattern(pattern string) ([]string, error) {
+	m := hostRange.FindStringSubmatchIndex(pattern)
+
+	if m == nil {
+		return []string{pattern}, nil
+	}
+
+	prefix := pattern[:m[0]]
+	suffix := pattern[m[1]:]
+	start := pattern[m[2]:m[3]]
+	end := pattern[m[4]:m[5]]
+	stride := 1
+
+	if m[6] >= 0 {
+		stride, _ = strconv.Atoi(pattern[m[6]:m[7]])
+	}
+
+	if stride < 1 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidHostPattern, pattern)
+	}
+
+	var values []string
+
+	if first, err := strconv.Atoi(start); err == nil {
+		last, err := strconv.Atoi(end)
+
+		if err != nil || last < first {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidHostPattern, pattern)
+		}
+
+		for i := first; i <= last; i += stride {
+			values = append(values, fmt.Sprintf("%0*d", len(start), i))
+		}
+	} else {
+		if len(start) != 1 || len(end) != 1 || end[0] < start[0] {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidHostPattern, pattern)
+		}
+
+		for c := start[0]; c <= end[0]; c += byte(stride) {
+			values = append(values, string(c))
+
+			// Guard against wrapping around
+			if int(c)+stride > 255 {
+				break
+			}
+		}
+	}
+
+	// Expand any further ranges in the rest of the pattern
+	rest, err := expandHostPattern(suffix)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+
+	for _, v := range values {
+		for _, r := range rest {
+			hosts = append(hosts, prefix+v+r)
+		}
+	}
+
+	return hosts, nil
+}
+
+// splitHostPort splits a host name with a trailing port (ex. web:2222). Host
+// names with more than one colon are assumed to be IPv6 addresses without a
+// port.
+func splitHostPort(name string) (string, string) {
+	host, port, found := strings.Cut(name, ":")
+
+	if !found || strings.Contains(port, ":") {
+		return name, ""
+	}
+
+	if _, err := strconv.Atoi(port); err != nil {
+		return name, ""
+	}
+
+	return host, port
+}
+
+// addParsedHost adds hosts from an inventory host entry, which may be a range
+// pattern and have a trailing port, to the passed group.
+func (inv *Inventory) addParsedHost(entry string, vars map[string]string, group string) error {
+	pattern, port := splitHostPort(entry)
+
+	names, err := expandHostPattern(pattern)
+
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		hv := make(map[string]string, len(vars)+1)
+
+		if len(port) > 0 {
+			hv[VarPort] = port
+		}
+
+		for k, v := range vars {
+			hv[k] = v
+		}
+
+		if group == GroupAll || group == GroupUngrouped {
+			inv.AddHost(name, hv)
+		} else {
+			inv.AddHost(name, hv, group)
+		}
+	}
+
+	return nil
+}
+
+// addChild makes child a child group of parent.
+func (inv *Inventory) addChild(parent, child string) {
+	inv.Group(child)
+
+	if parent == GroupAll {
+		return
+	}
+
+	g := inv.Group(parent)
+
+	if !slices.Contains(g.Children, child) {
+		g.Children = append(g.Children, child)
+	}
+}
+
+// parseINIValue unquotes an INI inventory value. Unquoted values are used
+// as-is.
+func parseINIValue(s string) (string, error) {
+	words, err := sshargs.Split(s)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+// ReadINI reads an inventory in Ansible's INI inventory format.
+func ReadINI(r io.Reader) (*Inventory, error) {
+	inv := New()
+	s := bufio.NewScanner(r)
+
+	group := GroupUngrouped
+	kind := "hosts"
+	line := 0
+
+	for s.Scan() {
+		line++
+
+		text := strings.TrimSpace(s.Text())
+
+		if len(text) == 0 || text[0] == '#' || text[0] == ';' {
+			continue
+		}
+
+		invalid := func(err error) error {
+			return fmt.Errorf("%w: line %d: %w", ErrInvalidInventory, line, err)
+		}
+
+		// Section header
+		if text[0] == '[' {
+			if !strings.HasSuffix(text, "]") {
+				return nil, invalid(fmt.Errorf("unterminated section header %s", text))
+			}
+
+			group, kind, _ = strings.Cut(text[1:len(text)-1], ":")
+
+			switch kind {
+			case "":
+				kind = "hosts"
+			case "vars", "children":
+			default:
+				return nil, invalid(fmt.Errorf("unknown section type %s", kind))
+			}
+
+			inv.Group(group)
+
+			continue
+		}
+
+		switch kind {
+		case "hosts":
+			words, err := sshargs.Split(text)
+
+			if err != nil {
+				return nil, invalid(err)
+			}
+
+			vars := make(map[string]string)
+
+			for _, w := range words[1:] {
+				if strings.HasPrefix(w, "#") {
+					break
+				}
+
+				k, v, found := strings.Cut(w, "=")
+
+				if !found {
+					return nil, invalid(fmt.Errorf("expected key=value, got %s", w))
+				}
+
+				vars[k] = v
+			}
+
+			if err := inv.addParsedHost(words[0], vars, group); err != nil {
+				return nil, invalid(err)
+			}
+		case "vars":
+			k, v, found := strings.Cut(text, "=")
+
+			if !found {
+				return nil, invalid(fmt.Errorf("expected key=value, got %s", text))
+			}
+
+			value, err := parseINIValue(strings.TrimSpace(v))
+
+			if err != nil {
+				return nil, invalid(err)
+			}
+
+			inv.Group(group).Vars[strings.TrimSpace(k)] = value
+		case "children":
+			inv.addChild(group, text)
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return inv, nil
+}
+
+// yamlVars decodes a YAML mapping of variables. Scalar values are kept as
+// written, while lists and mappings are converted to their YAML flow form.
+func yamlVars(node *yaml.Node) (map[string]string, error) {
+	vars := make(map[string]string)
+
+	if node == nil || node.Tag == "!!null" {
+		return vars, nil
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%w: line %d: expected a mapping", ErrInvalidInventory, node.Line)
+	}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		value := node.Content[i+1]
+
+		if value.Kind == yaml.ScalarNode {
+			if value.Tag != "!!null" {
+				vars[key] = value.Value
+			}
+
+			continue
+		}
+
+		value.Style = yaml.FlowStyle
+
+		out, err := yaml.Marshal(value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		vars[key] = strings.TrimSpace(string(out))
+	}
+
+	return vars, nil
+}
+
+// readYAMLGroup adds a group, and recursively its children, from a YAML
+// inventory to the inventory.
+func (inv *Inventory) readYAMLGroup(name string, node *yaml.Node) error {
+	inv.Group(name)
+
+	if node.Tag == "!!null" {
+		return nil
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("%w: line %d: expected a mapping for group %s", ErrInvalidInventory, node.Line, name)
+	}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i]
+		value := node.Content[i+1]
+
+		switch key.Value {
+		case "hosts":
+			if value.Tag == "!!null" {
+				continue
+			}
+
+			if value.Kind != yaml.MappingNode {
+				return fmt.Errorf("%w: line %d: expected a mapping of hosts", ErrInvalidInventory, value.Line)
+			}
+
+			for j := 0; j < len(value.Content); j += 2 {
+				vars, err := yamlVars(value.Content[j+1])
+
+				if err != nil {
+					return err
+				}
+
+				if err := inv.addParsedHost(value.Content[j].Value, vars, name); err != nil {
+					return fmt.Errorf("%w: line %d: %w", ErrInvalidInventory, value.Content[j].Line, err)
+				}
+			}
+		case "vars":
+			vars, err := yamlVars(value)
+
+			if err != nil {
+				return err
+			}
+
+			for k, v := range vars {
+				inv.Group(name).Vars[k] = v
+			}
+		case "children":
+			if value.Tag == "!!null" {
+				continue
+			}
+
+			if value.Kind != yaml.MappingNode {
+				return fmt.Errorf("%w: line %d: expected a mapping of child groups", ErrInvalidInventory, value.Line)
+			}
+
+			for j := 0; j < len(value.Content); j += 2 {
+				child := value.Content[j].Value
+
+				inv.addChild(name, child)
+
+				if err := inv.readYAMLGroup(child, value.Content[j+1]); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("%w: line %d: unknown group key %s", ErrInvalidInventory, key.Line, key.Value)
+		}
+	}
+
+	return nil
+}
+
+// ReadYAML reads an inventory in Ansible's YAML inventory format.
+func ReadYAML(r io.Reader) (*Inventory, error) {
+	var doc yaml.Node
+
+	err := yaml.NewDecoder(r).Decode(&doc)
+
+	if err == io.EOF {
+		return New(), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidInventory, err)
+	}
+
+	root := doc.Content[0]
+
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%w: line %d: expected a mapping of groups", ErrInvalidInventory, root.Line)
+	}
+
+	inv := New()
+
+	for i := 0; i < len(root.Content); i += 2 {
+		if err := inv.readYAMLGroup(root.Content[i].Value, root.Content[i+1]); err != nil {
+			return nil, err
+		}
+	}
+
+	return inv, nil
+}
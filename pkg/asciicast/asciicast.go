@@ -0,0 +1,235 @@
+// Package asciicast reads and writes terminal session recordings in the
+// asciicast v2 format (https://docs.asciinema.org/manual/asciicast/v2/), as
+// used by asciinema.
+//
+// A recording is a JSON header line, followed by one JSON line per event. Each
+// event is an array of the time since the recording started (in seconds), the
+// event type and its data (ex. [1.5, "o", "hello"]).
+package asciicast
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Version is the asciicast format version read and written by this package.
+const Version = 2
+
+// Event types.
+const (
+	Output = "o" // data written to the terminal
+	Input  = "i" // data typed at the terminal
+	Resize = "r" // terminal resized, with data as "COLSxROWS"
+	Marker = "m" // marker (ex. a chapter), with data as its label
+)
+
+// A Header is the first line of a recording.
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// An Event is something that happened during a recording.
+type Event struct {
+	Time float64 // seconds since the recording started
+	Type string  // event type (ex. Output)
+	Data string
+}
+
+// MarshalJSON encodes the event as an asciicast event array.
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{e.Time, e.Type, e.Data})
+}
+
+// UnmarshalJSON decodes an asciicast event array.
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var fields []json.RawMessage
+
+	if err := json.Unmarshal(b, &fields); err != nil || len(fields) != 3 {
+		return ErrInvalidEvent
+	}
+
+	if json.Unmarshal(fields[0], &e.Time) != nil ||
+		json.Unmarshal(fields[1], &e.Type) != nil ||
+		json.Unmarshal(fields[2], &e.Data) != nil {
+		return ErrInvalidEvent
+	}
+
+	return nil
+}
+
+// A Writer records terminal output as an asciicast. It is safe for concurrent
+// use.
+type Writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	started time.Time
+	pending []byte
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+}
+
+// NewWriter writes the passed header to w and returns a Writer that records
+// events after it. The recording starts now, and if the header has no
+// timestamp, it is set to the current time. The version is always set to
+// Version.
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	return newWriter(w, h, time.Now)
+}
+
+func newWriter(w io.Writer, h Header, now func() time.Time) (*Writer, error) {
+	aw := &Writer{w: w, started: now(), now: now}
+
+	h.Version = Version
+
+	if h.Timestamp == 0 {
+		h.Timestamp = aw.started.Unix()
+	}
+
+	if err := aw.writeLine(h); err != nil {
+		return nil, err
+	}
+
+	return aw, nil
+}
+
+// writeLine writes v as a line of JSON.
+func (aw *Writer) writeLine(v any) error {
+	b, err := json.Marshal(v)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = aw.w.Write(append(b, '\n'))
+
+	return err
+}
+
+// Write records p as terminal output. A multi-byte character split across
+// writes is recorded once it is complete.
+func (aw *Writer) Write(p []byte) (int, error) {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	data := append(aw.pending, p...)
+	n := len(data) - incompleteSuffix(data)
+
+	aw.pending = bytes.Clone(data[n:])
+
+	if n == 0 {
+		return len(p), nil
+	}
+
+	if err := aw.writeLine(Event{aw.elapsed(), Output, string(data[:n])}); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Resize records the terminal being resized.
+func (aw *Writer) Resize(width int, height int) error {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	return aw.writeLine(Event{aw.elapsed(), Resize, fmt.Sprintf("%dx%d", width, height)})
+}
+
+// elapsed returns the seconds since the recording started, to the
+// microsecond.
+func (aw *Writer) elapsed() float64 {
+	return float64(aw.now().Sub(aw.started).Microseconds()) / 1e6
+}
+
+// incompleteSuffix returns the length of the incomplete UTF-8 sequence at the
+// end of b, if there is one.
+func incompleteSuffix(b []byte) int {
+	for i := 1; i < utf8.UTFMax && i <= len(b); i++ {
+		c := b[len(b)-i]
+
+		if utf8.RuneStart(c) {
+			if !utf8.FullRune(b[len(b)-i:]) {
+				return i
+			}
+
+			return 0
+		}
+	}
+
+	return 0
+}
+
+// A Reader reads a recording.
+type Reader struct {
+	Header Header
+
+	s    *bufio.Scanner
+	line int
+}
+
+// NewReader reads the header of a recording from r, and returns a Reader for
+// its events.
+func NewReader(r io.Reader) (*Reader, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+
+	ar := &Reader{s: s}
+
+	if !s.Scan() {
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, ErrInvalidHeader
+	}
+
+	ar.line = 1
+
+	if err := json.Unmarshal(s.Bytes(), &ar.Header); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+
+	if ar.Header.Version != Version {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, ar.Header.Version)
+	}
+
+	return ar, nil
+}
+
+// Next returns the next event in the recording, or io.EOF once all of them
+// have been read. Blank lines are skipped.
+func (ar *Reader) Next() (Event, error) {
+	for ar.s.Scan() {
+		ar.line++
+
+		if len(bytes.TrimSpace(ar.s.Bytes())) == 0 {
+			continue
+		}
+
+		var e Event
+
+		if err := json.Unmarshal(ar.s.Bytes(), &e); err != nil {
+			return Event{}, fmt.Errorf("%w: line %d", ErrInvalidEvent, ar.line)
+		}
+
+		return e, nil
+	}
+
+	if err := ar.s.Err(); err != nil {
+		return Event{}, err
+	}
+
+	return Event{}, io.EOF
+}
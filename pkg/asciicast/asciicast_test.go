@@ -0,0 +1,152 @@
+package asciicast
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	started := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := started
+
+	var b bytes.Buffer
+
+	w, err := newWriter(&b, Header{Width: 80, Height: 24, Title: "web"}, func() time.Time { return now })
+
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+
+	now = now.Add(1500 * time.Millisecond)
+	w.Write([]byte("hello\r\n"))
+
+	// "é" split across two writes is recorded once it's complete
+	now = now.Add(time.Second)
+	w.Write([]byte("caf\xc3"))
+	now = now.Add(time.Second)
+	w.Write([]byte("\xa9"))
+
+	w.Resize(100, 30)
+
+	want := `{"version":2,"width":80,"height":24,"timestamp":1777636800,"title":"web"}
+[1.5,"o","hello\r\n"]
+[2.5,"o","caf"]
+[3.5,"o","é"]
+[3.5,"r","100x30"]
+`
+
+	if b.String() != want {
+		t.Errorf("recording = %s, want %s", b.String(), want)
+	}
+}
+
+func TestReader(t *testing.T) {
+	r, err := NewReader(strings.NewReader(`{"version":2,"width":80,"height":24}
+[0.5,"o","a"]
+
+[1,"i","b"]
+`))
+
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+
+	if r.Header.Width != 80 || r.Header.Height != 24 {
+		t.Errorf("Header = %v, want 80x24", r.Header)
+	}
+
+	var got []Event
+
+	for {
+		e, err := r.Next()
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Reader.Next() error = %v", err)
+		}
+
+		got = append(got, e)
+	}
+
+	want := []Event{{0.5, Output, "a"}, {1, Input, "b"}}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr error
+	}{
+		{"empty", "", ErrInvalidHeader},
+		{"header", "[1]\n", ErrInvalidHeader},
+		{"version", `{"version":1}` + "\n", ErrUnsupportedVersion},
+		{"event", `{"version":2}` + "\n" + `[1,"o"]` + "\n", ErrInvalidEvent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(strings.NewReader(tt.data))
+
+			if err == nil {
+				_, err = r.Next()
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlay(t *testing.T) {
+	var delays []time.Duration
+
+	sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	data := `{"version":2,"width":80,"height":24}
+[1,"o","a"]
+[1.5,"r","100x30"]
+[2,"o","b"]
+[12,"o","c"]
+`
+
+	tests := []struct {
+		name string
+		opts PlayOptions
+		want []time.Duration
+	}{
+		{"recorded", PlayOptions{}, []time.Duration{time.Second, time.Second, 10 * time.Second}},
+		{"speed", PlayOptions{Speed: 2}, []time.Duration{500 * time.Millisecond, 500 * time.Millisecond, 5 * time.Second}},
+		{"idle", PlayOptions{IdleLimit: 2 * time.Second}, []time.Duration{time.Second, time.Second, 2 * time.Second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delays = nil
+
+			r, _ := NewReader(strings.NewReader(data))
+
+			var b bytes.Buffer
+
+			if err := Play(context.Background(), &b, r, tt.opts); err != nil {
+				t.Fatalf("Play() error = %v", err)
+			}
+
+			if b.String() != "abc" || !slices.Equal(delays, tt.want) {
+				t.Errorf("Play() = %q after %v, want %q after %v", b.String(), delays, "abc", tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+package asciicast
+
+import "errors"
+
+var ErrInvalidEvent = errors.New("invalid recording event")
+var ErrInvalidHeader = errors.New("invalid recording header")
+var ErrUnsupportedVersion = errors.New("unsupported recording version")
@@ -0,0 +1,75 @@
+package asciicast
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// PlayOptions controls how a recording is played back.
+type PlayOptions struct {
+	// Speed multiplies the playback speed (ex. 2 plays twice as fast). Zero
+	// plays at the recorded speed.
+	Speed float64
+
+	// IdleLimit caps the pause between events. Zero leaves pauses as they
+	// were recorded.
+	IdleLimit time.Duration
+}
+
+// sleep waits for d, or until ctx is done. It is replaced in tests.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Play writes the output events read from r to w, with the timing they were
+// recorded with. Other events are skipped. Playback stops early if ctx is
+// done.
+func Play(ctx context.Context, w io.Writer, r *Reader, opts PlayOptions) error {
+	speed := opts.Speed
+
+	if speed <= 0 {
+		speed = 1
+	}
+
+	last := 0.0
+
+	for {
+		e, err := r.Next()
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if e.Type != Output {
+			continue
+		}
+
+		delay := time.Duration((e.Time - last) / speed * float64(time.Second))
+		last = e.Time
+
+		if opts.IdleLimit > 0 {
+			delay = min(delay, opts.IdleLimit)
+		}
+
+		if delay > 0 {
+			if err := sleep(ctx, delay); err != nil {
+				return err
+			}
+		}
+
+		if _, err := io.WriteString(w, e.Data); err != nil {
+			return err
+		}
+	}
+}
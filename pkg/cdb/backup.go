@@ -0,0 +1,247 @@
+package cdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ArchiveFormatVersion is the version of the backup archive layout written by
+// this package. It is independent of the DB schema version.
+const ArchiveFormatVersion = 1
+
+// An Archive is a full copy of a connection DB, including program defaults,
+// global settings and connections.
+//
+// Every table in the DB is copied, so tables added by future schema versions
+// are included without changes to the archive layout.
+type Archive struct {
+	FormatVersion int                      `json:"format_version"`
+	SchemaVersion string                   `json:"schema_version"`
+	Created       time.Time                `json:"created"`
+	Tables        map[string]*ArchiveTable `json:"tables"`
+}
+
+// An ArchiveTable holds the contents of a single DB table.
+type ArchiveTable struct {
+	Columns []string `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+// archiveUpgrades contains functions that upgrade an archive from the
+// previous schema version to the keyed schema version. They mirror the DB
+// schema upgrades.
+var archiveUpgrades = map[string]func(a *Archive) error{
+	"v1.1": func(a *Archive) error {
+		addArchiveColumn(a, "connections", "binary")
+		return nil
+	},
+	"v1.2": func(a *Archive) error {
+		addArchiveColumn(a, "connections", "jump")
+		return nil
+	},
+	"v1.3": func(a *Archive) error {
+		addArchiveColumn(a, "connections", "pre_hook")
+		addArchiveColumn(a, "connections", "post_hook")
+		return nil
+	},
+	"v1.4": func(a *Archive) error {
+		// Restoring an older archive clears the session records
+		if _, ok := a.Tables["sessions"]; !ok {
+			a.Tables["sessions"] = &ArchiveTable{Columns: fileTables["sessions"], Rows: [][]any{}}
+		}
+
+		return nil
+	},
+	"v1.5": func(a *Archive) error {
+		addArchiveColumn(a, "connections", "record")
+		addArchiveColumn(a, "sessions", "recording")
+		return nil
+	},
+	"v1.6": func(a *Archive) error {
+		addArchiveColumn(a, "connections", "range")
+		return nil
+	},
+}
+
+// addArchiveColumn adds an empty column to an archive table, if the archive
+// has the table and it doesn't have the column already.
+func addArchiveColumn(a *Archive, table string, column string) {
+	t, ok := a.Tables[table]
+
+	if !ok || slices.Contains(t.Columns, column) {
+		return
+	}
+
+	t.Columns = append(t.Columns, column)
+
+	for i := range t.Rows {
+		t.Rows[i] = append(t.Rows[i], nil)
+	}
+}
+
+// normalizeSchemaVersion returns version prefixed with a "v", as schema
+// versions written by the Tcl version of the tool don't have one.
+func normalizeSchemaVersion(version string) string {
+	if !strings.HasPrefix(version, "v") {
+		return "v" + version
+	}
+
+	return version
+}
+
+// ReadArchive decodes an archive from the passed reader. The archive's format
+// version is checked, but its schema version is not. See Restore.
+//
+// ErrArchiveInvalid is returned if the reader doesn't hold an archive.
+func ReadArchive(r io.Reader) (*Archive, error) {
+	var a Archive
+
+	d := json.NewDecoder(r)
+
+	// Keep numbers as-is, so that integers survive the round trip
+	d.UseNumber()
+
+	if err := d.Decode(&a); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrArchiveInvalid, err)
+	}
+
+	if a.FormatVersion < 1 || a.Tables == nil {
+		return nil, ErrArchiveInvalid
+	}
+
+	if a.FormatVersion > ArchiveFormatVersion {
+		return nil, ErrArchiveFormatTooNew
+	}
+
+	return &a, nil
+}
+
+// Write encodes the archive to the passed writer.
+func (a *Archive) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(a)
+}
+
+// Upgrade upgrades the archive to the schema version supported by this
+// package. Archives that are already at the current version are unchanged.
+//
+// ErrSchemaTooNew is returned if the archive is newer than this package, and
+// ErrSchemaNoUpgrade if it is too old to be upgraded.
+func (a *Archive) Upgrade() error {
+	err := ValidateDbSchemaVersion(a.SchemaVersion)
+
+	if err == nil {
+		return nil
+	} else if err != ErrSchemaUpgradeNeeded {
+		return err
+	}
+
+	// Apply each upgrade newer than the archive, oldest first
+	for _, v := range pendingUpgrades(a.SchemaVersion, archiveUpgrades) {
+		if err := archiveUpgrades[v](a); err != nil {
+			return err
+		}
+	}
+
+	a.SchemaVersion = SchemaVersion
+
+	// Keep the archived copy of the global settings consistent
+	if t, ok := a.Tables["global"]; ok {
+		setting := slices.Index(t.Columns, "setting")
+		value := slices.Index(t.Columns, "value")
+
+		for _, row := range t.Rows {
+			if setting >= 0 && value >= 0 && row[setting] == "schema_version" {
+				row[value] = SchemaVersion
+			}
+		}
+	}
+
+	return nil
+}
+
+// BackupContext returns an Archive containing the contents of every table in
+// the connection DB.
+func (conndb *ConnectionDB) BackupContext(ctx context.Context) (*Archive, error) {
+	version, err := conndb.GetDbSchemaVersionContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Archive{
+		FormatVersion: ArchiveFormatVersion,
+		SchemaVersion: version,
+		Created:       time.Now().UTC(),
+	}
+
+	a.Tables, err = conndb.store.Dump(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Backup uses context.Background internally; to specify the context, use
+// BackupContext.
+func (conndb *ConnectionDB) Backup() (*Archive, error) {
+	return conndb.BackupContext(context.Background())
+}
+
+// RestoreContext replaces the contents of the connection DB with the contents
+// of the passed archive. The archive is upgraded first, if it was made with an
+// older schema version. The connection DB must be initialized and at the
+// current schema version.
+//
+// Tables present in the archive are emptied, then refilled from the archive, in
+// a single transaction. If any part of the restore fails, the DB is left
+// unchanged.
+func (conndb *ConnectionDB) RestoreContext(ctx context.Context, a *Archive) error {
+	if err := a.Upgrade(); err != nil {
+		return err
+	}
+
+	if err := conndb.CheckDbHealthContext(ctx); err != nil {
+		return err
+	}
+
+	return conndb.store.WithTx(ctx, func(st Store) error {
+		return st.Load(ctx, a.Tables)
+	})
+}
+
+// Restore uses context.Background internally; to specify the context, use
+// RestoreContext.
+func (conndb *ConnectionDB) Restore(a *Archive) error {
+	return conndb.RestoreContext(context.Background(), a)
+}
+
+// archiveValues converts values decoded from an archive into SQL parameters.
+func archiveValues(row []any) []any {
+	values := make([]any, len(row))
+
+	for i, v := range row {
+		n, ok := v.(json.Number)
+
+		if !ok {
+			values[i] = v
+		} else if i64, err := n.Int64(); err == nil {
+			values[i] = i64
+		} else if f64, err := n.Float64(); err == nil {
+			values[i] = f64
+		} else {
+			values[i] = n.String()
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,186 @@
+package cdb
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newTestConnDb returns a new, initialized ConnectionDB backed by a Sqlite
+// file in a temporary directory.
+func newTestConnDb(t testing.TB) *ConnectionDB {
+	t.Helper()
+
+	conndb, err := Connect("sqlite", filepath.Join(t.TempDir(), "test.connections"))
+
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	if err := conndb.InitializeDb(SchemaVersion); err != nil {
+		t.Fatalf("ConnectionDB.InitializeDb() error = %v", err)
+	}
+
+	t.Cleanup(conndb.Close)
+
+	return &conndb
+}
+
+func TestConnectionDB_BackupRestore(t *testing.T) {
+	src := newTestConnDb(t)
+
+	for _, c := range []Connection{
+		{Nickname: "something", Host: "somewhere", User: "me"},
+		{Nickname: "else", Host: "elsewhere", Args: "-p 2222"},
+	} {
+		if _, err := src.Add(&c); err != nil {
+			t.Fatalf("ConnectionDB.Add() error = %v", err)
+		}
+	}
+
+	if err := src.SetDefault("user", "asdf"); err != nil {
+		t.Fatalf("ConnectionDB.SetDefault() error = %v", err)
+	}
+
+	a, err := src.Backup()
+
+	if err != nil {
+		t.Fatalf("ConnectionDB.Backup() error = %v", err)
+	}
+
+	var b bytes.Buffer
+
+	if err := a.Write(&b); err != nil {
+		t.Fatalf("Archive.Write() error = %v", err)
+	}
+
+	restored, err := ReadArchive(&b)
+
+	if err != nil {
+		t.Fatalf("ReadArchive() error = %v", err)
+	}
+
+	// Restore into a DB with different contents, which should be replaced
+	dst := newTestConnDb(t)
+
+	if _, err := dst.Add(&Connection{Nickname: "stale", Host: "gone"}); err != nil {
+		t.Fatalf("ConnectionDB.Add() error = %v", err)
+	}
+
+	if err := dst.Restore(restored); err != nil {
+		t.Fatalf("ConnectionDB.Restore() error = %v", err)
+	}
+
+	want, _ := src.GetAll()
+	got, err := dst.GetAll()
+
+	if err != nil {
+		t.Fatalf("ConnectionDB.GetAll() error = %v", err)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("restored %d connections, want %d", len(got), len(want))
+	}
+
+	for i := range got {
+		if got[i].String() != want[i].String() || got[i].Args != want[i].Args {
+			t.Errorf("restored connection %v, want %v", got[i], want[i])
+		}
+	}
+
+	if user, _ := dst.GetDefault("user"); user != "asdf" {
+		t.Errorf("restored default user = %q, want %q", user, "asdf")
+	}
+}
+
+func TestArchive_Upgrade(t *testing.T) {
+	a := &Archive{
+		FormatVersion: ArchiveFormatVersion,
+		SchemaVersion: "1.0",
+		Tables: map[string]*ArchiveTable{
+			"global": {
+				Columns: []string{"setting", "value"},
+				Rows:    [][]any{{"schema_version", "1.0"}},
+			},
+			"connections": {
+				Columns: []string{"id", "nickname", "host"},
+				Rows:    [][]any{{1, "something", "somewhere"}},
+			},
+		},
+	}
+
+	if err := a.Upgrade(); err != nil {
+		t.Fatalf("Archive.Upgrade() error = %v", err)
+	}
+
+	if a.SchemaVersion != SchemaVersion {
+		t.Errorf("Archive.SchemaVersion = %v, want %v", a.SchemaVersion, SchemaVersion)
+	}
+
+	if got := a.Tables["global"].Rows[0][1]; got != SchemaVersion {
+		t.Errorf("archived schema_version = %v, want %v", got, SchemaVersion)
+	}
+
+	cns := a.Tables["connections"]
+
+	if !slices.Contains(cns.Columns, "binary") || len(cns.Rows[0]) != len(cns.Columns) {
+		t.Errorf("connections not upgraded: columns %v, row %v", cns.Columns, cns.Rows[0])
+	}
+
+	a.SchemaVersion = "v100.0"
+
+	if err := a.Upgrade(); err != ErrSchemaTooNew {
+		t.Errorf("Archive.Upgrade() error = %v, want %v", err, ErrSchemaTooNew)
+	}
+}
+
+func TestReadArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive string
+		wantErr error
+	}{
+		{
+			name:    "valid",
+			archive: `{"format_version": 1, "schema_version": "v1.1", "tables": {}}`,
+			wantErr: nil,
+		},
+		{
+			name:    "no-tables",
+			archive: `{"format_version": 1, "schema_version": "v1.1"}`,
+			wantErr: ErrArchiveInvalid,
+		},
+		{
+			name:    "empty-object",
+			archive: `{}`,
+			wantErr: ErrArchiveInvalid,
+		},
+		{
+			name:    "not-json",
+			archive: "garbage",
+			wantErr: ErrArchiveInvalid,
+		},
+		{
+			name:    "empty",
+			archive: "",
+			wantErr: ErrArchiveInvalid,
+		},
+		{
+			name:    "too-new",
+			archive: `{"format_version": 99, "schema_version": "v1.1", "tables": {}}`,
+			wantErr: ErrArchiveFormatTooNew,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadArchive(strings.NewReader(tt.archive))
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReadArchive() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
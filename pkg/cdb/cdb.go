@@ -4,42 +4,105 @@ package cdb
 
 import (
 	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
 
 // Close Gracefully closes a connection to a database.
 func (conndb ConnectionDB) Close() {
-	defer conndb.connection.Close()
+	defer conndb.store.Close()
 }
 
 // Connect connects to a database and returns a ConnectionDB. In the event an
 // error occurs, it'll be returned.
+//
+// The driver selects the storage backend:
+//
+//	sqlite - a SQLite database file at path
+//	postgres, pgx - a PostgreSQL database, where path is a connection string.
+//		pgx's database/sql driver is used, unless the package is built with
+//		the nopostgres tag (see PostgresDriver).
+//	file - a JSON or YAML file at path (see NewFileStore)
+//
+// Several sshcm processes may use the same connection DB at once. SQLite DBs
+// are opened in WAL mode, so that readers don't wait for writers, and with a
+// busy timeout, so that writers wait for each other rather than failing.
+// Multi-step changes to SQLite DBs and file stores also hold an advisory lock
+// on a ".lock" file next to the DB, which is left in place. If a DB stays busy
+// for too long, ErrBusy is returned.
 func Connect(driver string, path string) (ConnectionDB, error) {
 	var cdb ConnectionDB
 
-	supported := false
-
 	switch driver {
-
 	case "sqlite":
-		supported = true
-	}
+		db, err := sql.Open(driver, sqliteDSN(path))
+
+		if err != nil {
+			return cdb, err
+		}
 
-	if !supported {
+		lockPath, _, _ := strings.Cut(path, "?")
+		cdb.store = newSQLStore(db, SQLite, lockPath+".lock")
+	case "postgres", "pgx":
+		if driver == "postgres" {
+			driver = PostgresDriver()
+		}
+
+		db, err := sql.Open(driver, path)
+
+		if err != nil {
+			return cdb, err
+		}
+
+		// PostgreSQL does its own row locking
+		cdb.store = NewSQLStore(db, Postgres)
+	case "file":
+		cdb.store = NewFileStore(path)
+	default:
 		return cdb, ErrUnsupportedSqlDriver
 	}
 
-	db, err := sql.Open(driver, path)
+	return cdb, nil
+}
+
+// PostgresDriver returns the name of the database/sql driver used for
+// PostgreSQL connection DBs: postgres, if the program registered a driver of
+// that name (ex. by importing github.com/lib/pq), or else pgx, which is
+// registered by this package unless it is built with the nopostgres tag. An
+// empty string is returned if neither is registered.
+func PostgresDriver() string {
+	drivers := sql.Drivers()
 
-	if err != nil {
-		return cdb, err
+	for _, driver := range []string{"postgres", "pgx"} {
+		if slices.Contains(drivers, driver) {
+			return driver
+		}
 	}
 
-	// Assemble connection struct & prep for loading default settings
-	cdb.connection = db
+	return ""
+}
 
-	return cdb, nil
+// sqliteBusyTimeout is how long a SQLite statement waits for another writer to
+// release the DB before failing.
+const sqliteBusyTimeout = 5 * time.Second
+
+// sqliteDSN adds the connection settings sshcm needs to a SQLite DB path: WAL
+// journaling, a busy timeout, and immediate transactions, which take the DB's
+// write lock when they begin (and wait for it), rather than failing part way
+// through when another writer holds it.
+func sqliteDSN(path string) string {
+	sep := "?"
+
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+
+	return fmt.Sprintf("%s%s_pragma=busy_timeout(%d)&_pragma=journal_mode(wal)&_txlock=immediate",
+		path, sep, sqliteBusyTimeout.Milliseconds())
 }
 
 // NewConnection will create a new, empty Connection struct.
@@ -1,10 +1,13 @@
 package cdb
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 
 	"github.com/cannable/sshcm/pkg/misc"
@@ -34,7 +37,14 @@ type Connection struct {
 	Args        string        // connection-specific arguments to pass to SSH Command
 	Identity    string        // connection-specific OpenSSH-style identity string (ex. path or name)
 	Command     string        // connection-specific Command to run (ex. sftp)
+	Jump        string        // comma-separated nicknames of jump host connections
+	PreHook     string        // connection-specific local command run before connecting
+	PostHook    string        // connection-specific local command run after disconnecting
+	Record      bool          // record the terminal output of every session
+	Range       string        // numbers a pattern connection stands for (ex. 1-40)
 	Binary      string        // to be deleted
+
+	pattern string // nickname of the pattern connection this is an instance of
 }
 
 var ListViewColumnWidths = map[string]int{
@@ -46,14 +56,19 @@ var ListViewColumnWidths = map[string]int{
 	"args":        10,
 	"identity":    10,
 	"command":     10,
+	"jump":        10,
+	"pre_hook":    10,
+	"post_hook":   10,
+	"record":      6,
+	"range":       8,
 }
 
-// Delete removes a connection from the underlying SQL database.
+// DeleteContext removes a connection from the underlying SQL database.
 // It will return nil if the operation succeeeded and err otherwise.
 // Several checks are implemented that return package-specific errors. These
 // checks are simple and only cover obvious situations that will cause SQL
 // query exceptions.
-func (c Connection) Delete() error {
+func (c Connection) DeleteContext(ctx context.Context) error {
 	// See if this connection has a parent ConnectionDB attached
 	if c.db == nil {
 		return ErrConnNoDb
@@ -64,25 +79,55 @@ func (c Connection) Delete() error {
 		return ErrConnNoId
 	}
 
-	// Does the ID exist?
-	exists, err := c.db.Exists(c.Id)
-
-	if err != nil {
-		return err
+	// Instances share their pattern's id
+	if len(c.pattern) > 0 {
+		return ErrPatternInstance
 	}
 
-	if !exists {
-		return ErrIdNotExist
-	}
+	return c.db.atomic(ctx, func(db *ConnectionDB) error {
+		// Does the ID exist?
+		exists, err := db.ExistsContext(ctx, c.Id)
 
-	// Try deleting the connection
-	_, err = c.db.connection.Exec(`
-        DELETE FROM connections
-		WHERE id = $1
-		`,
-		sqlNullableInt64(c.Id))
+		if err != nil {
+			return err
+		}
 
-	return err
+		if !exists {
+			return ErrIdNotExist
+		}
+
+		// Jump hosts can't be removed while other connections use them
+		stored, err := db.GetContext(ctx, c.Id)
+
+		if err != nil {
+			return err
+		}
+
+		users, err := db.jumpUsers(ctx, stored.Nickname)
+
+		if err != nil {
+			return err
+		}
+
+		if len(users) > 0 {
+			var nicknames []string
+
+			for _, u := range users {
+				nicknames = append(nicknames, u.Nickname)
+			}
+
+			return fmt.Errorf("%w: %s is used by %s", ErrJumpInUse, stored.Nickname, strings.Join(nicknames, ", "))
+		}
+
+		// Try deleting the connection
+		return db.store.DeleteConnection(ctx, c.Id)
+	})
+}
+
+// Delete uses context.Background internally; to specify the context, use
+// DeleteContext.
+func (c Connection) Delete() error {
+	return c.DeleteContext(context.Background())
 }
 
 // WriteRecordLong writes a record-format, multi-line string to the passed
@@ -101,6 +146,11 @@ func (c Connection) WriteRecordLong(w io.Writer) error {
 	fmt.Fprintf(&b, "%-*s: %s\n", offset, "Args", c.Args)
 	fmt.Fprintf(&b, "%-*s: %s\n", offset, "Identity", c.Identity)
 	fmt.Fprintf(&b, "%-*s: %s\n", offset, "Command", c.Command)
+	fmt.Fprintf(&b, "%-*s: %s\n", offset, "Jump", c.Jump)
+	fmt.Fprintf(&b, "%-*s: %s\n", offset, "Pre hook", c.PreHook)
+	fmt.Fprintf(&b, "%-*s: %s\n", offset, "Post hook", c.PostHook)
+	fmt.Fprintf(&b, "%-*s: %t\n", offset, "Record", c.Record)
+	fmt.Fprintf(&b, "%-*s: %s\n", offset, "Range", c.Range)
 
 	_, err := fmt.Fprint(w, b.String())
 
@@ -138,6 +188,11 @@ func (c Connection) WriteCSV(w *csv.Writer) error {
 		c.Args,
 		c.Identity,
 		c.Command,
+		c.Jump,
+		c.PreHook,
+		c.PostHook,
+		strconv.FormatBool(c.Record),
+		c.Range,
 	})
 }
 
@@ -160,7 +215,7 @@ func (c Connection) WriteJSON(w io.Writer) error {
 // This func will write all connection properties.
 // An error will be returned if one occurs, otherwise error will be nil.
 func (c Connection) WriteLineLong(w io.Writer) error {
-	_, err := fmt.Fprintf(w, "%-*d %s %s %s %s %s %s %s\n",
+	_, err := fmt.Fprintf(w, "%-*d %s %s %s %s %s %s %s %s %s %s %-*t %s\n",
 		ListViewColumnWidths["id"], c.Id,
 		misc.StringTrimmer(c.Nickname, ListViewColumnWidths["nickname"]),
 		misc.StringTrimmer(c.User, ListViewColumnWidths["user"]),
@@ -169,6 +224,11 @@ func (c Connection) WriteLineLong(w io.Writer) error {
 		misc.StringTrimmer(c.Args, ListViewColumnWidths["args"]),
 		misc.StringTrimmer(c.Identity, ListViewColumnWidths["identity"]),
 		misc.StringTrimmer(c.Command, ListViewColumnWidths["command"]),
+		misc.StringTrimmer(c.Jump, ListViewColumnWidths["jump"]),
+		misc.StringTrimmer(c.PreHook, ListViewColumnWidths["pre_hook"]),
+		misc.StringTrimmer(c.PostHook, ListViewColumnWidths["post_hook"]),
+		ListViewColumnWidths["record"], c.Record,
+		misc.StringTrimmer(c.Range, ListViewColumnWidths["range"]),
 	)
 
 	return err
@@ -196,17 +256,22 @@ func (c Connection) String() string {
 	return fmt.Sprintf("%s (%d)", c.Nickname, c.Id)
 }
 
-// Update updates an existing connection in the SQL database.
+// UpdateContext updates an existing connection in the SQL database.
 // It will return nil if the operation succeeeded and err otherwise.
 // Several checks are implemented that return package-specific errors. These
 // checks are simple and only cover obvious situations that will cause SQL
 // query exceptions.
-func (c Connection) Update() error {
+func (c Connection) UpdateContext(ctx context.Context) error {
 	// See if this connection has a parent ConnectionDB attached
 	if c.db == nil {
 		return ErrConnNoDb
 	}
 
+	// Instances share their pattern's id
+	if len(c.pattern) > 0 {
+		return ErrPatternInstance
+	}
+
 	// Validate connection properties
 	err := c.Validate()
 
@@ -216,49 +281,91 @@ func (c Connection) Update() error {
 		return err
 	}
 
-	// Does the ID exist?
-	exists, err := c.db.Exists(c.Id)
-
-	if err != nil {
+	if err := c.validateWrite(); err != nil {
 		return err
 	}
 
-	if !exists {
-		return ErrIdNotExist
-	}
+	return c.db.atomic(ctx, func(db *ConnectionDB) error {
+		// Does the ID exist?
+		stored, err := db.GetContext(ctx, c.Id)
+
+		if errors.Is(err, ErrConnectionNotFound) {
+			return ErrIdNotExist
+		} else if err != nil {
+			return err
+		}
+
+		// Nicknames must stay unique
+		other, err := db.GetByPropertyContext(ctx, "nickname", c.Nickname)
+
+		if err == nil && other.Id != c.Id {
+			return ErrDuplicateNickname
+		} else if err != nil && !errors.Is(err, ErrConnectionNotFound) {
+			return err
+		}
+
+		// Jump hosts must exist, and can't lead back to this connection
+		if err := db.validateJump(ctx, c, stored.Nickname); err != nil {
+			return err
+		}
+
+		// Connections using this one as a jump host follow it when it's renamed
+		if stored.Nickname != c.Nickname {
+			if err := db.renameJump(ctx, stored.Nickname, c.Nickname); err != nil {
+				return err
+			}
+		}
+
+		// Try updating the connection
+		return db.store.UpdateConnection(ctx, c)
+	})
+}
 
-	// Try updating the connection
-	_, err = c.db.connection.Exec(`
-		UPDATE connections SET
-			nickname = $2,
-			host = $3,
-			user = $4,
-			description = $5,
-			args = $6,
-			identity = $7,
-			command = $8
-		WHERE id = $1
-		`,
-		sqlNullableInt64(c.Id),
-		sqlNullableString(c.Nickname),
-		sqlNullableString(c.Host),
-		sqlNullableString(c.User),
-		sqlNullableString(c.Description),
-		sqlNullableString(c.Args),
-		sqlNullableString(c.Identity),
-		sqlNullableString(c.Command),
-	)
+// Update uses context.Background internally; to specify the context, use
+// UpdateContext.
+func (c Connection) Update() error {
+	return c.UpdateContext(context.Background())
+}
 
-	return err
+// CopySettings copies the settings of src onto the connection, leaving its id,
+// nickname and jump hosts as they are. It is used when an imported connection
+// replaces an existing connection of the same nickname. Jump hosts are set
+// separately (see Tx.SetJumpHosts), as they may refer to connections that
+// haven't been imported yet.
+func (c *Connection) CopySettings(src Connection) {
+	c.Host = src.Host
+	c.User = src.User
+	c.Description = src.Description
+	c.Args = src.Args
+	c.Identity = src.Identity
+	c.Command = src.Command
+	c.PreHook = src.PreHook
+	c.PostHook = src.PostHook
+	c.Record = src.Record
+	c.Range = src.Range
+}
+
+// validateWrite checks what Validate doesn't, as stored connections may
+// predate it: that settings only use known placeholders (see
+// ValidatePlaceholders), and that pattern connections have a valid range (see
+// Instance). It is run when connections are added or updated.
+func (c Connection) validateWrite() error {
+	if err := c.ValidatePlaceholders(); err != nil {
+		return err
+	}
+
+	return c.validatePattern()
 }
 
 // Validate runs checks against Connection properties. This should be run
 // before performing write operations against the database, as its purpose is
 // to catch potentially fix-able errors before making SQL angry.
 
-// Currently, the only check performed is whether the nickname is in a valid
-// format (ex. starts with a letter). Additional checks may be added in the
-// future.
+// Checks include whether the nickname is in a valid format (ex. starts with a
+// letter). Connections are validated whenever they are read, so checks that
+// stored connections may predate (see validateWrite) are made when
+// connections are added or updated instead.
+// Additional checks may be added in the future.
 func (c Connection) Validate() error {
 	// Validate Nickname
 	if c.Nickname == "" {
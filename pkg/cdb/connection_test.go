@@ -75,3 +75,65 @@ func TestConnection_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestConnection_CopySettings(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, conndb *ConnectionDB) {
+		if _, err := conndb.Add(&Connection{Nickname: "bastion", Host: "bastion"}); err != nil {
+			t.Fatalf("ConnectionDB.Add() error = %v", err)
+		}
+
+		id, err := conndb.Add(&Connection{Nickname: "web{n}", Host: "old{n}", Jump: "bastion", PreHook: "old-pre", Range: "1-10"})
+
+		if err != nil {
+			t.Fatalf("ConnectionDB.Add() error = %v", err)
+		}
+
+		// Import over the existing connection
+		imported := Connection{
+			Id:          99,
+			Nickname:    "web{n}",
+			Host:        "web{n}.example.com",
+			User:        "me",
+			Description: "Web server",
+			Args:        "-p 2222",
+			Identity:    "~/.ssh/web",
+			Command:     "sftp",
+			PreHook:     "vpn-up",
+			PostHook:    "vpn-down",
+			Record:      true,
+			Range:       "1-40",
+		}
+
+		err = conndb.WithTx(func(tx *Tx) error {
+			existing, err := tx.GetByProperty("nickname", "web{n}")
+
+			if err != nil {
+				return err
+			}
+
+			existing.CopySettings(imported)
+
+			return tx.Update(existing)
+		})
+
+		if err != nil {
+			t.Fatalf("ConnectionDB.WithTx() error = %v", err)
+		}
+
+		got, err := conndb.Get(id)
+
+		if err != nil {
+			t.Fatalf("ConnectionDB.Get() error = %v", err)
+		}
+
+		want := imported
+		want.Id = id
+		want.Jump = "bastion"
+
+		got.db = nil
+
+		if got != want {
+			t.Errorf("imported connection = %#v, want %#v", got, want)
+		}
+	})
+}
@@ -1,24 +1,21 @@
 package cdb
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 	"strconv"
 )
 
+// A ConnectionDB is a database of connections and program defaults. It sits
+// on a Store, which holds the data.
 type ConnectionDB struct {
-	connection DbConnIface
-}
+	store Store
 
-// DbConnIface provides an interface for interacting with a DB (or mock)
-type DbConnIface interface {
-	Close() error
-	Exec(query string, args ...any) (sql.Result, error)
-	Query(query string, args ...any) (*sql.Rows, error)
-	QueryRow(query string, args ...any) *sql.Row
+	// inTx is true if the ConnectionDB belongs to a Tx.
+	inTx bool
 }
 
-func (conndb *ConnectionDB) Add(c *Connection) (int64, error) {
+func (conndb *ConnectionDB) AddContext(ctx context.Context, c *Connection) (int64, error) {
 	err := c.Validate()
 
 	// The only error we should get from validation is that the connection ID is zero.
@@ -26,197 +23,133 @@ func (conndb *ConnectionDB) Add(c *Connection) (int64, error) {
 		return -1, err
 	}
 
-	// See if the nickname already exists
-	exists, err := conndb.ExistsByProperty("nickname", c.Nickname)
-
-	if err != nil {
+	if err := c.validateWrite(); err != nil {
 		return -1, err
-	} else if exists {
-		return -1, ErrDuplicateNickname
 	}
 
-	// Try adding the connection
-	result, err := conndb.connection.Exec(`
-		INSERT INTO connections (
-			nickname,
-			host,
-			user,
-			description,
-			args,
-			identity,
-			command
-		) VALUES (
-			$1,
-			$2,
-			$3,
-			$4,
-			$5,
-			$6,
-			$7
-		)`,
-		sqlNullableString(c.Nickname),
-		sqlNullableString(c.Host),
-		sqlNullableString(c.User),
-		sqlNullableString(c.Description),
-		sqlNullableString(c.Args),
-		sqlNullableString(c.Identity),
-		sqlNullableString(c.Command),
-	)
+	var id int64
 
-	if err != nil {
-		return -1, err
-	}
+	err = conndb.atomic(ctx, func(db *ConnectionDB) error {
+		// See if the nickname already exists
+		exists, err := db.ExistsByPropertyContext(ctx, "nickname", c.Nickname)
 
-	id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		} else if exists {
+			return ErrDuplicateNickname
+		}
+
+		// Jump hosts must exist
+		if err := db.validateJump(ctx, *c, ""); err != nil {
+			return err
+		}
+
+		id, err = db.store.AddConnection(ctx, *c)
+
+		return err
+	})
 
 	if err != nil {
 		return -1, err
 	}
 
-	return id, err
+	return id, nil
 }
 
-func (conndb *ConnectionDB) Exists(id int64) (bool, error) {
-	var check int
-
-	err := conndb.connection.QueryRow("SELECT id FROM connections WHERE id = $1", id).Scan(&check)
+// Add uses context.Background internally; to specify the context, use
+// AddContext.
+func (conndb *ConnectionDB) Add(c *Connection) (int64, error) {
+	return conndb.AddContext(context.Background(), c)
+}
 
-	// Return true/false explicitly (based on specific success/fail conditions)
-	if err == nil {
-		return true, nil
-	} else if err == sql.ErrNoRows {
-		return false, nil
-	}
+func (conndb *ConnectionDB) ExistsContext(ctx context.Context, id int64) (bool, error) {
+	return conndb.store.ConnectionExists(ctx, "id", id)
+}
 
-	return false, err
+// Exists uses context.Background internally; to specify the context, use
+// ExistsContext.
+func (conndb *ConnectionDB) Exists(id int64) (bool, error) {
+	return conndb.ExistsContext(context.Background(), id)
 }
 
-func (conndb *ConnectionDB) ExistsByProperty(property string, value string) (bool, error) {
+func (conndb *ConnectionDB) ExistsByPropertyContext(ctx context.Context, property string, value string) (bool, error) {
 	// Make sure we're dealing with a valid property first
 	if !IsValidProperty(property) {
 		return false, ErrInvalidConnectionProperty
 	}
 
-	var check int
-
-	err := conndb.connection.QueryRow(`
-		SELECT id
-		FROM connections
-		WHERE `+property+" = $1", value).Scan(&check)
-
-	// Return true/false explicitly (based on specific success/fail conditions)
-	if err == nil {
-		return true, nil
-	} else if err == sql.ErrNoRows {
-		return false, nil
-	}
+	return conndb.store.ConnectionExists(ctx, property, value)
+}
 
-	return false, err
+// ExistsByProperty uses context.Background internally; to specify the context,
+// use ExistsByPropertyContext.
+func (conndb *ConnectionDB) ExistsByProperty(property string, value string) (bool, error) {
+	return conndb.ExistsByPropertyContext(context.Background(), property, value)
 }
 
-func (conndb *ConnectionDB) Get(id int64) (Connection, error) {
-	// Get connection details from DB
-	row := conndb.connection.QueryRow(`
-		SELECT
-			id,
-			nickname,
-			host,
-			user,
-			description,
-			args,
-			identity,
-			command
-		FROM connections
-		WHERE id = $1
-		ORDER BY id;
-	`, id)
-
-	// Get connection details from DB
-	var sqlId sql.NullInt64
-	var nickname, host, user, description, args, identity, command sql.NullString
-
-	err := row.Scan(
-		&sqlId,
-		&nickname,
-		&host,
-		&user,
-		&description,
-		&args,
-		&identity,
-		&command,
-	)
-
-	// Check SQL scanning errors before continuing
+// attach attaches a connection read from the store to the ConnectionDB, so
+// that connection methods work.
+func (conndb *ConnectionDB) attach(c Connection, err error) (Connection, error) {
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return Connection{}, ErrConnectionNotFound
-		}
-
 		return Connection{}, err
 	}
 
-	// If any of the connection properties are invalid, fail
-	if !(sqlId.Valid ||
-		nickname.Valid ||
-		host.Valid ||
-		user.Valid ||
-		description.Valid ||
-		args.Valid ||
-		identity.Valid ||
-		command.Valid) {
-		return Connection{}, ErrConnFromDbInvalid
-	}
+	c.db = conndb
 
-	// Attach the connection to its parent (so that connection methods work)
-	c := Connection{
-		db:          conndb,
-		Id:          sqlId.Int64,
-		Nickname:    nickname.String,
-		Host:        host.String,
-		User:        user.String,
-		Description: description.String,
-		Args:        args.String,
-		Identity:    identity.String,
-		Command:     command.String,
-	}
+	return c, nil
+}
 
-	err = c.Validate()
+func (conndb *ConnectionDB) GetContext(ctx context.Context, id int64) (Connection, error) {
+	return conndb.attach(conndb.store.GetConnection(ctx, "id", id))
+}
 
-	return c, err
+// Get uses context.Background internally; to specify the context, use
+// GetContext.
+func (conndb *ConnectionDB) Get(id int64) (Connection, error) {
+	return conndb.GetContext(context.Background(), id)
 }
 
-func (conndb *ConnectionDB) GetAll() ([]*Connection, error) {
+// GetAllContext returns all connections, ordered by id.
+func (conndb *ConnectionDB) GetAllContext(ctx context.Context) ([]*Connection, error) {
 	var cns []*Connection
-	rows, err := conndb.connection.Query("SELECT id FROM connections")
-
-	if err != nil {
-		return cns, err
-	}
 
-	for rows.Next() {
-		var id int64
+	err := conndb.ForEachContext(ctx, func(c *Connection) error {
+		cns = append(cns, c)
+		return nil
+	})
 
-		if err := rows.Scan(&id); err != nil {
-			return cns, err
-		}
-
-		c, err := conndb.Get(id)
+	return cns, err
+}
 
-		if err != nil {
-			return cns, err
-		}
+// GetAll uses context.Background internally; to specify the context, use
+// GetAllContext.
+func (conndb *ConnectionDB) GetAll() ([]*Connection, error) {
+	return conndb.GetAllContext(context.Background())
+}
 
-		cns = append(cns, &c)
-	}
+// ForEachContext calls fn for each connection, ordered by id. Connections are
+// read from the store and passed to fn one at a time, so that callers can
+// stream large connection DBs without holding every connection in memory.
+// Iteration stops at the first error (including one returned by fn), which is
+// returned.
+func (conndb *ConnectionDB) ForEachContext(ctx context.Context, fn func(*Connection) error) error {
+	return conndb.eachListed(ctx, ListOptions{}, fn)
+}
 
-	return cns, err
+// ForEach uses context.Background internally; to specify the context, use
+// ForEachContext.
+func (conndb *ConnectionDB) ForEach(fn func(*Connection) error) error {
+	return conndb.ForEachContext(context.Background(), fn)
 }
 
-// GetByIdOrNickname looks up a connection by id or nickname, then returns a
-// Connection struct.
-// If the look up succeeded, err will be nil and it can be assumed that the
-// Connection is safe to use.
-func (conndb *ConnectionDB) GetByIdOrNickname(arg string) (Connection, error) {
+// GetByIdOrNicknameContext looks up a connection by id or nickname, then
+// returns a Connection struct. If the look up succeeded, err will be nil and it
+// can be assumed that the Connection is safe to use.
+//
+// Nicknames that no connection has are matched against the ranges of pattern
+// connections, and the matching instance is returned (see
+// Connection.Instance). Stored connections win over pattern instances.
+func (conndb *ConnectionDB) GetByIdOrNicknameContext(ctx context.Context, arg string) (Connection, error) {
 	var c Connection
 
 	// Get connection by ID or nickname
@@ -229,7 +162,7 @@ func (conndb *ConnectionDB) GetByIdOrNickname(arg string) (Connection, error) {
 		}
 
 		// Get connection by id
-		c, err = conndb.Get(int64(id))
+		c, err = conndb.GetContext(ctx, int64(id))
 
 		if err != nil {
 			return c, err
@@ -241,8 +174,13 @@ func (conndb *ConnectionDB) GetByIdOrNickname(arg string) (Connection, error) {
 			// Got a valid nickname
 			nickname := arg
 
-			// Get connection by nickname
-			c, err = conndb.GetByProperty("nickname", nickname)
+			// Get connection by nickname, or else a pattern connection
+			// instance
+			c, err = conndb.GetByPropertyContext(ctx, "nickname", nickname)
+
+			if errors.Is(err, ErrConnectionNotFound) {
+				c, err = conndb.getInstance(ctx, nickname)
+			}
 
 			if err != nil {
 				return c, err
@@ -255,110 +193,36 @@ func (conndb *ConnectionDB) GetByIdOrNickname(arg string) (Connection, error) {
 	return c, nil
 }
 
-func (conndb *ConnectionDB) GetByProperty(property string, value string) (Connection, error) {
+// GetByIdOrNickname uses context.Background internally; to specify the context,
+// use GetByIdOrNicknameContext.
+func (conndb *ConnectionDB) GetByIdOrNickname(arg string) (Connection, error) {
+	return conndb.GetByIdOrNicknameContext(context.Background(), arg)
+}
+
+func (conndb *ConnectionDB) GetByPropertyContext(ctx context.Context, property string, value string) (Connection, error) {
 	if !IsValidProperty(property) {
 		return Connection{}, ErrPropertyInvalid
 	}
 
-	// Get connection details from DB
-	row := conndb.connection.QueryRow(`
-		SELECT
-			id,
-			nickname,
-			host,
-			user,
-			description,
-			args,
-			identity,
-			command
-		FROM connections
-		WHERE `+property+" = $1", value)
-
-	// Get connection details from DB
-	var sqlId sql.NullInt64
-	var nickname, host, user, description, args, identity, command sql.NullString
-
-	err := row.Scan(
-		&sqlId,
-		&nickname,
-		&host,
-		&user,
-		&description,
-		&args,
-		&identity,
-		&command,
-	)
-
-	// Check SQL scanning errors before continuing
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return Connection{}, ErrConnectionNotFound
-		}
-
-		return Connection{}, err
-	}
-
-	// If any of the connection properties are invalid, fail
-	if !(sqlId.Valid ||
-		nickname.Valid ||
-		host.Valid ||
-		user.Valid ||
-		description.Valid ||
-		args.Valid ||
-		identity.Valid ||
-		command.Valid) {
-		return Connection{}, ErrConnFromDbInvalid
-	}
+	return conndb.attach(conndb.store.GetConnection(ctx, property, value))
+}
 
-	// Attach the connection to its parent (so that connection methods work)
-	c := Connection{
-		db:          conndb,
-		Id:          sqlId.Int64,
-		Nickname:    nickname.String,
-		Host:        host.String,
-		User:        user.String,
-		Description: description.String,
-		Args:        args.String,
-		Identity:    identity.String,
-		Command:     command.String,
-	}
+// GetByProperty uses context.Background internally; to specify the context, use
+// GetByPropertyContext.
+func (conndb *ConnectionDB) GetByProperty(property string, value string) (Connection, error) {
+	return conndb.GetByPropertyContext(context.Background(), property, value)
+}
 
-	err = c.Validate()
+// SearchContext returns the connections whose nickname, host, user or
+// description contain search (case-insensitive), ordered by id.
+func (conndb *ConnectionDB) SearchContext(ctx context.Context, search string) ([]*Connection, error) {
+	cns, _, err := conndb.List(ctx, ListOptions{Filter: search})
 
-	return c, err
+	return cns, err
 }
 
+// Search uses context.Background internally; to specify the context, use
+// SearchContext.
 func (conndb *ConnectionDB) Search(search string) ([]*Connection, error) {
-	var cns []*Connection
-	rows, err := conndb.connection.Query(`
-		SELECT id
-		FROM connections
-		WHERE (nickname LIKE $1)
-		OR (host LIKE $1)
-		OR (user LIKE $1)
-		OR (description LIKE $1)
-		ORDER BY id;
-	`, "%"+search+"%")
-
-	if err != nil {
-		return cns, err
-	}
-
-	for rows.Next() {
-		var id int64
-
-		if err := rows.Scan(&id); err != nil {
-			return cns, err
-		}
-
-		c, err := conndb.Get(id)
-
-		if err != nil {
-			return cns, err
-		}
-
-		cns = append(cns, &c)
-	}
-
-	return cns, err
+	return conndb.SearchContext(context.Background(), search)
 }
@@ -1,6 +1,9 @@
 package cdb
 
 import (
+	"context"
+	"errors"
+	"slices"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -16,6 +19,9 @@ func TestConnectionDB_AddSimple(t *testing.T) {
 
 	conndb, mock := newMockConnDb()
 
+	// The nickname check and insert run in a single transaction
+	mock.ExpectBegin()
+
 	// Pretend that there are no existing connections with this nickname
 	rows := sqlmock.NewRows([]string{"id"})
 	mock.ExpectQuery("SELECT id").WithArgs(c.Nickname).WillReturnRows(rows)
@@ -28,8 +34,15 @@ func TestConnectionDB_AddSimple(t *testing.T) {
 		"",
 		"",
 		"",
+		"",
+		"",
+		"",
+		int64(0),
+		"",
 	).WillReturnResult(sqlmock.NewResult(1, 1))
 
+	mock.ExpectCommit()
+
 	_, err := conndb.Add(c)
 
 	conndb.Close()
@@ -210,3 +223,95 @@ func TestConnectionDB_ExistsByProperty(t *testing.T) {
 		})
 	}
 }
+
+func TestConnectionDB_ForEach(t *testing.T) {
+	conndb := newTestConnDb(t)
+
+	for _, nickname := range []string{"a", "b", "c"} {
+		if _, err := conndb.Add(&Connection{Nickname: nickname, Host: "somewhere"}); err != nil {
+			t.Fatalf("ConnectionDB.Add() error = %v", err)
+		}
+	}
+
+	var got []string
+
+	err := conndb.ForEach(func(c *Connection) error {
+		got = append(got, c.Nickname)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("ConnectionDB.ForEach() error = %v", err)
+	}
+
+	if !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("ConnectionDB.ForEach() visited %v, want [a b c]", got)
+	}
+
+	// An error from fn stops iteration and is returned
+	stop := errors.New("stop")
+	visited := 0
+
+	err = conndb.ForEach(func(c *Connection) error {
+		visited++
+		return stop
+	})
+
+	if err != stop || visited != 1 {
+		t.Errorf("ConnectionDB.ForEach() error = %v after %d connections, want %v after 1", err, visited, stop)
+	}
+}
+
+func TestConnectionDB_Canceled(t *testing.T) {
+	conndb := newTestConnDb(t)
+
+	if _, err := conndb.Add(&Connection{Nickname: "something", Host: "somewhere"}); err != nil {
+		t.Fatalf("ConnectionDB.Add() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{"AddContext", func() error {
+			_, err := conndb.AddContext(ctx, &Connection{Nickname: "else", Host: "elsewhere"})
+			return err
+		}},
+		{"GetContext", func() error {
+			_, err := conndb.GetContext(ctx, 1)
+			return err
+		}},
+		{"SearchContext", func() error {
+			_, err := conndb.SearchContext(ctx, "some")
+			return err
+		}},
+		{"SetDefaultContext", func() error {
+			return conndb.SetDefaultContext(ctx, "user", "me")
+		}},
+		{"BackupContext", func() error {
+			_, err := conndb.BackupContext(ctx)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fn(); !errors.Is(err, context.Canceled) {
+				t.Errorf("ConnectionDB.%s() error = %v, want %v", tt.name, err, context.Canceled)
+			}
+		})
+	}
+
+	// Nothing was changed
+	c, err := conndb.Get(1)
+
+	if err != nil || c.Nickname != "something" {
+		t.Errorf("ConnectionDB.Get() = %v, %v", c, err)
+	}
+
+	if exists, _ := conndb.ExistsByProperty("nickname", "else"); exists {
+		t.Errorf("ConnectionDB.AddContext() added a connection after cancellation")
+	}
+}
@@ -1,13 +1,14 @@
 package cdb
 
 import (
-	"database/sql"
+	"context"
+	"slices"
 	"strings"
 
 	"golang.org/x/mod/semver"
 )
 
-const SchemaVersion = "v1.1"
+const SchemaVersion = "v1.6"
 
 var schemas = map[string]string{
 	"v1.0": `
@@ -53,21 +54,211 @@ var schemas = map[string]string{
 			'command'       TEXT,
 			'binary'        TEXT
 		);`,
+	"v1.2": `
+		CREATE TABLE 'global' (
+			'setting'	TEXT UNIQUE,
+			'value'	    TEXT,
+			PRIMARY KEY('setting')
+		);
+		CREATE TABLE 'defaults' (
+			'setting'	TEXT UNIQUE,
+			'value'	    TEXT,
+			PRIMARY KEY('setting')
+		);
+		CREATE TABLE 'connections' (
+			'id'         INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT UNIQUE,
+			'nickname'      TEXT NOT NULL UNIQUE,
+			'host'          TEXT NOT NULL,
+			'user'          TEXT,
+			'description'   TEXT,
+			'args'          TEXT,
+			'identity'      TEXT,
+			'command'       TEXT,
+			'binary'        TEXT,
+			'jump'          TEXT
+		);`,
+	"v1.3": `
+		CREATE TABLE 'global' (
+			'setting'	TEXT UNIQUE,
+			'value'	    TEXT,
+			PRIMARY KEY('setting')
+		);
+		CREATE TABLE 'defaults' (
+			'setting'	TEXT UNIQUE,
+			'value'	    TEXT,
+			PRIMARY KEY('setting')
+		);
+		CREATE TABLE 'connections' (
+			'id'         INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT UNIQUE,
+			'nickname'      TEXT NOT NULL UNIQUE,
+			'host'          TEXT NOT NULL,
+			'user'          TEXT,
+			'description'   TEXT,
+			'args'          TEXT,
+			'identity'      TEXT,
+			'command'       TEXT,
+			'binary'        TEXT,
+			'jump'          TEXT,
+			'pre_hook'      TEXT,
+			'post_hook'     TEXT
+		);`,
+	"v1.4": `
+		CREATE TABLE 'global' (
+			'setting'	TEXT UNIQUE,
+			'value'	    TEXT,
+			PRIMARY KEY('setting')
+		);
+		CREATE TABLE 'defaults' (
+			'setting'	TEXT UNIQUE,
+			'value'	    TEXT,
+			PRIMARY KEY('setting')
+		);
+		CREATE TABLE 'connections' (
+			'id'         INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT UNIQUE,
+			'nickname'      TEXT NOT NULL UNIQUE,
+			'host'          TEXT NOT NULL,
+			'user'          TEXT,
+			'description'   TEXT,
+			'args'          TEXT,
+			'identity'      TEXT,
+			'command'       TEXT,
+			'binary'        TEXT,
+			'jump'          TEXT,
+			'pre_hook'      TEXT,
+			'post_hook'     TEXT
+		);
+		CREATE TABLE 'sessions' (
+			'id'            INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT UNIQUE,
+			'connection_id' INTEGER,
+			'nickname'      TEXT NOT NULL,
+			'started'       TEXT NOT NULL,
+			'ended'         TEXT NOT NULL,
+			'exit_status'   INTEGER NOT NULL
+		);`,
+	"v1.5": `
+		CREATE TABLE 'global' (
+			'setting'	TEXT UNIQUE,
+			'value'	    TEXT,
+			PRIMARY KEY('setting')
+		);
+		CREATE TABLE 'defaults' (
+			'setting'	TEXT UNIQUE,
+			'value'	    TEXT,
+			PRIMARY KEY('setting')
+		);
+		CREATE TABLE 'connections' (
+			'id'         INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT UNIQUE,
+			'nickname'      TEXT NOT NULL UNIQUE,
+			'host'          TEXT NOT NULL,
+			'user'          TEXT,
+			'description'   TEXT,
+			'args'          TEXT,
+			'identity'      TEXT,
+			'command'       TEXT,
+			'binary'        TEXT,
+			'jump'          TEXT,
+			'pre_hook'      TEXT,
+			'post_hook'     TEXT,
+			'record'        INTEGER
+		);
+		CREATE TABLE 'sessions' (
+			'id'            INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT UNIQUE,
+			'connection_id' INTEGER,
+			'nickname'      TEXT NOT NULL,
+			'started'       TEXT NOT NULL,
+			'ended'         TEXT NOT NULL,
+			'exit_status'   INTEGER NOT NULL,
+			'recording'     TEXT
+		);`,
+	"v1.6": `
+		CREATE TABLE 'global' (
+			'setting'	TEXT UNIQUE,
+			'value'	    TEXT,
+			PRIMARY KEY('setting')
+		);
+		CREATE TABLE 'defaults' (
+			'setting'	TEXT UNIQUE,
+			'value'	    TEXT,
+			PRIMARY KEY('setting')
+		);
+		CREATE TABLE 'connections' (
+			'id'         INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT UNIQUE,
+			'nickname'      TEXT NOT NULL UNIQUE,
+			'host'          TEXT NOT NULL,
+			'user'          TEXT,
+			'description'   TEXT,
+			'args'          TEXT,
+			'identity'      TEXT,
+			'command'       TEXT,
+			'binary'        TEXT,
+			'jump'          TEXT,
+			'pre_hook'      TEXT,
+			'post_hook'     TEXT,
+			'record'        INTEGER,
+			'range'         TEXT
+		);
+		CREATE TABLE 'sessions' (
+			'id'            INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT UNIQUE,
+			'connection_id' INTEGER,
+			'nickname'      TEXT NOT NULL,
+			'started'       TEXT NOT NULL,
+			'ended'         TEXT NOT NULL,
+			'exit_status'   INTEGER NOT NULL,
+			'recording'     TEXT
+		);`,
 }
 
+// schemaUpgrades contains the statements that upgrade a SQLite DB from the
+// previous schema version to the keyed schema version. The schema version
+// itself is updated by the caller.
 var schemaUpgrades = map[string]string{
 	"v1.1": `
 		ALTER TABLE 'connections' ADD COLUMN 'binary' TEXT;
-		INSERT INTO 'defaults' (setting,value) VALUES ('binary',NULL);
-		UPDATE 'global' SET value='1.1' WHERE setting='schema_version';
-	);`,
+		INSERT INTO 'defaults' (setting,value) VALUES ('binary',NULL);`,
+	"v1.2": `
+		ALTER TABLE 'connections' ADD COLUMN 'jump' TEXT;`,
+	"v1.3": `
+		ALTER TABLE 'connections' ADD COLUMN 'pre_hook' TEXT;
+		ALTER TABLE 'connections' ADD COLUMN 'post_hook' TEXT;`,
+	"v1.4": `
+		CREATE TABLE 'sessions' (
+			'id'            INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT UNIQUE,
+			'connection_id' INTEGER,
+			'nickname'      TEXT NOT NULL,
+			'started'       TEXT NOT NULL,
+			'ended'         TEXT NOT NULL,
+			'exit_status'   INTEGER NOT NULL
+		);`,
+	"v1.5": `
+		ALTER TABLE 'connections' ADD COLUMN 'record' INTEGER;
+		ALTER TABLE 'sessions' ADD COLUMN 'recording' TEXT;`,
+	"v1.6": `
+		ALTER TABLE 'connections' ADD COLUMN 'range' TEXT;`,
 }
 
-// CheckDbHealth runs health checks on the connection DB and returns an error
-// if there is an issue.
-func (conndb *ConnectionDB) CheckDbHealth() error {
+// pendingUpgrades returns the versions in upgrades that are newer than
+// version, up to and including SchemaVersion, oldest first.
+func pendingUpgrades[T any](version string, upgrades map[string]T) []string {
+	version = normalizeSchemaVersion(version)
+
+	var versions []string
+
+	for v := range upgrades {
+		if semver.Compare(v, version) > 0 && semver.Compare(v, SchemaVersion) <= 0 {
+			versions = append(versions, v)
+		}
+	}
+
+	slices.SortFunc(versions, semver.Compare)
+
+	return versions
+}
+
+// CheckDbHealthContext runs health checks on the connection DB and returns an
+// error if there is an issue.
+func (conndb *ConnectionDB) CheckDbHealthContext(ctx context.Context) error {
 	// Read the DB version
-	version, err := conndb.GetDbSchemaVersion()
+	version, err := conndb.GetDbSchemaVersionContext(ctx)
 
 	if err != nil {
 		return err
@@ -77,75 +268,85 @@ func (conndb *ConnectionDB) CheckDbHealth() error {
 	return ValidateDbSchemaVersion(version)
 }
 
-// InitializeDb will populate an empty Sqlite file with the tables and default
-// values sshcm expects.
-// The function will return nil upon completion or an error when an exception
-// occurs.
-func (conndb *ConnectionDB) InitializeDb(version string) error {
-	err := ValidateDbSchemaVersion(version)
+// CheckDbHealth uses context.Background internally; to specify the context, use
+// CheckDbHealthContext.
+func (conndb *ConnectionDB) CheckDbHealth() error {
+	return conndb.CheckDbHealthContext(context.Background())
+}
 
-	db := conndb.connection
+// InitializeDbContext will populate an empty connection DB with the tables and
+// default values sshcm expects, in a single transaction. The function will
+// return nil upon completion or an error when an exception occurs.
+func (conndb *ConnectionDB) InitializeDbContext(ctx context.Context, version string) error {
+	err := ValidateDbSchemaVersion(version)
 
 	if err != nil {
 		return err
 	}
 
-	// Create table schema
-	_, err = db.Exec(schemas[version])
+	return conndb.store.Initialize(ctx, version)
+}
+
+// InitializeDb uses context.Background internally; to specify the context, use
+// InitializeDbContext.
+func (conndb *ConnectionDB) InitializeDb(version string) error {
+	return conndb.InitializeDbContext(context.Background(), version)
+}
+
+// UpgradeDbContext upgrades the connection DB to the schema version supported
+// by this package (SchemaVersion), in a single transaction. A DB that is
+// already at that version is left unchanged.
+//
+// ErrSchemaTooNew is returned if the DB is newer than this package, and
+// ErrSchemaNoUpgrade if it is too old to be upgraded.
+func (conndb *ConnectionDB) UpgradeDbContext(ctx context.Context) error {
+	version, err := conndb.GetDbSchemaVersionContext(ctx)
 
 	if err != nil {
 		return err
 	}
 
-	// Initialize global settings
-	_, err = db.Exec(`
-			INSERT INTO 'global' (setting,value)
-			VALUES ('schema_version',$1);
-		`, version)
+	err = ValidateDbSchemaVersion(version)
 
-	if err != nil {
+	if err == nil {
+		return nil
+	} else if err != ErrSchemaUpgradeNeeded {
 		return err
 	}
 
-	// Initialize default options
-	_, err = db.Exec(`
-			BEGIN TRANSACTION;
-			INSERT INTO 'defaults' (setting,value) VALUES ('binary',NULL);
-			INSERT INTO 'defaults' (setting,value) VALUES ('user',NULL);
-			INSERT INTO 'defaults' (setting,value) VALUES ('args',NULL);
-			INSERT INTO 'defaults' (setting,value) VALUES ('identity',NULL);
-			INSERT INTO 'defaults' (setting,value) VALUES ('command',NULL);
-			COMMIT;
-		`)
-
-	return err
+	return conndb.store.Upgrade(ctx, version)
 }
 
-// GetDbSchemaVersion will read and return the schema version from an sshcm
-// Sqlite database file. This does not validate whether the schema version is
-// usable by this package, it simply reads the version from the DB and returns
-// the result.
-func (conndb *ConnectionDB) GetDbSchemaVersion() (string, error) {
-	var v sql.NullString
+// UpgradeDb uses context.Background internally; to specify the context, use
+// UpgradeDbContext.
+func (conndb *ConnectionDB) UpgradeDb() error {
+	return conndb.UpgradeDbContext(context.Background())
+}
 
-	db := conndb.connection
+// InitializedContext returns true if the connection DB has been set up with
+// InitializeDb. A new connection DB (ex. a new Sqlite file) is not.
+func (conndb *ConnectionDB) InitializedContext(ctx context.Context) (bool, error) {
+	return conndb.store.Initialized(ctx)
+}
 
-	row := db.QueryRow(
-		`SELECT value
-		FROM global
-		WHERE setting = 'schema_version'`)
-	err := row.Scan(&v)
+// Initialized uses context.Background internally; to specify the context, use
+// InitializedContext.
+func (conndb *ConnectionDB) Initialized() (bool, error) {
+	return conndb.InitializedContext(context.Background())
+}
 
-	// Run some checks against the version number we pulled from the database
-	if err != nil {
-		// We got an error running the query
-		return "", err
-	} else if !v.Valid {
-		// The read version string is invalid
-		return "", ErrSchemaVerInvalid
-	}
+// GetDbSchemaVersionContext will read and return the schema version from an
+// sshcm connection DB. This does not validate whether the schema version
+// is usable by this package, it simply reads the version from the DB and
+// returns the result.
+func (conndb *ConnectionDB) GetDbSchemaVersionContext(ctx context.Context) (string, error) {
+	return conndb.store.SchemaVersion(ctx)
+}
 
-	return v.String, nil
+// GetDbSchemaVersion uses context.Background internally; to specify the
+// context, use GetDbSchemaVersionContext.
+func (conndb *ConnectionDB) GetDbSchemaVersion() (string, error) {
+	return conndb.GetDbSchemaVersionContext(context.Background())
 }
 
 // ValidateDbSchemaVersion runs checks against the passed schema version to see
@@ -12,7 +12,7 @@ func newMockConnDb() (*ConnectionDB, sqlmock.Sqlmock) {
 	db, mock, err := sqlmock.New()
 
 	conndb := &ConnectionDB{
-		connection: db,
+		store: NewSQLStore(db, SQLite),
 	}
 
 	if err != nil {
@@ -32,12 +32,47 @@ func Test_validateDbSchemaVersion(t *testing.T) {
 		//wantErr bool
 		want error
 	}{
+		{
+			name: "v1.6",
+			args: args{
+				version: "v1.6",
+			},
+			want: nil,
+		},
+		{
+			name: "v1.5",
+			args: args{
+				version: "v1.5",
+			},
+			want: ErrSchemaUpgradeNeeded,
+		},
+		{
+			name: "v1.4",
+			args: args{
+				version: "v1.4",
+			},
+			want: ErrSchemaUpgradeNeeded,
+		},
+		{
+			name: "v1.3",
+			args: args{
+				version: "v1.3",
+			},
+			want: ErrSchemaUpgradeNeeded,
+		},
+		{
+			name: "v1.2",
+			args: args{
+				version: "v1.2",
+			},
+			want: ErrSchemaUpgradeNeeded,
+		},
 		{
 			name: "v1.1",
 			args: args{
 				version: "v1.1",
 			},
-			want: nil,
+			want: ErrSchemaUpgradeNeeded,
 		},
 		{
 			name: "empty",
@@ -116,7 +151,7 @@ func TestConnectionDB_GetDbSchemaVersion(t *testing.T) {
 
 			got, err := conndb.GetDbSchemaVersion()
 
-			defer conndb.connection.Close()
+			defer conndb.Close()
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ConnectionDB.GetDbSchemaVersion() error = %v, wantErr %v", err, tt.wantErr)
@@ -1,50 +1,81 @@
 package cdb
 
 import (
-	"database/sql"
+	"context"
 )
 
-// GetDefault retrieves a program default property from the connection database.
+// GetDefaultContext retrieves a program default property from the connection
+// database.
 //
 // If the passed property name is not valid, ErrInvalidDefault will be returned.
-func (conndb *ConnectionDB) GetDefault(name string) (string, error) {
-	var def sql.NullString
-
+func (conndb *ConnectionDB) GetDefaultContext(ctx context.Context, name string) (string, error) {
 	if !IsValidDefault(name) {
 		return "", ErrInvalidDefault
 	}
 
-	// Get connection details from DB
-	err := conndb.connection.QueryRow(`
-		SELECT value
-		FROM defaults
-		WHERE setting = $1
-	`, name).Scan(&def)
-
-	if err != nil {
-		return "", err
-	}
+	return conndb.store.GetDefault(ctx, name)
+}
 
-	return def.String, nil
+// GetDefault uses context.Background internally; to specify the context, use
+// GetDefaultContext.
+func (conndb *ConnectionDB) GetDefault(name string) (string, error) {
+	return conndb.GetDefaultContext(context.Background(), name)
 }
 
-// SetDefault updates a program default property in the connection database.
+// SetDefaultContext updates a program default property in the connection
+// database.
 //
 // If the passed property name is not valid, ErrInvalidDefault will be returned.
-func (conndb *ConnectionDB) SetDefault(name string, value string) error {
+func (conndb *ConnectionDB) SetDefaultContext(ctx context.Context, name string, value string) error {
 	if !IsValidDefault(name) {
 		return ErrInvalidDefault
 	}
 
-	// Try updating the connection
-	_, err := conndb.connection.Exec(`
-		UPDATE defaults SET
-			value = $2
-		WHERE setting = $1
-		`,
-		name,
-		sqlNullableString(value),
-	)
-
-	return err
+	return conndb.atomic(ctx, func(db *ConnectionDB) error {
+		return db.store.SetDefault(ctx, name, value)
+	})
+}
+
+// SetDefault uses context.Background internally; to specify the context, use
+// SetDefaultContext.
+func (conndb *ConnectionDB) SetDefault(name string, value string) error {
+	return conndb.SetDefaultContext(context.Background(), name, value)
+}
+
+// GetSettingContext retrieves a global setting from the connection database.
+// Settings that haven't been set are empty.
+//
+// If the passed setting name is not valid, ErrInvalidSetting will be returned.
+func (conndb *ConnectionDB) GetSettingContext(ctx context.Context, name string) (string, error) {
+	if !IsValidSetting(name) {
+		return "", ErrInvalidSetting
+	}
+
+	return conndb.store.GetSetting(ctx, name)
+}
+
+// GetSetting uses context.Background internally; to specify the context, use
+// GetSettingContext.
+func (conndb *ConnectionDB) GetSetting(name string) (string, error) {
+	return conndb.GetSettingContext(context.Background(), name)
+}
+
+// SetSettingContext updates a global setting in the connection database.
+// Setting it to an empty string removes it.
+//
+// If the passed setting name is not valid, ErrInvalidSetting will be returned.
+func (conndb *ConnectionDB) SetSettingContext(ctx context.Context, name string, value string) error {
+	if !IsValidSetting(name) {
+		return ErrInvalidSetting
+	}
+
+	return conndb.atomic(ctx, func(db *ConnectionDB) error {
+		return db.store.SetSetting(ctx, name, value)
+	})
+}
+
+// SetSetting uses context.Background internally; to specify the context, use
+// SetSettingContext.
+func (conndb *ConnectionDB) SetSetting(name string, value string) error {
+	return conndb.SetSettingContext(context.Background(), name, value)
 }
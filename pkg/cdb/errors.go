@@ -2,6 +2,11 @@ package cdb
 
 import "errors"
 
+var ErrArchiveFormatTooNew = errors.New("archive format version too new")
+var ErrArchiveInvalid = errors.New("archive is invalid")
+var ErrArchiveUnknownColumn = errors.New("archive contains an unknown column")
+var ErrArchiveUnknownTable = errors.New("archive contains an unknown table")
+var ErrBusy = errors.New("connection DB is busy")
 var ErrConnFromDbInvalid = errors.New("connection from DB is invalid")
 var ErrConnIdZero = errors.New("connection id is zero")
 var ErrConnNoDb = errors.New("connection does not have a parent db attached")
@@ -15,10 +20,23 @@ var ErrInvalidConnectionProperty = errors.New("invalid connection property")
 var ErrInvalidDefault = errors.New("invalid default")
 var ErrInvalidId = errors.New("invalid id")
 var ErrInvalidIdOrNickname = errors.New("invalid id or nickname")
+var ErrInvalidListRange = errors.New("list limit and offset must not be negative")
 var ErrInvalidNickname = errors.New("invalid nickname")
+var ErrInvalidPattern = errors.New("invalid pattern connection")
+var ErrInvalidPlaceholder = errors.New("invalid placeholder")
+var ErrInvalidRange = errors.New("invalid pattern range (ex. 1-40)")
+var ErrInvalidSetting = errors.New("invalid global setting")
+var ErrJumpInUse = errors.New("connection is a jump host for other connections")
+var ErrJumpLoop = errors.New("jump host chain leads back to itself")
+var ErrJumpNotFound = errors.New("jump host connection does not exist")
 var ErrNickNameNotExist = errors.New("connection nickname does not exist")
 var ErrNicknameLetter = errors.New("nickname does not begin with a letter")
+var ErrNestedTx = errors.New("nested transactions are not supported")
+var ErrPatternInstance = errors.New("connection belongs to a pattern connection; change the pattern instead")
+var ErrPlaceholderUnset = errors.New("placeholder environment variable is not set")
 var ErrPropertyInvalid = errors.New("property is invalid")
+var ErrSessionInvalid = errors.New("session start and end times are invalid")
+var ErrStoreInitialized = errors.New("store is already initialized")
 var ErrUnsupportedSqlDriver = errors.New("sql driver not supported")
 
 // DB schema errors
@@ -0,0 +1,180 @@
+package cdb
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// envNamePattern matches the environment variable names placeholders can
+// refer to.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// percentTokens contains the letters of the percent tokens sshcm expands.
+const percentTokens = "hrnud%"
+
+// Expand returns a copy of the connection with the placeholders in its host,
+// user, identity, args and command settings replaced, so that one connection
+// can serve several users. Placeholders are:
+//
+//	${NAME}, ${env:NAME}  the environment variable NAME (ex. ${USER}, which is
+//	                      the local user name if USER isn't set)
+//	{{.Field}}            the connection's Id, Nickname, Description, Host
+//	                      or User
+//	%h, %r, %n            the host, (remote) user or nickname
+//	%u, %d                the local user name or home directory
+//	%%                    a literal %
+//
+// Percent tokens mean what they do in ssh_config, and are only replaced in
+// the host, user and identity settings; ssh handles them itself in ssh
+// options (ex. -o ControlPath=~/.ssh/cm-%r@%h). Other percent tokens are left
+// as they are, for ssh (ex. %C or %L in an identity) or because they aren't
+// tokens at all (ex. the zone of fe80::1%eth0). The host can't refer to
+// itself or to the user, and the user can't refer to itself. The other
+// settings see the expanded host and user.
+//
+// ErrInvalidPlaceholder is returned for placeholders that aren't known, and
+// ErrPlaceholderUnset for environment variables that aren't set.
+func (c Connection) Expand() (Connection, error) {
+	return c.expand(os.LookupEnv)
+}
+
+// expand expands the connection's placeholders (see Expand), looking up
+// environment variables with lookupEnv.
+func (c Connection) expand(lookupEnv func(string) (string, bool)) (Connection, error) {
+	home, _ := os.UserHomeDir()
+
+	vars := map[string]string{
+		".Id":          strconv.FormatInt(c.Id, 10),
+		".Nickname":    c.Nickname,
+		".Description": c.Description,
+		"%n":           c.Nickname,
+		"%u":           localUser(),
+		"%d":           home,
+		"%%":           "%",
+	}
+
+	fields := []struct {
+		name    string
+		value   *string
+		percent bool
+		vars    []string // the vars the expanded field is known by
+	}{
+		{"host", &c.Host, true, []string{".Host", "%h"}},
+		{"user", &c.User, true, []string{".User", "%r"}},
+		{"identity", &c.Identity, true, nil},
+		{"args", &c.Args, false, nil},
+		{"command", &c.Command, false, nil},
+	}
+
+	for _, f := range fields {
+		value, err := expandPlaceholders(*f.value, vars, f.percent, lookupEnv)
+
+		if err != nil {
+			return c, fmt.Errorf("%s: %w", f.name, err)
+		}
+
+		*f.value = value
+
+		for _, name := range f.vars {
+			vars[name] = value
+		}
+	}
+
+	return c, nil
+}
+
+// ValidatePlaceholders checks that the connection only uses known placeholders
+// (see Expand). Environment variables don't need to be set.
+func (c Connection) ValidatePlaceholders() error {
+	_, err := c.expand(func(string) (string, bool) { return "", true })
+
+	return err
+}
+
+// expandPlaceholders returns s with its placeholders replaced (see Expand).
+// Template fields are looked up in vars by name (ex. ".Nickname"), as are
+// percent tokens (ex. "%h") if percent is true. Percent tokens that sshcm
+// doesn't expand are kept.
+func expandPlaceholders(s string, vars map[string]string, percent bool, lookupEnv func(string) (string, bool)) (string, error) {
+	var b strings.Builder
+
+	for i := 0; i < len(s); {
+		rest := s[i:]
+
+		switch {
+		case strings.HasPrefix(rest, "${"):
+			end := strings.Index(rest, "}")
+
+			if end < 0 {
+				return "", fmt.Errorf("%w: %s", ErrInvalidPlaceholder, rest)
+			}
+
+			name := strings.TrimPrefix(rest[2:end], "env:")
+
+			if !envNamePattern.MatchString(name) {
+				return "", fmt.Errorf("%w: %s", ErrInvalidPlaceholder, rest[:end+1])
+			}
+
+			value, ok := lookupEnv(name)
+
+			// USER isn't set everywhere (ex. Windows or cron)
+			if !ok && name == "USER" {
+				value, ok = localUser(), true
+			}
+
+			if !ok {
+				return "", fmt.Errorf("%w: %s", ErrPlaceholderUnset, name)
+			}
+
+			b.WriteString(value)
+			i += end + 1
+
+		case strings.HasPrefix(rest, "{{"):
+			end := strings.Index(rest, "}}")
+
+			if end < 0 {
+				return "", fmt.Errorf("%w: %s", ErrInvalidPlaceholder, rest)
+			}
+
+			name := strings.TrimSpace(rest[2:end])
+			value, ok := vars[name]
+
+			if !strings.HasPrefix(name, ".") || !ok {
+				return "", fmt.Errorf("%w: %s", ErrInvalidPlaceholder, rest[:end+2])
+			}
+
+			b.WriteString(value)
+			i += end + 2
+
+		case percent && len(rest) > 1 && rest[0] == '%' && strings.IndexByte(percentTokens, rest[1]) >= 0:
+			value, ok := vars[rest[:2]]
+
+			// The host and user can't refer to themselves
+			if !ok {
+				return "", fmt.Errorf("%w: %s", ErrInvalidPlaceholder, rest[:2])
+			}
+
+			b.WriteString(value)
+			i += 2
+
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+
+	return b.String(), nil
+}
+
+// localUser returns the name of the user sshcm is running as.
+func localUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	return os.Getenv("USER")
+}
@@ -0,0 +1,159 @@
+package cdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConnection_Expand(t *testing.T) {
+	t.Setenv("SSHCM_TEST_USER", "alice")
+	t.Setenv("SSHCM_TEST_BASTION", "bastion.example.com")
+
+	home, _ := os.UserHomeDir()
+
+	c := Connection{
+		Id:       4,
+		Nickname: "web",
+		Host:     "{{.Nickname}}.example.com",
+		User:     "${SSHCM_TEST_USER}",
+		Identity: "%d/.ssh/%r@%h",
+		Args:     "-o ProxyJump=${env:SSHCM_TEST_BASTION} -o ControlPath=%r@%h",
+		Command:  "ssh",
+	}
+
+	got, err := c.Expand()
+
+	if err != nil {
+		t.Fatalf("Connection.Expand() error = %v", err)
+	}
+
+	want := Connection{
+		Id:       4,
+		Nickname: "web",
+		Host:     "web.example.com",
+		User:     "alice",
+		Identity: home + "/.ssh/alice@web.example.com",
+		Args:     "-o ProxyJump=bastion.example.com -o ControlPath=%r@%h",
+		Command:  "ssh",
+	}
+
+	if got != want {
+		t.Errorf("Connection.Expand() = %#v, want %#v", got, want)
+	}
+
+	// Percent tokens sshcm doesn't know are left for ssh
+	got, err = (Connection{Host: "fe80::1%eth0", User: "%u", Identity: "~/.ssh/id_%L-%C"}).Expand()
+
+	if err != nil || got.Host != "fe80::1%eth0" || got.Identity != "~/.ssh/id_%L-%C" {
+		t.Errorf("Connection.Expand() = %#v, %v, want unknown tokens kept", got, err)
+	}
+
+	// USER falls back to the local user name
+	t.Setenv("USER", "")
+	os.Unsetenv("USER")
+
+	if got, err := (Connection{User: "${USER}"}).Expand(); err != nil || got.User != localUser() {
+		t.Errorf("Connection.Expand() user = %q, %v, want %q", got.User, err, localUser())
+	}
+
+	tests := []struct {
+		name    string
+		c       Connection
+		wantErr error
+	}{
+		{"unset", Connection{Host: "${SSHCM_TEST_UNSET}"}, ErrPlaceholderUnset},
+		{"unknown-source", Connection{Host: "${vault:db}"}, ErrInvalidPlaceholder},
+		{"unknown-field", Connection{Host: "{{.Port}}"}, ErrInvalidPlaceholder},
+		{"unterminated", Connection{User: "${USER"}, ErrInvalidPlaceholder},
+		{"host-itself", Connection{Host: "%h"}, ErrInvalidPlaceholder},
+		{"host-user", Connection{Host: "{{ .User }}"}, ErrInvalidPlaceholder},
+		{"percent", Connection{Host: "fe80::1%%eth0", Args: "100%"}, nil},
+		{"percent-end", Connection{Host: "web", Identity: "~/.ssh/key%"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.c.Expand(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Connection.Expand() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConnection_ValidatePlaceholders(t *testing.T) {
+	c := Connection{Id: 1, Nickname: "web", Host: "${SSHCM_TEST_UNSET}"}
+
+	if err := c.ValidatePlaceholders(); err != nil {
+		t.Errorf("Connection.ValidatePlaceholders() error = %v, want nil", err)
+	}
+
+	c.User = "{{.Password}}"
+
+	if err := c.ValidatePlaceholders(); !errors.Is(err, ErrInvalidPlaceholder) {
+		t.Errorf("Connection.ValidatePlaceholders() error = %v, want %v", err, ErrInvalidPlaceholder)
+	}
+
+	// Placeholders are checked on write, not whenever connections are read
+	if err := c.Validate(); err != nil {
+		t.Errorf("Connection.Validate() error = %v, want nil", err)
+	}
+
+	runStoreTests(t, func(t *testing.T, conndb *ConnectionDB) {
+		c.Id = 0
+
+		if _, err := conndb.Add(&c); !errors.Is(err, ErrInvalidPlaceholder) {
+			t.Errorf("ConnectionDB.Add() error = %v, want %v", err, ErrInvalidPlaceholder)
+		}
+	})
+}
+
+func TestConnectionDB_ReadOldPlaceholders(t *testing.T) {
+	conndb, err := Connect("sqlite", filepath.Join(t.TempDir(), "test.connections"))
+
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	defer conndb.Close()
+
+	if err := conndb.store.Initialize(context.Background(), "v1.5"); err != nil {
+		t.Fatalf("Store.Initialize() error = %v", err)
+	}
+
+	// Written before placeholders existed
+	_, err = conndb.store.(*sqlStore).db.Exec(`INSERT INTO connections (nickname, host, identity, args)
+		VALUES ('lab', 'fe80::1%eth0', '~/.ssh/id_%L', 'echo ${oops')`)
+
+	if err != nil {
+		t.Fatalf("INSERT error = %v", err)
+	}
+
+	if err := conndb.UpgradeDb(); err != nil {
+		t.Fatalf("ConnectionDB.UpgradeDb() error = %v", err)
+	}
+
+	c, err := conndb.GetByIdOrNickname("lab")
+
+	if err != nil || c.Host != "fe80::1%eth0" || c.Identity != "~/.ssh/id_%L" {
+		t.Fatalf("ConnectionDB.GetByIdOrNickname() = %#v, %v, want the stored connection", c, err)
+	}
+
+	if cns, _, err := conndb.List(context.Background(), ListOptions{}); err != nil || len(cns) != 1 {
+		t.Errorf("ConnectionDB.List() = %v, %v, want 1 connection", cns, err)
+	}
+
+	// The connection can be fixed...
+	c.Args = ""
+
+	if err := c.Update(); err != nil {
+		t.Errorf("Connection.Update() error = %v, want nil", err)
+	}
+
+	// ...or removed
+	if err := c.Delete(); err != nil {
+		t.Errorf("Connection.Delete() error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,1074 @@
+package cdb
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileData is the contents of a file store.
+type fileData struct {
+	SchemaVersion string            `json:"schema_version" yaml:"schema_version"`
+	NextId        int64             `json:"next_id" yaml:"next_id"`
+	Settings      map[string]string `json:"settings,omitempty" yaml:"settings,omitempty"`
+	Defaults      map[string]string `json:"defaults" yaml:"defaults"`
+	Connections   []fileConnection  `json:"connections" yaml:"connections"`
+	NextSessionId int64             `json:"next_session_id,omitempty" yaml:"next_session_id,omitempty"`
+	Sessions      []fileSession     `json:"sessions,omitempty" yaml:"sessions,omitempty"`
+}
+
+// fileConnection is a connection, as kept in a file store.
+type fileConnection struct {
+	Id          int64  `json:"id" yaml:"id"`
+	Nickname    string `json:"nickname" yaml:"nickname"`
+	Host        string `json:"host" yaml:"host"`
+	User        string `json:"user,omitempty" yaml:"user,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Args        string `json:"args,omitempty" yaml:"args,omitempty"`
+	Identity    string `json:"identity,omitempty" yaml:"identity,omitempty"`
+	Command     string `json:"command,omitempty" yaml:"command,omitempty"`
+	Jump        string `json:"jump,omitempty" yaml:"jump,omitempty"`
+	PreHook     string `json:"pre_hook,omitempty" yaml:"pre_hook,omitempty"`
+	PostHook    string `json:"post_hook,omitempty" yaml:"post_hook,omitempty"`
+	Record      bool   `json:"record,omitempty" yaml:"record,omitempty"`
+	Range       string `json:"range,omitempty" yaml:"range,omitempty"`
+}
+
+// fileSession is a session record, as kept in a file store.
+type fileSession struct {
+	Id           int64     `json:"id" yaml:"id"`
+	ConnectionId int64     `json:"connection_id,omitempty" yaml:"connection_id,omitempty"`
+	Nickname     string    `json:"nickname" yaml:"nickname"`
+	Started      time.Time `json:"started" yaml:"started"`
+	Ended        time.Time `json:"ended" yaml:"ended"`
+	ExitStatus   int       `json:"exit_status" yaml:"exit_status"`
+	Recording    string    `json:"recording,omitempty" yaml:"recording,omitempty"`
+}
+
+// fileTables lists the archive tables a file store holds, and their columns.
+// binary is accepted (and ignored) so that archives of SQLite DBs can be
+// restored.
+var fileTables = map[string][]string{
+	"global":   {"setting", "value"},
+	"defaults": {"setting", "value"},
+	"connections": {
+		"id",
+		"nickname",
+		"host",
+		"user",
+		"description",
+		"args",
+		"identity",
+		"command",
+		"binary",
+		"jump",
+		"pre_hook",
+		"post_hook",
+		"record",
+		"range",
+	},
+	"sessions": {
+		"id",
+		"connection_id",
+		"nickname",
+		"started",
+		"ended",
+		"exit_status",
+		"recording",
+	},
+}
+
+// A fileStore is a Store kept in a single JSON or YAML file. The whole file is
+// read for every operation, and rewritten for every change, so it suits small
+// connection DBs that are kept in version control or edited by hand.
+type fileStore struct {
+	path string
+	yaml bool
+
+	// data is set in a transaction, where changes are made in memory and
+	// written once the transaction is committed.
+	data *fileData
+}
+
+// NewFileStore returns a Store kept in the file at path. Files whose names end
+// in .yaml or .yml are kept as YAML; all others are kept as JSON.
+//
+// The file is created by Initialize. Changes are written to a temporary file
+// that is renamed over the original, so readers never see a partial write.
+// Writers hold an advisory lock on a ".lock" file next to it while they read,
+// change and write the file, so that changes from several processes aren't
+// lost.
+func NewFileStore(path string) Store {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	return &fileStore{
+		path: path,
+		yaml: ext == ".yaml" || ext == ".yml",
+	}
+}
+
+// connection converts a stored connection to a detached Connection.
+func (fc fileConnection) connection() Connection {
+	return Connection{
+		Id:          fc.Id,
+		Nickname:    fc.Nickname,
+		Host:        fc.Host,
+		User:        fc.User,
+		Description: fc.Description,
+		Args:        fc.Args,
+		Identity:    fc.Identity,
+		Command:     fc.Command,
+		Jump:        fc.Jump,
+		PreHook:     fc.PreHook,
+		PostHook:    fc.PostHook,
+		Record:      fc.Record,
+		Range:       fc.Range,
+	}
+}
+
+// newFileConnection converts a Connection to a stored connection.
+func newFileConnection(c Connection) fileConnection {
+	return fileConnection{
+		Id:          c.Id,
+		Nickname:    c.Nickname,
+		Host:        c.Host,
+		User:        c.User,
+		Description: c.Description,
+		Args:        c.Args,
+		Identity:    c.Identity,
+		Command:     c.Command,
+		Jump:        c.Jump,
+		PreHook:     c.PreHook,
+		PostHook:    c.PostHook,
+		Record:      c.Record,
+		Range:       c.Range,
+	}
+}
+
+// property returns the value of a stored connection's property ("id", "record"
+// or one of ValidProperties), as it is kept in a SQL store.
+func (fc fileConnection) property(name string) any {
+	switch name {
+	case "id":
+		return fc.Id
+	case "nickname":
+		return fc.Nickname
+	case "host":
+		return fc.Host
+	case "user":
+		return fc.User
+	case "description":
+		return fc.Description
+	case "args":
+		return fc.Args
+	case "identity":
+		return fc.Identity
+	case "command":
+		return fc.Command
+	case "jump":
+		return fc.Jump
+	case "pre_hook":
+		return fc.PreHook
+	case "post_hook":
+		return fc.PostHook
+	case "record":
+		return sqlBool(fc.Record)
+	case "range":
+		return fc.Range
+	}
+
+	return nil
+}
+
+// matches returns true if the connection matches a ListOptions filter.
+func (fc fileConnection) matches(filter string) bool {
+	filter = strings.ToLower(filter)
+
+	for _, v := range []string{fc.Nickname, fc.Host, fc.User, fc.Description} {
+		if strings.Contains(strings.ToLower(v), filter) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// read decodes the file.
+func (s *fileStore) read() (*fileData, error) {
+	b, err := os.ReadFile(s.path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var d fileData
+
+	if s.yaml {
+		err = yaml.Unmarshal(b, &d)
+	} else {
+		err = json.Unmarshal(b, &d)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path, err)
+	}
+
+	if d.Defaults == nil {
+		d.Defaults = make(map[string]string)
+	}
+
+	return &d, nil
+}
+
+// write encodes d to the file, replacing it atomically.
+func (s *fileStore) write(d *fileData) error {
+	var buf bytes.Buffer
+
+	if s.yaml {
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+
+		if err := enc.Encode(d); err != nil {
+			return err
+		}
+
+		if err := enc.Close(); err != nil {
+			return err
+		}
+	} else {
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(d); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+".*")
+
+	if err != nil {
+		return err
+	}
+
+	// Removing the temporary file after it's renamed does nothing
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path)
+}
+
+// view calls fn with the store's contents.
+func (s *fileStore) view(ctx context.Context, fn func(d *fileData) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if s.data != nil {
+		return fn(s.data)
+	}
+
+	d, err := s.read()
+
+	if err != nil {
+		return err
+	}
+
+	return fn(d)
+}
+
+// update calls fn with the store's contents, then saves any changes fn made,
+// unless it returned an error.
+func (s *fileStore) update(ctx context.Context, fn func(d *fileData) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// In a transaction, changes are saved on commit
+	if s.data != nil {
+		return fn(s.data)
+	}
+
+	unlock, err := lockFile(ctx, s.path+".lock")
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	d, err := s.read()
+
+	if err != nil {
+		return err
+	}
+
+	if err := fn(d); err != nil {
+		return err
+	}
+
+	return s.write(d)
+}
+
+// index returns the index of the connection with the passed property value,
+// or -1.
+func (d *fileData) index(property string, value any) int {
+	// Ids may be passed as any integer type, so compare them as strings
+	if property == "id" {
+		value = fmt.Sprint(value)
+	}
+
+	return slices.IndexFunc(d.Connections, func(fc fileConnection) bool {
+		v := fc.property(property)
+
+		if property == "id" {
+			v = fmt.Sprint(v)
+		}
+
+		return v == value
+	})
+}
+
+func (s *fileStore) Initialize(ctx context.Context, version string) error {
+	if _, ok := SQLite.schemas[version]; !ok {
+		return ErrSchemaVerInvalid
+	}
+
+	unlock, err := lockFile(ctx, s.path+".lock")
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	if _, err := os.Stat(s.path); err == nil {
+		return ErrStoreInitialized
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	d := &fileData{
+		SchemaVersion: version,
+		NextId:        1,
+		Defaults:      make(map[string]string),
+		Connections:   []fileConnection{},
+	}
+
+	for _, name := range ValidDefaults {
+		d.Defaults[name] = ""
+	}
+
+	return s.write(d)
+}
+
+// Upgrade only updates the schema version, as file stores have no tables to
+// change; connection properties added by newer versions start out empty.
+func (s *fileStore) Upgrade(ctx context.Context, version string) error {
+	return s.update(ctx, func(d *fileData) error {
+		d.SchemaVersion = SchemaVersion
+		return nil
+	})
+}
+
+func (s *fileStore) Initialized(ctx context.Context) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	_, err := os.Stat(s.path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *fileStore) SchemaVersion(ctx context.Context) (string, error) {
+	var version string
+
+	err := s.view(ctx, func(d *fileData) error {
+		if len(d.SchemaVersion) < 1 {
+			return ErrSchemaVerInvalid
+		}
+
+		version = d.SchemaVersion
+
+		return nil
+	})
+
+	return version, err
+}
+
+func (s *fileStore) AddConnection(ctx context.Context, c Connection) (int64, error) {
+	var id int64
+
+	err := s.update(ctx, func(d *fileData) error {
+		if d.index("nickname", c.Nickname) >= 0 {
+			return ErrDuplicateNickname
+		}
+
+		// Ids are never reused, even if the file was edited by hand
+		for _, fc := range d.Connections {
+			d.NextId = max(d.NextId, fc.Id+1)
+		}
+
+		id = max(d.NextId, 1)
+		d.NextId = id + 1
+
+		c.Id = id
+		d.Connections = append(d.Connections, newFileConnection(c))
+
+		return nil
+	})
+
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+func (s *fileStore) UpdateConnection(ctx context.Context, c Connection) error {
+	return s.update(ctx, func(d *fileData) error {
+		i := d.index("id", c.Id)
+
+		if i < 0 {
+			return ErrIdNotExist
+		}
+
+		d.Connections[i] = newFileConnection(c)
+
+		return nil
+	})
+}
+
+func (s *fileStore) DeleteConnection(ctx context.Context, id int64) error {
+	return s.update(ctx, func(d *fileData) error {
+		d.Connections = slices.DeleteFunc(d.Connections, func(fc fileConnection) bool {
+			return fc.Id == id
+		})
+
+		return nil
+	})
+}
+
+func (s *fileStore) ConnectionExists(ctx context.Context, property string, value any) (bool, error) {
+	var exists bool
+
+	err := s.view(ctx, func(d *fileData) error {
+		exists = d.index(property, value) >= 0
+		return nil
+	})
+
+	return exists, err
+}
+
+func (s *fileStore) GetConnection(ctx context.Context, property string, value any) (Connection, error) {
+	var c Connection
+
+	err := s.view(ctx, func(d *fileData) error {
+		i := d.index(property, value)
+
+		if i < 0 {
+			return ErrConnectionNotFound
+		}
+
+		c = d.Connections[i].connection()
+
+		return c.Validate()
+	})
+
+	return c, err
+}
+
+// list returns the stored connections matching filter, sorted as described
+// for ListOptions.
+func (d *fileData) list(opts ListOptions) []fileConnection {
+	var cns []fileConnection
+
+	for _, fc := range d.Connections {
+		if len(opts.Filter) < 1 || fc.matches(opts.Filter) {
+			cns = append(cns, fc)
+		}
+	}
+
+	sortBy := opts.SortBy
+
+	if len(sortBy) < 1 {
+		sortBy = "id"
+	}
+
+	sort.SliceStable(cns, func(i, j int) bool {
+		a, b := cns[i], cns[j]
+
+		if opts.Desc {
+			a, b = b, a
+		}
+
+		if sortBy != "id" {
+			av := strings.ToLower(a.property(sortBy).(string))
+			bv := strings.ToLower(b.property(sortBy).(string))
+
+			if av != bv {
+				return av < bv
+			}
+		}
+
+		return a.Id < b.Id
+	})
+
+	return cns
+}
+
+func (s *fileStore) ListConnections(ctx context.Context, opts ListOptions, fn func(Connection) error) error {
+	var cns []fileConnection
+
+	err := s.view(ctx, func(d *fileData) error {
+		cns = d.list(opts)
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	cns = cns[min(opts.Offset, len(cns)):]
+
+	if opts.Limit > 0 {
+		cns = cns[:min(opts.Limit, len(cns))]
+	}
+
+	for _, fc := range cns {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		c := fc.connection()
+
+		if err := c.Validate(); err != nil {
+			return err
+		}
+
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *fileStore) CountConnections(ctx context.Context, filter string) (int, error) {
+	var total int
+
+	err := s.view(ctx, func(d *fileData) error {
+		total = len(d.list(ListOptions{Filter: filter}))
+		return nil
+	})
+
+	return total, err
+}
+
+func (s *fileStore) AddSession(ctx context.Context, session Session) (int64, error) {
+	var id int64
+
+	err := s.update(ctx, func(d *fileData) error {
+		id = max(d.NextSessionId, 1)
+		d.NextSessionId = id + 1
+
+		d.Sessions = append(d.Sessions, fileSession{
+			Id:           id,
+			ConnectionId: session.ConnectionId,
+			Nickname:     session.Nickname,
+			Started:      session.Started.UTC(),
+			Ended:        session.Ended.UTC(),
+			ExitStatus:   session.ExitStatus,
+			Recording:    session.Recording,
+		})
+
+		return nil
+	})
+
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+func (s *fileStore) ListSessions(ctx context.Context, connectionId int64, limit int, fn func(Session) error) error {
+	var sessions []fileSession
+
+	err := s.view(ctx, func(d *fileData) error {
+		sessions = slices.DeleteFunc(slices.Clone(d.Sessions), func(fs fileSession) bool {
+			return connectionId > 0 && fs.ConnectionId != connectionId
+		})
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// Newest first, like the SQL stores
+	slices.SortStableFunc(sessions, func(a, b fileSession) int {
+		if c := b.Started.Compare(a.Started); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(b.Id, a.Id)
+	})
+
+	if limit > 0 && len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+
+	for _, fs := range sessions {
+		err := fn(Session{
+			Id:           fs.Id,
+			ConnectionId: fs.ConnectionId,
+			Nickname:     fs.Nickname,
+			Started:      fs.Started,
+			Ended:        fs.Ended,
+			ExitStatus:   fs.ExitStatus,
+			Recording:    fs.Recording,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *fileStore) GetDefault(ctx context.Context, name string) (string, error) {
+	var def string
+
+	err := s.view(ctx, func(d *fileData) error {
+		def = d.Defaults[name]
+		return nil
+	})
+
+	return def, err
+}
+
+func (s *fileStore) SetDefault(ctx context.Context, name string, value string) error {
+	return s.update(ctx, func(d *fileData) error {
+		d.Defaults[name] = value
+		return nil
+	})
+}
+
+func (s *fileStore) GetSetting(ctx context.Context, name string) (string, error) {
+	var value string
+
+	err := s.view(ctx, func(d *fileData) error {
+		value = d.Settings[name]
+		return nil
+	})
+
+	return value, err
+}
+
+func (s *fileStore) SetSetting(ctx context.Context, name string, value string) error {
+	return s.update(ctx, func(d *fileData) error {
+		if len(value) < 1 {
+			delete(d.Settings, name)
+			return nil
+		}
+
+		if d.Settings == nil {
+			d.Settings = make(map[string]string)
+		}
+
+		d.Settings[name] = value
+
+		return nil
+	})
+}
+
+func (s *fileStore) Dump(ctx context.Context) (map[string]*ArchiveTable, error) {
+	dump := make(map[string]*ArchiveTable)
+
+	err := s.view(ctx, func(d *fileData) error {
+		global := &ArchiveTable{
+			Columns: fileTables["global"],
+			Rows:    [][]any{{"schema_version", d.SchemaVersion}},
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(d.Settings)) {
+			global.Rows = append(global.Rows, []any{name, d.Settings[name]})
+		}
+
+		dump["global"] = global
+
+		defaults := &ArchiveTable{Columns: fileTables["defaults"], Rows: [][]any{}}
+
+		for _, name := range slices.Sorted(maps.Keys(d.Defaults)) {
+			defaults.Rows = append(defaults.Rows, []any{name, d.Defaults[name]})
+		}
+
+		dump["defaults"] = defaults
+
+		// binary isn't kept, so it isn't dumped
+		cols := slices.DeleteFunc(slices.Clone(fileTables["connections"]), func(col string) bool {
+			return col == "binary"
+		})
+
+		connections := &ArchiveTable{Columns: cols, Rows: [][]any{}}
+
+		for _, fc := range d.Connections {
+			row := make([]any, len(cols))
+
+			for i, col := range cols {
+				row[i] = fc.property(col)
+			}
+
+			connections.Rows = append(connections.Rows, row)
+		}
+
+		dump["connections"] = connections
+
+		sessions := &ArchiveTable{Columns: fileTables["sessions"], Rows: [][]any{}}
+
+		for _, fs := range d.Sessions {
+			var connId, recording any
+
+			if fs.ConnectionId > 0 {
+				connId = fs.ConnectionId
+			}
+
+			if len(fs.Recording) > 0 {
+				recording = fs.Recording
+			}
+
+			sessions.Rows = append(sessions.Rows, []any{
+				fs.Id,
+				connId,
+				fs.Nickname,
+				formatSessionTime(fs.Started),
+				formatSessionTime(fs.Ended),
+				fs.ExitStatus,
+				recording,
+			})
+		}
+
+		dump["sessions"] = sessions
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dump, nil
+}
+
+// archiveString converts a value decoded from an archive to a string. NULLs
+// become empty strings.
+func archiveString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+
+	return fmt.Sprint(v)
+}
+
+// archiveInt64 converts a value decoded from an archive to an int64.
+func archiveInt64(v any) (int64, error) {
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case json.Number:
+		return v.Int64()
+	}
+
+	return strconv.ParseInt(archiveString(v), 10, 64)
+}
+
+// archiveBool converts a value decoded from an archive to a bool. NULLs are
+// false.
+func archiveBool(v any) (bool, error) {
+	switch v := v.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	}
+
+	i, err := archiveInt64(v)
+
+	return i != 0, err
+}
+
+func (s *fileStore) Load(ctx context.Context, tables map[string]*ArchiveTable) error {
+	// Make sure every archived table and column is known before changing
+	// anything
+	for name, t := range tables {
+		cols, ok := fileTables[name]
+
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrArchiveUnknownTable, name)
+		}
+
+		for _, col := range t.Columns {
+			if !slices.Contains(cols, col) {
+				return fmt.Errorf("%w: %s.%s", ErrArchiveUnknownColumn, name, col)
+			}
+		}
+
+		for _, row := range t.Rows {
+			if len(row) != len(t.Columns) {
+				return fmt.Errorf("%w: %s", ErrArchiveInvalid, name)
+			}
+		}
+	}
+
+	return s.update(ctx, func(d *fileData) error {
+		if t, ok := tables["global"]; ok {
+			d.SchemaVersion = ""
+			d.Settings = nil
+
+			for _, row := range t.Rows {
+				r := archiveRow(t, row)
+				name, value := archiveString(r["setting"]), archiveString(r["value"])
+
+				switch {
+				case name == "schema_version":
+					d.SchemaVersion = value
+				case len(value) > 0:
+					if d.Settings == nil {
+						d.Settings = make(map[string]string)
+					}
+
+					d.Settings[name] = value
+				}
+			}
+		}
+
+		if t, ok := tables["defaults"]; ok {
+			d.Defaults = make(map[string]string)
+
+			// Defaults this package doesn't use (ex. binary) are dropped
+			for _, row := range t.Rows {
+				r := archiveRow(t, row)
+				name := archiveString(r["setting"])
+
+				if IsValidDefault(name) {
+					d.Defaults[name] = archiveString(r["value"])
+				}
+			}
+		}
+
+		if t, ok := tables["connections"]; ok {
+			if err := d.loadConnections(t); err != nil {
+				return err
+			}
+		}
+
+		if t, ok := tables["sessions"]; ok {
+			return d.loadSessions(t)
+		}
+
+		return nil
+	})
+}
+
+// archiveRow maps an archive table row's column names to its values.
+func archiveRow(t *ArchiveTable, row []any) map[string]any {
+	r := make(map[string]any, len(row))
+
+	for i, col := range t.Columns {
+		r[col] = row[i]
+	}
+
+	return r
+}
+
+// loadConnections replaces the stored connections with those in t.
+func (d *fileData) loadConnections(t *ArchiveTable) error {
+	d.Connections = []fileConnection{}
+
+	for _, row := range t.Rows {
+		r := archiveRow(t, row)
+
+		fc := fileConnection{
+			Nickname:    archiveString(r["nickname"]),
+			Host:        archiveString(r["host"]),
+			User:        archiveString(r["user"]),
+			Description: archiveString(r["description"]),
+			Args:        archiveString(r["args"]),
+			Identity:    archiveString(r["identity"]),
+			Command:     archiveString(r["command"]),
+			Jump:        archiveString(r["jump"]),
+			PreHook:     archiveString(r["pre_hook"]),
+			PostHook:    archiveString(r["post_hook"]),
+			Range:       archiveString(r["range"]),
+		}
+
+		record, err := archiveBool(r["record"])
+
+		if err != nil {
+			return fmt.Errorf("%w: connections: invalid record flag %v", ErrArchiveInvalid, r["record"])
+		}
+
+		fc.Record = record
+
+		if v, ok := r["id"]; ok && v != nil {
+			id, err := archiveInt64(v)
+
+			if err != nil || id < 1 {
+				return fmt.Errorf("%w: connections: invalid id %v", ErrArchiveInvalid, v)
+			}
+
+			fc.Id = id
+		}
+
+		if err := fc.connection().Validate(); err != nil && err != ErrConnIdZero {
+			return fmt.Errorf("%w: connections: %w", ErrArchiveInvalid, err)
+		}
+
+		if d.index("nickname", fc.Nickname) >= 0 || (fc.Id > 0 && d.index("id", fc.Id) >= 0) {
+			return fmt.Errorf("%w: connections: duplicate connection %s", ErrArchiveInvalid, fc.Nickname)
+		}
+
+		d.Connections = append(d.Connections, fc)
+	}
+
+	// Number connections without an id after those with one
+	for _, fc := range d.Connections {
+		d.NextId = max(d.NextId, fc.Id+1)
+	}
+
+	for i := range d.Connections {
+		if d.Connections[i].Id == 0 {
+			d.Connections[i].Id = d.NextId
+			d.NextId++
+		}
+	}
+
+	return nil
+}
+
+// loadSessions replaces the stored sessions with those in t.
+func (d *fileData) loadSessions(t *ArchiveTable) error {
+	d.Sessions = nil
+	d.NextSessionId = 1
+
+	for _, row := range t.Rows {
+		r := archiveRow(t, row)
+
+		var fs fileSession
+		var err error
+
+		fs.Nickname = archiveString(r["nickname"])
+		fs.Recording = archiveString(r["recording"])
+
+		if fs.Id, err = archiveInt64(r["id"]); err != nil || fs.Id < 1 {
+			return fmt.Errorf("%w: sessions: invalid id %v", ErrArchiveInvalid, r["id"])
+		}
+
+		if v := r["connection_id"]; v != nil {
+			if fs.ConnectionId, err = archiveInt64(v); err != nil {
+				return fmt.Errorf("%w: sessions: invalid connection id %v", ErrArchiveInvalid, v)
+			}
+		}
+
+		status, err := archiveInt64(r["exit_status"])
+
+		if err != nil {
+			return fmt.Errorf("%w: sessions: invalid exit status %v", ErrArchiveInvalid, r["exit_status"])
+		}
+
+		fs.ExitStatus = int(status)
+
+		fs.Started, err = parseSessionTime(archiveString(r["started"]))
+
+		if err != nil {
+			return fmt.Errorf("%w: sessions: %w", ErrArchiveInvalid, err)
+		}
+
+		fs.Ended, err = parseSessionTime(archiveString(r["ended"]))
+
+		if err != nil {
+			return fmt.Errorf("%w: sessions: %w", ErrArchiveInvalid, err)
+		}
+
+		d.Sessions = append(d.Sessions, fs)
+		d.NextSessionId = max(d.NextSessionId, fs.Id+1)
+	}
+
+	return nil
+}
+
+func (s *fileStore) WithTx(ctx context.Context, fn func(st Store) error) error {
+	if s.data != nil {
+		return ErrNestedTx
+	}
+
+	unlock, err := lockFile(ctx, s.path+".lock")
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	d, err := s.read()
+
+	if err != nil {
+		return err
+	}
+
+	// Changes are made to the in-memory copy, which is discarded on error
+	if err := fn(&fileStore{path: s.path, yaml: s.yaml, data: d}); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.write(d)
+}
+
+// Close does nothing; the file is only open while it is read or written.
+func (s *fileStore) Close() error {
+	return nil
+}
@@ -14,3 +14,13 @@ func sqlNullableInt64(i int64) sql.NullInt64 {
 func sqlNullableString(s string) sql.NullString {
 	return sql.NullString{String: s, Valid: true}
 }
+
+// sqlBool returns the passed bool as an INTEGER (1 or 0), so that it is stored
+// the same way by every dialect.
+func sqlBool(b bool) int64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
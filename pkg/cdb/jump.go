@@ -0,0 +1,210 @@
+package cdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// JumpHosts returns the nicknames of the connection's jump hosts, in the
+// order they are connected through.
+func (c Connection) JumpHosts() []string {
+	var nicknames []string
+
+	for _, n := range strings.Split(c.Jump, ",") {
+		if n = strings.TrimSpace(n); len(n) > 0 {
+			nicknames = append(nicknames, n)
+		}
+	}
+
+	return nicknames
+}
+
+// JoinJumpHosts returns the Jump property value for the passed jump host
+// nicknames.
+func JoinJumpHosts(nicknames []string) string {
+	return strings.Join(nicknames, ",")
+}
+
+// RemovalOrder returns the passed connections in an order they can be deleted
+// in, with each jump host after the connections using it (see ErrJumpInUse).
+func RemovalOrder(cns []Connection) []Connection {
+	var ordered []Connection
+
+	remaining := slices.Clone(cns)
+
+	for len(remaining) > 0 {
+		// Find a connection that none of the others jump through
+		i := slices.IndexFunc(remaining, func(c Connection) bool {
+			return !slices.ContainsFunc(remaining, func(u Connection) bool {
+				return slices.Contains(u.JumpHosts(), c.Nickname)
+			})
+		})
+
+		// Jump host loops can't be stored, but don't spin if there is one
+		if i < 0 {
+			return append(ordered, remaining...)
+		}
+
+		ordered = append(ordered, remaining[i])
+		remaining = slices.Delete(remaining, i, i+1)
+	}
+
+	return ordered
+}
+
+// SetJumpHosts sets the Jump property of several connections in the
+// transaction. jumps holds the new Jump values by nickname, so that jump hosts
+// can be set once every connection in a batch exists (ex. after an import in
+// which a jump host comes after the connections using it).
+//
+// Changed jump hosts are cleared before any are set, so that a chain that is
+// being rearranged doesn't look like a loop part way through.
+func (tx *Tx) SetJumpHosts(jumps map[string]string) error {
+	var changed []Connection
+
+	for _, nickname := range slices.Sorted(maps.Keys(jumps)) {
+		c, err := tx.GetByProperty("nickname", nickname)
+
+		if err != nil {
+			return err
+		}
+
+		if c.Jump == jumps[nickname] {
+			continue
+		}
+
+		if len(c.Jump) > 0 {
+			c.Jump = ""
+
+			if err := tx.Update(c); err != nil {
+				return err
+			}
+		}
+
+		c.Jump = jumps[nickname]
+		changed = append(changed, c)
+	}
+
+	for _, c := range changed {
+		if len(c.Jump) < 1 {
+			continue
+		}
+
+		if err := tx.Update(c); err != nil {
+			return fmt.Errorf("connection '%s': %w", c.Nickname, err)
+		}
+	}
+
+	return nil
+}
+
+// JumpChainContext returns the connections that must be connected through, in
+// order, to reach the passed connection. Jump hosts may have jump hosts of
+// their own, which come before them in the chain.
+//
+// ErrJumpNotFound is returned if a jump host doesn't exist, and ErrJumpLoop
+// if the chain leads back to a connection already in it.
+func (conndb *ConnectionDB) JumpChainContext(ctx context.Context, c Connection) ([]Connection, error) {
+	var chain []Connection
+
+	err := conndb.jumpChain(ctx, c, []string{c.Nickname}, &chain)
+
+	return chain, err
+}
+
+// JumpChain uses context.Background internally; to specify the context, use
+// JumpChainContext.
+func (conndb *ConnectionDB) JumpChain(c Connection) ([]Connection, error) {
+	return conndb.JumpChainContext(context.Background(), c)
+}
+
+// jumpChain appends the jump hosts of c (and theirs) to chain. path holds the
+// nicknames of the connections being resolved, to catch loops.
+func (conndb *ConnectionDB) jumpChain(ctx context.Context, c Connection, path []string, chain *[]Connection) error {
+	for _, nickname := range c.JumpHosts() {
+		if slices.Contains(path, nickname) {
+			return fmt.Errorf("%w: %s", ErrJumpLoop, nickname)
+		}
+
+		hop, err := conndb.GetByPropertyContext(ctx, "nickname", nickname)
+
+		if errors.Is(err, ErrConnectionNotFound) {
+			return fmt.Errorf("%w: %s", ErrJumpNotFound, nickname)
+		} else if err != nil {
+			return err
+		}
+
+		err = conndb.jumpChain(ctx, hop, append(slices.Clip(path), nickname), chain)
+
+		if err != nil {
+			return err
+		}
+
+		*chain = append(*chain, hop)
+	}
+
+	return nil
+}
+
+// validateJump checks that the jump hosts of a connection that is about to be
+// stored exist and don't lead back to it. previous is the connection's stored
+// nickname, if it has one.
+func (conndb *ConnectionDB) validateJump(ctx context.Context, c Connection, previous string) error {
+	path := []string{c.Nickname}
+
+	if len(previous) > 0 && previous != c.Nickname {
+		path = append(path, previous)
+	}
+
+	var chain []Connection
+
+	return conndb.jumpChain(ctx, c, path, &chain)
+}
+
+// jumpUsers returns the connections that use the connection with the passed
+// nickname as a jump host.
+func (conndb *ConnectionDB) jumpUsers(ctx context.Context, nickname string) ([]*Connection, error) {
+	var users []*Connection
+
+	err := conndb.ForEachContext(ctx, func(c *Connection) error {
+		if slices.Contains(c.JumpHosts(), nickname) {
+			users = append(users, c)
+		}
+
+		return nil
+	})
+
+	return users, err
+}
+
+// renameJump updates the connections that use a jump host when its nickname
+// changes.
+func (conndb *ConnectionDB) renameJump(ctx context.Context, from string, to string) error {
+	users, err := conndb.jumpUsers(ctx, from)
+
+	if err != nil {
+		return err
+	}
+
+	for _, c := range users {
+		nicknames := c.JumpHosts()
+
+		for i := range nicknames {
+			if nicknames[i] == from {
+				nicknames[i] = to
+			}
+		}
+
+		c.Jump = JoinJumpHosts(nicknames)
+
+		if err := conndb.store.UpdateConnection(ctx, *c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
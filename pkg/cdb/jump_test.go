@@ -0,0 +1,296 @@
+package cdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestConnection_JumpHosts(t *testing.T) {
+	tests := []struct {
+		jump string
+		want []string
+	}{
+		{"", nil},
+		{"bastion", []string{"bastion"}},
+		{"outer, inner", []string{"outer", "inner"}},
+		{",outer,,inner,", []string{"outer", "inner"}},
+	}
+
+	for _, tt := range tests {
+		got := Connection{Jump: tt.jump}.JumpHosts()
+
+		if JoinJumpHosts(got) != JoinJumpHosts(tt.want) || len(got) != len(tt.want) {
+			t.Errorf("Connection{Jump: %q}.JumpHosts() = %q, want %q", tt.jump, got, tt.want)
+		}
+	}
+}
+
+// addJumpTestConnections adds outer, inner (jumping through outer) and web
+// (jumping through inner), and returns their ids.
+func addJumpTestConnections(t *testing.T, conndb *ConnectionDB) map[string]int64 {
+	ids := make(map[string]int64)
+
+	for _, c := range []Connection{
+		{Nickname: "outer", Host: "outer.example.com"},
+		{Nickname: "inner", Host: "10.0.0.1", Jump: "outer"},
+		{Nickname: "web", Host: "10.0.1.1", Jump: "inner"},
+	} {
+		id, err := conndb.Add(&c)
+
+		if err != nil {
+			t.Fatalf("ConnectionDB.Add(%s) error = %v", c.Nickname, err)
+		}
+
+		ids[c.Nickname] = id
+	}
+
+	return ids
+}
+
+func TestStore_JumpChain(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, conndb *ConnectionDB) {
+		ids := addJumpTestConnections(t, conndb)
+
+		web, _ := conndb.Get(ids["web"])
+		chain, err := conndb.JumpChain(web)
+
+		if err != nil || len(chain) != 2 || chain[0].Nickname != "outer" || chain[1].Nickname != "inner" {
+			t.Errorf("ConnectionDB.JumpChain() = %v, %v, want [outer inner]", chain, err)
+		}
+
+		outer, _ := conndb.Get(ids["outer"])
+
+		if chain, err := conndb.JumpChain(outer); len(chain) != 0 || err != nil {
+			t.Errorf("ConnectionDB.JumpChain() = %v, %v, want none", chain, err)
+		}
+
+		_, err = conndb.Add(&Connection{Nickname: "db", Host: "10.0.1.2", Jump: "inner,missing"})
+
+		if !errors.Is(err, ErrJumpNotFound) {
+			t.Errorf("ConnectionDB.Add() error = %v, want %v", err, ErrJumpNotFound)
+		}
+
+		_, err = conndb.Add(&Connection{Nickname: "self", Host: "10.0.1.3", Jump: "self"})
+
+		if !errors.Is(err, ErrJumpLoop) {
+			t.Errorf("ConnectionDB.Add() error = %v, want %v", err, ErrJumpLoop)
+		}
+
+		// outer -> web -> inner -> outer
+		outer.Jump = "web"
+
+		if err := outer.Update(); !errors.Is(err, ErrJumpLoop) {
+			t.Errorf("Connection.Update() error = %v, want %v", err, ErrJumpLoop)
+		}
+	})
+}
+
+func TestStore_JumpInUse(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, conndb *ConnectionDB) {
+		ids := addJumpTestConnections(t, conndb)
+
+		inner, _ := conndb.Get(ids["inner"])
+
+		if err := inner.Delete(); !errors.Is(err, ErrJumpInUse) {
+			t.Errorf("Connection.Delete() error = %v, want %v", err, ErrJumpInUse)
+		}
+
+		// Jump hosts can be removed along with the connections using them
+		err := conndb.WithTx(func(tx *Tx) error {
+			web, _ := tx.Get(ids["web"])
+
+			if err := tx.Delete(web); err != nil {
+				return err
+			}
+
+			return tx.Delete(inner)
+		})
+
+		if err != nil {
+			t.Errorf("ConnectionDB.WithTx() error = %v", err)
+		}
+
+		if ok, _ := conndb.Exists(ids["inner"]); ok {
+			t.Errorf("ConnectionDB.Exists() = true after delete")
+		}
+	})
+}
+
+func TestStore_JumpRename(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, conndb *ConnectionDB) {
+		ids := addJumpTestConnections(t, conndb)
+
+		outer, _ := conndb.Get(ids["outer"])
+		outer.Nickname = "bastion"
+
+		if err := outer.Update(); err != nil {
+			t.Fatalf("Connection.Update() error = %v", err)
+		}
+
+		inner, _ := conndb.Get(ids["inner"])
+
+		if inner.Jump != "bastion" {
+			t.Errorf("renamed jump host: inner.Jump = %q, want %q", inner.Jump, "bastion")
+		}
+
+		web, _ := conndb.Get(ids["web"])
+
+		if chain, err := conndb.JumpChain(web); err != nil || len(chain) != 2 || chain[0].Nickname != "bastion" {
+			t.Errorf("ConnectionDB.JumpChain() = %v, %v, want [bastion inner]", chain, err)
+		}
+	})
+}
+
+func TestStore_Upgrade(t *testing.T) {
+	for name, newStore := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			conndb := NewConnectionDB(newStore(t))
+			defer conndb.Close()
+
+			err := conndb.store.Initialize(context.Background(), "v1.1")
+
+			if err == ErrSchemaVerInvalid {
+				t.Skip("store can't be initialized at v1.1")
+			} else if err != nil {
+				t.Fatalf("Store.Initialize() error = %v", err)
+			}
+
+			if err := conndb.CheckDbHealth(); err != ErrSchemaUpgradeNeeded {
+				t.Fatalf("ConnectionDB.CheckDbHealth() error = %v, want %v", err, ErrSchemaUpgradeNeeded)
+			}
+
+			if err := conndb.UpgradeDb(); err != nil {
+				t.Fatalf("ConnectionDB.UpgradeDb() error = %v", err)
+			}
+
+			if v, err := conndb.GetDbSchemaVersion(); v != SchemaVersion || err != nil {
+				t.Errorf("ConnectionDB.GetDbSchemaVersion() = %v, %v, want %v", v, err, SchemaVersion)
+			}
+
+			// Upgraded DBs can store jump hosts
+			addJumpTestConnections(t, &conndb)
+
+			if err := conndb.UpgradeDb(); err != nil {
+				t.Errorf("ConnectionDB.UpgradeDb() twice error = %v", err)
+			}
+		})
+	}
+}
+
+func TestRemovalOrder(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, conndb *ConnectionDB) {
+		ids := addJumpTestConnections(t, conndb)
+
+		var cns []Connection
+
+		for _, nickname := range []string{"outer", "inner", "web"} {
+			c, _ := conndb.Get(ids[nickname])
+			cns = append(cns, c)
+		}
+
+		ordered := RemovalOrder(cns)
+
+		var nicknames []string
+
+		for _, c := range ordered {
+			nicknames = append(nicknames, c.Nickname)
+		}
+
+		if JoinJumpHosts(nicknames) != "web,inner,outer" {
+			t.Errorf("RemovalOrder() = %v, want [web inner outer]", nicknames)
+		}
+
+		// A bastion can be removed along with the connections using it
+		err := conndb.WithTx(func(tx *Tx) error {
+			for _, c := range ordered {
+				if err := tx.Delete(c); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			t.Fatalf("ConnectionDB.WithTx() error = %v", err)
+		}
+
+		if n, _ := conndb.Count(context.Background(), ""); n != 0 {
+			t.Errorf("ConnectionDB.Count() = %v, want 0", n)
+		}
+	})
+}
+
+func TestTx_SetJumpHosts(t *testing.T) {
+	// jumpChain returns the nicknames of the jump chain of the named connection
+	jumpChain := func(t *testing.T, conndb *ConnectionDB, nickname string) string {
+		c, err := conndb.GetByIdOrNickname(nickname)
+
+		if err != nil {
+			t.Fatalf("ConnectionDB.GetByIdOrNickname() error = %v", err)
+		}
+
+		chain, err := conndb.JumpChain(c)
+
+		if err != nil {
+			t.Fatalf("ConnectionDB.JumpChain() error = %v", err)
+		}
+
+		var nicknames []string
+
+		for _, j := range chain {
+			nicknames = append(nicknames, j.Nickname)
+		}
+
+		return JoinJumpHosts(nicknames)
+	}
+
+	runStoreTests(t, func(t *testing.T, conndb *ConnectionDB) {
+		// The jump hosts come after the connections using them, as they may
+		// in an import file
+		err := conndb.WithTx(func(tx *Tx) error {
+			for _, nickname := range []string{"web", "inner", "outer"} {
+				if _, err := tx.Add(&Connection{Nickname: nickname, Host: nickname + ".example.com"}); err != nil {
+					return err
+				}
+			}
+
+			return tx.SetJumpHosts(map[string]string{"web": "inner", "inner": "outer", "outer": ""})
+		})
+
+		if err != nil {
+			t.Fatalf("ConnectionDB.WithTx() error = %v", err)
+		}
+
+		if got := jumpChain(t, conndb, "web"); got != "outer,inner" {
+			t.Errorf("jump chain of web = %q, want %q", got, "outer,inner")
+		}
+
+		// Reverse the chain, which would be a loop if inner still jumped
+		// through outer when outer was set to jump through inner
+		err = conndb.WithTx(func(tx *Tx) error {
+			return tx.SetJumpHosts(map[string]string{"web": "outer", "inner": "", "outer": "inner"})
+		})
+
+		if err != nil {
+			t.Fatalf("Tx.SetJumpHosts() error = %v", err)
+		}
+
+		if got := jumpChain(t, conndb, "web"); got != "inner,outer" {
+			t.Errorf("jump chain of web = %q, want %q", got, "inner,outer")
+		}
+
+		err = conndb.WithTx(func(tx *Tx) error {
+			return tx.SetJumpHosts(map[string]string{"web": "missing"})
+		})
+
+		if !errors.Is(err, ErrJumpNotFound) {
+			t.Errorf("Tx.SetJumpHosts() error = %v, want %v", err, ErrJumpNotFound)
+		}
+
+		if got := jumpChain(t, conndb, "web"); got != "inner,outer" {
+			t.Errorf("jump chain of web = %q after a failed change, want %q", got, "inner,outer")
+		}
+	})
+}
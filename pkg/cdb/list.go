@@ -0,0 +1,96 @@
+package cdb
+
+import (
+	"context"
+)
+
+// ListOptions controls which connections List returns, and in what order.
+type ListOptions struct {
+	// Filter limits results to connections whose nickname, host, user or
+	// description contain it (case-insensitive). An empty filter matches
+	// every connection.
+	Filter string
+
+	// SortBy is the connection property to sort by, or "id" (the default).
+	// Text properties are sorted case-insensitively, with ties broken by id.
+	SortBy string
+
+	// Desc reverses the sort order.
+	Desc bool
+
+	// Limit is the maximum number of connections to return. Zero means no
+	// limit.
+	Limit int
+
+	// Offset is the number of matching connections to skip.
+	Offset int
+}
+
+// check returns an error if the options can't be used: the sort property
+// must be "id" or one of ValidProperties, and Limit and Offset must not be
+// negative.
+func (opts ListOptions) check() error {
+	if opts.Limit < 0 || opts.Offset < 0 {
+		return ErrInvalidListRange
+	}
+
+	if len(opts.SortBy) > 0 && opts.SortBy != "id" && !IsValidProperty(opts.SortBy) {
+		return ErrPropertyInvalid
+	}
+
+	return nil
+}
+
+// paged returns true if the options limit the number of results.
+func (opts ListOptions) paged() bool {
+	return opts.Limit > 0 || opts.Offset > 0
+}
+
+// List returns the connections matching opts, along with the total number of
+// matching connections (ignoring Limit and Offset). Connections are fetched
+// in a single pass over the store (one query for SQL stores); the total needs
+// a second, counting pass only when the results are paged.
+func (conndb *ConnectionDB) List(ctx context.Context, opts ListOptions) ([]*Connection, int, error) {
+	var cns []*Connection
+
+	err := conndb.eachListed(ctx, opts, func(c *Connection) error {
+		cns = append(cns, c)
+		return nil
+	})
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if !opts.paged() {
+		return cns, len(cns), nil
+	}
+
+	total, err := conndb.Count(ctx, opts.Filter)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return cns, total, nil
+}
+
+// Count returns the number of connections matching filter, as described for
+// ListOptions.Filter.
+func (conndb *ConnectionDB) Count(ctx context.Context, filter string) (int, error) {
+	return conndb.store.CountConnections(ctx, filter)
+}
+
+// eachListed calls fn for each connection matching opts, in order, as they
+// are read.
+func (conndb *ConnectionDB) eachListed(ctx context.Context, opts ListOptions, fn func(*Connection) error) error {
+	if err := opts.check(); err != nil {
+		return err
+	}
+
+	return conndb.store.ListConnections(ctx, opts, func(c Connection) error {
+		c.db = conndb
+
+		return fn(&c)
+	})
+}
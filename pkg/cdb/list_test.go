@@ -0,0 +1,185 @@
+package cdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// newListTestConnDb returns a test DB populated with n connections. Every
+// tenth connection has "web" in its description.
+func newListTestConnDb(tb testing.TB, n int) *ConnectionDB {
+	tb.Helper()
+
+	conndb := newTestConnDb(tb)
+
+	err := conndb.WithTx(func(tx *Tx) error {
+		for i := range n {
+			desc := "database server"
+
+			if i%10 == 0 {
+				desc = "web server"
+			}
+
+			_, err := tx.Add(&Connection{
+				Nickname:    fmt.Sprintf("host%05d", i),
+				Host:        fmt.Sprintf("h%d.example.com", n-i),
+				User:        "me",
+				Description: desc,
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		tb.Fatalf("ConnectionDB.WithTx() error = %v", err)
+	}
+
+	return conndb
+}
+
+func TestConnectionDB_List(t *testing.T) {
+	conndb := newTestConnDb(t)
+
+	for _, c := range []Connection{
+		{Nickname: "bravo", Host: "b.example.com", Description: "Web server"},
+		{Nickname: "Alpha", Host: "a.example.com"},
+		{Nickname: "charlie", Host: "c.example.com", Description: "web proxy"},
+		{Nickname: "delta", Host: "d.example.com"},
+	} {
+		if _, err := conndb.Add(&c); err != nil {
+			t.Fatalf("ConnectionDB.Add() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		opts      ListOptions
+		want      []string
+		wantTotal int
+		wantErr   error
+	}{
+		{
+			name:      "all",
+			want:      []string{"bravo", "Alpha", "charlie", "delta"},
+			wantTotal: 4,
+		},
+		{
+			name:      "sort-nickname",
+			opts:      ListOptions{SortBy: "nickname"},
+			want:      []string{"Alpha", "bravo", "charlie", "delta"},
+			wantTotal: 4,
+		},
+		{
+			name:      "sort-desc",
+			opts:      ListOptions{SortBy: "id", Desc: true},
+			want:      []string{"delta", "charlie", "Alpha", "bravo"},
+			wantTotal: 4,
+		},
+		{
+			name:      "filter",
+			opts:      ListOptions{Filter: "WEB"},
+			want:      []string{"bravo", "charlie"},
+			wantTotal: 2,
+		},
+		{
+			name:      "limit",
+			opts:      ListOptions{SortBy: "host", Limit: 2},
+			want:      []string{"Alpha", "bravo"},
+			wantTotal: 4,
+		},
+		{
+			name:      "offset",
+			opts:      ListOptions{SortBy: "host", Offset: 3},
+			want:      []string{"delta"},
+			wantTotal: 4,
+		},
+		{
+			name:      "past-end",
+			opts:      ListOptions{Filter: "web", Limit: 5, Offset: 10},
+			wantTotal: 2,
+		},
+		{
+			name:    "bad-sort",
+			opts:    ListOptions{SortBy: "id; DROP TABLE connections"},
+			wantErr: ErrPropertyInvalid,
+		},
+		{
+			name:    "negative-limit",
+			opts:    ListOptions{Limit: -1},
+			wantErr: ErrInvalidListRange,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cns, total, err := conndb.List(context.Background(), tt.opts)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ConnectionDB.List() error = %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ConnectionDB.List() error = %v", err)
+			}
+
+			var got []string
+
+			for _, c := range cns {
+				got = append(got, c.Nickname)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ConnectionDB.List() = %v, want %v", got, tt.want)
+			}
+
+			if total != tt.wantTotal {
+				t.Errorf("ConnectionDB.List() total = %d, want %d", total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestConnectionDB_ListCanceled(t *testing.T) {
+	conndb := newTestConnDb(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, _, err := conndb.List(ctx, ListOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("ConnectionDB.List() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func BenchmarkConnectionDB_List(b *testing.B) {
+	conndb := newListTestConnDb(b, 10000)
+
+	benchmarks := []struct {
+		name string
+		opts ListOptions
+	}{
+		{"all", ListOptions{}},
+		{"sorted", ListOptions{SortBy: "host", Desc: true}},
+		{"filtered", ListOptions{Filter: "web"}},
+		{"paged", ListOptions{SortBy: "nickname", Limit: 50, Offset: 5000}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			for b.Loop() {
+				if _, _, err := conndb.List(context.Background(), bm.opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package cdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// lockTimeout is how long to wait for another sshcm process to release the
+// advisory lock on a connection DB before giving up with ErrBusy.
+const lockTimeout = 30 * time.Second
+
+// lockRetryInterval is how often a held advisory lock is retried.
+const lockRetryInterval = 10 * time.Millisecond
+
+// lockFile takes an exclusive advisory lock on the file at path, creating it
+// if needed, and returns a function that releases it. The lock is shared with
+// other processes and with other callers in this process, so it serializes
+// multi-step changes to a connection DB.
+//
+// If the lock is still held by someone else after lockTimeout, ErrBusy is
+// returned.
+func lockFile(ctx context.Context, path string) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := time.After(lockTimeout)
+
+	for {
+		locked, err := tryLock(f)
+
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		if locked {
+			return func() {
+				unlock(f)
+				f.Close()
+			}, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-timeout:
+			f.Close()
+			return nil, fmt.Errorf("%w: %s is locked", ErrBusy, path)
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !unix && !windows
+
+package cdb
+
+import "os"
+
+// tryLock always succeeds; file locking isn't available on this platform, so
+// connection DBs are only protected by their own locking.
+func tryLock(f *os.File) (bool, error) {
+	return true, nil
+}
+
+// unlock does nothing.
+func unlock(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,245 @@
+package cdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	unlock, err := lockFile(context.Background(), path)
+
+	if err != nil {
+		t.Fatalf("lockFile() error = %v", err)
+	}
+
+	// A second lock, even in the same process, waits for the first
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := lockFile(ctx, path); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("lockFile() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	unlock()
+
+	unlock, err = lockFile(context.Background(), path)
+
+	if err != nil {
+		t.Fatalf("lockFile() after unlock error = %v", err)
+	}
+
+	unlock()
+}
+
+// hammer makes a series of multi-step changes to the connection DB at path,
+// as worker, from a new ConnectionDB.
+func hammer(driver string, path string, worker string, n int) error {
+	conndb, err := Connect(driver, path)
+
+	if err != nil {
+		return err
+	}
+
+	defer conndb.Close()
+
+	for i := range n {
+		nickname := fmt.Sprintf("w%sc%d", worker, i)
+
+		id, err := conndb.Add(&Connection{Nickname: nickname, Host: "somewhere"})
+
+		if err != nil {
+			return fmt.Errorf("add %s: %w", nickname, err)
+		}
+
+		c, err := conndb.Get(id)
+
+		if err != nil {
+			return fmt.Errorf("get %s: %w", nickname, err)
+		}
+
+		c.User = worker
+
+		if err := c.Update(); err != nil {
+			return fmt.Errorf("update %s: %w", nickname, err)
+		}
+
+		if err := conndb.SetDefault("user", worker); err != nil {
+			return fmt.Errorf("set default: %w", err)
+		}
+
+		// Add and remove a connection in a transaction
+		err = conndb.WithTx(func(tx *Tx) error {
+			id, err := tx.Add(&Connection{Nickname: nickname + "tmp", Host: "somewhere"})
+
+			if err != nil {
+				return err
+			}
+
+			c, err := tx.Get(id)
+
+			if err != nil {
+				return err
+			}
+
+			return tx.Delete(c)
+		})
+
+		if err != nil {
+			return fmt.Errorf("transaction %s: %w", nickname, err)
+		}
+	}
+
+	return nil
+}
+
+// hammerTests lists the drivers and connection DB files the concurrency tests
+// run against.
+var hammerTests = []struct {
+	driver string
+	file   string
+}{
+	{"sqlite", "test.connections"},
+	{"file", "connections.json"},
+}
+
+// checkHammered checks that every worker's connections were added.
+func checkHammered(t *testing.T, driver string, path string, workers int, n int) {
+	t.Helper()
+
+	conndb, err := Connect(driver, path)
+
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	defer conndb.Close()
+
+	cns, err := conndb.GetAll()
+
+	if err != nil {
+		t.Fatalf("ConnectionDB.GetAll() error = %v", err)
+	}
+
+	if len(cns) != workers*n {
+		t.Errorf("ConnectionDB.GetAll() returned %d connections, want %d", len(cns), workers*n)
+	}
+}
+
+// newHammerDb initializes a connection DB for the concurrency tests.
+func newHammerDb(t *testing.T, driver string, path string) {
+	t.Helper()
+
+	conndb, err := Connect(driver, path)
+
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	defer conndb.Close()
+
+	if err := conndb.InitializeDb(SchemaVersion); err != nil {
+		t.Fatalf("ConnectionDB.InitializeDb() error = %v", err)
+	}
+}
+
+func TestConnectionDB_ConcurrentGoroutines(t *testing.T) {
+	const workers, n = 8, 10
+
+	for _, tt := range hammerTests {
+		t.Run(tt.driver, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			newHammerDb(t, tt.driver, path)
+
+			var wg sync.WaitGroup
+			errs := make(chan error, workers)
+
+			for w := range workers {
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+					errs <- hammer(tt.driver, path, strconv.Itoa(w), n)
+				}()
+			}
+
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				if err != nil {
+					t.Error(err)
+				}
+			}
+
+			checkHammered(t, tt.driver, path, workers, n)
+		})
+	}
+}
+
+// TestConnectionDB_ConcurrentWorker is run in child processes by
+// TestConnectionDB_ConcurrentProcesses.
+func TestConnectionDB_ConcurrentWorker(t *testing.T) {
+	path := os.Getenv("SSHCM_TEST_HAMMER_DB")
+
+	if len(path) < 1 {
+		t.Skip("only run by TestConnectionDB_ConcurrentProcesses")
+	}
+
+	n, _ := strconv.Atoi(os.Getenv("SSHCM_TEST_HAMMER_N"))
+
+	err := hammer(os.Getenv("SSHCM_TEST_HAMMER_DRIVER"), path, os.Getenv("SSHCM_TEST_HAMMER_WORKER"), n)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConnectionDB_ConcurrentProcesses(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping multi-process test in short mode")
+	}
+
+	const workers, n = 4, 10
+
+	for _, tt := range hammerTests {
+		t.Run(tt.driver, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			newHammerDb(t, tt.driver, path)
+
+			var cmds []*exec.Cmd
+
+			for w := range workers {
+				cmd := exec.Command(os.Args[0], "-test.run=^TestConnectionDB_ConcurrentWorker$")
+				cmd.Env = append(os.Environ(),
+					"SSHCM_TEST_HAMMER_DB="+path,
+					"SSHCM_TEST_HAMMER_DRIVER="+tt.driver,
+					"SSHCM_TEST_HAMMER_N="+strconv.Itoa(n),
+					"SSHCM_TEST_HAMMER_WORKER=p"+strconv.Itoa(w))
+
+				if err := cmd.Start(); err != nil {
+					t.Fatalf("starting worker: %v", err)
+				}
+
+				cmds = append(cmds, cmd)
+			}
+
+			for _, cmd := range cmds {
+				if err := cmd.Wait(); err != nil {
+					t.Errorf("worker failed: %v", err)
+				}
+			}
+
+			checkHammered(t, tt.driver, path, workers, n)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package cdb
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLock tries to take an exclusive flock on f, without waiting. It returns
+// false if the lock is held elsewhere.
+func tryLock(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// unlock releases a lock taken by tryLock.
+func unlock(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,205 @@
+package cdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxPatternInstances is the most connections a pattern's range can hold.
+const maxPatternInstances = 10000
+
+// patternToken matches the number token of a pattern connection: {n}, or
+// {n:0W} to zero-pad the number to W digits (ex. {n:02}).
+var patternToken = regexp.MustCompile(`\{n(?::([^}]*))?\}`)
+
+// patternWidth matches the widths numbers can be padded to.
+var patternWidth = regexp.MustCompile(`^0[1-9]$`)
+
+// IsPattern returns true if the connection is a pattern connection, which
+// stands for a numbered set of connections (see Instance).
+func (c Connection) IsPattern() bool {
+	return len(c.Range) > 0
+}
+
+// Pattern returns the nickname of the pattern connection that c is an
+// instance of, or an empty string if it isn't one.
+func (c Connection) Pattern() string {
+	return c.pattern
+}
+
+// ParseRange parses the range of a pattern connection (ex. "1-40"), and
+// returns its first and last numbers.
+func ParseRange(s string) (int, int, error) {
+	from, to, ok := strings.Cut(s, "-")
+
+	first, err1 := strconv.Atoi(strings.TrimSpace(from))
+	last, err2 := strconv.Atoi(strings.TrimSpace(to))
+
+	if !ok || err1 != nil || err2 != nil || first < 0 || last < first {
+		return 0, 0, fmt.Errorf("%w: %s", ErrInvalidRange, s)
+	}
+
+	if last-first >= maxPatternInstances {
+		return 0, 0, fmt.Errorf("%w: %s has more than %d numbers", ErrInvalidRange, s, maxPatternInstances)
+	}
+
+	return first, last, nil
+}
+
+// formatPatternNumber returns n, formatted as the passed number token (ex.
+// "{n:02}") says.
+func formatPatternNumber(token string, n int) string {
+	width := patternToken.FindStringSubmatch(token)[1]
+
+	if len(width) > 0 {
+		return fmt.Sprintf("%0*d", int(width[1]-'0'), n)
+	}
+
+	return strconv.Itoa(n)
+}
+
+// substitutePattern replaces the number tokens in s with n.
+func substitutePattern(s string, n int) string {
+	return patternToken.ReplaceAllStringFunc(s, func(token string) string {
+		return formatPatternNumber(token, n)
+	})
+}
+
+// validatePattern checks the range and number tokens of a pattern connection.
+// Connections that aren't patterns can't have a number token in their
+// nickname.
+func (c Connection) validatePattern() error {
+	tokens := patternToken.FindAllString(c.Nickname, -1)
+
+	if !c.IsPattern() {
+		if len(tokens) > 0 {
+			return fmt.Errorf("%w: %s has no range", ErrInvalidPattern, c.Nickname)
+		}
+
+		return nil
+	}
+
+	if _, _, err := ParseRange(c.Range); err != nil {
+		return err
+	}
+
+	if len(tokens) != 1 {
+		return fmt.Errorf("%w: the nickname must have one {n}", ErrInvalidPattern)
+	}
+
+	for _, s := range []string{c.Nickname, c.Host, c.User, c.Description, c.Args, c.Identity, c.Command} {
+		for _, m := range patternToken.FindAllStringSubmatch(s, -1) {
+			if len(m[1]) > 0 && !patternWidth.MatchString(m[1]) {
+				return fmt.Errorf("%w: %s", ErrInvalidPattern, m[0])
+			}
+		}
+	}
+
+	return nil
+}
+
+// Instance returns the connection that pattern connection c stands for with
+// number n, with the number tokens in its nickname, host, user, description,
+// args, identity and command replaced. For example, pattern web{n} with host
+// web{n:02}.example.com has instance web7, with host web07.example.com.
+//
+// Instances keep the id of their pattern, so that their sessions are recorded
+// against it, but can't be changed or deleted (see ErrPatternInstance).
+func (c Connection) Instance(n int) Connection {
+	i := c
+
+	i.pattern = c.Nickname
+	i.Range = ""
+
+	for _, s := range []*string{&i.Nickname, &i.Host, &i.User, &i.Description, &i.Args, &i.Identity, &i.Command} {
+		*s = substitutePattern(*s, n)
+	}
+
+	return i
+}
+
+// Instances returns every instance of pattern connection c, in order (see
+// Instance). If c isn't a pattern, it is returned on its own.
+func (c Connection) Instances() []Connection {
+	if !c.IsPattern() {
+		return []Connection{c}
+	}
+
+	first, last, err := ParseRange(c.Range)
+
+	if err != nil {
+		return nil
+	}
+
+	var instances []Connection
+
+	for n := first; n <= last; n++ {
+		instances = append(instances, c.Instance(n))
+	}
+
+	return instances
+}
+
+// MatchInstance returns the instance of pattern connection c with the passed
+// nickname, and true if there is one.
+func (c Connection) MatchInstance(nickname string) (Connection, bool) {
+	if !c.IsPattern() {
+		return Connection{}, false
+	}
+
+	first, last, err := ParseRange(c.Range)
+	loc := patternToken.FindStringIndex(c.Nickname)
+
+	if err != nil || loc == nil {
+		return Connection{}, false
+	}
+
+	prefix, token, suffix := c.Nickname[:loc[0]], c.Nickname[loc[0]:loc[1]], c.Nickname[loc[1]:]
+
+	digits, hasPrefix := strings.CutPrefix(nickname, prefix)
+	digits, hasSuffix := strings.CutSuffix(digits, suffix)
+
+	if !hasPrefix || !hasSuffix || len(digits) < 1 {
+		return Connection{}, false
+	}
+
+	n, err := strconv.Atoi(digits)
+
+	// Numbers must be written the way the pattern writes them (ex. web07, not
+	// web7, for web{n:02})
+	if err != nil || n < first || n > last || formatPatternNumber(token, n) != digits {
+		return Connection{}, false
+	}
+
+	return c.Instance(n), true
+}
+
+// getInstance returns the instance of a pattern connection with the passed
+// nickname. If more than one pattern matches, the one with the lowest id wins.
+// ErrConnectionNotFound is returned if no pattern matches.
+func (conndb *ConnectionDB) getInstance(ctx context.Context, nickname string) (Connection, error) {
+	var found Connection
+
+	errFound := errors.New("found")
+
+	err := conndb.ForEachContext(ctx, func(c *Connection) error {
+		if i, ok := c.MatchInstance(nickname); ok {
+			found = i
+			return errFound
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, errFound) {
+		return found, nil
+	} else if err != nil {
+		return Connection{}, err
+	}
+
+	return Connection{}, ErrConnectionNotFound
+}
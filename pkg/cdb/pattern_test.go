@@ -0,0 +1,173 @@
+package cdb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		s         string
+		wantFirst int
+		wantLast  int
+		wantErr   bool
+	}{
+		{"1-40", 1, 40, false},
+		{"0 - 9", 0, 9, false},
+		{"7-7", 7, 7, false},
+		{"40-1", 0, 0, true},
+		{"-1-5", 0, 0, true},
+		{"1", 0, 0, true},
+		{"a-b", 0, 0, true},
+		{"1-100000", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			first, last, err := ParseRange(tt.s)
+
+			if (err != nil) != tt.wantErr || first != tt.wantFirst || last != tt.wantLast {
+				t.Errorf("ParseRange() = %v, %v, %v, want %v, %v, error %v", first, last, err, tt.wantFirst, tt.wantLast, tt.wantErr)
+			}
+
+			if err != nil && !errors.Is(err, ErrInvalidRange) {
+				t.Errorf("ParseRange() error = %v, want %v", err, ErrInvalidRange)
+			}
+		})
+	}
+}
+
+func TestConnection_Instance(t *testing.T) {
+	c := Connection{
+		Id:          3,
+		Nickname:    "web{n}",
+		Host:        "web{n:02}.example.com",
+		Description: "Web server {n}",
+		Range:       "1-40",
+	}
+
+	got := c.Instance(7)
+
+	want := Connection{
+		Id:          3,
+		Nickname:    "web7",
+		Host:        "web07.example.com",
+		Description: "Web server 7",
+		pattern:     "web{n}",
+	}
+
+	if got != want {
+		t.Errorf("Connection.Instance() = %#v, want %#v", got, want)
+	}
+
+	if got.IsPattern() || got.Pattern() != "web{n}" {
+		t.Errorf("Connection.Instance() is pattern %v of %q, want instance of web{n}", got.IsPattern(), got.Pattern())
+	}
+
+	if n := len(c.Instances()); n != 40 {
+		t.Errorf("len(Connection.Instances()) = %v, want 40", n)
+	}
+
+	if n := len((Connection{Nickname: "web"}).Instances()); n != 1 {
+		t.Errorf("len(Connection.Instances()) = %v, want 1", n)
+	}
+}
+
+func TestConnection_MatchInstance(t *testing.T) {
+	c := Connection{Nickname: "web{n:02}-prod", Host: "10.0.0.{n}", Range: "1-40"}
+
+	tests := []struct {
+		nickname string
+		wantHost string
+		wantOk   bool
+	}{
+		{"web07-prod", "10.0.0.7", true},
+		{"web40-prod", "10.0.0.40", true},
+		{"web7-prod", "", false},
+		{"web41-prod", "", false},
+		{"web00-prod", "", false},
+		{"web07", "", false},
+		{"app07-prod", "", false},
+		{"web-prod", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.nickname, func(t *testing.T) {
+			got, ok := c.MatchInstance(tt.nickname)
+
+			if ok != tt.wantOk || got.Host != tt.wantHost {
+				t.Errorf("Connection.MatchInstance() = %q, %v, want %q, %v", got.Host, ok, tt.wantHost, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestConnection_ValidatePattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       Connection
+		wantErr error
+	}{
+		{"pattern", Connection{Nickname: "web{n}", Host: "web{n:03}", Range: "1-40"}, nil},
+		{"no-range", Connection{Nickname: "web{n}", Host: "web"}, ErrInvalidPattern},
+		{"no-token", Connection{Nickname: "web", Host: "web{n}", Range: "1-40"}, ErrInvalidPattern},
+		{"two-tokens", Connection{Nickname: "web{n}-{n}", Host: "web", Range: "1-40"}, ErrInvalidPattern},
+		{"bad-width", Connection{Nickname: "web{n}", Host: "web{n:2}", Range: "1-40"}, ErrInvalidPattern},
+		{"bad-range", Connection{Nickname: "web{n}", Host: "web", Range: "40"}, ErrInvalidRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.c.validatePattern(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Connection.validatePattern() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConnectionDB_GetInstance(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, conndb *ConnectionDB) {
+		id, err := conndb.Add(&Connection{Nickname: "web{n}", Host: "web{n:02}.example.com", Range: "1-40"})
+
+		if err != nil {
+			t.Fatalf("ConnectionDB.Add() error = %v", err)
+		}
+
+		if _, err := conndb.Add(&Connection{Nickname: "web3", Host: "legacy.example.com"}); err != nil {
+			t.Fatalf("ConnectionDB.Add() error = %v", err)
+		}
+
+		c, err := conndb.GetByIdOrNickname("web17")
+
+		if err != nil || c.Id != id || c.Host != "web17.example.com" || c.Pattern() != "web{n}" {
+			t.Errorf("ConnectionDB.GetByIdOrNickname() = %#v, %v, want web17.example.com", c, err)
+		}
+
+		// Stored connections win over pattern instances
+		if c, err := conndb.GetByIdOrNickname("web3"); err != nil || c.Host != "legacy.example.com" {
+			t.Errorf("ConnectionDB.GetByIdOrNickname() = %#v, %v, want legacy.example.com", c, err)
+		}
+
+		if _, err := conndb.Add(&Connection{Nickname: "db{n}", Host: "db"}); !errors.Is(err, ErrInvalidPattern) {
+			t.Errorf("ConnectionDB.Add() error = %v, want %v", err, ErrInvalidPattern)
+		}
+
+		if _, err := conndb.GetByIdOrNickname("web41"); !errors.Is(err, ErrConnectionNotFound) {
+			t.Errorf("ConnectionDB.GetByIdOrNickname() error = %v, want %v", err, ErrConnectionNotFound)
+		}
+
+		c.Host = "elsewhere"
+
+		if err := c.Update(); !errors.Is(err, ErrPatternInstance) {
+			t.Errorf("Connection.Update() error = %v, want %v", err, ErrPatternInstance)
+		}
+
+		if err := c.Delete(); !errors.Is(err, ErrPatternInstance) {
+			t.Errorf("Connection.Delete() error = %v, want %v", err, ErrPatternInstance)
+		}
+
+		if p, err := conndb.Get(id); err != nil || p.Range != "1-40" || p.Host != "web{n:02}.example.com" {
+			t.Errorf("ConnectionDB.Get() = %#v, %v, want the unchanged pattern", p, err)
+		}
+	})
+}
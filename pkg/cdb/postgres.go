@@ -0,0 +1,9 @@
+//go:build !nopostgres
+
+package cdb
+
+// PostgreSQL connection DBs use pgx's database/sql driver, registered as
+// "pgx". Build with the nopostgres tag to leave it out, which makes for a
+// smaller binary; a driver registered as "postgres" must then be imported by
+// the program to use PostgreSQL (see PostgresDriver).
+import _ "github.com/jackc/pgx/v5/stdlib"
@@ -0,0 +1,104 @@
+package cdb
+
+import (
+	"context"
+	"time"
+)
+
+// sessionTimeFormat is the format session times are stored in. Times are
+// stored in UTC, with a fixed number of digits, so that they sort as text.
+const sessionTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// A Session is a record of an ssh session run by sshcm (ex. with connect
+// --supervise).
+type Session struct {
+	Id           int64     // unique session id
+	ConnectionId int64     // id of the connection the session was for
+	Nickname     string    // nickname of the connection when the session ran
+	Started      time.Time // when ssh was started
+	Ended        time.Time // when ssh exited
+	ExitStatus   int       // ssh's exit status (255 for connection errors)
+	Recording    string    // path to the session's recording, if it was recorded
+}
+
+// Duration returns how long the session ran for.
+func (s Session) Duration() time.Duration {
+	return s.Ended.Sub(s.Started)
+}
+
+// Validate checks that the session has a nickname and that it ended after it
+// started.
+func (s Session) Validate() error {
+	if len(s.Nickname) < 1 {
+		return ErrConnNoNickname
+	}
+
+	if s.Started.IsZero() || s.Ended.Before(s.Started) {
+		return ErrSessionInvalid
+	}
+
+	return nil
+}
+
+// formatSessionTime returns t as stored in the connection DB.
+func formatSessionTime(t time.Time) string {
+	return t.UTC().Format(sessionTimeFormat)
+}
+
+// parseSessionTime parses a time stored in the connection DB.
+func parseSessionTime(s string) (time.Time, error) {
+	return time.Parse(sessionTimeFormat, s)
+}
+
+// AddSessionContext records a finished session and returns its id.
+func (conndb *ConnectionDB) AddSessionContext(ctx context.Context, s *Session) (int64, error) {
+	if err := s.Validate(); err != nil {
+		return -1, err
+	}
+
+	var id int64
+
+	err := conndb.atomic(ctx, func(db *ConnectionDB) error {
+		var err error
+
+		id, err = db.store.AddSession(ctx, *s)
+
+		return err
+	})
+
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// AddSession uses context.Background internally; to specify the context, use
+// AddSessionContext.
+func (conndb *ConnectionDB) AddSession(s *Session) (int64, error) {
+	return conndb.AddSessionContext(context.Background(), s)
+}
+
+// SessionsContext returns the recorded sessions, newest first. If connectionId
+// is more than zero, only that connection's sessions are returned, and if
+// limit is more than zero, at most limit sessions are returned.
+func (conndb *ConnectionDB) SessionsContext(ctx context.Context, connectionId int64, limit int) ([]Session, error) {
+	if limit < 0 {
+		return nil, ErrInvalidListRange
+	}
+
+	sessions := []Session{}
+
+	err := conndb.store.ListSessions(ctx, connectionId, limit, func(s Session) error {
+		sessions = append(sessions, s)
+		return nil
+	})
+
+	return sessions, err
+}
+
+// Sessions uses context.Background internally; to specify the context, use
+// SessionsContext.
+func (conndb *ConnectionDB) Sessions(connectionId int64, limit int) ([]Session, error) {
+	return conndb.SessionsContext(context.Background(), connectionId, limit)
+}
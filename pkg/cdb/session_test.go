@@ -0,0 +1,64 @@
+package cdb
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStore_Sessions(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, conndb *ConnectionDB) {
+		started := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+		for i, s := range []Session{
+			{ConnectionId: 1, Nickname: "web", Started: started, Ended: started.Add(time.Minute)},
+			{ConnectionId: 2, Nickname: "db", Started: started.Add(time.Hour), Ended: started.Add(2 * time.Hour), ExitStatus: 255, Recording: "/tmp/db.cast"},
+			{ConnectionId: 1, Nickname: "web", Started: started.Add(3 * time.Hour), Ended: started.Add(3*time.Hour + time.Second), ExitStatus: 1},
+		} {
+			id, err := conndb.AddSession(&s)
+
+			if err != nil || id != int64(i+1) {
+				t.Fatalf("ConnectionDB.AddSession() = %v, %v, want %d", id, err, i+1)
+			}
+		}
+
+		tests := []struct {
+			name         string
+			connectionId int64
+			limit        int
+			want         []int64
+		}{
+			{"all", 0, 0, []int64{3, 2, 1}},
+			{"connection", 1, 0, []int64{3, 1}},
+			{"limit", 0, 2, []int64{3, 2}},
+			{"none", 3, 0, []int64{}},
+		}
+
+		for _, tt := range tests {
+			sessions, err := conndb.Sessions(tt.connectionId, tt.limit)
+
+			var got []int64
+
+			for _, s := range sessions {
+				got = append(got, s.Id)
+			}
+
+			if err != nil || !slices.Equal(got, tt.want) {
+				t.Errorf("%s: ConnectionDB.Sessions() = %v, %v, want %v", tt.name, got, err, tt.want)
+			}
+		}
+
+		sessions, _ := conndb.Sessions(2, 0)
+
+		if len(sessions) != 1 || sessions[0].Duration() != time.Hour || sessions[0].ExitStatus != 255 || sessions[0].Recording != "/tmp/db.cast" || !sessions[0].Started.Equal(started.Add(time.Hour)) {
+			t.Errorf("ConnectionDB.Sessions() = %v, want the db session", sessions)
+		}
+
+		// Sessions must end after they start
+		_, err := conndb.AddSession(&Session{Nickname: "web", Started: started, Ended: started.Add(-time.Second)})
+
+		if err != ErrSessionInvalid {
+			t.Errorf("ConnectionDB.AddSession() error = %v, want %v", err, ErrSessionInvalid)
+		}
+	})
+}
@@ -0,0 +1,1148 @@
+package cdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DbConnIface provides an interface for interacting with a DB (or mock)
+type DbConnIface interface {
+	Begin() (*sql.Tx, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	Close() error
+	Exec(query string, args ...any) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// A Dialect describes how a SQL store talks to a particular database engine.
+// Queries are written to work on every engine where possible; a Dialect holds
+// the parts that differ.
+type Dialect struct {
+	// Name is the name of the dialect (ex. sqlite).
+	Name string
+
+	// schemas contains the statements that create the tables for each schema
+	// version that can be initialized.
+	schemas map[string][]string
+
+	// upgrades contains the statements that upgrade a DB from the previous
+	// schema version to the keyed schema version.
+	upgrades map[string][]string
+
+	// like is the case-insensitive LIKE operator.
+	like string
+
+	// returning is true if the id of an added connection is read with
+	// INSERT ... RETURNING, rather than sql.Result.LastInsertId.
+	returning bool
+
+	// noLimit is the LIMIT clause used when only an OFFSET is wanted.
+	noLimit string
+
+	// tablesQuery returns the names of all user tables.
+	tablesQuery string
+
+	// columnsQuery returns the names of all columns in the table passed as
+	// its only argument.
+	columnsQuery string
+
+	// afterLoad is run after Load, to fix up state the archive doesn't hold
+	// (ex. sequences).
+	afterLoad []string
+
+	// busy returns true if an error means the DB is locked by another writer,
+	// and the statement can be retried.
+	busy func(err error) bool
+}
+
+// SQLite is the dialect for SQLite databases.
+var SQLite = &Dialect{
+	Name: "sqlite",
+	schemas: map[string][]string{
+		"v1.0": {schemas["v1.0"]},
+		"v1.1": {schemas["v1.1"]},
+		"v1.2": {schemas["v1.2"]},
+		"v1.3": {schemas["v1.3"]},
+		"v1.4": {schemas["v1.4"]},
+		"v1.5": {schemas["v1.5"]},
+		"v1.6": {schemas["v1.6"]},
+	},
+	upgrades: map[string][]string{
+		"v1.1": {schemaUpgrades["v1.1"]},
+		"v1.2": {schemaUpgrades["v1.2"]},
+		"v1.3": {schemaUpgrades["v1.3"]},
+		"v1.4": {schemaUpgrades["v1.4"]},
+		"v1.5": {schemaUpgrades["v1.5"]},
+		"v1.6": {schemaUpgrades["v1.6"]},
+	},
+	like:    "LIKE",
+	noLimit: "LIMIT -1",
+	tablesQuery: `
+		SELECT name
+		FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+		ORDER BY name`,
+	columnsQuery: `SELECT name FROM pragma_table_info($1)`,
+	busy:         sqliteBusy,
+}
+
+// sqliteBusy returns true if err is a SQLITE_BUSY or SQLITE_LOCKED error.
+func sqliteBusy(err error) bool {
+	var e interface{ Code() int }
+
+	if !errors.As(err, &e) {
+		return false
+	}
+
+	// Extended result codes keep the primary code in the low byte
+	switch e.Code() & 0xff {
+	case 5, 6:
+		return true
+	}
+
+	return false
+}
+
+// Postgres is the dialect for PostgreSQL databases. Tables are created in the
+// connection's current schema.
+var Postgres = &Dialect{
+	Name: "postgres",
+	schemas: map[string][]string{
+		"v1.6": {
+			`CREATE TABLE global (
+				setting TEXT PRIMARY KEY,
+				value   TEXT
+			)`,
+			`CREATE TABLE defaults (
+				setting TEXT PRIMARY KEY,
+				value   TEXT
+			)`,
+			`CREATE TABLE connections (
+				id          BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+				nickname    TEXT NOT NULL UNIQUE,
+				host        TEXT NOT NULL,
+				"user"      TEXT,
+				description TEXT,
+				args        TEXT,
+				identity    TEXT,
+				command     TEXT,
+				"binary"    TEXT,
+				jump        TEXT,
+				pre_hook    TEXT,
+				post_hook   TEXT,
+				record      INTEGER,
+				"range"     TEXT
+			)`,
+			`CREATE TABLE sessions (
+				id            BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+				connection_id BIGINT,
+				nickname      TEXT NOT NULL,
+				started       TEXT NOT NULL,
+				ended         TEXT NOT NULL,
+				exit_status   INTEGER NOT NULL,
+				recording     TEXT
+			)`,
+		},
+	},
+	upgrades: map[string][]string{
+		"v1.2": {`ALTER TABLE connections ADD COLUMN jump TEXT`},
+		"v1.3": {
+			`ALTER TABLE connections ADD COLUMN pre_hook TEXT`,
+			`ALTER TABLE connections ADD COLUMN post_hook TEXT`,
+		},
+		"v1.4": {
+			`CREATE TABLE sessions (
+				id            BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+				connection_id BIGINT,
+				nickname      TEXT NOT NULL,
+				started       TEXT NOT NULL,
+				ended         TEXT NOT NULL,
+				exit_status   INTEGER NOT NULL
+			)`,
+		},
+		"v1.5": {
+			`ALTER TABLE connections ADD COLUMN record INTEGER`,
+			`ALTER TABLE sessions ADD COLUMN recording TEXT`,
+		},
+		"v1.6": {`ALTER TABLE connections ADD COLUMN "range" TEXT`},
+	},
+	like:      "ILIKE",
+	returning: true,
+	tablesQuery: `
+		SELECT table_name
+		FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_type = 'BASE TABLE'
+		ORDER BY table_name`,
+	columnsQuery: `
+		SELECT column_name
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+		ORDER BY ordinal_position`,
+	afterLoad: []string{
+		`SELECT setval(pg_get_serial_sequence('connections', 'id'),
+			(SELECT COALESCE(MAX(id), 0) + 1 FROM connections), false)`,
+		`SELECT setval(pg_get_serial_sequence('sessions', 'id'),
+			(SELECT COALESCE(MAX(id), 0) + 1 FROM sessions), false)`,
+	},
+}
+
+// A sqlStore is a Store kept in a SQL database.
+type sqlStore struct {
+	db      DbConnIface
+	dialect *Dialect
+
+	// lockPath is the advisory lock file held during transactions, if any.
+	lockPath string
+}
+
+// NewSQLStore returns a Store kept in the passed SQL database, which is
+// queried using the passed dialect. Statements that fail because another
+// writer has the DB locked are retried a few times.
+func NewSQLStore(db DbConnIface, dialect *Dialect) Store {
+	return newSQLStore(db, dialect, "")
+}
+
+// newSQLStore returns a sqlStore. If lockPath isn't empty, transactions hold an
+// advisory lock on it (see lockFile).
+func newSQLStore(db DbConnIface, dialect *Dialect, lockPath string) *sqlStore {
+	if dialect.busy != nil {
+		db = retryConn{DbConnIface: db, busy: dialect.busy}
+	}
+
+	return &sqlStore{db: db, dialect: dialect, lockPath: lockPath}
+}
+
+// busyRetries is the number of times a statement that failed because the DB
+// was busy is retried.
+const busyRetries = 5
+
+// busyRetryDelay is the delay before the first retry of a busy statement. It
+// doubles after each retry.
+const busyRetryDelay = 50 * time.Millisecond
+
+// retryBusy calls fn, retrying it while it fails with an error that busy
+// returns true for. If it's still failing after busyRetries retries, the error
+// is wrapped in ErrBusy.
+func retryBusy(ctx context.Context, busy func(error) bool, fn func() error) error {
+	delay := busyRetryDelay
+
+	for i := 0; ; i++ {
+		err := fn()
+
+		if err == nil || !busy(err) {
+			return err
+		}
+
+		if i >= busyRetries {
+			return fmt.Errorf("%w: %w", ErrBusy, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+	}
+}
+
+// retryConn retries statements that fail because the DB is busy, and
+// transactions that fail to begin for the same reason. Statements run in a
+// transaction aren't retried, as the transaction already holds the DB's
+// write lock.
+type retryConn struct {
+	DbConnIface
+	busy func(error) bool
+}
+
+func (c retryConn) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	var tx *sql.Tx
+
+	err := retryBusy(ctx, c.busy, func() (err error) {
+		tx, err = c.DbConnIface.BeginTx(ctx, opts)
+		return err
+	})
+
+	return tx, err
+}
+
+func (c retryConn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	var result sql.Result
+
+	err := retryBusy(ctx, c.busy, func() (err error) {
+		result, err = c.DbConnIface.ExecContext(ctx, query, args...)
+		return err
+	})
+
+	return result, err
+}
+
+func (c retryConn) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	var rows *sql.Rows
+
+	err := retryBusy(ctx, c.busy, func() (err error) {
+		rows, err = c.DbConnIface.QueryContext(ctx, query, args...)
+		return err
+	})
+
+	return rows, err
+}
+
+// QueryRowContext retries the query while it fails, but can't wrap the final
+// error in ErrBusy, as it is returned by the row's Scan method.
+func (c retryConn) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	var row *sql.Row
+
+	retryBusy(ctx, c.busy, func() error {
+		row = c.DbConnIface.QueryRowContext(ctx, query, args...)
+		return row.Err()
+	})
+
+	return row
+}
+
+// txConn adapts a *sql.Tx to DbConnIface, so that a sqlStore can run in a
+// transaction.
+type txConn struct {
+	*sql.Tx
+}
+
+func (txConn) Begin() (*sql.Tx, error) {
+	return nil, ErrNestedTx
+}
+
+func (txConn) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return nil, ErrNestedTx
+}
+
+// Close does nothing; the transaction is finished by WithTx.
+func (txConn) Close() error {
+	return nil
+}
+
+// connectionColumns lists the connections table columns, in the order
+// scanConnection expects them. user is a reserved word in PostgreSQL, and range
+// is a keyword in SQLite, so they are quoted.
+const connectionColumns = `
+	id,
+	nickname,
+	host,
+	"user",
+	description,
+	args,
+	identity,
+	command,
+	jump,
+	pre_hook,
+	post_hook,
+	record,
+	"range"`
+
+// A rowScanner is a single query result row (*sql.Row or *sql.Rows).
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// quoteIdent quotes a table or column name for use in a SQL statement.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// scanConnection scans a row of connectionColumns into a detached Connection.
+func scanConnection(row rowScanner) (Connection, error) {
+	var sqlId sql.NullInt64
+	var nickname, host, user, description, args, identity, command, jump, preHook, postHook sql.NullString
+	var record sql.NullBool
+	var patternRange sql.NullString
+
+	err := row.Scan(
+		&sqlId,
+		&nickname,
+		&host,
+		&user,
+		&description,
+		&args,
+		&identity,
+		&command,
+		&jump,
+		&preHook,
+		&postHook,
+		&record,
+		&patternRange,
+	)
+
+	// Check SQL scanning errors before continuing
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Connection{}, ErrConnectionNotFound
+		}
+
+		return Connection{}, err
+	}
+
+	// If any of the connection properties are invalid, fail
+	if !(sqlId.Valid ||
+		nickname.Valid ||
+		host.Valid ||
+		user.Valid ||
+		description.Valid ||
+		args.Valid ||
+		identity.Valid ||
+		command.Valid ||
+		jump.Valid ||
+		preHook.Valid ||
+		postHook.Valid) {
+		return Connection{}, ErrConnFromDbInvalid
+	}
+
+	c := Connection{
+		Id:          sqlId.Int64,
+		Nickname:    nickname.String,
+		Host:        host.String,
+		User:        user.String,
+		Description: description.String,
+		Args:        args.String,
+		Identity:    identity.String,
+		Command:     command.String,
+		Jump:        jump.String,
+		PreHook:     preHook.String,
+		PostHook:    postHook.String,
+		Record:      record.Bool,
+		Range:       patternRange.String,
+	}
+
+	err = c.Validate()
+
+	return c, err
+}
+
+func (s *sqlStore) Initialize(ctx context.Context, version string) error {
+	statements, ok := s.dialect.schemas[version]
+
+	if !ok {
+		return ErrSchemaVerInvalid
+	}
+
+	// Set up the DB in a single transaction, so that a failure doesn't leave
+	// it half-initialized
+	return s.WithTx(ctx, func(st Store) error {
+		// Another process may have set up the DB since the caller checked
+		initialized, err := st.Initialized(ctx)
+
+		if err != nil {
+			return err
+		} else if initialized {
+			return ErrStoreInitialized
+		}
+
+		db := st.(*sqlStore).db
+
+		// Create table schema
+		for _, statement := range statements {
+			if _, err := db.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+
+		// Initialize global settings
+		_, err = db.ExecContext(ctx, `
+			INSERT INTO global (setting, value)
+			VALUES ('schema_version', $1);
+		`, version)
+
+		if err != nil {
+			return err
+		}
+
+		// Initialize default options
+		_, err = db.ExecContext(ctx, `
+			INSERT INTO defaults (setting, value) VALUES
+				('binary', NULL),
+				('user', NULL),
+				('args', NULL),
+				('identity', NULL),
+				('command', NULL);
+		`)
+
+		return err
+	})
+}
+
+func (s *sqlStore) Upgrade(ctx context.Context, version string) error {
+	return s.WithTx(ctx, func(st Store) error {
+		db := st.(*sqlStore).db
+
+		for _, v := range pendingUpgrades(version, s.dialect.upgrades) {
+			for _, statement := range s.dialect.upgrades[v] {
+				if _, err := db.ExecContext(ctx, statement); err != nil {
+					return err
+				}
+			}
+
+			version = v
+		}
+
+		// The dialect may not know how to upgrade DBs this old
+		if version != SchemaVersion {
+			return ErrSchemaNoUpgrade
+		}
+
+		_, err := db.ExecContext(ctx, `
+			UPDATE global
+			SET value = $1
+			WHERE setting = 'schema_version'
+		`, SchemaVersion)
+
+		return err
+	})
+}
+
+func (s *sqlStore) Initialized(ctx context.Context) (bool, error) {
+	tables, err := s.tableNames(ctx)
+
+	if err != nil {
+		return false, err
+	}
+
+	return slices.Contains(tables, "global"), nil
+}
+
+func (s *sqlStore) SchemaVersion(ctx context.Context) (string, error) {
+	var v sql.NullString
+
+	row := s.db.QueryRowContext(ctx,
+		`SELECT value
+		FROM global
+		WHERE setting = 'schema_version'`)
+	err := row.Scan(&v)
+
+	// Run some checks against the version number we pulled from the database
+	if err != nil {
+		// We got an error running the query
+		return "", err
+	} else if !v.Valid {
+		// The read version string is invalid
+		return "", ErrSchemaVerInvalid
+	}
+
+	return v.String, nil
+}
+
+func (s *sqlStore) AddConnection(ctx context.Context, c Connection) (int64, error) {
+	query := `
+		INSERT INTO connections (
+			nickname,
+			host,
+			"user",
+			description,
+			args,
+			identity,
+			command,
+			jump,
+			pre_hook,
+			post_hook,
+			record,
+			"range"
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8,
+			$9,
+			$10,
+			$11,
+			$12
+		)`
+
+	args := []any{
+		sqlNullableString(c.Nickname),
+		sqlNullableString(c.Host),
+		sqlNullableString(c.User),
+		sqlNullableString(c.Description),
+		sqlNullableString(c.Args),
+		sqlNullableString(c.Identity),
+		sqlNullableString(c.Command),
+		sqlNullableString(c.Jump),
+		sqlNullableString(c.PreHook),
+		sqlNullableString(c.PostHook),
+		sqlBool(c.Record),
+		sqlNullableString(c.Range),
+	}
+
+	if s.dialect.returning {
+		var id int64
+
+		err := s.db.QueryRowContext(ctx, query+"\n\t\tRETURNING id", args...).Scan(&id)
+
+		if err != nil {
+			return -1, err
+		}
+
+		return id, nil
+	}
+
+	result, err := s.db.ExecContext(ctx, query, args...)
+
+	if err != nil {
+		return -1, err
+	}
+
+	return result.LastInsertId()
+}
+
+func (s *sqlStore) UpdateConnection(ctx context.Context, c Connection) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE connections SET
+			nickname = $2,
+			host = $3,
+			"user" = $4,
+			description = $5,
+			args = $6,
+			identity = $7,
+			command = $8,
+			jump = $9,
+			pre_hook = $10,
+			post_hook = $11,
+			record = $12,
+			"range" = $13
+		WHERE id = $1
+		`,
+		sqlNullableInt64(c.Id),
+		sqlNullableString(c.Nickname),
+		sqlNullableString(c.Host),
+		sqlNullableString(c.User),
+		sqlNullableString(c.Description),
+		sqlNullableString(c.Args),
+		sqlNullableString(c.Identity),
+		sqlNullableString(c.Command),
+		sqlNullableString(c.Jump),
+		sqlNullableString(c.PreHook),
+		sqlNullableString(c.PostHook),
+		sqlBool(c.Record),
+		sqlNullableString(c.Range),
+	)
+
+	return err
+}
+
+func (s *sqlStore) DeleteConnection(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM connections
+		WHERE id = $1
+		`,
+		sqlNullableInt64(id))
+
+	return err
+}
+
+func (s *sqlStore) ConnectionExists(ctx context.Context, property string, value any) (bool, error) {
+	var check int64
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id
+		FROM connections
+		WHERE `+quoteIdent(property)+" = $1", value).Scan(&check)
+
+	// Return true/false explicitly (based on specific success/fail conditions)
+	if err == nil {
+		return true, nil
+	} else if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	return false, err
+}
+
+func (s *sqlStore) GetConnection(ctx context.Context, property string, value any) (Connection, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT`+connectionColumns+`
+		FROM connections
+		WHERE `+quoteIdent(property)+" = $1", value)
+
+	return scanConnection(row)
+}
+
+// where returns the WHERE clause (including leading whitespace) and its
+// arguments for a ListOptions filter.
+func (s *sqlStore) where(filter string) (string, []any) {
+	if len(filter) < 1 {
+		return "", nil
+	}
+
+	like := " " + s.dialect.like + " $1)"
+
+	return `
+		WHERE (nickname` + like + `
+		OR (host` + like + `
+		OR ("user"` + like + `
+		OR (description` + like, []any{"%" + filter + "%"}
+}
+
+func (s *sqlStore) ListConnections(ctx context.Context, opts ListOptions, fn func(Connection) error) error {
+	where, args := s.where(opts.Filter)
+
+	dir := " ASC"
+
+	if opts.Desc {
+		dir = " DESC"
+	}
+
+	// Text properties sort case-insensitively, with ties broken by id
+	order := "\n\t\tORDER BY id" + dir
+
+	if len(opts.SortBy) > 0 && opts.SortBy != "id" {
+		order = "\n\t\tORDER BY LOWER(" + quoteIdent(opts.SortBy) + ")" + dir + ", id" + dir
+	}
+
+	query := `
+		SELECT` + connectionColumns + `
+		FROM connections` + where + order
+
+	if opts.Limit > 0 {
+		query += "\n\t\tLIMIT " + strconv.Itoa(opts.Limit)
+	} else if opts.Offset > 0 && len(s.dialect.noLimit) > 0 {
+		query += "\n\t\t" + s.dialect.noLimit
+	}
+
+	if opts.Offset > 0 {
+		query += " OFFSET " + strconv.Itoa(opts.Offset)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query+";", args...)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		c, err := scanConnection(rows)
+
+		if err != nil {
+			return err
+		}
+
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (s *sqlStore) CountConnections(ctx context.Context, filter string) (int, error) {
+	where, args := s.where(filter)
+
+	var total int
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM connections`+where+`;
+	`, args...).Scan(&total)
+
+	return total, err
+}
+
+func (s *sqlStore) AddSession(ctx context.Context, session Session) (int64, error) {
+	query := `
+		INSERT INTO sessions (
+			connection_id,
+			nickname,
+			started,
+			ended,
+			exit_status,
+			recording
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6
+		)`
+
+	args := []any{
+		sql.NullInt64{Int64: session.ConnectionId, Valid: session.ConnectionId > 0},
+		session.Nickname,
+		formatSessionTime(session.Started),
+		formatSessionTime(session.Ended),
+		session.ExitStatus,
+		sql.NullString{String: session.Recording, Valid: len(session.Recording) > 0},
+	}
+
+	if s.dialect.returning {
+		var id int64
+
+		err := s.db.QueryRowContext(ctx, query+"\n\t\tRETURNING id", args...).Scan(&id)
+
+		if err != nil {
+			return -1, err
+		}
+
+		return id, nil
+	}
+
+	result, err := s.db.ExecContext(ctx, query, args...)
+
+	if err != nil {
+		return -1, err
+	}
+
+	return result.LastInsertId()
+}
+
+func (s *sqlStore) ListSessions(ctx context.Context, connectionId int64, limit int, fn func(Session) error) error {
+	query := `
+		SELECT id, connection_id, nickname, started, ended, exit_status, recording
+		FROM sessions`
+
+	var args []any
+
+	if connectionId > 0 {
+		query += "\n\t\tWHERE connection_id = $1"
+		args = append(args, connectionId)
+	}
+
+	query += "\n\t\tORDER BY started DESC, id DESC"
+
+	if limit > 0 {
+		query += "\n\t\tLIMIT " + strconv.Itoa(limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query+";", args...)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var session Session
+		var connId sql.NullInt64
+		var started, ended string
+		var recording sql.NullString
+
+		err := rows.Scan(&session.Id, &connId, &session.Nickname, &started, &ended, &session.ExitStatus, &recording)
+
+		if err != nil {
+			return err
+		}
+
+		session.ConnectionId = connId.Int64
+		session.Recording = recording.String
+
+		if session.Started, err = parseSessionTime(started); err != nil {
+			return err
+		}
+
+		if session.Ended, err = parseSessionTime(ended); err != nil {
+			return err
+		}
+
+		if err := fn(session); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (s *sqlStore) GetDefault(ctx context.Context, name string) (string, error) {
+	var def sql.NullString
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT value
+		FROM defaults
+		WHERE setting = $1
+	`, name).Scan(&def)
+
+	// Defaults added after the DB was created have no row until they are set
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return def.String, nil
+}
+
+func (s *sqlStore) SetDefault(ctx context.Context, name string, value string) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE defaults SET
+			value = $2
+		WHERE setting = $1
+		`,
+		name,
+		sqlNullableString(value),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO defaults (setting, value)
+		VALUES ($1, $2)
+		`,
+		name,
+		sqlNullableString(value),
+	)
+
+	return err
+}
+
+func (s *sqlStore) GetSetting(ctx context.Context, name string) (string, error) {
+	var value sql.NullString
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT value
+		FROM global
+		WHERE setting = $1
+	`, name).Scan(&value)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return value.String, err
+}
+
+func (s *sqlStore) SetSetting(ctx context.Context, name string, value string) error {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM global
+		WHERE setting = $1
+		`,
+		name,
+	)
+
+	if err != nil || len(value) < 1 {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO global (setting, value)
+		VALUES ($1, $2)
+		`,
+		name,
+		value,
+	)
+
+	return err
+}
+
+// tableNames returns the names of all user tables in the DB.
+func (s *sqlStore) tableNames(ctx context.Context) ([]string, error) {
+	return s.names(ctx, s.dialect.tablesQuery)
+}
+
+// tableColumns returns the names of all columns in a table.
+func (s *sqlStore) tableColumns(ctx context.Context, table string) ([]string, error) {
+	return s.names(ctx, s.dialect.columnsQuery, table)
+}
+
+// names runs a query returning a single column of names.
+func (s *sqlStore) names(ctx context.Context, query string, args ...any) ([]string, error) {
+	var names []string
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return names, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+
+		if err := rows.Scan(&name); err != nil {
+			return names, err
+		}
+
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+func (s *sqlStore) Dump(ctx context.Context) (map[string]*ArchiveTable, error) {
+	tables, err := s.tableNames(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	dump := make(map[string]*ArchiveTable)
+
+	for _, table := range tables {
+		t, err := s.dumpTable(ctx, table)
+
+		if err != nil {
+			return nil, err
+		}
+
+		dump[table] = t
+	}
+
+	return dump, nil
+}
+
+// dumpTable copies the contents of a single table.
+func (s *sqlStore) dumpTable(ctx context.Context, table string) (*ArchiveTable, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT * FROM "+quoteIdent(table))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+
+	if err != nil {
+		return nil, err
+	}
+
+	t := &ArchiveTable{Columns: cols, Rows: [][]any{}}
+
+	for rows.Next() {
+		values := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+
+		// Store blobs as text, so that they're readable in the archive
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+
+		t.Rows = append(t.Rows, values)
+	}
+
+	return t, rows.Err()
+}
+
+func (s *sqlStore) Load(ctx context.Context, tables map[string]*ArchiveTable) error {
+	// Make sure every archived table and column exists before writing anything
+	names, err := s.tableNames(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	for name, t := range tables {
+		if !slices.Contains(names, name) {
+			return fmt.Errorf("%w: %s", ErrArchiveUnknownTable, name)
+		}
+
+		cols, err := s.tableColumns(ctx, name)
+
+		if err != nil {
+			return err
+		}
+
+		for _, col := range t.Columns {
+			if !slices.Contains(cols, col) {
+				return fmt.Errorf("%w: %s.%s", ErrArchiveUnknownColumn, name, col)
+			}
+		}
+	}
+
+	for name, t := range tables {
+		if _, err := s.db.ExecContext(ctx, "DELETE FROM "+quoteIdent(name)); err != nil {
+			return err
+		}
+
+		var cols, params []string
+
+		for i, col := range t.Columns {
+			cols = append(cols, quoteIdent(col))
+			params = append(params, fmt.Sprintf("$%d", i+1))
+		}
+
+		insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+			quoteIdent(name), strings.Join(cols, ", "), strings.Join(params, ", "))
+
+		for _, row := range t.Rows {
+			if len(row) != len(t.Columns) {
+				return fmt.Errorf("%w: %s", ErrArchiveInvalid, name)
+			}
+
+			if _, err := s.db.ExecContext(ctx, insert, archiveValues(row)...); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, statement := range s.dialect.afterLoad {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WithTx holds the store's advisory lock, if it has one, for the whole
+// transaction.
+func (s *sqlStore) WithTx(ctx context.Context, fn func(st Store) error) error {
+	if len(s.lockPath) > 0 {
+		unlock, err := lockFile(ctx, s.lockPath)
+
+		if err != nil {
+			return err
+		}
+
+		defer unlock()
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	// Rolling back a committed transaction does nothing
+	defer tx.Rollback()
+
+	if err := fn(&sqlStore{db: txConn{tx}, dialect: s.dialect}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
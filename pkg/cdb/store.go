@@ -0,0 +1,105 @@
+package cdb
+
+import (
+	"context"
+)
+
+// A Store is the storage backend a ConnectionDB sits on.
+//
+// ConnectionDB validates connections, property names and default settings
+// and enforces unique nicknames before calling its Store, so implementations
+// only need to store and retrieve what they are passed. Connections returned
+// by a Store are detached; ConnectionDB attaches them to itself.
+//
+// This package provides SQL stores for SQLite and PostgreSQL (see NewSQLStore)
+// and a JSON or YAML file store (see NewFileStore). Every implementation must
+// pass the same conformance tests.
+type Store interface {
+	// Initialize sets up an empty store at the passed schema version, with
+	// empty program defaults.
+	Initialize(ctx context.Context, version string) error
+
+	// Initialized returns true if the store has been set up.
+	Initialized(ctx context.Context) (bool, error)
+
+	// SchemaVersion returns the schema version the store was set up with.
+	SchemaVersion(ctx context.Context) (string, error)
+
+	// Upgrade upgrades the store from the passed schema version, which it
+	// was set up with, to SchemaVersion.
+	Upgrade(ctx context.Context, version string) error
+
+	// AddConnection stores a new connection and returns its id. Ids are
+	// never reused.
+	AddConnection(ctx context.Context, c Connection) (int64, error)
+
+	// UpdateConnection replaces the properties of the connection with c's
+	// id.
+	UpdateConnection(ctx context.Context, c Connection) error
+
+	// DeleteConnection removes the connection with the passed id.
+	DeleteConnection(ctx context.Context, id int64) error
+
+	// ConnectionExists returns true if a connection's property ("id" or one
+	// of ValidProperties) has the passed value.
+	ConnectionExists(ctx context.Context, property string, value any) (bool, error)
+
+	// GetConnection returns the connection whose property ("id" or one of
+	// ValidProperties) has the passed value. ErrConnectionNotFound is
+	// returned if there isn't one.
+	GetConnection(ctx context.Context, property string, value any) (Connection, error)
+
+	// ListConnections calls fn for each connection matching opts, in order.
+	// opts has already been checked. Iteration stops at the first error,
+	// which is returned.
+	ListConnections(ctx context.Context, opts ListOptions, fn func(Connection) error) error
+
+	// CountConnections returns the number of connections matching filter, as
+	// described for ListOptions.Filter.
+	CountConnections(ctx context.Context, filter string) (int, error)
+
+	// AddSession stores a session record and returns its id. Ids are never
+	// reused.
+	AddSession(ctx context.Context, s Session) (int64, error)
+
+	// ListSessions calls fn for each session of the connection with the
+	// passed id (or every session, if it is zero), newest first, stopping
+	// after limit sessions if limit is more than zero. Iteration stops at the
+	// first error, which is returned.
+	ListSessions(ctx context.Context, connectionId int64, limit int, fn func(Session) error) error
+
+	// GetDefault returns a program default setting.
+	GetDefault(ctx context.Context, name string) (string, error)
+
+	// SetDefault changes a program default setting.
+	SetDefault(ctx context.Context, name string, value string) error
+
+	// GetSetting returns a global setting, or an empty string if it isn't
+	// set.
+	GetSetting(ctx context.Context, name string) (string, error)
+
+	// SetSetting changes a global setting. Setting it to an empty string
+	// removes it.
+	SetSetting(ctx context.Context, name string, value string) error
+
+	// Dump returns the contents of the store as archive tables, using the
+	// SQLite table layout, so that archives can be restored into any store.
+	Dump(ctx context.Context) (map[string]*ArchiveTable, error)
+
+	// Load replaces the contents of the passed archive tables. Unknown
+	// tables and columns are rejected with ErrArchiveUnknownTable and
+	// ErrArchiveUnknownColumn.
+	Load(ctx context.Context, tables map[string]*ArchiveTable) error
+
+	// WithTx calls fn with a Store whose changes are kept only if fn returns
+	// nil. Stores passed to fn return ErrNestedTx from WithTx.
+	WithTx(ctx context.Context, fn func(s Store) error) error
+
+	// Close releases the store's resources.
+	Close() error
+}
+
+// NewConnectionDB returns a ConnectionDB that sits on the passed Store.
+func NewConnectionDB(s Store) ConnectionDB {
+	return ConnectionDB{store: s}
+}
@@ -0,0 +1,569 @@
+package cdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// testStores returns a function creating a new, uninitialized store for each
+// backend under test. PostgreSQL is tested when SSHCM_TEST_POSTGRES_DSN is set
+// (and a driver is registered, see PostgresDriver); its tables are dropped
+// before each test.
+func testStores(t *testing.T) map[string]func(t *testing.T) Store {
+	stores := map[string]func(t *testing.T) Store{
+		"sqlite": func(t *testing.T) Store {
+			conndb, err := Connect("sqlite", filepath.Join(t.TempDir(), "test.connections"))
+
+			if err != nil {
+				t.Fatalf("Connect() error = %v", err)
+			}
+
+			return conndb.store
+		},
+		"json": func(t *testing.T) Store {
+			return NewFileStore(filepath.Join(t.TempDir(), "connections.json"))
+		},
+		"yaml": func(t *testing.T) Store {
+			return NewFileStore(filepath.Join(t.TempDir(), "connections.yaml"))
+		},
+	}
+
+	dsn := os.Getenv("SSHCM_TEST_POSTGRES_DSN")
+
+	if driver := PostgresDriver(); len(dsn) > 0 && len(driver) > 0 {
+		stores["postgres"] = func(t *testing.T) Store {
+			db, err := sql.Open(driver, dsn)
+
+			if err != nil {
+				t.Fatalf("sql.Open() error = %v", err)
+			}
+
+			for _, table := range []string{"sessions", "connections", "defaults", "global"} {
+				if _, err := db.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+					t.Fatalf("DROP TABLE error = %v", err)
+				}
+			}
+
+			return NewSQLStore(db, Postgres)
+		}
+	}
+
+	return stores
+}
+
+// runStoreTests runs fn against a new, initialized ConnectionDB on each store
+// under test.
+func runStoreTests(t *testing.T, fn func(t *testing.T, conndb *ConnectionDB)) {
+	for name, newStore := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			conndb := NewConnectionDB(newStore(t))
+
+			if err := conndb.InitializeDb(SchemaVersion); err != nil {
+				t.Fatalf("ConnectionDB.InitializeDb() error = %v", err)
+			}
+
+			t.Cleanup(conndb.Close)
+
+			fn(t, &conndb)
+		})
+	}
+}
+
+func TestStore_Initialize(t *testing.T) {
+	for name, newStore := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			conndb := NewConnectionDB(newStore(t))
+			defer conndb.Close()
+
+			if ok, err := conndb.Initialized(); ok || err != nil {
+				t.Fatalf("ConnectionDB.Initialized() = %v, %v, want false", ok, err)
+			}
+
+			if err := conndb.InitializeDb(SchemaVersion); err != nil {
+				t.Fatalf("ConnectionDB.InitializeDb() error = %v", err)
+			}
+
+			if ok, err := conndb.Initialized(); !ok || err != nil {
+				t.Errorf("ConnectionDB.Initialized() = %v, %v, want true", ok, err)
+			}
+
+			if err := conndb.CheckDbHealth(); err != nil {
+				t.Errorf("ConnectionDB.CheckDbHealth() error = %v", err)
+			}
+
+			if err := conndb.InitializeDb(SchemaVersion); err == nil {
+				t.Errorf("ConnectionDB.InitializeDb() twice did not fail")
+			}
+		})
+	}
+}
+
+func TestStore_Connections(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, conndb *ConnectionDB) {
+		id, err := conndb.Add(&Connection{Nickname: "something", Host: "somewhere", User: "me"})
+
+		if err != nil {
+			t.Fatalf("ConnectionDB.Add() error = %v", err)
+		}
+
+		if _, err := conndb.Add(&Connection{Nickname: "something", Host: "elsewhere"}); err != ErrDuplicateNickname {
+			t.Errorf("ConnectionDB.Add() error = %v, want %v", err, ErrDuplicateNickname)
+		}
+
+		c, err := conndb.Get(id)
+
+		if err != nil || c.Nickname != "something" || c.User != "me" {
+			t.Fatalf("ConnectionDB.Get() = %v, %v, want the added connection", c, err)
+		}
+
+		c.Host = "elsewhere"
+		c.Args = "-p 2222"
+		c.Record = true
+
+		if err := c.Update(); err != nil {
+			t.Fatalf("Connection.Update() error = %v", err)
+		}
+
+		c, err = conndb.GetByProperty("host", "elsewhere")
+
+		if err != nil || c.Id != id || c.Args != "-p 2222" || !c.Record {
+			t.Errorf("ConnectionDB.GetByProperty() = %v, %v, want the updated connection", c, err)
+		}
+
+		other := Connection{Nickname: "else", Host: "elsewhere"}
+
+		if _, err := conndb.Add(&other); err != nil {
+			t.Fatalf("ConnectionDB.Add() error = %v", err)
+		}
+
+		c.Nickname = "else"
+
+		if err := c.Update(); err != ErrDuplicateNickname {
+			t.Errorf("Connection.Update() error = %v, want %v", err, ErrDuplicateNickname)
+		}
+
+		c, _ = conndb.Get(id)
+
+		if err := c.Delete(); err != nil {
+			t.Fatalf("Connection.Delete() error = %v", err)
+		}
+
+		if exists, err := conndb.Exists(id); exists || err != nil {
+			t.Errorf("ConnectionDB.Exists() = %v, %v, want false", exists, err)
+		}
+
+		if _, err := conndb.Get(id); err != ErrConnectionNotFound {
+			t.Errorf("ConnectionDB.Get() error = %v, want %v", err, ErrConnectionNotFound)
+		}
+
+		// Ids aren't reused
+		newId, err := conndb.Add(&Connection{Nickname: "something", Host: "somewhere"})
+
+		if err != nil || newId <= id {
+			t.Errorf("ConnectionDB.Add() = %v, %v, want an id greater than %v", newId, err, id)
+		}
+	})
+}
+
+func TestStore_List(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, conndb *ConnectionDB) {
+		for _, c := range []Connection{
+			{Nickname: "bravo", Host: "b.example.com", Description: "Web server"},
+			{Nickname: "Alpha", Host: "a.example.com"},
+			{Nickname: "charlie", Host: "c.example.com", User: "WEB"},
+			{Nickname: "delta", Host: "a.example.com"},
+		} {
+			if _, err := conndb.Add(&c); err != nil {
+				t.Fatalf("ConnectionDB.Add() error = %v", err)
+			}
+		}
+
+		tests := []struct {
+			name  string
+			opts  ListOptions
+			want  []string
+			total int
+		}{
+			{"all", ListOptions{}, []string{"bravo", "Alpha", "charlie", "delta"}, 4},
+			{"sorted", ListOptions{SortBy: "nickname"}, []string{"Alpha", "bravo", "charlie", "delta"}, 4},
+			{"ties by id", ListOptions{SortBy: "host", Desc: true}, []string{"charlie", "bravo", "delta", "Alpha"}, 4},
+			{"filter", ListOptions{Filter: "web"}, []string{"bravo", "charlie"}, 2},
+			{"limit", ListOptions{Limit: 2}, []string{"bravo", "Alpha"}, 4},
+			{"offset", ListOptions{Offset: 3}, []string{"delta"}, 4},
+			{"past end", ListOptions{Offset: 10}, nil, 4},
+		}
+
+		for _, tt := range tests {
+			cns, total, err := conndb.List(context.Background(), tt.opts)
+
+			if err != nil {
+				t.Fatalf("%s: ConnectionDB.List() error = %v", tt.name, err)
+			}
+
+			var got []string
+
+			for _, c := range cns {
+				got = append(got, c.Nickname)
+			}
+
+			if !slices.Equal(got, tt.want) || total != tt.total {
+				t.Errorf("%s: ConnectionDB.List() = %v, %d, want %v, %d", tt.name, got, total, tt.want, tt.total)
+			}
+		}
+	})
+}
+
+func TestStore_Defaults(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, conndb *ConnectionDB) {
+		if def, err := conndb.GetDefault("user"); def != "" || err != nil {
+			t.Errorf("ConnectionDB.GetDefault() = %q, %v, want empty", def, err)
+		}
+
+		if err := conndb.SetDefault("user", "asdf"); err != nil {
+			t.Fatalf("ConnectionDB.SetDefault() error = %v", err)
+		}
+
+		if def, err := conndb.GetDefault("user"); def != "asdf" || err != nil {
+			t.Errorf("ConnectionDB.GetDefault() = %q, %v, want asdf", def, err)
+		}
+
+		// Defaults newer than the DB have no row until they are set
+		if def, err := conndb.GetDefault("hook_timeout"); def != "" || err != nil {
+			t.Errorf("ConnectionDB.GetDefault() = %q, %v, want empty", def, err)
+		}
+
+		if err := conndb.SetDefault("hook_timeout", "1m"); err != nil {
+			t.Fatalf("ConnectionDB.SetDefault() error = %v", err)
+		}
+
+		if def, err := conndb.GetDefault("hook_timeout"); def != "1m" || err != nil {
+			t.Errorf("ConnectionDB.GetDefault() = %q, %v, want 1m", def, err)
+		}
+
+		if err := conndb.SetDefault("binary", "ssh"); err != ErrInvalidDefault {
+			t.Errorf("ConnectionDB.SetDefault() error = %v, want %v", err, ErrInvalidDefault)
+		}
+	})
+}
+
+func TestStore_Settings(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, conndb *ConnectionDB) {
+		if v, err := conndb.GetSetting("ssh_config_include"); v != "" || err != nil {
+			t.Errorf("ConnectionDB.GetSetting() = %q, %v, want empty", v, err)
+		}
+
+		for _, want := range []string{"/tmp/a", "/tmp/b", ""} {
+			if err := conndb.SetSetting("ssh_config_include", want); err != nil {
+				t.Fatalf("ConnectionDB.SetSetting() error = %v", err)
+			}
+
+			if v, err := conndb.GetSetting("ssh_config_include"); v != want || err != nil {
+				t.Errorf("ConnectionDB.GetSetting() = %q, %v, want %q", v, err, want)
+			}
+		}
+
+		if err := conndb.SetSetting("schema_version", "1"); err != ErrInvalidSetting {
+			t.Errorf("ConnectionDB.SetSetting() error = %v, want %v", err, ErrInvalidSetting)
+		}
+
+		if v, _ := conndb.GetDbSchemaVersion(); v != SchemaVersion {
+			t.Errorf("ConnectionDB.GetDbSchemaVersion() = %v, want %v", v, SchemaVersion)
+		}
+	})
+}
+
+func TestStore_WithTx(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, conndb *ConnectionDB) {
+		err := conndb.WithTx(func(tx *Tx) error {
+			if _, err := tx.Add(&Connection{Nickname: "something", Host: "somewhere"}); err != nil {
+				return err
+			}
+
+			return tx.SetDefault("user", "asdf")
+		})
+
+		if err != nil {
+			t.Fatalf("ConnectionDB.WithTx() error = %v", err)
+		}
+
+		failed := errors.New("failed")
+
+		err = conndb.WithTx(func(tx *Tx) error {
+			if _, err := tx.Add(&Connection{Nickname: "else", Host: "elsewhere"}); err != nil {
+				return err
+			}
+
+			if err := tx.SetDefault("user", "qwerty"); err != nil {
+				return err
+			}
+
+			return failed
+		})
+
+		if err != failed {
+			t.Fatalf("ConnectionDB.WithTx() error = %v, want %v", err, failed)
+		}
+
+		err = conndb.WithTx(func(tx *Tx) error {
+			return tx.db.WithTx(func(*Tx) error {
+				return nil
+			})
+		})
+
+		if !errors.Is(err, ErrNestedTx) {
+			t.Errorf("ConnectionDB.WithTx() error = %v, want %v", err, ErrNestedTx)
+		}
+
+		cns, _ := conndb.GetAll()
+
+		if len(cns) != 1 || cns[0].Nickname != "something" {
+			t.Errorf("ConnectionDB.GetAll() = %v, want only the committed connection", cns)
+		}
+
+		if def, _ := conndb.GetDefault("user"); def != "asdf" {
+			t.Errorf("ConnectionDB.GetDefault() = %q, want asdf", def)
+		}
+	})
+}
+
+// TestStore_BackupRestore restores a SQLite backup into each store, then
+// restores each store's backup into SQLite.
+func TestStore_BackupRestore(t *testing.T) {
+	src := newTestConnDb(t)
+
+	for _, c := range []Connection{
+		{Nickname: "something", Host: "somewhere", User: "me"},
+		{Nickname: "else", Host: "elsewhere", Args: "-p 2222", Record: true},
+	} {
+		if _, err := src.Add(&c); err != nil {
+			t.Fatalf("ConnectionDB.Add() error = %v", err)
+		}
+	}
+
+	if err := src.SetDefault("user", "asdf"); err != nil {
+		t.Fatalf("ConnectionDB.SetDefault() error = %v", err)
+	}
+
+	if err := src.SetSetting("ssh_config_include", "/tmp/sshcm_config"); err != nil {
+		t.Fatalf("ConnectionDB.SetSetting() error = %v", err)
+	}
+
+	started := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	session := Session{ConnectionId: 1, Nickname: "something", Started: started, Ended: started.Add(time.Hour), ExitStatus: 255, Recording: "/tmp/something.cast"}
+
+	if _, err := src.AddSession(&session); err != nil {
+		t.Fatalf("ConnectionDB.AddSession() error = %v", err)
+	}
+
+	want, _ := src.GetAll()
+
+	// roundTrip backs up a DB and restores the backup into another
+	roundTrip := func(t *testing.T, from, to *ConnectionDB) {
+		a, err := from.Backup()
+
+		if err != nil {
+			t.Fatalf("ConnectionDB.Backup() error = %v", err)
+		}
+
+		var b bytes.Buffer
+
+		if err := a.Write(&b); err != nil {
+			t.Fatalf("Archive.Write() error = %v", err)
+		}
+
+		a, err = ReadArchive(&b)
+
+		if err != nil {
+			t.Fatalf("ReadArchive() error = %v", err)
+		}
+
+		if err := to.Restore(a); err != nil {
+			t.Fatalf("ConnectionDB.Restore() error = %v", err)
+		}
+
+		got, err := to.GetAll()
+
+		if err != nil || len(got) != len(want) {
+			t.Fatalf("ConnectionDB.GetAll() = %v, %v, want %v", got, err, want)
+		}
+
+		for i := range got {
+			if got[i].Id != want[i].Id || got[i].String() != want[i].String() || got[i].Args != want[i].Args || got[i].Record != want[i].Record {
+				t.Errorf("restored connection %v, want %v", got[i], want[i])
+			}
+		}
+
+		if user, _ := to.GetDefault("user"); user != "asdf" {
+			t.Errorf("restored default user = %q, want %q", user, "asdf")
+		}
+
+		if v, _ := to.GetSetting("ssh_config_include"); v != "/tmp/sshcm_config" {
+			t.Errorf("restored setting = %q, want %q", v, "/tmp/sshcm_config")
+		}
+
+		sessions, err := to.Sessions(0, 0)
+
+		if err != nil || len(sessions) != 1 || sessions[0].Nickname != "something" || !sessions[0].Ended.Equal(session.Ended) || sessions[0].ExitStatus != 255 || sessions[0].Recording != session.Recording {
+			t.Errorf("restored sessions = %v, %v, want %v", sessions, err, session)
+		}
+	}
+
+	runStoreTests(t, func(t *testing.T, conndb *ConnectionDB) {
+		roundTrip(t, src, conndb)
+
+		// Ids carry on after the restored connections
+		id, err := conndb.Add(&Connection{Nickname: "new", Host: "somewhere"})
+
+		if err != nil || id != 3 {
+			t.Errorf("ConnectionDB.Add() = %v, %v, want 3", id, err)
+		}
+
+		c, _ := conndb.Get(id)
+		c.Delete()
+
+		roundTrip(t, conndb, newTestConnDb(t))
+	})
+}
+
+func TestStore_RestoreUnknown(t *testing.T) {
+	tests := []struct {
+		name   string
+		tables map[string]*ArchiveTable
+		want   error
+	}{
+		{
+			name: "table",
+			tables: map[string]*ArchiveTable{
+				"bogus": {Columns: []string{"setting"}, Rows: [][]any{}},
+			},
+			want: ErrArchiveUnknownTable,
+		},
+		{
+			name: "column",
+			tables: map[string]*ArchiveTable{
+				"connections": {Columns: []string{"id", "bogus"}, Rows: [][]any{}},
+			},
+			want: ErrArchiveUnknownColumn,
+		},
+	}
+
+	runStoreTests(t, func(t *testing.T, conndb *ConnectionDB) {
+		for _, tt := range tests {
+			a := &Archive{
+				FormatVersion: ArchiveFormatVersion,
+				SchemaVersion: SchemaVersion,
+				Tables:        tt.tables,
+			}
+
+			if err := conndb.Restore(a); !errors.Is(err, tt.want) {
+				t.Errorf("%s: ConnectionDB.Restore() error = %v, want %v", tt.name, err, tt.want)
+			}
+		}
+	})
+}
+
+func TestFileStore_Format(t *testing.T) {
+	dir := t.TempDir()
+
+	for file, prefix := range map[string]string{
+		"connections.json": "{",
+		"connections.yml":  "schema_version:",
+	} {
+		path := filepath.Join(dir, file)
+		conndb := NewConnectionDB(NewFileStore(path))
+
+		if err := conndb.InitializeDb(SchemaVersion); err != nil {
+			t.Fatalf("ConnectionDB.InitializeDb() error = %v", err)
+		}
+
+		b, err := os.ReadFile(path)
+
+		if err != nil {
+			t.Fatalf("os.ReadFile() error = %v", err)
+		}
+
+		if !bytes.HasPrefix(b, []byte(prefix)) {
+			t.Errorf("%s begins %q, want %q", file, b[:min(len(b), 20)], prefix)
+		}
+
+		info, err := os.Stat(path)
+
+		if err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("%s mode = %v, %v, want 0600", file, info.Mode().Perm(), err)
+		}
+	}
+}
+
+// newMockPostgresConnDb returns a new ConnectionDB with a sqlmock connection
+// that is queried using the Postgres dialect.
+func newMockPostgresConnDb(t *testing.T) (*ConnectionDB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+
+	conndb := NewConnectionDB(NewSQLStore(db, Postgres))
+
+	t.Cleanup(conndb.Close)
+
+	return &conndb, mock
+}
+
+func TestPostgres_Add(t *testing.T) {
+	conndb, mock := newMockPostgresConnDb(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id").WithArgs("something").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`INSERT INTO connections \(\s*nickname,\s*host,\s*"user",.*RETURNING id`).
+		WithArgs("something", "somewhere", "me", "", "", "", "", "", "", "", int64(0), "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+
+	id, err := conndb.Add(&Connection{Nickname: "something", Host: "somewhere", User: "me"})
+
+	if err != nil || id != 7 {
+		t.Errorf("ConnectionDB.Add() = %v, %v, want 7", id, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPostgres_List(t *testing.T) {
+	conndb, mock := newMockPostgresConnDb(t)
+
+	cols := []string{"id", "nickname", "host", "user", "description", "args", "identity", "command", "jump", "pre_hook", "post_hook", "record", "range"}
+
+	mock.ExpectQuery(`WHERE \(nickname ILIKE \$1\).*ORDER BY LOWER\("user"\) DESC, id DESC\s+OFFSET 5;$`).
+		WithArgs("%web%").
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(1, "something", "somewhere", "me", "", "", "", "", "", "", "", nil, nil))
+	mock.ExpectQuery(`SELECT COUNT\(\*\)\s+FROM connections\s+WHERE \(nickname ILIKE \$1\)`).
+		WithArgs("%web%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(6))
+
+	cns, total, err := conndb.List(context.Background(), ListOptions{
+		Filter: "web",
+		SortBy: "user",
+		Desc:   true,
+		Offset: 5,
+	})
+
+	if err != nil || len(cns) != 1 || total != 6 {
+		t.Errorf("ConnectionDB.List() = %v, %v, %v, want 1 of 6 connections", cns, total, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,113 @@
+package cdb
+
+import (
+	"context"
+)
+
+// A Tx is a connection DB transaction, started by WithTx or WithTxContext. Its
+// methods work like the ConnectionDB methods of the same name, but run in the
+// transaction, using the context the transaction was started with.
+//
+// Connections read through a Tx are attached to it, so their Update and Delete
+// methods also run in the transaction. Neither a Tx nor its connections may be
+// used once the transaction has finished.
+type Tx struct {
+	ctx context.Context
+	db  ConnectionDB
+}
+
+// WithTxContext runs fn in a transaction. If fn returns nil, the transaction
+// is committed. If fn returns an error or panics, or ctx is cancelled, the
+// transaction is rolled back and the connection DB is left unchanged.
+//
+// fn should make all of its changes through the passed Tx. Using the
+// ConnectionDB directly while the transaction is open may block or fail with
+// a locking error.
+func (conndb *ConnectionDB) WithTxContext(ctx context.Context, fn func(tx *Tx) error) error {
+	return conndb.store.WithTx(ctx, func(st Store) error {
+		return fn(&Tx{
+			ctx: ctx,
+			db:  ConnectionDB{store: st, inTx: true},
+		})
+	})
+}
+
+// WithTx uses context.Background internally; to specify the context, use
+// WithTxContext.
+func (conndb *ConnectionDB) WithTx(fn func(tx *Tx) error) error {
+	return conndb.WithTxContext(context.Background(), fn)
+}
+
+// atomic runs fn in a transaction, so that the steps of a multi-step change
+// (ex. checking that a nickname is unique, then adding a connection) can't be
+// interleaved with changes from other processes. If the ConnectionDB already
+// belongs to a Tx, fn runs in that transaction.
+func (conndb *ConnectionDB) atomic(ctx context.Context, fn func(db *ConnectionDB) error) error {
+	if conndb.inTx {
+		return fn(conndb)
+	}
+
+	return conndb.store.WithTx(ctx, func(st Store) error {
+		return fn(&ConnectionDB{store: st, inTx: true})
+	})
+}
+
+// Add adds a new connection in the transaction and returns its id.
+func (tx *Tx) Add(c *Connection) (int64, error) {
+	return tx.db.AddContext(tx.ctx, c)
+}
+
+// Update updates an existing connection in the transaction.
+func (tx *Tx) Update(c Connection) error {
+	c.db = &tx.db
+
+	return c.UpdateContext(tx.ctx)
+}
+
+// Delete removes a connection in the transaction.
+func (tx *Tx) Delete(c Connection) error {
+	c.db = &tx.db
+
+	return c.DeleteContext(tx.ctx)
+}
+
+// ExistsByProperty returns true if a connection with the passed property
+// value exists.
+func (tx *Tx) ExistsByProperty(property string, value string) (bool, error) {
+	return tx.db.ExistsByPropertyContext(tx.ctx, property, value)
+}
+
+// Get returns the connection with the passed id.
+func (tx *Tx) Get(id int64) (Connection, error) {
+	return tx.db.GetContext(tx.ctx, id)
+}
+
+// GetByIdOrNickname returns the connection with the passed id or nickname.
+func (tx *Tx) GetByIdOrNickname(arg string) (Connection, error) {
+	return tx.db.GetByIdOrNicknameContext(tx.ctx, arg)
+}
+
+// GetByProperty returns the connection with the passed property value.
+func (tx *Tx) GetByProperty(property string, value string) (Connection, error) {
+	return tx.db.GetByPropertyContext(tx.ctx, property, value)
+}
+
+// GetDefault returns a program default setting.
+func (tx *Tx) GetDefault(name string) (string, error) {
+	return tx.db.GetDefaultContext(tx.ctx, name)
+}
+
+// SetDefault changes a program default setting in the transaction.
+func (tx *Tx) SetDefault(name string, value string) error {
+	return tx.db.SetDefaultContext(tx.ctx, name, value)
+}
+
+// GetSetting returns a global setting.
+func (tx *Tx) GetSetting(name string) (string, error) {
+	return tx.db.GetSettingContext(tx.ctx, name)
+}
+
+// SetSetting changes a global setting in the transaction.
+func (tx *Tx) SetSetting(name string, value string) error {
+	return tx.db.SetSettingContext(tx.ctx, name, value)
+}
@@ -0,0 +1,138 @@
+package cdb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConnectionDB_WithTx(t *testing.T) {
+	conndb := newTestConnDb(t)
+
+	existing := Connection{Nickname: "something", Host: "somewhere"}
+
+	if _, err := conndb.Add(&existing); err != nil {
+		t.Fatalf("ConnectionDB.Add() error = %v", err)
+	}
+
+	err := conndb.WithTx(func(tx *Tx) error {
+		if _, err := tx.Add(&Connection{Nickname: "else", Host: "elsewhere"}); err != nil {
+			return err
+		}
+
+		c, err := tx.GetByIdOrNickname("something")
+
+		if err != nil {
+			return err
+		}
+
+		c.User = "me"
+
+		// Connections read through a Tx update in the transaction
+		if err := c.Update(); err != nil {
+			return err
+		}
+
+		return tx.SetDefault("user", "asdf")
+	})
+
+	if err != nil {
+		t.Fatalf("ConnectionDB.WithTx() error = %v", err)
+	}
+
+	c, err := conndb.GetByProperty("nickname", "something")
+
+	if err != nil || c.User != "me" {
+		t.Errorf("ConnectionDB.GetByProperty() = %v, %v, want an updated connection", c, err)
+	}
+
+	if exists, _ := conndb.ExistsByProperty("nickname", "else"); !exists {
+		t.Errorf("ConnectionDB.WithTx() did not commit the added connection")
+	}
+
+	if def, _ := conndb.GetDefault("user"); def != "asdf" {
+		t.Errorf("ConnectionDB.GetDefault() = %v, want asdf", def)
+	}
+}
+
+func TestConnectionDB_WithTxRollback(t *testing.T) {
+	conndb := newTestConnDb(t)
+
+	existing := Connection{Nickname: "something", Host: "somewhere"}
+
+	if _, err := conndb.Add(&existing); err != nil {
+		t.Fatalf("ConnectionDB.Add() error = %v", err)
+	}
+
+	// changes adds a connection and removes the existing one, then fails
+	changes := func(tx *Tx) error {
+		if _, err := tx.Add(&Connection{Nickname: "else", Host: "elsewhere"}); err != nil {
+			return err
+		}
+
+		c, err := tx.Get(1)
+
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Delete(c); err != nil {
+			return err
+		}
+
+		// Adding a duplicate nickname fails
+		_, err = tx.Add(&Connection{Nickname: "else", Host: "elsewhere"})
+
+		return err
+	}
+
+	err := conndb.WithTx(changes)
+
+	if !errors.Is(err, ErrDuplicateNickname) {
+		t.Fatalf("ConnectionDB.WithTx() error = %v, want %v", err, ErrDuplicateNickname)
+	}
+
+	// A panic rolls back too
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("ConnectionDB.WithTx() did not pass on a panic")
+			}
+		}()
+
+		conndb.WithTx(func(tx *Tx) error {
+			if err := tx.SetDefault("user", "asdf"); err != nil {
+				return err
+			}
+
+			panic("failed")
+		})
+	}()
+
+	cns, err := conndb.GetAll()
+
+	if err != nil {
+		t.Fatalf("ConnectionDB.GetAll() error = %v", err)
+	}
+
+	if len(cns) != 1 || cns[0].Nickname != "something" {
+		t.Errorf("ConnectionDB.GetAll() = %v, want only the original connection", cns)
+	}
+
+	if def, _ := conndb.GetDefault("user"); def != "" {
+		t.Errorf("ConnectionDB.GetDefault() = %v, want it unchanged", def)
+	}
+}
+
+func TestConnectionDB_WithTxNested(t *testing.T) {
+	conndb := newTestConnDb(t)
+
+	err := conndb.WithTx(func(tx *Tx) error {
+		return tx.db.WithTx(func(*Tx) error {
+			return nil
+		})
+	})
+
+	if !errors.Is(err, ErrNestedTx) {
+		t.Errorf("ConnectionDB.WithTx() error = %v, want %v", err, ErrNestedTx)
+	}
+}
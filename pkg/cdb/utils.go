@@ -6,14 +6,19 @@ import (
 	"unicode"
 )
 
-var ValidDefaults = [4]string{
+var ValidDefaults = [9]string{
 	"args",
 	"command",
 	"identity",
 	"user",
+	"pre_hook",
+	"post_hook",
+	"hook_timeout",
+	"hook_abort",
+	"record_dir",
 }
 
-var ValidProperties = [7]string{
+var ValidProperties = [11]string{
 	"nickname",
 	"host",
 	"user",
@@ -21,6 +26,16 @@ var ValidProperties = [7]string{
 	"args",
 	"identity",
 	"command",
+	"jump",
+	"pre_hook",
+	"post_hook",
+	"range",
+}
+
+// ValidSettings lists the global settings that can be changed. The schema
+// version is also kept with the global settings, but can't be changed.
+var ValidSettings = [1]string{
+	"ssh_config_include",
 }
 
 // IsValidDefault checks the passed default property name against a list of
@@ -50,6 +65,18 @@ func IsValidProperty(property string) bool {
 	return false
 }
 
+// IsValidSetting checks the passed global setting name against ValidSettings.
+//
+// Returns true if the name is valid, false otherwise.
+func IsValidSetting(name string) bool {
+	for _, v := range ValidSettings {
+		if strings.Compare(name, v) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // ValidateNickname runs checks against the passed nickname string.
 //
 // If the tests pass and the nickname is valid, nil is returned.
@@ -68,6 +95,18 @@ func ValidateNickname(nickname string) error {
 	return nil
 }
 
+// NicknameFor returns a valid nickname for a host known by name elsewhere (ex.
+// in an inventory being imported). name is returned as is if it is a valid
+// nickname. Otherwise, as with hosts named by IP address, it is prefixed with
+// "host-". An empty name is returned as is.
+func NicknameFor(name string) string {
+	if len(name) < 1 || ValidateNickname(name) == nil {
+		return name
+	}
+
+	return "host-" + name
+}
+
 // ValidateId runs checks against the passed id as a string.
 //
 // If the tests pass and the id is valid, nil is returned.
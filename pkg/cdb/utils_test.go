@@ -173,6 +173,31 @@ func TestValidateNickname(t *testing.T) {
 	}
 }
 
+func TestNicknameFor(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"demo", "demo"},
+		{"10.0.0.5", "host-10.0.0.5"},
+		{"::1", "host-::1"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NicknameFor(tt.name)
+
+			if got != tt.want {
+				t.Errorf("NicknameFor() = %q, want %q", got, tt.want)
+			}
+
+			if got != "" && ValidateNickname(got) != nil {
+				t.Errorf("NicknameFor() = %q, not a valid nickname", got)
+			}
+		})
+	}
+}
+
 func TestValidateId(t *testing.T) {
 	type args struct {
 		id string
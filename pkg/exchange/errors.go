@@ -0,0 +1,7 @@
+package exchange
+
+import "errors"
+
+var ErrFormatTooNew = errors.New("document format version is newer than supported")
+var ErrInvalidDocument = errors.New("unexpected document structure")
+var ErrNoFormatVersion = errors.New("document has no format version")
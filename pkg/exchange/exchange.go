@@ -0,0 +1,112 @@
+// Package exchange defines the versioned document format sshcm uses to import
+// and export connections.
+//
+// A Document is an envelope holding the format version, the connection DB
+// schema version it was exported from, program defaults and connections. The
+// same types are used for the json, yaml and toml formats, and Connection on
+// its own is used for newline-delimited JSON. A JSON Schema for the format is
+// generated from the types by Schema.
+package exchange
+
+import (
+	"github.com/cannable/sshcm/pkg/cdb"
+)
+
+// FormatVersion is the current version of the document format. It is
+// incremented when a change is made that older versions of sshcm can't read.
+//
+// Version 2 added the jump, pre_hook, post_hook, record and range connection
+// properties, and the hook and record_dir defaults. Version 1 documents are
+// still read.
+const FormatVersion = 2
+
+// A Connection is the exported form of a connection.
+type Connection struct {
+	Id          int64  `json:"id" yaml:"id" toml:"id" desc:"Connection id in the exporting connection DB. Ignored on import, where connections are matched by nickname."`
+	Nickname    string `json:"nickname" yaml:"nickname" toml:"nickname" desc:"Unique connection nickname. Must start with a letter." schema:"required"`
+	User        string `json:"user" yaml:"user" toml:"user" desc:"User name to connect as."`
+	Host        string `json:"host" yaml:"host" toml:"host" desc:"Host name or IP address to connect to." schema:"required"`
+	Description string `json:"description" yaml:"description" toml:"description" desc:"Free-form description of the connection."`
+	Args        string `json:"args" yaml:"args" toml:"args" desc:"Additional arguments passed to ssh, split like a shell command line."`
+	Identity    string `json:"identity" yaml:"identity" toml:"identity" desc:"Identity (private key) file passed to ssh with -i."`
+	Command     string `json:"command" yaml:"command" toml:"command" desc:"ssh command to run (ex. ssh or sftp)."`
+	Jump        string `json:"jump" yaml:"jump" toml:"jump" desc:"Comma-separated nicknames of the connections to jump through, in order (like ssh -J)."`
+	PreHook     string `json:"pre_hook" yaml:"pre_hook" toml:"pre_hook" desc:"Local shell command run before connecting."`
+	PostHook    string `json:"post_hook" yaml:"post_hook" toml:"post_hook" desc:"Local shell command run after the ssh session ends."`
+	Record      bool   `json:"record" yaml:"record" toml:"record" desc:"Record the terminal output of every session, as with connect --record."`
+	Range       string `json:"range" yaml:"range" toml:"range" desc:"Numbers a pattern connection stands for (ex. 1-40). {n} in the nickname and other settings is replaced by each number, and {n:02} zero-pads it."`
+}
+
+// A Document is an import/export file.
+type Document struct {
+	FormatVersion int               `json:"format_version" yaml:"format_version" toml:"format_version" desc:"Version of the document format." schema:"required"`
+	SchemaVersion string            `json:"schema_version" yaml:"schema_version" toml:"schema_version" desc:"Schema version of the connection DB the document was exported from (ex. v1.1)."`
+	Defaults      map[string]string `json:"defaults" yaml:"defaults" toml:"defaults" desc:"Program default settings, by name."`
+	Connections   []Connection      `json:"connections" yaml:"connections" toml:"connections" desc:"Connections." schema:"required"`
+}
+
+// NewDocument returns an empty Document for the current format version and
+// connection DB schema version.
+func NewDocument() *Document {
+	return &Document{
+		FormatVersion: FormatVersion,
+		SchemaVersion: cdb.SchemaVersion,
+		Defaults:      make(map[string]string),
+		Connections:   []Connection{},
+	}
+}
+
+// FromConnection returns the exported form of a connection.
+func FromConnection(c *cdb.Connection) Connection {
+	return Connection{
+		Id:          c.Id,
+		Nickname:    c.Nickname,
+		User:        c.User,
+		Host:        c.Host,
+		Description: c.Description,
+		Args:        c.Args,
+		Identity:    c.Identity,
+		Command:     c.Command,
+		Jump:        c.Jump,
+		PreHook:     c.PreHook,
+		PostHook:    c.PostHook,
+		Record:      c.Record,
+		Range:       c.Range,
+	}
+}
+
+// ToConnection returns a new, detached cdb.Connection with the exported
+// connection's properties.
+func (r Connection) ToConnection() cdb.Connection {
+	c := cdb.NewConnection()
+
+	c.Id = r.Id
+	c.Nickname = r.Nickname
+	c.User = r.User
+	c.Host = r.Host
+	c.Description = r.Description
+	c.Args = r.Args
+	c.Identity = r.Identity
+	c.Command = r.Command
+	c.Jump = r.Jump
+	c.PreHook = r.PreHook
+	c.PostHook = r.PostHook
+	c.Record = r.Record
+	c.Range = r.Range
+
+	return c
+}
+
+// CheckVersion returns an error if a document's format version can't be read
+// by this version of sshcm. Documents without a format version are rejected.
+func CheckVersion(version int) error {
+	if version < 1 {
+		return ErrNoFormatVersion
+	}
+
+	if version > FormatVersion {
+		return ErrFormatTooNew
+	}
+
+	return nil
+}
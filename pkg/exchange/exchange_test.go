@@ -0,0 +1,168 @@
+package exchange
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the published JSON Schema")
+
+// decodeAll decodes a document, returning its defaults and connections.
+func decodeAll(s string) (map[string]string, []Connection, error) {
+	var defs map[string]string
+	var cns []Connection
+
+	err := Decode(strings.NewReader(s), Handler{
+		Defaults: func(d map[string]string) error {
+			defs = d
+			return nil
+		},
+		Connection: func(c Connection) error {
+			cns = append(cns, c)
+			return nil
+		},
+	})
+
+	return defs, cns, err
+}
+
+func TestEncoderDecode(t *testing.T) {
+	want := []Connection{
+		{Id: 1, Nickname: "something", Host: "somewhere", User: "me"},
+		{Id: 2, Nickname: "else", Host: "elsewhere", Args: "-p 2222"},
+	}
+
+	var b bytes.Buffer
+
+	enc, err := NewEncoder(&b, map[string]string{"user": "asdf"})
+
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+
+	for _, c := range want {
+		if err := enc.Encode(c); err != nil {
+			t.Fatalf("Encoder.Encode() error = %v", err)
+		}
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Encoder.Close() error = %v", err)
+	}
+
+	defs, got, err := decodeAll(b.String())
+
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if defs["user"] != "asdf" {
+		t.Errorf("Decode() defaults = %v", defs)
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("Decode() connections = %v, want %v", got, want)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		wantDefs map[string]string
+		wantErr  error
+	}{
+		{
+			name: "legacy",
+			doc:  `[{"Id": 3, "Nickname": "something", "Host": "somewhere", "Binary": ""}]`,
+		},
+		{
+			name:     "version-last",
+			doc:      `{"defaults": {"user": "me"}, "connections": [{"nickname": "something", "host": "somewhere"}], "format_version": 1}`,
+			wantDefs: map[string]string{"user": "me"},
+		},
+		{
+			name:    "no-version",
+			doc:     `{"connections": []}`,
+			wantErr: ErrNoFormatVersion,
+		},
+		{
+			name:    "too-new",
+			doc:     `{"format_version": 99, "connections": []}`,
+			wantErr: ErrFormatTooNew,
+		},
+		{
+			name:    "unknown-key",
+			doc:     `{"format_version": 1, "conections": []}`,
+			wantErr: ErrInvalidDocument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defs, cns, err := decodeAll(tt.doc)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Decode() error = %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			if len(cns) != 1 || cns[0].Nickname != "something" || cns[0].Host != "somewhere" {
+				t.Errorf("Decode() connections = %v", cns)
+			}
+
+			if !maps.Equal(defs, tt.wantDefs) {
+				t.Errorf("Decode() defaults = %v, want %v", defs, tt.wantDefs)
+			}
+		})
+	}
+}
+
+func TestDecode_UnknownConnectionField(t *testing.T) {
+	_, _, err := decodeAll(`{"format_version": 1, "connections": [{"nickname": "something", "hots": "x"}]}`)
+
+	if err == nil || !strings.Contains(err.Error(), "connection 1") {
+		t.Errorf("Decode() error = %v, want an error for connection 1", err)
+	}
+}
+
+// TestSchema checks that the published JSON Schema matches the types. Run
+// "go test ./pkg/exchange -update" to regenerate it after changing them.
+func TestSchema(t *testing.T) {
+	path := filepath.Join("..", "..", "schema", "export.schema.json")
+
+	got, err := MarshalSchema()
+
+	if err != nil {
+		t.Fatalf("MarshalSchema() error = %v", err)
+	}
+
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s is out of date, run go test ./pkg/exchange -update", path)
+	}
+}
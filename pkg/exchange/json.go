@@ -0,0 +1,251 @@
+package exchange
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+// An Encoder streams a Document in JSON format: the envelope is written
+// first, then each connection as it is encoded, one per line.
+type Encoder struct {
+	w io.Writer
+	n int
+}
+
+// NewEncoder writes the start of a Document, including the passed program
+// defaults, to w and returns an Encoder for its connections. Close must be
+// called to finish the document.
+func NewEncoder(w io.Writer, defaults map[string]string) (*Encoder, error) {
+	doc := NewDocument()
+
+	if defaults != nil {
+		doc.Defaults = defaults
+	}
+
+	// Encode the envelope without connections, then leave the connections
+	// array open
+	header, err := json.Marshal(struct {
+		FormatVersion int               `json:"format_version"`
+		SchemaVersion string            `json:"schema_version"`
+		Defaults      map[string]string `json:"defaults"`
+	}{doc.FormatVersion, doc.SchemaVersion, doc.Defaults})
+
+	if err != nil {
+		return nil, err
+	}
+
+	header = append(header[:len(header)-1], `,"connections":[`...)
+
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &Encoder{w: w}, nil
+}
+
+// Encode writes a connection to the document.
+func (e *Encoder) Encode(c Connection) error {
+	out, err := json.Marshal(c)
+
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+
+	if e.n == 0 {
+		sep = "\n"
+	}
+
+	e.n++
+
+	if _, err := io.WriteString(e.w, sep); err != nil {
+		return err
+	}
+
+	_, err = e.w.Write(out)
+
+	return err
+}
+
+// Close finishes the document.
+func (e *Encoder) Close() error {
+	_, err := io.WriteString(e.w, "\n]}\n")
+
+	return err
+}
+
+// A Handler receives the contents of a document as it is decoded.
+type Handler struct {
+	// Defaults is called with the document's program defaults, if it has
+	// any.
+	Defaults func(map[string]string) error
+
+	// Connection is called with each connection, in document order.
+	Connection func(Connection) error
+}
+
+// Decode reads a JSON document, passing its contents to h as they are
+// decoded, so that large documents don't need to be held in memory.
+//
+// Both the Document envelope and the older bare array of connections (as
+// written by sshcm before the envelope was introduced) are accepted. Unknown
+// properties are rejected in envelope documents, so that typos don't go
+// unnoticed. Errors decoding or handling a connection are wrapped with its
+// position in the document.
+func Decode(r io.Reader, h Handler) error {
+	d := json.NewDecoder(r)
+
+	tok, err := d.Token()
+
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('['):
+		// Bare arrays used Go field names and included fields that aren't
+		// part of the format any more
+		return decodeConnections(d, h)
+	case json.Delim('{'):
+	default:
+		return fmt.Errorf("%w: expected an object or array", ErrInvalidDocument)
+	}
+
+	d.DisallowUnknownFields()
+
+	version := 0
+
+	// Members that came before the format version are decoded once it's
+	// known
+	pending := make(map[string]json.RawMessage)
+
+	for d.More() {
+		tok, err := d.Token()
+
+		if err != nil {
+			return err
+		}
+
+		key, _ := tok.(string)
+
+		switch key {
+		case "format_version":
+			if err := d.Decode(&version); err != nil {
+				return err
+			}
+
+			if err := CheckVersion(version); err != nil {
+				return err
+			}
+		case "schema_version":
+			var schema string
+
+			if err := d.Decode(&schema); err != nil {
+				return err
+			}
+		case "defaults", "connections":
+			if version == 0 {
+				var raw json.RawMessage
+
+				if err := d.Decode(&raw); err != nil {
+					return err
+				}
+
+				pending[key] = raw
+
+				continue
+			}
+
+			if err := decodeMember(d, key, h); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: unknown key %s", ErrInvalidDocument, key)
+		}
+	}
+
+	if _, err := d.Token(); err != nil {
+		return err
+	}
+
+	if version == 0 {
+		return ErrNoFormatVersion
+	}
+
+	// Defaults sort before connections
+	for _, key := range slices.Sorted(maps.Keys(pending)) {
+		pd := json.NewDecoder(bytes.NewReader(pending[key]))
+		pd.DisallowUnknownFields()
+
+		if err := decodeMember(pd, key, h); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeMember decodes the value of a defaults or connections member of a
+// Document.
+func decodeMember(d *json.Decoder, key string, h Handler) error {
+	if key == "defaults" {
+		var defs map[string]string
+
+		if err := d.Decode(&defs); err != nil {
+			return err
+		}
+
+		if h.Defaults == nil || len(defs) == 0 {
+			return nil
+		}
+
+		return h.Defaults(defs)
+	}
+
+	tok, err := d.Token()
+
+	if err != nil {
+		return err
+	}
+
+	if tok != json.Delim('[') {
+		return fmt.Errorf("%w: connections must be an array", ErrInvalidDocument)
+	}
+
+	return decodeConnections(d, h)
+}
+
+// decodeConnections decodes connections from an array whose opening bracket
+// has been read, up to and including the closing bracket.
+func decodeConnections(d *json.Decoder, h Handler) error {
+	n := 0
+
+	for d.More() {
+		n++
+
+		var c Connection
+
+		if err := d.Decode(&c); err != nil {
+			return fmt.Errorf("connection %d: %w", n, err)
+		}
+
+		if h.Connection == nil {
+			continue
+		}
+
+		if err := h.Connection(c); err != nil {
+			return fmt.Errorf("connection %d: %w", n, err)
+		}
+	}
+
+	_, err := d.Token()
+
+	return err
+}
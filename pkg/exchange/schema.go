@@ -0,0 +1,90 @@
+package exchange
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// SchemaID is the identifier of the published JSON Schema for documents.
+const SchemaID = "https://github.com/cannable/sshcm/schema/export.schema.json"
+
+// Schema returns a JSON Schema (draft 2020-12) describing the JSON form of a
+// Document. It is generated from the Document and Connection types: property
+// names come from their json tags, descriptions from desc tags, and required
+// properties are marked with schema:"required". format_version is limited to
+// the versions this package reads.
+func Schema() map[string]any {
+	s := typeSchema(reflect.TypeFor[Document]())
+
+	version := s["properties"].(map[string]any)["format_version"].(map[string]any)
+	version["minimum"] = 1
+	version["maximum"] = FormatVersion
+
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["$id"] = SchemaID
+	s["title"] = "sshcm connection export"
+
+	return s
+}
+
+// MarshalSchema returns the JSON Schema for documents as indented JSON, as
+// published in schema/export.schema.json.
+func MarshalSchema() ([]byte, error) {
+	out, err := json.MarshalIndent(Schema(), "", "  ")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(out, '\n'), nil
+}
+
+// typeSchema returns the JSON Schema for a Go type.
+func typeSchema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		props := make(map[string]any)
+		required := []string{}
+
+		for i := range t.NumField() {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+
+			p := typeSchema(f.Type)
+
+			if desc := f.Tag.Get("desc"); len(desc) > 0 {
+				p["description"] = desc
+			}
+
+			props[name] = p
+
+			if f.Tag.Get("schema") == "required" {
+				required = append(required, name)
+			}
+		}
+
+		return map[string]any{
+			"type":                 "object",
+			"properties":           props,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+
+	return map[string]any{}
+}
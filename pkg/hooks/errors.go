@@ -0,0 +1,6 @@
+package hooks
+
+import "errors"
+
+var ErrHookFailed = errors.New("hook failed")
+var ErrHookTimeout = errors.New("hook timed out")
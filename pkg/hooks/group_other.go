@@ -0,0 +1,11 @@
+//go:build !unix
+
+package hooks
+
+import "os/exec"
+
+// ownGroup does nothing, as process groups aren't available on this platform.
+// Only the shell is killed when a hook times out.
+func ownGroup(cmd *exec.Cmd) func() {
+	return func() {}
+}
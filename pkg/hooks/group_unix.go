@@ -0,0 +1,52 @@
+//go:build unix
+
+package hooks
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// ownGroup makes cmd run in a process group of its own, which is killed as a
+// whole when cmd's context is done, so that commands the hook started (ex. the
+// sleep in "vpn-up; sleep 30") don't outlive it.
+//
+// If sshcm is in the foreground of the terminal, the hook's group takes the
+// foreground while it runs, so that the hook can still read from the terminal
+// (ex. a passphrase prompt). The returned function gives the terminal back,
+// and must be called once cmd has finished.
+func ownGroup(cmd *exec.Cmd) func() {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+
+	fd := int(os.Stdin.Fd())
+
+	if !term.IsTerminal(fd) {
+		return func() {}
+	}
+
+	pgrp := unix.Getpgrp()
+
+	if fg, err := unix.IoctlGetInt(fd, unix.TIOCGPGRP); err != nil || fg != pgrp {
+		return func() {}
+	}
+
+	// Ctty is the descriptor in the child, where the terminal is stdin
+	cmd.SysProcAttr.Foreground = true
+	cmd.SysProcAttr.Ctty = 0
+
+	return func() {
+		// Taking the foreground from the background raises SIGTTOU
+		signal.Ignore(syscall.SIGTTOU)
+		defer signal.Reset(syscall.SIGTTOU)
+
+		unix.IoctlSetPointerInt(fd, unix.TIOCSPGRP, pgrp)
+	}
+}
@@ -0,0 +1,90 @@
+// Package hooks runs the local commands (hooks) sshcm runs before connecting
+// and after a session ends, with the connection exposed to them as SSHCM_*
+// environment variables.
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/cannable/sshcm/pkg/cdb"
+)
+
+// Hook phases, as passed to hooks in SSHCM_HOOK.
+const (
+	Pre  = "pre"
+	Post = "post"
+)
+
+// Env returns the environment variables that expose a connection to hooks
+// (ex. SSHCM_NICKNAME=web), as "key=value" strings.
+func Env(c cdb.Connection) []string {
+	return []string{
+		"SSHCM_ID=" + strconv.FormatInt(c.Id, 10),
+		"SSHCM_NICKNAME=" + c.Nickname,
+		"SSHCM_HOST=" + c.Host,
+		"SSHCM_USER=" + c.User,
+		"SSHCM_DESCRIPTION=" + c.Description,
+		"SSHCM_ARGS=" + c.Args,
+		"SSHCM_IDENTITY=" + c.Identity,
+		"SSHCM_COMMAND=" + c.Command,
+		"SSHCM_JUMP=" + c.Jump,
+	}
+}
+
+// shell returns the command that runs command with the system shell.
+func shell(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+
+	return exec.CommandContext(ctx, "/bin/sh", "-c", command)
+}
+
+// killWait is how long Run waits for a killed hook's output to be closed (ex.
+// by a background command that escaped its process group).
+const killWait = time.Second
+
+// Run runs a hook command with the system shell, attached to the terminal,
+// with env added to the current environment. If timeout is more than zero,
+// the hook is killed once it has run for that long, along with the commands it
+// started (see ownGroup).
+//
+// ErrHookFailed is returned if the hook can't be run or exits with a non-zero
+// status, and ErrHookTimeout if it runs out of time.
+func Run(ctx context.Context, command string, env []string, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := shell(ctx, command)
+
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.WaitDelay = killWait
+
+	restore := ownGroup(cmd)
+	err := cmd.Run()
+	restore()
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s: %s", ErrHookTimeout, timeout, command)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrHookFailed, command, err)
+	}
+
+	return nil
+}
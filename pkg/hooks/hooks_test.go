@@ -0,0 +1,91 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cannable/sshcm/pkg/cdb"
+)
+
+func TestEnv(t *testing.T) {
+	env := Env(cdb.Connection{Id: 4, Nickname: "web", Host: "10.0.1.1", User: "ops", Jump: "bastion"})
+
+	for _, want := range []string{
+		"SSHCM_ID=4",
+		"SSHCM_NICKNAME=web",
+		"SSHCM_HOST=10.0.1.1",
+		"SSHCM_USER=ops",
+		"SSHCM_IDENTITY=",
+		"SSHCM_JUMP=bastion",
+	} {
+		if !slices.Contains(env, want) {
+			t.Errorf("Env() = %q, missing %q", env, want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook tests use a POSIX shell")
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		timeout time.Duration
+		wantErr error
+	}{
+		{"success", `exit 0`, 0, nil},
+		{"env", `test "$SSHCM_NICKNAME" = web && test "$SSHCM_HOOK" = pre`, 0, nil},
+		{"failure", `exit 3`, 0, ErrHookFailed},
+		{"missing", `/nonexistent/hook`, 0, ErrHookFailed},
+		{"timeout", `exec sleep 5`, 50 * time.Millisecond, ErrHookTimeout},
+		{"timeout-child", `sleep 5; true`, 50 * time.Millisecond, ErrHookTimeout},
+	}
+
+	env := append(Env(cdb.Connection{Nickname: "web"}), "SSHCM_HOOK="+Pre)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Run(context.Background(), tt.command, env, tt.timeout)
+
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Errorf("Run() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRun_TimeoutKillsChildren(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook tests use a POSIX shell")
+	}
+
+	marker := filepath.Join(t.TempDir(), "marker")
+
+	// The inner shell is a child of the hook's shell, not exec'd in its place
+	command := `sh -c 'sleep 0.3; touch "$MARKER"'; true`
+	started := time.Now()
+
+	err := Run(context.Background(), command, []string{"MARKER=" + marker}, 50*time.Millisecond)
+
+	if !errors.Is(err, ErrHookTimeout) {
+		t.Fatalf("Run() error = %v, want %v", err, ErrHookTimeout)
+	}
+
+	if elapsed := time.Since(started); elapsed > 250*time.Millisecond {
+		t.Errorf("Run() returned after %v, want it to return on timeout", elapsed)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("the hook's child kept running after the timeout")
+	}
+}
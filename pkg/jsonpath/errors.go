@@ -0,0 +1,6 @@
+package jsonpath
+
+import "errors"
+
+var ErrInvalidPath = errors.New("invalid JSONPath expression")
+var ErrInvalidMapping = errors.New("invalid field mapping")
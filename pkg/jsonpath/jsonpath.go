@@ -0,0 +1,272 @@
+// Package jsonpath implements the subset of JSONPath needed to pick values
+// out of decoded JSON documents: member access (.name or ['name']), array
+// indexes ([0], [-1]) and wildcards (.* or [*]).
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A segment is a single step of a path. A segment either selects a member
+// of an object by key, an element of an array by index, or every child of an
+// object or array.
+type segment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// A Path is a parsed JSONPath expression.
+type Path struct {
+	expr     string
+	segments []segment
+}
+
+// Parse parses a JSONPath expression. Expressions start with $, which refers
+// to the value the path is applied to. The leading "$." may be left out (ex.
+// "tags.ssh_user" is the same as "$.tags.ssh_user").
+func Parse(expr string) (Path, error) {
+	p := Path{expr: expr}
+	s := strings.TrimSpace(expr)
+
+	invalid := func(msg string) error {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidPath, expr, msg)
+	}
+
+	if strings.HasPrefix(s, "$") {
+		s = s[1:]
+	} else if len(s) > 0 && s[0] != '[' {
+		s = "." + s
+	}
+
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+
+			end := strings.IndexAny(s, ".[")
+
+			if end < 0 {
+				end = len(s)
+			}
+
+			name := s[:end]
+			s = s[end:]
+
+			if len(name) < 1 {
+				return p, invalid("empty member name")
+			}
+
+			if name == "*" {
+				p.segments = append(p.segments, segment{wildcard: true})
+			} else {
+				p.segments = append(p.segments, segment{key: name})
+			}
+		case '[':
+			end := closingBracket(s)
+
+			if end < 0 {
+				return p, invalid("unterminated [")
+			}
+
+			sel := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+
+			switch {
+			case sel == "*":
+				p.segments = append(p.segments, segment{wildcard: true})
+			case len(sel) >= 2 && (sel[0] == '\'' || sel[0] == '"') && sel[len(sel)-1] == sel[0]:
+				p.segments = append(p.segments, segment{key: unescape(sel[1 : len(sel)-1])})
+			default:
+				i, err := strconv.Atoi(sel)
+
+				if err != nil {
+					return p, invalid("expected an index, * or quoted name in []")
+				}
+
+				p.segments = append(p.segments, segment{index: i, isIndex: true})
+			}
+		default:
+			return p, invalid(fmt.Sprintf("unexpected %q", s[0]))
+		}
+	}
+
+	return p, nil
+}
+
+// A Field is a named path in a mapping.
+type Field struct {
+	Name string
+	Path Path
+}
+
+// ParseMapping parses a comma-separated list of name=path pairs (ex.
+// "nickname=$.name,host=$.public_ip"). Commas inside brackets are part of the
+// path. Fields are returned in the order they were listed.
+func ParseMapping(spec string) ([]Field, error) {
+	var fields []Field
+
+	depth := 0
+	start := 0
+
+	for i := 0; i <= len(spec); i++ {
+		if i < len(spec) {
+			switch spec[i] {
+			case '[':
+				depth++
+				continue
+			case ']':
+				depth--
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+
+		pair := strings.TrimSpace(spec[start:i])
+		start = i + 1
+
+		if len(pair) < 1 {
+			continue
+		}
+
+		name, expr, found := strings.Cut(pair, "=")
+
+		if !found || len(strings.TrimSpace(name)) < 1 {
+			return nil, fmt.Errorf("%w: expected name=path, got %s", ErrInvalidMapping, pair)
+		}
+
+		p, err := Parse(expr)
+
+		if err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, Field{Name: strings.TrimSpace(name), Path: p})
+	}
+
+	return fields, nil
+}
+
+// closingBracket returns the index of the ] that closes the [ at the start of
+// s, skipping over quoted names. -1 is returned if there isn't one.
+func closingBracket(s string) int {
+	var quote byte
+
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote != 0 && s[i] == '\\':
+			i++
+		case quote != 0 && s[i] == quote:
+			quote = 0
+		case quote != 0:
+		case s[i] == '\'' || s[i] == '"':
+			quote = s[i]
+		case s[i] == ']':
+			return i
+		}
+	}
+
+	return -1
+}
+
+// unescape removes backslash escapes from a quoted member name.
+func unescape(s string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// String returns the expression the path was parsed from.
+func (p Path) String() string {
+	return p.expr
+}
+
+// Get returns every value the path selects from v, which should be a
+// document decoded by encoding/json into an any. Paths without wildcards
+// select at most one value. Members and elements that don't exist are
+// skipped, so no values are returned if nothing matches.
+func (p Path) Get(v any) []any {
+	values := []any{v}
+
+	for _, seg := range p.segments {
+		var next []any
+
+		for _, v := range values {
+			switch v := v.(type) {
+			case map[string]any:
+				if seg.wildcard {
+					for _, k := range sortedKeys(v) {
+						next = append(next, v[k])
+					}
+				} else if child, ok := v[seg.key]; ok && !seg.isIndex {
+					next = append(next, child)
+				}
+			case []any:
+				if seg.wildcard {
+					next = append(next, v...)
+				} else if seg.isIndex {
+					i := seg.index
+
+					if i < 0 {
+						i += len(v)
+					}
+
+					if i >= 0 && i < len(v) {
+						next = append(next, v[i])
+					}
+				}
+			}
+		}
+
+		values = next
+	}
+
+	return values
+}
+
+// sortedKeys returns the keys of an object in sorted order, so that
+// wildcards select members in a predictable order. encoding/json doesn't keep
+// the document order of members.
+func sortedKeys(m map[string]any) []string {
+	return slices.Sorted(maps.Keys(m))
+}
+
+// Format returns a selected value as a string. Strings are returned as-is,
+// null as an empty string, and anything else in its JSON form.
+func Format(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+
+	out, err := json.Marshal(v)
+
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(out)
+}
@@ -0,0 +1,108 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testDoc = `{
+	"name": "something",
+	"ip": "10.0.0.1",
+	"port": 2222,
+	"tags": {"ssh_user": "me", "odd.key": "x"},
+	"hosts": [{"name": "a"}, {"name": "b"}, {"name": "c"}]
+}`
+
+func decodeTestDoc(t *testing.T) any {
+	t.Helper()
+
+	d := json.NewDecoder(strings.NewReader(testDoc))
+	d.UseNumber()
+
+	var doc any
+
+	if err := d.Decode(&doc); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	return doc
+}
+
+func TestPath_Get(t *testing.T) {
+	doc := decodeTestDoc(t)
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"$.name", []string{"something"}},
+		{"name", []string{"something"}},
+		{"$.port", []string{"2222"}},
+		{"$.tags.ssh_user", []string{"me"}},
+		{"$.tags['odd.key']", []string{"x"}},
+		{"$['tags'][\"ssh_user\"]", []string{"me"}},
+		{"$.hosts[1].name", []string{"b"}},
+		{"$.hosts[-1].name", []string{"c"}},
+		{"$.hosts[*].name", []string{"a", "b", "c"}},
+		{"$.hosts.*.name", []string{"a", "b", "c"}},
+		{"$.hosts[5].name", nil},
+		{"$.missing", nil},
+		{"$.tags", []string{`{"odd.key":"x","ssh_user":"me"}`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			p, err := Parse(tt.expr)
+
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			var got []string
+
+			for _, v := range p.Get(doc) {
+				got = append(got, Format(v))
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Path.Get() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{"$.", "$.a..b", "$[", "$[x]", "$x"} {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := Parse(expr); !errors.Is(err, ErrInvalidPath) {
+				t.Errorf("Parse() error = %v, want %v", err, ErrInvalidPath)
+			}
+		})
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+	fields, err := ParseMapping("nickname=$.name, host=$['a,b'],user=tags.ssh_user")
+
+	if err != nil {
+		t.Fatalf("ParseMapping() error = %v", err)
+	}
+
+	var got []string
+
+	for _, f := range fields {
+		got = append(got, f.Name+"="+f.Path.String())
+	}
+
+	want := []string{"nickname=$.name", "host=$['a,b']", "user=tags.ssh_user"}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseMapping() = %v, want %v", got, want)
+	}
+
+	if _, err := ParseMapping("nickname"); !errors.Is(err, ErrInvalidMapping) {
+		t.Errorf("ParseMapping() error = %v, want %v", err, ErrInvalidMapping)
+	}
+}
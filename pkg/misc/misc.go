@@ -1,11 +1,45 @@
 package misc
 
 import (
-	"fmt"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
 )
 
+// Ellipsis is appended to strings that have been truncated.
+const Ellipsis = "…"
+
+// StringTrimmer pads or truncates s so that it is exactly len terminal cells
+// wide. Truncated strings end with an ellipsis.
 func StringTrimmer(s string, len int) string {
-	f := fmt.Sprintf("%-*s", len, s)
+	return PadRight(Truncate(s, len), len)
+}
+
+// DisplayWidth returns the number of terminal cells needed to display s.
+// Wide characters (ex. CJK) take two cells, combining characters take none.
+func DisplayWidth(s string) int {
+	return runewidth.StringWidth(s)
+}
+
+// PadRight pads s with spaces until it is at least width terminal cells wide.
+func PadRight(s string, width int) string {
+	if w := DisplayWidth(s); w < width {
+		return s + strings.Repeat(" ", width-w)
+	}
+
+	return s
+}
+
+// Truncate shortens s so that it fits within width terminal cells. If s has to
+// be shortened, it is cut on a character boundary and ends with an ellipsis.
+func Truncate(s string, width int) string {
+	if width < 1 {
+		return ""
+	}
+
+	if DisplayWidth(s) <= width {
+		return s
+	}
 
-	return f[:len]
+	return runewidth.Truncate(s, width, Ellipsis)
 }
@@ -0,0 +1,50 @@
+package misc
+
+import "testing"
+
+func TestStringTrimmer(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		len  int
+		want string
+	}{
+		{
+			name: "pad",
+			s:    "ID",
+			len:  4,
+			want: "ID  ",
+		},
+		{
+			name: "exact",
+			s:    "host",
+			len:  4,
+			want: "host",
+		},
+		{
+			name: "truncate",
+			s:    "something",
+			len:  5,
+			want: "some…",
+		},
+		{
+			name: "multibyte",
+			s:    "Ünïcödé",
+			len:  4,
+			want: "Ünï…",
+		},
+		{
+			name: "wide",
+			s:    "日本語",
+			len:  4,
+			want: "日… ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StringTrimmer(tt.s, tt.len); got != tt.want {
+				t.Errorf("StringTrimmer() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+package sessions
+
+import "errors"
+
+var ErrInvalidSessionFile = errors.New("invalid session file")
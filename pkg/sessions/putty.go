@@ -0,0 +1,356 @@
+package sessions
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// puttySessionsKey is the registry key PuTTY keeps saved sessions under.
+const puttySessionsKey = `HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions\`
+
+// puttyDefaultSession is the name of the session PuTTY uses to hold default
+// settings. It isn't imported.
+const puttyDefaultSession = "Default Settings"
+
+// PuTTY proxy methods, as stored in the ProxyMethod value.
+const (
+	puttyProxyNone = iota
+	puttyProxySOCKS4
+	puttyProxySOCKS5
+	puttyProxyHTTP
+	puttyProxyTelnet
+	puttyProxyCommand
+	puttyProxySSH
+)
+
+// puttyProxyTypes maps PuTTY proxy methods to proxy types.
+var puttyProxyTypes = map[int]ProxyType{
+	puttyProxyNone:    ProxyNone,
+	puttyProxySOCKS4:  ProxySOCKS4,
+	puttyProxySOCKS5:  ProxySOCKS5,
+	puttyProxyHTTP:    ProxyHTTP,
+	puttyProxyCommand: ProxyCommand,
+	puttyProxySSH:     ProxyJump,
+}
+
+// puttyCommandTokens maps the tokens PuTTY substitutes in a local proxy
+// command to their ssh ProxyCommand equivalents.
+var puttyCommandTokens = [][2]string{
+	{"%host", "%h"},
+	{"%port", "%p"},
+	{"%user", "%r"},
+}
+
+// decodeRegFile returns the text of a registry file. regedit writes UTF-16
+// with a byte order mark, while older or hand-written files are plain text.
+func decodeRegFile(data []byte) string {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xfe {
+		return string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	}
+
+	data = data[2:]
+	units := make([]uint16, len(data)/2)
+
+	for i := range units {
+		units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+	}
+
+	return string(utf16.Decode(units))
+}
+
+// unescapeSessionName decodes the %XX escapes PuTTY uses in session key names.
+func unescapeSessionName(s string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+
+				continue
+			}
+		}
+
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// escapeSessionName escapes a session name the way PuTTY does for registry
+// key names.
+func escapeSessionName(s string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if c == ' ' || c == '\\' || c == '*' || c == '?' || c == '%' || c < ' ' || (i == 0 && c == '.') {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+// parseRegValue parses a registry value line (ex. "HostName"="somewhere" or
+// "PortNumber"=dword:00000016). Values of other types are returned as-is.
+func parseRegValue(line string) (string, string, error) {
+	if !strings.HasPrefix(line, `"`) {
+		return "", "", fmt.Errorf("expected a quoted value name, got %s", line)
+	}
+
+	name, rest, err := parseRegString(line)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	rest, found := strings.CutPrefix(rest, "=")
+
+	if !found {
+		return "", "", fmt.Errorf("expected = after value name %s", name)
+	}
+
+	if strings.HasPrefix(rest, `"`) {
+		value, _, err := parseRegString(rest)
+
+		return name, value, err
+	}
+
+	if hex, found := strings.CutPrefix(rest, "dword:"); found {
+		v, err := strconv.ParseUint(hex, 16, 32)
+
+		if err != nil {
+			return "", "", fmt.Errorf("invalid dword value for %s: %w", name, err)
+		}
+
+		return name, strconv.FormatUint(v, 10), nil
+	}
+
+	return name, rest, nil
+}
+
+// parseRegString parses a quoted registry string, returning the unescaped
+// string and the rest of the line.
+func parseRegString(s string) (string, string, error) {
+	var b strings.Builder
+
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+			}
+
+			b.WriteByte(s[i])
+		case '"':
+			return b.String(), s[i+1:], nil
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return "", "", fmt.Errorf("unterminated string %s", s)
+}
+
+// quoteRegString quotes a string for a registry file.
+func quoteRegString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// puttySession returns the session for a set of PuTTY session values. ok is
+// false if the session doesn't use ssh.
+func puttySession(name string, values map[string]string) (s Session, ok bool) {
+	if proto, found := values["Protocol"]; found && proto != "ssh" {
+		return s, false
+	}
+
+	atoi := func(name string) int {
+		v, _ := strconv.Atoi(values[name])
+
+		return v
+	}
+
+	s.Name = name
+	s.User, s.Host = splitUserHost(values["HostName"])
+	s.Port = atoi("PortNumber")
+	s.KeyFile = values["PublicKeyFile"]
+
+	if user := values["UserName"]; len(user) > 0 {
+		s.User = user
+	}
+
+	s.Proxy.Type = puttyProxyTypes[atoi("ProxyMethod")]
+
+	switch s.Proxy.Type {
+	case ProxyCommand:
+		cmd := values["ProxyTelnetCommand"]
+
+		for _, t := range puttyCommandTokens {
+			cmd = strings.ReplaceAll(cmd, t[0], t[1])
+		}
+
+		s.Proxy.Command = cmd
+	case ProxyNone:
+	default:
+		s.Proxy.Host = values["ProxyHost"]
+		s.Proxy.Port = atoi("ProxyPort")
+		s.Proxy.User = values["ProxyUsername"]
+	}
+
+	return s, true
+}
+
+// ReadPuTTY reads saved sessions from a PuTTY registry export. Sessions that
+// don't use ssh, along with PuTTY's default settings, are skipped.
+func ReadPuTTY(r io.Reader) ([]Session, error) {
+	data, err := io.ReadAll(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []Session
+
+	var name string
+	var values map[string]string
+
+	// flush adds the session being read, if there is one
+	flush := func() {
+		if values == nil || name == puttyDefaultSession {
+			return
+		}
+
+		if s, ok := puttySession(name, values); ok {
+			sessions = append(sessions, s)
+		}
+	}
+
+	sc := bufio.NewScanner(strings.NewReader(decodeRegFile(data)))
+	line := 0
+
+	for sc.Scan() {
+		line++
+
+		text := strings.TrimSpace(sc.Text())
+
+		if len(text) == 0 || text[0] == ';' || line == 1 {
+			continue
+		}
+
+		if text[0] == '[' {
+			flush()
+
+			key := strings.TrimSuffix(text[1:], "]")
+			values = nil
+
+			if escaped, found := strings.CutPrefix(key, puttySessionsKey); found && !strings.Contains(escaped, `\`) {
+				name = unescapeSessionName(escaped)
+				values = make(map[string]string)
+			}
+
+			continue
+		}
+
+		if values == nil {
+			continue
+		}
+
+		k, v, err := parseRegValue(text)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidSessionFile, line, err)
+		}
+
+		values[k] = v
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	flush()
+
+	return sessions, nil
+}
+
+// WritePuTTY writes sessions as a PuTTY registry export, in the UTF-16 format
+// regedit writes.
+func WritePuTTY(w io.Writer, sessions []Session) error {
+	var b strings.Builder
+
+	b.WriteString("Windows Registry Editor Version 5.00\r\n")
+
+	for _, s := range sessions {
+		str := func(name, value string) {
+			fmt.Fprintf(&b, "%s=%s\r\n", quoteRegString(name), quoteRegString(value))
+		}
+
+		dword := func(name string, value int) {
+			fmt.Fprintf(&b, "%s=dword:%08x\r\n", quoteRegString(name), value)
+		}
+
+		fmt.Fprintf(&b, "\r\n[%s%s]\r\n", puttySessionsKey, escapeSessionName(s.Name))
+
+		str("HostName", s.Host)
+		str("Protocol", "ssh")
+		dword("PortNumber", s.Port)
+		str("UserName", s.User)
+		str("PublicKeyFile", s.KeyFile)
+
+		method := puttyProxyNone
+
+		for m, t := range puttyProxyTypes {
+			if t == s.Proxy.Type {
+				method = m
+			}
+		}
+
+		dword("ProxyMethod", method)
+
+		switch s.Proxy.Type {
+		case ProxyCommand:
+			cmd := s.Proxy.Command
+
+			for _, t := range puttyCommandTokens {
+				cmd = strings.ReplaceAll(cmd, t[1], t[0])
+			}
+
+			str("ProxyTelnetCommand", cmd)
+		case ProxyNone:
+		default:
+			port := s.Proxy.Port
+
+			if port == 0 && s.Proxy.Type == ProxyJump {
+				port = DefaultPort
+			}
+
+			str("ProxyHost", s.Proxy.Host)
+			dword("ProxyPort", port)
+			str("ProxyUsername", s.Proxy.User)
+		}
+	}
+
+	units := utf16.Encode([]rune(b.String()))
+	out := make([]byte, 2, 2+2*len(units))
+
+	// Byte order mark
+	out[0], out[1] = 0xff, 0xfe
+
+	for _, u := range units {
+		out = append(out, byte(u), byte(u>>8))
+	}
+
+	_, err := w.Write(out)
+
+	return err
+}
@@ -0,0 +1,137 @@
+package sessions
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// remminaSection is the section of a .remmina file that holds its settings.
+const remminaSection = "remmina"
+
+// Remmina ssh authentication methods, as stored in the ssh_auth setting.
+const (
+	remminaAuthKeyFile = 1
+	remminaAuthAuto    = 3
+)
+
+// ReadRemmina reads a session from a Remmina connection file. ok is false if
+// the connection doesn't use ssh.
+//
+// A proxy command (ssh_proxycommand) or ssh tunnel (ssh_tunnel_server) is
+// converted to the equivalent proxy settings.
+func ReadRemmina(r io.Reader) (s Session, ok bool, err error) {
+	values := make(map[string]string)
+	sc := bufio.NewScanner(r)
+	section := ""
+	line := 0
+
+	for sc.Scan() {
+		line++
+
+		text := strings.TrimSpace(sc.Text())
+
+		if len(text) == 0 || text[0] == '#' || text[0] == ';' {
+			continue
+		}
+
+		if text[0] == '[' {
+			section = strings.Trim(text, "[]")
+			continue
+		}
+
+		if section != remminaSection {
+			continue
+		}
+
+		k, v, found := strings.Cut(text, "=")
+
+		if !found {
+			return s, false, fmt.Errorf("%w: line %d: expected key=value, got %s", ErrInvalidSessionFile, line, text)
+		}
+
+		values[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+
+	if err := sc.Err(); err != nil {
+		return s, false, err
+	}
+
+	if len(values) == 0 {
+		return s, false, fmt.Errorf("%w: no [%s] section", ErrInvalidSessionFile, remminaSection)
+	}
+
+	if !strings.EqualFold(values["protocol"], "ssh") {
+		return s, false, nil
+	}
+
+	s.Name = values["name"]
+	s.Host, s.Port = splitHostPort(values["server"])
+	s.User = values["username"]
+	s.Group = values["group"]
+
+	// The key file is only used with key file authentication, although
+	// Remmina keeps it around when another method is chosen
+	if auth := values["ssh_auth"]; auth == "" || auth == strconv.Itoa(remminaAuthKeyFile) {
+		s.KeyFile = values["ssh_privatekey"]
+	}
+
+	if cmd := values["ssh_proxycommand"]; len(cmd) > 0 {
+		s.Proxy = parseProxyCommand(cmd)
+	} else if values["ssh_tunnel_enabled"] == "1" && len(values["ssh_tunnel_server"]) > 0 {
+		s.Proxy.Type = ProxyJump
+		s.Proxy.Host, s.Proxy.Port = splitHostPort(values["ssh_tunnel_server"])
+		s.Proxy.User = values["ssh_tunnel_username"]
+	}
+
+	return s, true, nil
+}
+
+// WriteRemmina writes a session as a Remmina connection file.
+func WriteRemmina(w io.Writer, s Session) error {
+	var b strings.Builder
+
+	set := func(k, v string) {
+		fmt.Fprintf(&b, "%s=%s\n", k, v)
+	}
+
+	port := s.Port
+
+	if port == 0 {
+		port = DefaultPort
+	}
+
+	fmt.Fprintf(&b, "[%s]\n", remminaSection)
+
+	set("name", s.Name)
+	set("group", s.Group)
+	set("protocol", "SSH")
+	set("server", hostPort(s.Host, port))
+	set("username", s.User)
+
+	if len(s.KeyFile) > 0 {
+		set("ssh_auth", strconv.Itoa(remminaAuthKeyFile))
+		set("ssh_privatekey", s.KeyFile)
+	} else {
+		set("ssh_auth", strconv.Itoa(remminaAuthAuto))
+	}
+
+	switch s.Proxy.Type {
+	case ProxyNone:
+	case ProxyJump:
+		set("ssh_tunnel_enabled", "1")
+		set("ssh_tunnel_server", hostPort(s.Proxy.Host, s.Proxy.Port))
+		set("ssh_tunnel_username", s.Proxy.User)
+	default:
+		// Remmina has no native SOCKS or HTTP proxy support for ssh, so those
+		// are written as a proxy command
+		args := s.Proxy.args()
+		set("ssh_proxycommand", strings.TrimPrefix(args[len(args)-1], "ProxyCommand="))
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
@@ -0,0 +1,290 @@
+// Package sessions converts between sshcm connections and the saved session
+// formats of desktop SSH clients: PuTTY registry exports (.reg) and Remmina
+// connection files (.remmina).
+package sessions
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/cannable/sshcm/pkg/sshargs"
+)
+
+// DefaultPort is the standard ssh port. It is left out of converted
+// connection args.
+const DefaultPort = 22
+
+// A ProxyType is a way of reaching a host through a proxy.
+type ProxyType int
+
+const (
+	ProxyNone ProxyType = iota
+	ProxySOCKS4
+	ProxySOCKS5
+	ProxyHTTP
+	ProxyCommand
+	ProxyJump
+)
+
+// A Proxy holds the proxy settings of a session. Command is only used by
+// ProxyCommand, the other fields by the remaining proxy types.
+type Proxy struct {
+	Type    ProxyType
+	Host    string
+	Port    int
+	User    string
+	Command string
+}
+
+// A Session is a saved ssh session, independent of the tool it came from.
+type Session struct {
+	Name    string
+	Host    string
+	Port    int
+	User    string
+	KeyFile string
+	Group   string
+	Proxy   Proxy
+}
+
+// ncProxyVersions maps the proxy types that are reached through nc's -X
+// option to its protocol argument.
+var ncProxyVersions = map[ProxyType]string{
+	ProxySOCKS4: "4",
+	ProxySOCKS5: "5",
+	ProxyHTTP:   "connect",
+}
+
+// hostPort joins a host and, if it isn't zero, a port.
+func hostPort(host string, port int) string {
+	if port == 0 {
+		return host
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// splitHostPort splits an optional port from a host (ex. "host:2222" or
+// "[::1]:2222"). Host names with more than one colon are assumed to be IPv6
+// addresses without a port.
+func splitHostPort(s string) (string, int) {
+	host, port, err := net.SplitHostPort(s)
+
+	if err != nil {
+		return strings.Trim(s, "[]"), 0
+	}
+
+	p, err := strconv.Atoi(port)
+
+	if err != nil {
+		return s, 0
+	}
+
+	return host, p
+}
+
+// splitUserHost splits an optional user from a host (ex. "me@host").
+func splitUserHost(s string) (string, string) {
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+
+	return "", s
+}
+
+// args returns the proxy settings as ssh args.
+func (p Proxy) args() []string {
+	switch p.Type {
+	case ProxySOCKS4, ProxySOCKS5, ProxyHTTP:
+		cmd := []string{"nc", "-X", ncProxyVersions[p.Type], "-x", hostPort(p.Host, p.Port)}
+
+		if len(p.User) > 0 {
+			cmd = append(cmd, "-P", p.User)
+		}
+
+		cmd = append(cmd, "%h", "%p")
+
+		return []string{"-o", "ProxyCommand=" + sshargs.Join(cmd)}
+	case ProxyCommand:
+		return []string{"-o", "ProxyCommand=" + p.Command}
+	case ProxyJump:
+		jump := hostPort(p.Host, p.Port)
+
+		if len(p.User) > 0 {
+			jump = p.User + "@" + jump
+		}
+
+		return []string{"-J", jump}
+	}
+
+	return nil
+}
+
+// parseProxyCommand returns the proxy settings for an ssh ProxyCommand.
+// Commands that use nc to reach a SOCKS or HTTP proxy are recognized as such,
+// anything else is kept as a command.
+func parseProxyCommand(command string) Proxy {
+	generic := Proxy{Type: ProxyCommand, Command: command}
+
+	words, err := sshargs.Split(command)
+
+	if err != nil || len(words) < 1 || words[0] != "nc" {
+		return generic
+	}
+
+	p := Proxy{}
+
+	for i := 1; i < len(words); i++ {
+		switch words[i] {
+		case "-X", "-x", "-P":
+			if i+1 >= len(words) {
+				return generic
+			}
+
+			i++
+
+			switch words[i-1] {
+			case "-X":
+				for t, v := range ncProxyVersions {
+					if v == words[i] {
+						p.Type = t
+					}
+				}
+			case "-x":
+				p.Host, p.Port = splitHostPort(words[i])
+			case "-P":
+				p.User = words[i]
+			}
+		case "%h", "%p":
+		default:
+			return generic
+		}
+	}
+
+	// nc defaults to SOCKS5 when -X isn't passed
+	if p.Type == ProxyNone {
+		p.Type = ProxySOCKS5
+	}
+
+	if len(p.Host) < 1 {
+		return generic
+	}
+
+	return p
+}
+
+// parseJump returns the proxy settings for an ssh jump host. Only a single
+// jump host can be represented.
+func parseJump(jump string) (Proxy, bool) {
+	if strings.Contains(jump, ",") {
+		return Proxy{}, false
+	}
+
+	user, host := splitUserHost(jump)
+	host, port := splitHostPort(host)
+
+	return Proxy{Type: ProxyJump, Host: host, Port: port, User: user}, true
+}
+
+// ToConnection returns a connection for a session. The session name becomes
+// the nickname (prefixed if it isn't a valid nickname, see cdb.NicknameFor, as
+// with sessions named by IP address). The port and proxy settings are
+// converted to ssh args.
+func (s Session) ToConnection() cdb.Connection {
+	c := cdb.NewConnection()
+
+	c.Nickname = cdb.NicknameFor(s.Name)
+	c.Host = s.Host
+	c.User = s.User
+	c.Identity = s.KeyFile
+
+	var args []string
+
+	if s.Port != 0 && s.Port != DefaultPort {
+		args = append(args, "-p", strconv.Itoa(s.Port))
+	}
+
+	args = append(args, s.Proxy.args()...)
+
+	c.Args = sshargs.Join(args)
+
+	return c
+}
+
+// FromConnection returns a session for a connection. Callers should apply
+// program defaults to the connection first.
+//
+// The port, user, identity and proxy settings are taken from the connection's
+// args, with the connection's own user and identity taking precedence. ssh
+// options that can't be represented in a session are returned in dropped so
+// that the caller can warn about them.
+func FromConnection(c *cdb.Connection) (s Session, dropped []sshargs.Option, err error) {
+	split, err := sshargs.Split(c.Args)
+
+	if err != nil {
+		return s, nil, err
+	}
+
+	opts, _, err := sshargs.Parse(split)
+
+	if err != nil {
+		return s, nil, err
+	}
+
+	s = Session{Name: c.Nickname, Host: c.Host, Port: DefaultPort}
+
+	for _, o := range opts {
+		switch o.Flag {
+		case 'p':
+			if s.Port, err = strconv.Atoi(o.Value); err != nil {
+				return s, nil, fmt.Errorf("invalid port %s: %w", o.Value, err)
+			}
+		case 'l':
+			s.User = o.Value
+		case 'i':
+			s.KeyFile = o.Value
+		case 'J':
+			p, ok := parseJump(o.Value)
+
+			if !ok {
+				dropped = append(dropped, o)
+				continue
+			}
+
+			s.Proxy = p
+		case 'o':
+			key, value, _ := strings.Cut(o.Value, "=")
+
+			switch strings.ToLower(key) {
+			case "proxycommand":
+				s.Proxy = parseProxyCommand(value)
+			case "proxyjump":
+				p, ok := parseJump(value)
+
+				if !ok {
+					dropped = append(dropped, o)
+					continue
+				}
+
+				s.Proxy = p
+			default:
+				dropped = append(dropped, o)
+			}
+		default:
+			dropped = append(dropped, o)
+		}
+	}
+
+	if len(c.User) > 0 {
+		s.User = c.User
+	}
+
+	if len(c.Identity) > 0 {
+		s.KeyFile = c.Identity
+	}
+
+	return s, dropped, nil
+}
@@ -0,0 +1,202 @@
+package sessions
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/cannable/sshcm/pkg/sshargs"
+)
+
+func TestFromConnection(t *testing.T) {
+	tests := []struct {
+		name        string
+		conn        cdb.Connection
+		want        Session
+		wantDropped []sshargs.Option
+	}{
+		{
+			name: "plain",
+			conn: cdb.Connection{Nickname: "something", Host: "somewhere", User: "me"},
+			want: Session{Name: "something", Host: "somewhere", Port: 22, User: "me"},
+		},
+		{
+			name: "jump",
+			conn: cdb.Connection{Nickname: "something", Host: "somewhere", Args: "-p 2222 -i id -J you@jump:2200 -t"},
+			want: Session{
+				Name: "something", Host: "somewhere", Port: 2222, KeyFile: "id",
+				Proxy: Proxy{Type: ProxyJump, Host: "jump", Port: 2200, User: "you"},
+			},
+			wantDropped: []sshargs.Option{{Flag: 't'}},
+		},
+		{
+			name: "socks",
+			conn: cdb.Connection{Nickname: "something", Host: "somewhere", Args: "-o 'ProxyCommand=nc -X 4 -x proxy:1080 %h %p'"},
+			want: Session{
+				Name: "something", Host: "somewhere", Port: 22,
+				Proxy: Proxy{Type: ProxySOCKS4, Host: "proxy", Port: 1080},
+			},
+		},
+		{
+			name: "command",
+			conn: cdb.Connection{Nickname: "something", Host: "somewhere", Args: "-o 'ProxyCommand=ssh -W %h:%p gw'"},
+			want: Session{
+				Name: "something", Host: "somewhere", Port: 22,
+				Proxy: Proxy{Type: ProxyCommand, Command: "ssh -W %h:%p gw"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, dropped, err := FromConnection(&tt.conn)
+
+			if err != nil {
+				t.Fatalf("FromConnection() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("FromConnection() = %+v, want %+v", got, tt.want)
+			}
+
+			if !slices.Equal(dropped, tt.wantDropped) {
+				t.Errorf("FromConnection() dropped = %v, want %v", dropped, tt.wantDropped)
+			}
+
+			// Converting back should give the same settings
+			c := got.ToConnection()
+			back, _, err := FromConnection(&c)
+
+			if err != nil || back != got {
+				t.Errorf("Session.ToConnection() round trip = %+v, want %+v", back, got)
+			}
+		})
+	}
+}
+
+func TestSession_ToConnection(t *testing.T) {
+	tests := []struct {
+		name         string
+		s            Session
+		wantNickname string
+	}{
+		{"name", Session{Name: "my server", Host: "10.0.0.5"}, "my server"},
+		{"ip", Session{Name: "10.0.0.5", Host: "10.0.0.5"}, "host-10.0.0.5"},
+		{"ipv6", Session{Name: "::1", Host: "::1", Port: 2222}, "host-::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.s.ToConnection()
+
+			if c.Nickname != tt.wantNickname || c.Host != tt.s.Host {
+				t.Errorf("Session.ToConnection() = %q, %q, want %q, %q", c.Nickname, c.Host, tt.wantNickname, tt.s.Host)
+			}
+
+			c.Id = 1
+
+			if err := c.Validate(); err != nil {
+				t.Errorf("Session.ToConnection() is invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestReadPuTTY(t *testing.T) {
+	reg := `Windows Registry Editor Version 5.00
+
+[HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions\Default%20Settings]
+"HostName"=""
+
+[HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions\my%20server]
+"HostName"="me@somewhere"
+"PortNumber"=dword:000008ae
+"PublicKeyFile"="C:\\keys\\id.ppk"
+"ProxyMethod"=dword:00000005
+"ProxyTelnetCommand"="connect %host %port"
+
+[HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions\serial]
+"Protocol"="serial"
+`
+
+	got, err := ReadPuTTY(strings.NewReader(reg))
+
+	if err != nil {
+		t.Fatalf("ReadPuTTY() error = %v", err)
+	}
+
+	want := []Session{{
+		Name:    "my server",
+		Host:    "somewhere",
+		Port:    2222,
+		User:    "me",
+		KeyFile: `C:\keys\id.ppk`,
+		Proxy:   Proxy{Type: ProxyCommand, Command: "connect %h %p"},
+	}}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("ReadPuTTY() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPuTTYRoundTrip(t *testing.T) {
+	want := []Session{
+		{Name: "my server", Host: "somewhere", Port: 22, User: "me"},
+		{Name: "else", Host: "elsewhere", Port: 2222, Proxy: Proxy{Type: ProxyJump, Host: "jump", Port: 22, User: "you"}},
+	}
+
+	var b bytes.Buffer
+
+	if err := WritePuTTY(&b, want); err != nil {
+		t.Fatalf("WritePuTTY() error = %v", err)
+	}
+
+	// regedit files are UTF-16 with a byte order mark
+	if !bytes.HasPrefix(b.Bytes(), []byte{0xff, 0xfe}) {
+		t.Errorf("WritePuTTY() wrote no byte order mark")
+	}
+
+	got, err := ReadPuTTY(&b)
+
+	if err != nil {
+		t.Fatalf("ReadPuTTY() error = %v", err)
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("ReadPuTTY() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRemminaRoundTrip(t *testing.T) {
+	want := Session{
+		Name:    "something",
+		Host:    "::1",
+		Port:    2222,
+		User:    "me",
+		KeyFile: "/home/me/.ssh/id",
+		Group:   "servers",
+		Proxy:   Proxy{Type: ProxyHTTP, Host: "proxy", Port: 3128},
+	}
+
+	var b bytes.Buffer
+
+	if err := WriteRemmina(&b, want); err != nil {
+		t.Fatalf("WriteRemmina() error = %v", err)
+	}
+
+	got, ok, err := ReadRemmina(&b)
+
+	if err != nil || !ok {
+		t.Fatalf("ReadRemmina() ok = %v, error = %v", ok, err)
+	}
+
+	if got != want {
+		t.Errorf("ReadRemmina() = %+v, want %+v", got, want)
+	}
+
+	_, ok, err = ReadRemmina(strings.NewReader("[remmina]\nprotocol=RDP\nserver=somewhere\n"))
+
+	if err != nil || ok {
+		t.Errorf("ReadRemmina() of an RDP connection ok = %v, error = %v", ok, err)
+	}
+}
@@ -0,0 +1,146 @@
+package sshargs
+
+import (
+	"net"
+	"strings"
+)
+
+// A Hop is a jump host that an ssh connection is made through.
+type Hop struct {
+	Host     string
+	User     string
+	Identity string
+	Options  []Option // other ssh options for the hop (ex. a port)
+}
+
+// defaultPort is the port ssh connects to when none is set.
+const defaultPort = "22"
+
+// port returns the port set in the hop's options, if any.
+func (h Hop) port() string {
+	port, _ := Lookup(h.Options, 'p')
+	return port
+}
+
+// destination returns the hop's [user@]host.
+func (h Hop) destination() string {
+	if len(h.User) > 0 {
+		return h.User + "@" + h.Host
+	}
+
+	return h.Host
+}
+
+// jumpSpec returns the hop as a -J destination ([user@]host[:port]). If the
+// hop needs more than that to be reached (ex. an identity), ok is false.
+func (h Hop) jumpSpec() (spec string, ok bool) {
+	if len(h.Identity) > 0 {
+		return "", false
+	}
+
+	user := h.User
+
+	for _, o := range h.Options {
+		switch o.Flag {
+		case 'l':
+			if len(h.User) < 1 {
+				user = o.Value
+			}
+		case 'p':
+		default:
+			return "", false
+		}
+	}
+
+	spec = h.Host
+
+	if port := h.port(); len(port) > 0 {
+		spec = net.JoinHostPort(h.Host, port)
+	}
+
+	if len(user) > 0 {
+		spec = user + "@" + spec
+	}
+
+	return spec, true
+}
+
+// escapeTokens escapes the % characters in s, so that ssh doesn't expand them
+// as tokens (ex. %h) when it reads s as a ProxyCommand.
+func escapeTokens(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+// JumpOptions returns the ssh options that connect through the passed chain of
+// jump hosts, in order.
+//
+// If every hop can be written as [user@]host[:port], a single -J option is
+// returned. Otherwise, the chain is written as a ProxyCommand that runs ssh -W
+// through each hop in turn, with each hop's identity and options, nesting the
+// earlier hops as ProxyCommands of their own.
+func JumpOptions(hops []Hop) []Option {
+	if len(hops) < 1 {
+		return nil
+	}
+
+	var specs []string
+
+	for _, h := range hops {
+		spec, ok := h.jumpSpec()
+
+		if !ok {
+			return []Option{{Flag: 'o', Value: "ProxyCommand=" + proxyCommand(hops)}}
+		}
+
+		specs = append(specs, spec)
+	}
+
+	return []Option{{Flag: 'J', Value: strings.Join(specs, ",")}}
+}
+
+// proxyCommand returns a ProxyCommand that reaches the host ssh is connecting
+// to through the passed hops.
+//
+// Each hop's command is read (and its tokens expanded) by the ssh process
+// that runs it, so the command for an earlier hop, nested in a later one, is
+// escaped once more for every level it's nested.
+func proxyCommand(hops []Hop) string {
+	var inner string
+
+	for i, h := range hops {
+		args := append([]string{"ssh"}, Flatten(h.Options)...)
+
+		if len(h.Identity) > 0 {
+			args = append(args, "-i", h.Identity)
+		}
+
+		if len(inner) > 0 {
+			args = append(args, "-o", "ProxyCommand="+inner)
+		}
+
+		// Every hop but the last forwards to the next hop. The last forwards
+		// to the host ssh is connecting to, which ssh fills in.
+		target := "%h:%p"
+
+		if i < len(hops)-1 {
+			next := hops[i+1]
+			port := next.port()
+
+			if len(port) < 1 {
+				port = defaultPort
+			}
+
+			target = escapeTokens(net.JoinHostPort(next.Host, port))
+		}
+
+		for j := range args {
+			args[j] = escapeTokens(args[j])
+		}
+
+		args = append(args, "-W", target, escapeTokens(h.destination()))
+
+		inner = Join(args)
+	}
+
+	return inner
+}
@@ -0,0 +1,196 @@
+// Package sshargs provides helpers for working with the OpenSSH client
+// arguments stored in sshcm connections (ex. a connection's args property).
+//
+// Connection args are stored as a single, flat string. This package splits
+// that string into individual arguments, parses them into options and
+// translates them for use with related tools, like scp and sftp, that accept
+// a slightly different set of flags than ssh.
+package sshargs
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrMissingValue = errors.New("sshargs: option is missing a value")
+var ErrUnterminatedQuote = errors.New("sshargs: unterminated quote")
+var ErrTrailingEscape = errors.New("sshargs: trailing backslash")
+
+// sshValueFlags contains all ssh client flags that take a value.
+const sshValueFlags = "BbcDEeFIiJLlmOoPpQRSWw"
+
+// An Option is a single ssh command line flag and, if the flag takes one, its
+// value.
+type Option struct {
+	Flag  byte   // flag letter, without the leading dash (ex. 'p')
+	Value string // flag value (empty for boolean flags)
+}
+
+// Args returns the option as a slice of command line arguments.
+func (o Option) Args() []string {
+	if strings.IndexByte(sshValueFlags, o.Flag) < 0 {
+		return []string{"-" + string(o.Flag)}
+	}
+
+	return []string{"-" + string(o.Flag), o.Value}
+}
+
+// Split breaks the passed string into arguments using POSIX shell-like rules.
+// Arguments are separated by whitespace. Single quotes, double quotes and
+// backslash escapes are honoured, but no other expansion is performed.
+func Split(s string) ([]string, error) {
+	var args []string
+	var b strings.Builder
+
+	inArg := false
+	escaped := false
+	var quote rune
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			b.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				b.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' {
+				escaped = true
+			} else {
+				b.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == '\\':
+			escaped = true
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, b.String())
+				b.Reset()
+				inArg = false
+			}
+		default:
+			b.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if escaped {
+		return nil, ErrTrailingEscape
+	}
+
+	if quote != 0 {
+		return nil, ErrUnterminatedQuote
+	}
+
+	if inArg {
+		args = append(args, b.String())
+	}
+
+	return args, nil
+}
+
+// Quote returns s quoted such that Split (or a POSIX shell) will read it back
+// as a single argument. Strings that don't need quoting are returned as-is.
+func Quote(s string) string {
+	if s == "" {
+		return "''"
+	}
+
+	if !strings.ContainsAny(s, " \t\n\r'\"\\$`!*?[]{}()<>|&;#~") {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Join quotes each of the passed arguments and joins them with spaces. It is
+// the inverse of Split.
+func Join(args []string) string {
+	q := make([]string, len(args))
+
+	for i, a := range args {
+		q[i] = Quote(a)
+	}
+
+	return strings.Join(q, " ")
+}
+
+// Parse parses ssh client arguments into a slice of Options. Combined flags
+// (ex. "-AC") and values attached to their flag (ex. "-p2222") are supported.
+//
+// Parsing stops at the first argument that is not a flag, or after "--". Any
+// remaining arguments are returned unparsed.
+func Parse(args []string) ([]Option, []string, error) {
+	var opts []Option
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" {
+			return opts, args[i+1:], nil
+		}
+
+		if len(arg) < 2 || arg[0] != '-' {
+			return opts, args[i:], nil
+		}
+
+		for j := 1; j < len(arg); j++ {
+			flag := arg[j]
+
+			if strings.IndexByte(sshValueFlags, flag) < 0 {
+				opts = append(opts, Option{Flag: flag})
+				continue
+			}
+
+			// The value is either the rest of this argument or the next one
+			if j+1 < len(arg) {
+				opts = append(opts, Option{Flag: flag, Value: arg[j+1:]})
+			} else if i+1 < len(args) {
+				i++
+				opts = append(opts, Option{Flag: flag, Value: args[i]})
+			} else {
+				return opts, nil, ErrMissingValue
+			}
+
+			break
+		}
+	}
+
+	return opts, nil, nil
+}
+
+// Flatten returns the passed options as a slice of command line arguments.
+func Flatten(opts []Option) []string {
+	var args []string
+
+	for _, o := range opts {
+		args = append(args, o.Args()...)
+	}
+
+	return args
+}
+
+// Lookup returns the value of the last option with the passed flag and
+// whether it was found.
+func Lookup(opts []Option, flag byte) (string, bool) {
+	value := ""
+	found := false
+
+	for _, o := range opts {
+		if o.Flag == flag {
+			value = o.Value
+			found = true
+		}
+	}
+
+	return value, found
+}
@@ -0,0 +1,274 @@
+package sshargs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "empty",
+			s:    "",
+			want: nil,
+		},
+		{
+			name: "simple",
+			s:    "-p 2222  -A",
+			want: []string{"-p", "2222", "-A"},
+		},
+		{
+			name: "single-quotes",
+			s:    `-o 'ProxyCommand ssh -W %h:%p bastion'`,
+			want: []string{"-o", "ProxyCommand ssh -W %h:%p bastion"},
+		},
+		{
+			name: "double-quotes",
+			s:    `-i "/home/me/my key" -o "A=\"b\""`,
+			want: []string{"-i", "/home/me/my key", "-o", `A="b"`},
+		},
+		{
+			name: "escaped-space",
+			s:    `-i ~/my\ key`,
+			want: []string{"-i", "~/my key"},
+		},
+		{
+			name: "empty-quoted",
+			s:    `-o ''`,
+			want: []string{"-o", ""},
+		},
+		{
+			name:    "unterminated",
+			s:       `-o "asdf`,
+			wantErr: ErrUnterminatedQuote,
+		},
+		{
+			name:    "trailing-escape",
+			s:       `-A \`,
+			wantErr: ErrTrailingEscape,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Split(tt.s)
+
+			if err != tt.wantErr {
+				t.Errorf("Split() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoin(t *testing.T) {
+	args := []string{"-o", "ProxyCommand ssh -W %h:%p it's", "-p", "22", ""}
+
+	got, err := Split(Join(args))
+
+	if err != nil {
+		t.Fatalf("Split(Join()) error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, args) {
+		t.Errorf("Split(Join()) = %q, want %q", got, args)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		want     []Option
+		wantRest []string
+		wantErr  error
+	}{
+		{
+			name: "separate-value",
+			args: []string{"-p", "2222", "-A"},
+			want: []Option{{Flag: 'p', Value: "2222"}, {Flag: 'A'}},
+		},
+		{
+			name: "attached-value",
+			args: []string{"-p2222"},
+			want: []Option{{Flag: 'p', Value: "2222"}},
+		},
+		{
+			name: "combined",
+			args: []string{"-ACi", "id_test"},
+			want: []Option{{Flag: 'A'}, {Flag: 'C'}, {Flag: 'i', Value: "id_test"}},
+		},
+		{
+			name:     "positional",
+			args:     []string{"-t", "uptime", "-v"},
+			want:     []Option{{Flag: 't'}},
+			wantRest: []string{"uptime", "-v"},
+		},
+		{
+			name:     "double-dash",
+			args:     []string{"-q", "--", "-v"},
+			want:     []Option{{Flag: 'q'}},
+			wantRest: []string{"-v"},
+		},
+		{
+			name:    "missing-value",
+			args:    []string{"-A", "-p"},
+			wantErr: ErrMissingValue,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest, err := Parse(tt.args)
+
+			if err != tt.wantErr {
+				t.Errorf("Parse() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(rest, tt.wantRest) {
+				t.Errorf("Parse() rest = %q, want %q", rest, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	opts := []Option{{Flag: 'p', Value: "22"}, {Flag: 'A'}, {Flag: 'p', Value: "2222"}}
+
+	if got, ok := Lookup(opts, 'p'); !ok || got != "2222" {
+		t.Errorf("Lookup(p) = %q, %v, want %q, true", got, ok, "2222")
+	}
+
+	if _, ok := Lookup(opts, 'i'); ok {
+		t.Errorf("Lookup(i) found, want not found")
+	}
+}
+
+func TestForCopy(t *testing.T) {
+	opts := []Option{
+		{Flag: 'p', Value: "2222"},
+		{Flag: 'i', Value: "id_test"},
+		{Flag: 'l', Value: "me"},
+		{Flag: 'A'},
+		{Flag: 't'},
+		{Flag: 'L', Value: "8080:localhost:80"},
+	}
+
+	wantArgs := []string{
+		"-P", "2222",
+		"-i", "id_test",
+		"-o", "User=me",
+		"-o", "ForwardAgent=yes",
+	}
+
+	wantDropped := []Option{
+		{Flag: 't'},
+		{Flag: 'L', Value: "8080:localhost:80"},
+	}
+
+	args, dropped := ForCopy(opts)
+
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("ForCopy() args = %q, want %q", args, wantArgs)
+	}
+
+	if !reflect.DeepEqual(dropped, wantDropped) {
+		t.Errorf("ForCopy() dropped = %v, want %v", dropped, wantDropped)
+	}
+}
+
+func TestForConfig(t *testing.T) {
+	opts := []Option{
+		{Flag: 'p', Value: "2222"},
+		{Flag: 'A'},
+		{Flag: 'o', Value: "ServerAliveInterval=30"},
+		{Flag: 'o', Value: "StrictHostKeyChecking no"},
+		{Flag: 'L', Value: "8080:localhost:80"},
+		{Flag: 'L', Value: "[::1]:8080:[::1]:80"},
+		{Flag: 'R', Value: "9000"},
+		{Flag: 'W', Value: "somewhere:22"},
+	}
+
+	wantConfig := []ConfigOption{
+		{"Port", "2222"},
+		{"ForwardAgent", "yes"},
+		{"ServerAliveInterval", "30"},
+		{"StrictHostKeyChecking", "no"},
+		{"LocalForward", "8080 localhost:80"},
+		{"LocalForward", "[::1]:8080 [::1]:80"},
+		{"RemoteForward", "9000"},
+	}
+
+	wantDropped := []Option{
+		{Flag: 'W', Value: "somewhere:22"},
+	}
+
+	config, dropped := ForConfig(opts)
+
+	if !reflect.DeepEqual(config, wantConfig) {
+		t.Errorf("ForConfig() config = %q, want %q", config, wantConfig)
+	}
+
+	if !reflect.DeepEqual(dropped, wantDropped) {
+		t.Errorf("ForConfig() dropped = %v, want %v", dropped, wantDropped)
+	}
+}
+
+func TestJumpOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		hops []Hop
+		want []Option
+	}{
+		{
+			name: "none",
+		},
+		{
+			name: "plain",
+			hops: []Hop{
+				{Host: "outer.example.com", User: "me", Options: []Option{{Flag: 'p', Value: "2222"}}},
+				{Host: "10.0.0.1", Options: []Option{{Flag: 'l', Value: "admin"}}},
+			},
+			want: []Option{{Flag: 'J', Value: "me@outer.example.com:2222,admin@10.0.0.1"}},
+		},
+		{
+			name: "identity",
+			hops: []Hop{
+				{Host: "outer.example.com", User: "me", Identity: "~/.ssh/id_outer"},
+			},
+			want: []Option{{Flag: 'o', Value: "ProxyCommand=ssh -i '~/.ssh/id_outer' -W %h:%p me@outer.example.com"}},
+		},
+		{
+			name: "nested",
+			hops: []Hop{
+				{Host: "outer.example.com", Identity: "/keys/%u"},
+				{Host: "10.0.0.1", Options: []Option{{Flag: 'p', Value: "2222"}}},
+			},
+			want: []Option{{Flag: 'o', Value: "ProxyCommand=ssh -p 2222 -o 'ProxyCommand=ssh -i /keys/%%%%u -W 10.0.0.1:2222 outer.example.com' -W %h:%p 10.0.0.1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := JumpOptions(tt.hops); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("JumpOptions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
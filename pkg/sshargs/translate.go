@@ -0,0 +1,173 @@
+package sshargs
+
+import "strings"
+
+// copyPassFlags contains ssh flags that scp and sftp accept unchanged.
+const copyPassFlags = "46CcFiJoqv"
+
+// copyConfigFlags maps ssh flags that scp and sftp don't understand to the
+// equivalent ssh_config keyword, so that they can be passed via -o instead.
+var copyConfigFlags = map[byte]string{
+	'B': "BindInterface",
+	'b': "BindAddress",
+	'I': "PKCS11Provider",
+	'l': "User",
+	'm': "MACs",
+	'P': "Tag",
+	'S': "ControlPath",
+}
+
+// copyConfigBoolFlags maps boolean ssh flags to an equivalent ssh_config
+// keyword and value.
+var copyConfigBoolFlags = map[byte]string{
+	'A': "ForwardAgent=yes",
+	'a': "ForwardAgent=no",
+	'K': "GSSAPIAuthentication=yes",
+	'k': "GSSAPIDelegateCredentials=no",
+}
+
+// ForCopy translates ssh options into arguments suitable for scp or sftp.
+//
+// Both tools take a port via -P rather than -p, and neither supports several
+// ssh flags. Where possible, unsupported flags are rewritten as -o options.
+// Flags that make no sense for a file copy (ex. port forwarding, TTY
+// allocation) are returned in dropped so that the caller can warn about them.
+func ForCopy(opts []Option) (args []string, dropped []Option) {
+	for _, o := range opts {
+		switch {
+		case o.Flag == 'p':
+			args = append(args, "-P", o.Value)
+		case strings.IndexByte(copyPassFlags, o.Flag) >= 0:
+			args = append(args, o.Args()...)
+		case copyConfigFlags[o.Flag] != "":
+			args = append(args, "-o", copyConfigFlags[o.Flag]+"="+o.Value)
+		case copyConfigBoolFlags[o.Flag] != "":
+			args = append(args, "-o", copyConfigBoolFlags[o.Flag])
+		default:
+			dropped = append(dropped, o)
+		}
+	}
+
+	return args, dropped
+}
+
+// A ConfigOption is an ssh_config keyword and its value.
+type ConfigOption struct {
+	Keyword string
+	Value   string
+}
+
+// configFlags maps ssh flags that take a value to the equivalent ssh_config
+// keyword. Forwarding flags are handled separately, as their values are
+// written differently.
+var configFlags = map[byte]string{
+	'B': "BindInterface",
+	'b': "BindAddress",
+	'c': "Ciphers",
+	'D': "DynamicForward",
+	'E': "LogFile",
+	'e': "EscapeChar",
+	'I': "PKCS11Provider",
+	'i': "IdentityFile",
+	'J': "ProxyJump",
+	'l': "User",
+	'm': "MACs",
+	'P': "Tag",
+	'p': "Port",
+	'S': "ControlPath",
+}
+
+// configBoolFlags maps boolean ssh flags to an equivalent ssh_config keyword
+// and value.
+var configBoolFlags = map[byte]ConfigOption{
+	'4': {"AddressFamily", "inet"},
+	'6': {"AddressFamily", "inet6"},
+	'A': {"ForwardAgent", "yes"},
+	'a': {"ForwardAgent", "no"},
+	'C': {"Compression", "yes"},
+	'f': {"ForkAfterAuthentication", "yes"},
+	'g': {"GatewayPorts", "yes"},
+	'K': {"GSSAPIAuthentication", "yes"},
+	'k': {"GSSAPIDelegateCredentials", "no"},
+	'M': {"ControlMaster", "yes"},
+	'N': {"SessionType", "none"},
+	'n': {"StdinNull", "yes"},
+	'q': {"LogLevel", "QUIET"},
+	's': {"SessionType", "subsystem"},
+	'T': {"RequestTTY", "no"},
+	't': {"RequestTTY", "yes"},
+	'v': {"LogLevel", "VERBOSE"},
+	'X': {"ForwardX11", "yes"},
+	'x': {"ForwardX11", "no"},
+	'Y': {"ForwardX11Trusted", "yes"},
+}
+
+// forwardSpec rewrites a -L or -R forwarding spec (ex.
+// "8080:localhost:80") in the form ssh_config expects, with a space between
+// the listening address and the destination (ex. "8080 localhost:80").
+// Bracketed IPv6 addresses are supported. Specs without a destination (ex. a
+// dynamic -R forward) are returned as-is.
+func forwardSpec(spec string) string {
+	var colons []int
+
+	depth := 0
+
+	for i := 0; i < len(spec); i++ {
+		switch spec[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ':':
+			if depth == 0 {
+				colons = append(colons, i)
+			}
+		}
+	}
+
+	switch {
+	case len(colons) >= 2:
+		// [bind:]port:host:hostport
+		i := colons[len(colons)-2]
+		return spec[:i] + " " + spec[i+1:]
+	case len(colons) == 1 && strings.Contains(spec[colons[0]+1:], "/"):
+		// port:/path/to/socket
+		return spec[:colons[0]] + " " + spec[colons[0]+1:]
+	}
+
+	return spec
+}
+
+// ForConfig translates ssh options into ssh_config keywords, in the order they
+// were passed. Options passed via -o are split into their keyword and value.
+//
+// Flags that have no ssh_config equivalent (ex. -F, -G or -W) are returned in
+// dropped so that the caller can warn about them.
+func ForConfig(opts []Option) (config []ConfigOption, dropped []Option) {
+	for _, o := range opts {
+		switch {
+		case o.Flag == 'o':
+			key, value, ok := strings.Cut(o.Value, "=")
+
+			if !ok {
+				key, value, _ = strings.Cut(strings.TrimSpace(o.Value), " ")
+			}
+
+			config = append(config, ConfigOption{strings.TrimSpace(key), strings.TrimSpace(value)})
+		case o.Flag == 'L':
+			config = append(config, ConfigOption{"LocalForward", forwardSpec(o.Value)})
+		case o.Flag == 'R':
+			config = append(config, ConfigOption{"RemoteForward", forwardSpec(o.Value)})
+		case o.Flag == 'w':
+			config = append(config, ConfigOption{"Tunnel", "yes"}, ConfigOption{"TunnelDevice", o.Value})
+		case configFlags[o.Flag] != "":
+			config = append(config, ConfigOption{configFlags[o.Flag], o.Value})
+		case configBoolFlags[o.Flag] != ConfigOption{}:
+			config = append(config, configBoolFlags[o.Flag])
+		default:
+			dropped = append(dropped, o)
+		}
+	}
+
+	return config, dropped
+}
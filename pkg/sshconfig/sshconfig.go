@@ -0,0 +1,184 @@
+// Package sshconfig converts sshcm connections to OpenSSH client
+// configuration, so that plain ssh (and tools built on it) can use them: Host
+// blocks for an ssh_config file, and the "keyword value" lines printed by
+// ssh -G.
+package sshconfig
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/cannable/sshcm/pkg/sshargs"
+)
+
+// DefaultPort is the standard ssh port, which ssh -G always reports.
+const DefaultPort = "22"
+
+// A Host is an ssh_config Host block: an alias and the options that apply to
+// it, in the order they should be written.
+type Host struct {
+	Alias   string
+	Options []sshargs.ConfigOption
+}
+
+// FromConnection returns the Host block for a connection, with the
+// connection's nickname as its alias. Callers should apply program defaults to
+// the connection first.
+//
+// Connection args are translated to ssh_config keywords. The user and
+// identity are taken from the args if they're set there (ex. "-l me"), with
+// the connection's own user and identity taking precedence, and any trailing
+// remote command becomes a RemoteCommand. The connection's jump hosts become a
+// ProxyJump through their nicknames, which resolve to the jump hosts' own Host
+// blocks when every connection is written to the same config. ssh options that
+// have no ssh_config equivalent are returned in dropped so that the caller can
+// warn about them.
+func FromConnection(c *cdb.Connection) (h Host, dropped []sshargs.Option, err error) {
+	split, err := sshargs.Split(c.Args)
+
+	if err != nil {
+		return h, nil, err
+	}
+
+	opts, rest, err := sshargs.Parse(split)
+
+	if err != nil {
+		return h, nil, err
+	}
+
+	config, dropped := sshargs.ForConfig(opts)
+
+	h.Alias = c.Nickname
+	h.Options = []sshargs.ConfigOption{{Keyword: "HostName", Value: c.Host}}
+
+	// ssh uses the first value it finds for most keywords, so the connection's
+	// own settings go first, replacing those from its args
+	if len(c.User) > 0 {
+		h.Options = append(h.Options, sshargs.ConfigOption{Keyword: "User", Value: c.User})
+	}
+
+	if len(c.Identity) > 0 {
+		h.Options = append(h.Options, sshargs.ConfigOption{Keyword: "IdentityFile", Value: c.Identity})
+	}
+
+	if len(c.Jump) > 0 {
+		h.Options = append(h.Options, sshargs.ConfigOption{Keyword: "ProxyJump", Value: cdb.JoinJumpHosts(c.JumpHosts())})
+	}
+
+	for _, o := range config {
+		if len(c.User) > 0 && strings.EqualFold(o.Keyword, "User") {
+			continue
+		}
+
+		if len(c.Identity) > 0 && strings.EqualFold(o.Keyword, "IdentityFile") {
+			continue
+		}
+
+		if len(c.Jump) > 0 && strings.EqualFold(o.Keyword, "ProxyJump") {
+			continue
+		}
+
+		h.Options = append(h.Options, o)
+	}
+
+	if len(rest) > 0 {
+		h.Options = append(h.Options, sshargs.ConfigOption{Keyword: "RemoteCommand", Value: sshargs.Join(rest)})
+	}
+
+	return h, dropped, nil
+}
+
+// Lookup returns the value of the first option with the passed keyword
+// (case-insensitive), and whether it was found.
+func (h Host) Lookup(keyword string) (string, bool) {
+	for _, o := range h.Options {
+		if strings.EqualFold(o.Keyword, keyword) {
+			return o.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// quote quotes an ssh_config argument if it contains whitespace.
+func quote(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t") {
+		return `"` + s + `"`
+	}
+
+	return s
+}
+
+// WriteConfig writes the Host block to w, with its options indented beneath
+// it.
+func (h Host) WriteConfig(w io.Writer) error {
+	b := bufio.NewWriter(w)
+
+	fmt.Fprintf(b, "Host %s\n", quote(h.Alias))
+
+	for _, o := range h.Options {
+		fmt.Fprintf(b, "    %s %s\n", o.Keyword, quote(o.Value))
+	}
+
+	return b.Flush()
+}
+
+// WriteG writes the host's options in the format printed by ssh -G: one
+// lowercase keyword and its value per line. Like ssh -G, the port is always
+// included, and the host name defaults to the alias.
+func (h Host) WriteG(w io.Writer) error {
+	b := bufio.NewWriter(w)
+
+	host, ok := h.Lookup("HostName")
+
+	if !ok || len(host) < 1 {
+		host = h.Alias
+	}
+
+	port, ok := h.Lookup("Port")
+
+	if !ok {
+		port = DefaultPort
+	}
+
+	fmt.Fprintf(b, "host %s\n", h.Alias)
+	fmt.Fprintf(b, "hostname %s\n", host)
+	fmt.Fprintf(b, "port %s\n", port)
+
+	for _, o := range h.Options {
+		switch strings.ToLower(o.Keyword) {
+		case "hostname", "port":
+			continue
+		}
+
+		fmt.Fprintf(b, "%s %s\n", strings.ToLower(o.Keyword), o.Value)
+	}
+
+	return b.Flush()
+}
+
+// Write writes an ssh_config file containing the passed Host blocks, suitable
+// for use with an Include directive. The file begins with a comment noting
+// that it was generated by sshcm.
+func Write(w io.Writer, hosts []Host) error {
+	_, err := fmt.Fprintln(w, "# Generated by sshcm from its connection DB. Changes made here will be lost.")
+
+	if err != nil {
+		return err
+	}
+
+	for _, h := range hosts {
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+
+		if err := h.WriteConfig(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
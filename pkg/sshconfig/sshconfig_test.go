@@ -0,0 +1,113 @@
+package sshconfig
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/cannable/sshcm/pkg/cdb"
+	"github.com/cannable/sshcm/pkg/sshargs"
+)
+
+// opt returns a ConfigOption.
+func opt(keyword string, value string) sshargs.ConfigOption {
+	return sshargs.ConfigOption{Keyword: keyword, Value: value}
+}
+
+func TestFromConnection(t *testing.T) {
+	tests := []struct {
+		name        string
+		conn        cdb.Connection
+		want        []sshargs.ConfigOption
+		wantDropped []sshargs.Option
+	}{
+		{
+			name: "plain",
+			conn: cdb.Connection{Nickname: "something", Host: "somewhere", User: "me"},
+			want: []sshargs.ConfigOption{opt("HostName", "somewhere"), opt("User", "me")},
+		},
+		{
+			name: "args",
+			conn: cdb.Connection{Nickname: "something", Host: "somewhere", Identity: "id", Args: "-p 2222 -l you -i other -J gw -W x:22 uptime"},
+			want: []sshargs.ConfigOption{
+				opt("HostName", "somewhere"),
+				opt("IdentityFile", "id"),
+				opt("Port", "2222"),
+				opt("User", "you"),
+				opt("ProxyJump", "gw"),
+				opt("RemoteCommand", "uptime"),
+			},
+			wantDropped: []sshargs.Option{{Flag: 'W', Value: "x:22"}},
+		},
+		{
+			name: "jump",
+			conn: cdb.Connection{Nickname: "something", Host: "10.0.0.1", Jump: "outer, inner", Args: "-J gw -p 2222"},
+			want: []sshargs.ConfigOption{
+				opt("HostName", "10.0.0.1"),
+				opt("ProxyJump", "outer,inner"),
+				opt("Port", "2222"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, dropped, err := FromConnection(&tt.conn)
+
+			if err != nil {
+				t.Fatalf("FromConnection() error = %v", err)
+			}
+
+			if got.Alias != tt.conn.Nickname || !reflect.DeepEqual(got.Options, tt.want) {
+				t.Errorf("FromConnection() = %+v, want %+v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(dropped, tt.wantDropped) {
+				t.Errorf("FromConnection() dropped = %v, want %v", dropped, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	hosts := []Host{
+		{Alias: "something", Options: []sshargs.ConfigOption{opt("HostName", "somewhere"), opt("Port", "2222")}},
+		{Alias: "else", Options: []sshargs.ConfigOption{opt("HostName", "elsewhere"), opt("IdentityFile", "~/My Keys/id")}},
+	}
+
+	want := `# Generated by sshcm from its connection DB. Changes made here will be lost.
+
+Host something
+    HostName somewhere
+    Port 2222
+
+Host else
+    HostName elsewhere
+    IdentityFile "~/My Keys/id"
+`
+
+	var b bytes.Buffer
+
+	if err := Write(&b, hosts); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if b.String() != want {
+		t.Errorf("Write() = %q, want %q", b.String(), want)
+	}
+}
+
+func TestHost_WriteG(t *testing.T) {
+	h := Host{Alias: "something", Options: []sshargs.ConfigOption{opt("HostName", "somewhere"), opt("User", "me"), opt("ForwardAgent", "yes")}}
+
+	want := "host something\nhostname somewhere\nport 22\nuser me\nforwardagent yes\n"
+
+	var b bytes.Buffer
+
+	if err := h.WriteG(&b); err != nil {
+		t.Fatalf("Host.WriteG() error = %v", err)
+	}
+
+	if b.String() != want {
+		t.Errorf("Host.WriteG() = %q, want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,177 @@
+// Package table renders rows of text as an aligned, plain-text table that
+// fits the width of a terminal.
+//
+// Columns are sized to fit their content. If the table is wider than the
+// available width, the widest columns are shrunk first and their cells are
+// truncated with an ellipsis. Widths are measured in terminal cells, so
+// multibyte and wide characters are handled correctly.
+package table
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cannable/sshcm/pkg/misc"
+)
+
+// minColumnWidth is the narrowest a column will be shrunk to fit a table
+// within its maximum width.
+const minColumnWidth = 4
+
+// ANSI escape sequences used when colour output is enabled.
+const (
+	ansiBold      = "\033[1m"
+	ansiUnderline = "\033[4m"
+	ansiReset     = "\033[0m"
+)
+
+// A Table is a set of rows with a header, ready for rendering.
+type Table struct {
+	Header   []string   // column headings
+	Rows     [][]string // table cells, one slice per row
+	MaxWidth int        // maximum table width in cells (0 means unlimited)
+	Color    bool       // highlight the header with ANSI escape sequences
+	Gap      int        // number of spaces between columns
+}
+
+// New returns a Table with the passed header and the default column gap.
+func New(header ...string) *Table {
+	return &Table{
+		Header: header,
+		Gap:    1,
+	}
+}
+
+// Append adds a row to the table.
+func (t *Table) Append(row ...string) {
+	t.Rows = append(t.Rows, row)
+}
+
+// naturalWidths returns the width each column needs to fit all of its cells
+// without truncation.
+func (t *Table) naturalWidths() []int {
+	widths := make([]int, len(t.Header))
+
+	for i, h := range t.Header {
+		widths[i] = misc.DisplayWidth(h)
+	}
+
+	for _, row := range t.Rows {
+		for i := 0; i < len(row) && i < len(widths); i++ {
+			if w := misc.DisplayWidth(row[i]); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+
+	return widths
+}
+
+// Widths returns the width of each column after fitting the table within
+// MaxWidth.
+//
+// Space is shared out evenly between columns. Columns narrower than their
+// share keep their natural width and the space they don't need is shared
+// between the remaining columns, so only the widest columns are truncated.
+func (t *Table) Widths() []int {
+	widths := t.naturalWidths()
+
+	if t.MaxWidth < 1 || len(widths) == 0 {
+		return widths
+	}
+
+	available := t.MaxWidth - t.Gap*(len(widths)-1)
+	total := 0
+
+	for _, w := range widths {
+		total += w
+	}
+
+	if total <= available {
+		return widths
+	}
+
+	// Hand out space to the narrowest columns first
+	fitted := make([]bool, len(widths))
+	remaining := len(widths)
+
+	for remaining > 0 {
+		share := available / remaining
+		progress := false
+
+		for i, w := range widths {
+			if !fitted[i] && w <= share {
+				fitted[i] = true
+				available -= w
+				remaining--
+				progress = true
+			}
+		}
+
+		if !progress {
+			break
+		}
+	}
+
+	// Split what's left between the columns that have to be truncated
+	for i := range widths {
+		if fitted[i] {
+			continue
+		}
+
+		share := available / remaining
+
+		widths[i] = max(share, minColumnWidth)
+		available -= share
+		remaining--
+	}
+
+	return widths
+}
+
+// Render writes the table to the passed writer.
+// An error will be returned if one occurs, otherwise error will be nil.
+func (t *Table) Render(w io.Writer) error {
+	widths := t.Widths()
+
+	header := t.formatRow(t.Header, widths)
+
+	if t.Color {
+		header = ansiBold + ansiUnderline + header + ansiReset
+	}
+
+	if _, err := fmt.Fprintln(w, header); err != nil {
+		return err
+	}
+
+	for _, row := range t.Rows {
+		if _, err := fmt.Fprintln(w, t.formatRow(row, widths)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formatRow truncates and pads each cell in a row to its column width. The
+// last column isn't padded, to avoid trailing whitespace.
+func (t *Table) formatRow(row []string, widths []int) string {
+	cells := make([]string, len(widths))
+
+	for i, width := range widths {
+		cell := ""
+
+		if i < len(row) {
+			cell = misc.Truncate(row[i], width)
+		}
+
+		if i < len(widths)-1 {
+			cell = misc.PadRight(cell, width)
+		}
+
+		cells[i] = cell
+	}
+
+	return strings.Join(cells, strings.Repeat(" ", t.Gap))
+}
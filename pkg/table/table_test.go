@@ -0,0 +1,68 @@
+package table
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTable_Widths(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxWidth int
+		want     []int
+	}{
+		{
+			name:     "unlimited",
+			maxWidth: 0,
+			want:     []int{2, 8, 30},
+		},
+		{
+			name:     "fits",
+			maxWidth: 80,
+			want:     []int{2, 8, 30},
+		},
+		{
+			name:     "shrink-widest",
+			maxWidth: 30,
+			want:     []int{2, 8, 18},
+		},
+		{
+			name:     "minimum",
+			maxWidth: 10,
+			want:     []int{2, 4, 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tbl := New("ID", "Nickname", "Description")
+			tbl.Append("1", "web", strings.Repeat("x", 30))
+			tbl.MaxWidth = tt.maxWidth
+
+			if got := tbl.Widths(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Table.Widths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTable_Render(t *testing.T) {
+	tbl := New("ID", "Nickname", "Host")
+	tbl.Append("1", "日本語", "a.example.com")
+	tbl.Append("20", "web", "b.example.com")
+	tbl.MaxWidth = 20
+
+	var b strings.Builder
+
+	if err := tbl.Render(&b); err != nil {
+		t.Fatalf("Table.Render() error = %v", err)
+	}
+
+	want := "ID Nickname Host\n" +
+		"1  日本語   a.examp…\n" +
+		"20 web      b.examp…\n"
+
+	if got := b.String(); got != want {
+		t.Errorf("Table.Render() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,5 @@
+package tui
+
+import "errors"
+
+var ErrNotTerminal = errors.New("not a terminal")
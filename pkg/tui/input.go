@@ -0,0 +1,93 @@
+package tui
+
+import (
+	"slices"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// An Input is a single-line text field.
+type Input struct {
+	value  []rune
+	cursor int // index in value the cursor is before
+}
+
+// NewInput returns an Input holding value, with the cursor at its end.
+func NewInput(value string) *Input {
+	in := &Input{value: []rune(value)}
+	in.cursor = len(in.value)
+
+	return in
+}
+
+// Value returns the text in the field.
+func (in *Input) Value() string {
+	return string(in.value)
+}
+
+// Handle edits the field as the passed key press says, and returns true if
+// the key press was an editing key.
+func (in *Input) Handle(ev Event) bool {
+	switch ev.Key {
+	case KeyRune:
+		in.value = slices.Insert(in.value, in.cursor, ev.Rune)
+		in.cursor++
+	case KeyBackspace:
+		if in.cursor > 0 {
+			in.value = slices.Delete(in.value, in.cursor-1, in.cursor)
+			in.cursor--
+		}
+	case KeyDelete:
+		if in.cursor < len(in.value) {
+			in.value = slices.Delete(in.value, in.cursor, in.cursor+1)
+		}
+	case KeyLeft:
+		in.cursor = max(in.cursor-1, 0)
+	case KeyRight:
+		in.cursor = min(in.cursor+1, len(in.value))
+	case KeyHome:
+		in.cursor = 0
+	case KeyEnd:
+		in.cursor = len(in.value)
+	case KeyCtrlU:
+		in.value = nil
+		in.cursor = 0
+	default:
+		return false
+	}
+
+	return true
+}
+
+// View returns the part of the field that fits in width terminal cells, and
+// the column the cursor is in. The text scrolls so that the cursor stays in
+// view.
+func (in *Input) View(width int) (string, int) {
+	if width < 1 {
+		return "", 0
+	}
+
+	// Scroll so that the cursor, and the cell after the text, fit
+	start := 0
+
+	for runewidth.StringWidth(string(in.value[start:in.cursor])) >= width {
+		start++
+	}
+
+	var visible []rune
+
+	used := 0
+
+	for _, r := range in.value[start:] {
+		w := runewidth.RuneWidth(r)
+
+		if used+w > width {
+			break
+		}
+
+		visible = append(visible, r)
+		used += w
+	}
+
+	return string(visible), runewidth.StringWidth(string(in.value[start:in.cursor]))
+}
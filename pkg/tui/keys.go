@@ -0,0 +1,129 @@
+package tui
+
+import (
+	"unicode/utf8"
+)
+
+// A Key identifies a key press. Printable characters are KeyRune, with the
+// character in Event.Rune.
+type Key int
+
+const (
+	KeyUnknown Key = iota
+	KeyRune
+	KeyEnter
+	KeyEsc
+	KeyBackspace
+	KeyDelete
+	KeyTab
+	KeyBacktab
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyHome
+	KeyEnd
+	KeyPgUp
+	KeyPgDn
+	KeyCtrlC
+	KeyCtrlS
+	KeyCtrlU
+)
+
+// An Event is a key press.
+type Event struct {
+	Key  Key
+	Rune rune // the character typed, for KeyRune
+}
+
+// controlKeys maps control characters to the keys they are sent for.
+var controlKeys = map[byte]Key{
+	'\r':   KeyEnter,
+	'\n':   KeyEnter,
+	'\t':   KeyTab,
+	0x7f:   KeyBackspace,
+	0x08:   KeyBackspace,
+	0x03:   KeyCtrlC,
+	0x13:   KeyCtrlS,
+	0x15:   KeyCtrlU,
+	'\x1b': KeyEsc,
+}
+
+// csiKeys maps the final byte of CSI (ESC [) and SS3 (ESC O) sequences without
+// parameters to the keys they are sent for.
+var csiKeys = map[byte]Key{
+	'A': KeyUp,
+	'B': KeyDown,
+	'C': KeyRight,
+	'D': KeyLeft,
+	'H': KeyHome,
+	'F': KeyEnd,
+	'Z': KeyBacktab,
+}
+
+// tildeKeys maps the parameter of CSI sequences ending in ~ (ex. ESC [3~) to
+// the keys they are sent for.
+var tildeKeys = map[string]Key{
+	"1": KeyHome,
+	"7": KeyHome,
+	"4": KeyEnd,
+	"8": KeyEnd,
+	"3": KeyDelete,
+	"5": KeyPgUp,
+	"6": KeyPgDn,
+}
+
+// Decode returns the key presses in b, as read from a terminal in raw mode.
+// A lone ESC is the escape key. Escape sequences that aren't known are
+// returned as KeyUnknown.
+func Decode(b []byte) []Event {
+	var events []Event
+
+	for len(b) > 0 {
+		var ev Event
+		var n int
+
+		switch {
+		case b[0] == '\x1b' && len(b) > 2 && (b[1] == '[' || b[1] == 'O'):
+			ev, n = decodeSequence(b)
+		case b[0] < 0x20 || b[0] == 0x7f:
+			ev, n = Event{Key: controlKeys[b[0]]}, 1
+		default:
+			r, size := utf8.DecodeRune(b)
+			ev, n = Event{Key: KeyRune, Rune: r}, size
+
+			if r == utf8.RuneError {
+				ev.Key = KeyUnknown
+			}
+		}
+
+		events = append(events, ev)
+		b = b[n:]
+	}
+
+	return events
+}
+
+// decodeSequence decodes the CSI or SS3 escape sequence at the start of b,
+// and returns its key and length.
+func decodeSequence(b []byte) (Event, int) {
+	// Parameter and intermediate bytes come before the final byte
+	i := 2
+
+	for i < len(b) && b[i] >= 0x20 && b[i] <= 0x3f {
+		i++
+	}
+
+	if i >= len(b) {
+		return Event{Key: KeyUnknown}, len(b)
+	}
+
+	params, final := string(b[2:i]), b[i]
+
+	if final == '~' {
+		return Event{Key: tildeKeys[params]}, i + 1
+	}
+
+	// Modified keys (ex. ESC [1;5A) are treated as the plain key
+	return Event{Key: csiKeys[final]}, i + 1
+}
@@ -0,0 +1,189 @@
+// Package tui provides the building blocks of a full-screen terminal
+// interface: a Screen that puts the terminal in raw mode and draws frames on
+// the alternate screen with ANSI escape sequences, decoding of key presses and
+// single-line text fields.
+//
+// A Frame is drawn in full every time, so callers redraw after every key press
+// or resize rather than tracking what changed.
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ANSI escape sequences used to draw the screen.
+const (
+	ansiAltScreen  = "\033[?1049h"
+	ansiMainScreen = "\033[?1049l"
+	ansiShowCursor = "\033[?25h"
+	ansiHideCursor = "\033[?25l"
+	ansiHome       = "\033[H"
+	ansiClearLine  = "\033[K"
+	ansiClearBelow = "\033[J"
+	ansiBold       = "\033[1m"
+	ansiDim        = "\033[2m"
+	ansiReverse    = "\033[7m"
+	ansiReset      = "\033[0m"
+)
+
+// Bold returns s, to be drawn in bold.
+func Bold(s string) string {
+	return ansiBold + s + ansiReset
+}
+
+// Dim returns s, to be drawn dimmed.
+func Dim(s string) string {
+	return ansiDim + s + ansiReset
+}
+
+// Reverse returns s, to be drawn in reverse video (ex. a selected line).
+func Reverse(s string) string {
+	return ansiReverse + s + ansiReset
+}
+
+// A Frame is the content of the screen.
+type Frame struct {
+	// Lines are the lines of the screen, top first. They may hold styles (see
+	// Bold, Dim and Reverse), and should fit the width of the screen.
+	Lines []string
+
+	// ShowCursor shows the cursor at column CursorX of line CursorY (both
+	// starting at 0), for text entry.
+	ShowCursor bool
+	CursorX    int
+	CursorY    int
+}
+
+// readResult is the outcome of reading from the terminal.
+type readResult struct {
+	b   []byte
+	err error
+}
+
+// A Screen is a terminal in raw mode, showing the alternate screen.
+type Screen struct {
+	in    *os.File
+	out   *os.File
+	state *term.State
+
+	// Reads are done in the background, so that ReadEvents can be woken up
+	// (ex. on resize). At most one read is pending at a time.
+	reads   chan readResult
+	pending bool
+}
+
+// Open puts the terminal in raw mode and switches to the alternate screen.
+// in and out must both be terminals. The terminal is restored by Close.
+func Open(in *os.File, out *os.File) (*Screen, error) {
+	if !term.IsTerminal(int(in.Fd())) || !term.IsTerminal(int(out.Fd())) {
+		return nil, ErrNotTerminal
+	}
+
+	state, err := term.MakeRaw(int(in.Fd()))
+
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Screen{
+		in:    in,
+		out:   out,
+		state: state,
+		reads: make(chan readResult, 1),
+	}
+
+	if _, err := out.WriteString(ansiAltScreen + ansiHideCursor); err != nil {
+		term.Restore(int(in.Fd()), state)
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Close switches back to the main screen and restores the terminal mode.
+func (s *Screen) Close() error {
+	s.out.WriteString(ansiReset + ansiShowCursor + ansiMainScreen)
+
+	return term.Restore(int(s.in.Fd()), s.state)
+}
+
+// Size returns the width and height of the screen. 80x24 is returned if the
+// size can't be read.
+func (s *Screen) Size() (int, int) {
+	width, height, err := term.GetSize(int(s.out.Fd()))
+
+	if err != nil || width < 1 || height < 1 {
+		return 80, 24
+	}
+
+	return width, height
+}
+
+// Draw replaces the content of the screen with the passed frame. Lines past
+// the height of the screen are dropped.
+func (s *Screen) Draw(f Frame) error {
+	_, height := s.Size()
+
+	var b strings.Builder
+
+	b.WriteString(ansiHideCursor + ansiHome)
+
+	for i, line := range f.Lines {
+		if i >= height {
+			break
+		}
+
+		// Writing a line break on the last line would scroll the screen
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+
+		b.WriteString(line + ansiReset + ansiClearLine)
+	}
+
+	b.WriteString(ansiClearBelow)
+
+	if f.ShowCursor {
+		fmt.Fprintf(&b, "\033[%d;%dH%s", f.CursorY+1, f.CursorX+1, ansiShowCursor)
+	}
+
+	_, err := s.out.WriteString(b.String())
+
+	return err
+}
+
+// ReadEvents waits for key presses and returns them. If wake receives before
+// a key is pressed, ReadEvents returns no events; the read carries on, and its
+// key presses are returned by the next call.
+//
+// No read is left pending once key presses have been returned, so the
+// terminal can be handed to another program (ex. after Close).
+func (s *Screen) ReadEvents(wake <-chan struct{}) ([]Event, error) {
+	if !s.pending {
+		s.pending = true
+
+		go func() {
+			buf := make([]byte, 256)
+			n, err := s.in.Read(buf)
+
+			s.reads <- readResult{buf[:n], err}
+		}()
+	}
+
+	select {
+	case r := <-s.reads:
+		s.pending = false
+
+		if r.err != nil {
+			return nil, r.err
+		}
+
+		return Decode(r.b), nil
+	case <-wake:
+		return nil, nil
+	}
+}
@@ -0,0 +1,77 @@
+package tui
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		b    string
+		want []Event
+	}{
+		{"runes", "aé", []Event{{KeyRune, 'a'}, {KeyRune, 'é'}}},
+		{"controls", "\r\t\x7f\x03\x13\x15", []Event{{Key: KeyEnter}, {Key: KeyTab}, {Key: KeyBackspace}, {Key: KeyCtrlC}, {Key: KeyCtrlS}, {Key: KeyCtrlU}}},
+		{"escape", "\x1b", []Event{{Key: KeyEsc}}},
+		{"arrows", "\x1b[A\x1b[B\x1bOC\x1b[D", []Event{{Key: KeyUp}, {Key: KeyDown}, {Key: KeyRight}, {Key: KeyLeft}}},
+		{"tilde", "\x1b[3~\x1b[5~\x1b[6~\x1b[1~\x1b[4~", []Event{{Key: KeyDelete}, {Key: KeyPgUp}, {Key: KeyPgDn}, {Key: KeyHome}, {Key: KeyEnd}}},
+		{"modified", "\x1b[1;5Ax", []Event{{Key: KeyUp}, {KeyRune, 'x'}}},
+		{"backtab", "\x1b[Z", []Event{{Key: KeyBacktab}}},
+		{"unknown", "\x1b[99~\x01", []Event{{Key: KeyUnknown}, {Key: KeyUnknown}}},
+		{"truncated", "\x1b[1;", []Event{{Key: KeyUnknown}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Decode([]byte(tt.b)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInput(t *testing.T) {
+	in := NewInput("web")
+
+	for _, ev := range []Event{{KeyRune, '1'}, {Key: KeyHome}, {Key: KeyDelete}, {KeyRune, 'W'}, {Key: KeyEnd}, {Key: KeyBackspace}, {KeyRune, '2'}} {
+		if !in.Handle(ev) {
+			t.Errorf("Input.Handle(%v) = false, want true", ev)
+		}
+	}
+
+	if got := in.Value(); got != "Web2" {
+		t.Errorf("Input.Value() = %q, want %q", got, "Web2")
+	}
+
+	if in.Handle(Event{Key: KeyEnter}) {
+		t.Error("Input.Handle(KeyEnter) = true, want false")
+	}
+
+	in.Handle(Event{Key: KeyCtrlU})
+
+	if got := in.Value(); got != "" {
+		t.Errorf("Input.Value() = %q, want empty", got)
+	}
+}
+
+func TestInput_View(t *testing.T) {
+	in := NewInput("web01.example.com")
+
+	if view, x := in.View(8); view != "ple.com" || x != 7 {
+		t.Errorf("Input.View() = %q, %v, want %q, 7", view, x, "ple.com")
+	}
+
+	in.Handle(Event{Key: KeyHome})
+
+	if view, x := in.View(8); view != "web01.ex" || x != 0 {
+		t.Errorf("Input.View() = %q, %v, want %q, 0", view, x, "web01.ex")
+	}
+
+	// Wide characters take two cells
+	in = NewInput("日本語")
+
+	if view, x := in.View(5); view != "本語" || x != 4 {
+		t.Errorf("Input.View() = %q, %v, want %q, 4", view, x, "本語")
+	}
+}